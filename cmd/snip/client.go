@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Define a snippet type to hold a snippet as returned by the JSON API.
+type snippet struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	UserID  int       `json:"user_id"`
+	Private bool      `json:"private"`
+}
+
+// Define a newSnippet type to hold the body of a request to create a snippet.
+type newSnippet struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Expires int    `json:"expires"`
+	Private bool   `json:"private"`
+}
+
+// Define an apiError type to hold an error response from the API, so that the message and any problems with
+// individual fields can be shown to the user.
+type apiError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(e.Message)
+
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n  %s: %s", key, e.Fields[key])
+	}
+
+	return b.String()
+}
+
+// Define a client type which makes requests to a Snippetbox server's JSON API.
+type client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Function used to send a request to the API and decode the JSON response body into dst. Error responses are
+// returned as an *apiError.
+func (c *client) do(method, path string, body any, dst any) error {
+	var rd io.Reader
+
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, rd)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "snip/1.0")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rs, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rs.Body.Close()
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		var errorBody struct {
+			Error *apiError `json:"error"`
+		}
+
+		if err := json.NewDecoder(rs.Body).Decode(&errorBody); err != nil || errorBody.Error == nil {
+			return &apiError{Status: rs.StatusCode, Message: rs.Status}
+		}
+
+		return errorBody.Error
+	}
+
+	return json.NewDecoder(rs.Body).Decode(dst)
+}
+
+// Function used to create a snippet, returning the new snippet.
+func (c *client) create(input newSnippet) (*snippet, error) {
+	var data struct {
+		Snippet *snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodPost, "/api/v1/snippets", input, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Snippet, nil
+}
+
+// Function used to fetch the snippet with a specific ID.
+func (c *client) get(id int) (*snippet, error) {
+	var data struct {
+		Snippet *snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodGet, "/api/v1/snippets/"+strconv.Itoa(id), nil, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Snippet, nil
+}
+
+// Function used to fetch up to limit of the most recently created public snippets.
+func (c *client) latest(limit int) ([]*snippet, error) {
+	var data struct {
+		Snippets []*snippet `json:"snippets"`
+	}
+
+	qs := url.Values{}
+	qs.Set("sort", "-created")
+	qs.Set("page_size", strconv.Itoa(limit))
+
+	err := c.do(http.MethodGet, "/api/v1/snippets?"+qs.Encode(), nil, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Snippets, nil
+}
@@ -0,0 +1,267 @@
+// Command snip is a command-line client for a Snippetbox server's JSON API. It can create a snippet from standard
+// input, print the content of a snippet, and list the latest snippets:
+//
+//	echo "An old silent pond..." | snip create -title "Haiku"
+//	snip get 42
+//	snip list -n 5
+//
+// The server's URL and the personal API token used to authenticate are read from the SNIP_URL and SNIP_TOKEN
+// environment variables, or else from the config file (by default "snip/config" in the user's config directory),
+// which holds "url = ..." and "token = ..." lines.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The server used when none is configured, and the longest title the server accepts.
+const (
+	defaultURL     = "https://localhost:4000"
+	maxTitleLength = 100
+)
+
+// Define a config type to hold the settings which are read from the environment and the config file.
+type config struct {
+	URL   string
+	Token string
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "snip:", err)
+		os.Exit(1)
+	}
+}
+
+// Function used to run the command with the given arguments, so that it can be tested without a real terminal.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("snip", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: snip [flags] create|get|list [arguments]")
+		fs.PrintDefaults()
+	}
+
+	configPath := fs.String("config", defaultConfigPath(), "Path to the config file")
+	serverURL := fs.String("url", "", "Snippetbox server URL (overrides SNIP_URL and the config file)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *serverURL != "" {
+		cfg.URL = *serverURL
+	}
+
+	c := &client{
+		baseURL:    strings.TrimSuffix(cfg.URL, "/"),
+		token:      cfg.Token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+
+	command, args := fs.Arg(0), fs.Args()[1:]
+
+	switch command {
+	case "create":
+		return runCreate(c, args, stdin, stdout)
+	case "get":
+		return runGet(c, args, stdout)
+	case "list":
+		return runList(c, args, stdout)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// Create a snippet from standard input and print its URL. If no title is given, the first line of the snippet is
+// used.
+func runCreate(c *client, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+
+	title := fs.String("title", "", "Snippet title (defaults to the first line of the snippet)")
+	expires := fs.Int("expires", 365, "Number of days until the snippet expires (1, 7 or 365)")
+	private := fs.Bool("private", false, "Only show the snippet to its owner")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if c.token == "" {
+		return errors.New("creating a snippet needs an API token: set SNIP_TOKEN or add a token to the config file")
+	}
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+
+	if *title == "" {
+		*title = defaultTitle(string(content))
+	}
+
+	s, err := c.create(newSnippet{Title: *title, Content: string(content), Expires: *expires, Private: *private})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s/snippet/view/%d\n", c.baseURL, s.ID)
+	return nil
+}
+
+// Print the content of the snippet with the given ID, exactly as it was created.
+func runGet(c *client, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: snip get ID")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 1 {
+		return fmt.Errorf("invalid snippet ID %q", args[0])
+	}
+
+	s, err := c.get(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, s.Content)
+	return err
+}
+
+// Print a table of the latest public snippets.
+func runList(c *client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+
+	n := fs.Int("n", 10, "Number of snippets to list")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	snippets, err := c.latest(*n)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tTITLE")
+
+	for _, s := range snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.ID, s.Created.UTC().Format("2006-01-02 15:04"), s.Title)
+	}
+
+	return tw.Flush()
+}
+
+// Function used to make a title from the first non-blank line of a snippet, shortened to fit if necessary.
+func defaultTitle(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if runes := []rune(line); len(runes) > maxTitleLength {
+			line = string(runes[:maxTitleLength-3]) + "..."
+		}
+
+		return line
+	}
+
+	return "Untitled"
+}
+
+// Function used to return the default location of the config file, or an empty string if the user has no config
+// directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "snip", "config")
+}
+
+// Function used to read the settings from the config file and the environment. Environment variables take
+// precedence over the config file, and a missing config file is not an error.
+func loadConfig(path string) (config, error) {
+	cfg := config{URL: defaultURL}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cfg, err
+		}
+
+		if err == nil {
+			defer f.Close()
+
+			err = parseConfig(f, &cfg)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	if v := os.Getenv("SNIP_URL"); v != "" {
+		cfg.URL = v
+	}
+
+	if v := os.Getenv("SNIP_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+
+	return cfg, nil
+}
+
+// Function used to parse a config file made up of "key = value" lines. Blank lines and lines starting with # are
+// ignored.
+func parseConfig(r io.Reader, cfg *config) error {
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "url":
+			cfg.URL = value
+		case "token":
+			cfg.Token = value
+		default:
+			return fmt.Errorf("line %d: unknown setting %q", n, key)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+// Function used to start a fake Snippetbox API which accepts the token "secret".
+func newTestAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	created := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	mux.HandleFunc("POST /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"status":401,"message":"Unauthorized"}}`))
+			return
+		}
+
+		var input newSnippet
+		json.NewDecoder(r.Body).Decode(&input)
+
+		if input.Expires != 7 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error":{"status":422,"message":"Unprocessable Entity","fields":{"expires":"This field must equal 1, 7, or 365"}}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"snippet": snippet{ID: 2, Title: input.Title, Content: input.Content}})
+	})
+
+	mux.HandleFunc("GET /api/v1/snippets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"status":404,"message":"Not Found"}}`))
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"snippet": snippet{ID: 1, Title: "Haiku", Content: "An old silent pond...\n"}})
+	})
+
+	mux.HandleFunc("GET /api/v1/snippets", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("page_size"), "5")
+
+		json.NewEncoder(w).Encode(map[string]any{"snippets": []snippet{{ID: 1, Title: "Haiku", Created: created}}})
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestRun(t *testing.T) {
+	ts := newTestAPI(t)
+
+	// Configure the server URL in a config file, and the token in the environment.
+	configPath := filepath.Join(t.TempDir(), "config")
+
+	err := os.WriteFile(configPath, []byte("# Test server\nurl = "+ts.URL+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SNIP_URL", "")
+	t.Setenv("SNIP_TOKEN", "secret")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantOutput string
+		wantErr    string
+	}{
+		{
+			name:       "Create",
+			args:       []string{"create", "-expires", "7"},
+			stdin:      "\nAn old silent pond...\nA frog jumps into the pond,\n",
+			wantOutput: ts.URL + "/snippet/view/2\n",
+		},
+		{
+			name:    "Create invalid",
+			args:    []string{"create", "-expires", "30"},
+			stdin:   "An old silent pond...",
+			wantErr: "Unprocessable Entity\n  expires: This field must equal 1, 7, or 365",
+		},
+		{
+			name:       "Get",
+			args:       []string{"get", "1"},
+			wantOutput: "An old silent pond...\n",
+		},
+		{
+			name:    "Get missing",
+			args:    []string{"get", "2"},
+			wantErr: "Not Found",
+		},
+		{
+			name:    "Get invalid ID",
+			args:    []string{"get", "foo"},
+			wantErr: `invalid snippet ID "foo"`,
+		},
+		{
+			name:       "List",
+			args:       []string{"list", "-n", "5"},
+			wantOutput: "ID  CREATED           TITLE\n1   2024-03-17 10:15  Haiku\n",
+		},
+		{
+			name:    "Unknown command",
+			args:    []string{"edit"},
+			wantErr: `unknown command "edit"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			args := append([]string{"-config", configPath}, tt.args...)
+
+			err := run(args, strings.NewReader(tt.stdin), &stdout)
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				assert.Equal(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, stdout.String(), tt.wantOutput)
+		})
+	}
+}
+
+func TestDefaultTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "First line",
+			content: "  \n  An old silent pond...  \nA frog jumps into the pond,",
+			want:    "An old silent pond...",
+		},
+		{
+			name:    "Long line",
+			content: strings.Repeat("é", 150),
+			want:    strings.Repeat("é", 97) + "...",
+		},
+		{
+			name:    "Blank",
+			content: "\n \n",
+			want:    "Untitled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, defaultTitle(tt.content), tt.want)
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	var cfg config
+
+	err := parseConfig(strings.NewReader("url = https://snippetbox.example.com\n\ntoken=abc\n"), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, cfg, config{URL: "https://snippetbox.example.com", Token: "abc"})
+
+	err = parseConfig(strings.NewReader("colour = blue\n"), &cfg)
+	assert.Equal(t, err.Error(), `line 1: unknown setting "colour"`)
+}
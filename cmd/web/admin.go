@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+type blockedWordForm struct {
+	Word                string `form:"word"`
+	validator.Validator `form:"-"`
+}
+
+type maintenanceForm struct {
+	Enabled bool `form:"enabled"`
+}
+
+// Display the denylist of words which are not allowed in snippet titles, along with a form for adding words.
+func (app *application) adminBlockedWords(w http.ResponseWriter, r *http.Request) {
+	app.renderBlockedWords(w, r, http.StatusOK, blockedWordForm{})
+}
+
+func (app *application) adminBlockedWordsPost(w http.ResponseWriter, r *http.Request) {
+	var form blockedWordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Word), "word", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Word, 100), "word", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		app.renderBlockedWords(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	err = app.blockedWords.Insert(r.Context(), form.Word)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Word added to the denylist.")
+
+	http.Redirect(w, r, "/admin/blocked-words", http.StatusSeeOther)
+}
+
+func (app *application) adminBlockedWordsDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.blockedWords.Delete(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Word removed from the denylist.")
+
+	http.Redirect(w, r, "/admin/blocked-words", http.StatusSeeOther)
+}
+
+// Fetch the denylist and render it using the blockedwords.tmpl template, along with the given form.
+func (app *application) renderBlockedWords(w http.ResponseWriter, r *http.Request, status int, form blockedWordForm) {
+	blockedWords, err := app.blockedWords.All(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.BlockedWords = blockedWords
+	data.Form = form
+
+	app.render(w, r, status, "blockedwords.tmpl", data)
+}
+
+// Display the admin dashboard, which shows the numbers of snippets and users and links to the other admin pages.
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.snippets.Stats(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	userCount, err := app.users.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetStats = stats
+	data.UserCount = userCount
+
+	app.render(w, r, http.StatusOK, "admin.tmpl", data)
+}
+
+// Switch maintenance mode on or off (see maintenance.go), depending on the "enabled" form value. Only this instance
+// of the application is switched.
+func (app *application) adminMaintenancePost(w http.ResponseWriter, r *http.Request) {
+	var form maintenanceForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.maintenance.Store(form.Enabled)
+
+	app.logger.WarnContext(r.Context(), "maintenance mode changed", "enabled", form.Enabled,
+		"user_id", app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+
+	if form.Enabled {
+		app.flash(r, flashWarning, "Maintenance mode is on. Visitors will see the maintenance page.")
+	} else {
+		app.flash(r, flashInfo, "Maintenance mode is off.")
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// Display a page of users, optionally filtered by a search term matching their name or email address.
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	filters := app.readFilters(r, &v)
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	users, metadata, err := app.users.List(r.Context(), filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "adminusers.tmpl", data)
+}
+
+func (app *application) adminUserDeactivatePost(w http.ResponseWriter, r *http.Request) {
+	app.setUserActive(w, r, false)
+}
+
+func (app *application) adminUserActivatePost(w http.ResponseWriter, r *http.Request) {
+	app.setUserActive(w, r, true)
+}
+
+// Deactivate or reactivate the user with the ID given in the URL. Admins can't deactivate their own account, so
+// that there is always someone left who can undo a deactivation.
+func (app *application) setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	if id == app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.users.SetActive(r.Context(), id, active)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if active {
+		app.flash(r, flashSuccess, "User reactivated.")
+	} else {
+		app.flash(r, flashSuccess, "User deactivated.")
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// Display a page of every snippet (including private and expired snippets), optionally filtered by a search term
+// matching their title.
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	filters := app.readFilters(r, &v)
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.ListAll(r.Context(), filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "adminsnippets.tmpl", data)
+}
+
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	// Fetch the snippet before deleting it, so that its owner's webhooks can be sent its details. Expired snippets
+	// can't be fetched, but their owners have already been told about them with the expired event.
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var jobs []*models.Job
+
+	if snippet != nil {
+		jobs, err = app.newWebhookJobs(r.Context(), models.EventSnippetDeleted, snippet)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err = app.snippets.Delete(r.Context(), id, jobs...)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.fragments.Purge()
+	app.readOwnWrites(r)
+	app.flash(r, flashSuccess, "Snippet deleted.")
+
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// Display the configuration snapshot taken at startup, for debugging differences between environments.
+func (app *application) adminConfig(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Config = app.configEntries
+
+	app.render(w, r, http.StatusOK, "adminconfig.tmpl", data)
+}
+
+// Display a page of the messages sent through the contact form, newest first, optionally filtered by a search term
+// matching the sender's name or email address.
+func (app *application) adminContacts(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	filters := app.readFilters(r, &v)
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	messages, metadata, err := app.contacts.List(r.Context(), filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ContactMessages = messages
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "admincontacts.tmpl", data)
+}
+
+// Display the invite codes which have been created, along with a button for creating a new one.
+func (app *application) adminInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := app.invites.List(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Invites = invites
+
+	// A newly created invite code is only shown once, straight after it has been created.
+	data.NewInvite = app.sessionManager.PopString(r.Context(), "newInvite")
+
+	app.render(w, r, http.StatusOK, "admininvites.tmpl", data)
+}
+
+func (app *application) adminInvitesPost(w http.ResponseWriter, r *http.Request) {
+	code, err := app.invites.New(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), inviteLifetime)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "newInvite", code)
+
+	http.Redirect(w, r, "/admin/invites", http.StatusSeeOther)
+}
+
+func (app *application) adminInviteDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.invites.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.flash(r, flashSuccess, "The invite has been deleted.")
+
+	http.Redirect(w, r, "/admin/invites", http.StatusSeeOther)
+}
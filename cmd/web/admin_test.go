@@ -0,0 +1,301 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAdminBlockedWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Unauthenticated",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Regular user",
+			email:    "alice@example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			email:    "admin@example.com",
+			wantCode: http.StatusOK,
+			wantBody: "<td>spam</td>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, "/admin/blocked-words")
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				assert.StringContains(t, body, `<a href="/admin">Admin</a>`)
+			}
+		})
+	}
+}
+
+func TestAdminBlockedWordsPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin/blocked-words")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		word     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid word",
+			word:     "scam",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Blank word",
+			word:     "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("word", tt.word)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/admin/blocked-words", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminListings(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Dashboard",
+			urlPath:  "/admin",
+			wantCode: http.StatusOK,
+			wantBody: `<tr><th>Archived snippets</th><td>3</td></tr>`,
+		},
+		{
+			name:     "Users",
+			urlPath:  "/admin/users?q=alice",
+			wantCode: http.StatusOK,
+			wantBody: "alice@example.com",
+		},
+		{
+			name:     "Users with invalid page",
+			urlPath:  "/admin/users?page=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Snippets",
+			urlPath:  "/admin/snippets?page=1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Snippets with zero page",
+			urlPath:  "/admin/snippets?page=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Contact messages",
+			urlPath:  "/admin/contacts?q=bob",
+			wantCode: http.StatusOK,
+			wantBody: `<td class="contact-message">Hello! I love the site.</td>`,
+		},
+		{
+			name:     "Contact messages with invalid page",
+			urlPath:  "/admin/contacts?page=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Archive",
+			urlPath:  "/admin/archive",
+			wantCode: http.StatusOK,
+			wantBody: `<form action="/admin/archive/1/restore" method="POST">`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin/users")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	// Admins aren't offered the option of deactivating themselves.
+	assert.Equal(t, strings.Contains(body, "/admin/users/3/deactivate"), false)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Deactivate user",
+			urlPath:  "/admin/users/1/deactivate",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Reactivate user",
+			urlPath:  "/admin/users/1/activate",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Deactivate self",
+			urlPath:  "/admin/users/3/deactivate",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Deactivate non-existent user",
+			urlPath:  "/admin/users/99/deactivate",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Delete snippet",
+			urlPath:  "/admin/snippets/1/delete",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Delete non-existent snippet",
+			urlPath:  "/admin/snippets/2/delete",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Create invite",
+			urlPath:  "/admin/invites",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Delete invite",
+			urlPath:  "/admin/invites/1/delete",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Delete non-existent invite",
+			urlPath:  "/admin/invites/2/delete",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestAdminArchiveRestore(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin/archive")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		expires  string
+		wantCode int
+	}{
+		{
+			name:     "Valid",
+			urlPath:  "/admin/archive/1/restore",
+			expires:  "7",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Invalid expiry",
+			urlPath:  "/admin/archive/1/restore",
+			expires:  "30",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/admin/archive/2/restore",
+			expires:  "7",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+			form.Add("expires", tt.expires)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Define an envelope type which is used to wrap JSON responses in a top-level object, e.g. {"snippets": [...]}.
+type envelope map[string]any
+
+// Define the structs which represent users, snippets and pagination metadata in JSON API responses. These are kept
+// separate from the model types so that fields like the user's email address are never exposed by accident.
+type apiUser struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+type apiSnippet struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	UserID  int       `json:"user_id"`
+	Private bool      `json:"private"`
+}
+
+type apiMetadata struct {
+	CurrentPage  int `json:"current_page"`
+	PageSize     int `json:"page_size"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+type apiCursorMetadata struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func newAPIUser(u *models.User) apiUser {
+	return apiUser{
+		ID:      u.ID,
+		Name:    u.Name,
+		Created: u.Created,
+	}
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	return apiSnippet{
+		ID:      s.ID,
+		Title:   s.Title,
+		Content: s.Content,
+		Created: s.Created,
+		Expires: s.Expires,
+		UserID:  s.UserID,
+		Private: s.Private,
+	}
+}
+
+func newAPISnippets(snippets []*models.Snippet) []apiSnippet {
+	result := make([]apiSnippet, 0, len(snippets))
+
+	for _, s := range snippets {
+		result = append(result, newAPISnippet(s))
+	}
+
+	return result
+}
+
+func newAPIMetadata(m models.Metadata) apiMetadata {
+	return apiMetadata{
+		CurrentPage:  m.CurrentPage,
+		PageSize:     m.PageSize,
+		LastPage:     m.LastPage,
+		TotalRecords: m.TotalRecords,
+	}
+}
+
+// Function used to get the ID of the user making an API request, who may be authenticated by an API token or by a
+// session cookie. It returns 0 for anonymous requests.
+func (app *application) apiRequestUserID(r *http.Request) int {
+	if id := app.apiUserID(r); id != 0 {
+		return id
+	}
+
+	if app.isAuthenticated(r) {
+		return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	}
+
+	return 0
+}
+
+// Return a user's public details along with their public, unexpired snippets as JSON.
+func (app *application) apiUserSnippets(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	var v validator.Validator
+
+	limit := app.readLimit(r, &v)
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Private snippets are never included in the public API.
+	snippets, err := app.snippets.ByUser(r.Context(), user.ID, false, limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Allow clients and shared caches to reuse the response for a minute, so that dashboards polling the API
+	// don't hit the database on every request.
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=60")
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": newAPIUser(user), "snippets": newAPISnippets(snippets)}, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Return the public details of the user authenticated by the request's API token as JSON.
+func (app *application) apiMe(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(r.Context(), app.apiRequestUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": newAPIUser(user)}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAPIUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid ID",
+			urlPath:  "/api/v1/users/1/snippets",
+			wantCode: http.StatusOK,
+			wantBody: `"content": "An old silent pond..."`,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/api/v1/users/2/snippets",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/api/v1/users/foo/snippets",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, header.Get("Content-Type"), "application/json")
+				assert.Equal(t, header.Get("Cache-Control"), "public, max-age=60")
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
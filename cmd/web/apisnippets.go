@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The maximum size of the JSON body accepted when creating snippets through the API, and the maximum number of
+// snippets which can be created in one batch.
+const (
+	apiMaxBodyBytes     = 1 << 20
+	apiMaxBatchBodySize = 10 << 20
+	apiMaxBatchSize     = 100
+)
+
+// Define the body of a request to create a snippet through the API. The fields follow the same rules as the
+// HTML form for creating snippets.
+type apiSnippetInput struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Expires int    `json:"expires"`
+	Private bool   `json:"private"`
+}
+
+// Return a page of public, unexpired snippets as JSON, along with the pagination metadata. The snippets can be
+// sorted by creation time, expiry time or title, and filtered by author and creation time. Requests with a cursor
+// are handed over to apiSnippetListCursor().
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("cursor") {
+		app.apiSnippetListCursor(w, r)
+		return
+	}
+
+	var v validator.Validator
+
+	filters := app.readAPIFilters(r, &v, "-created", "created", "expires", "title", "-created", "-expires", "-title")
+	if !v.Valid() {
+		app.failedValidation(w, r, v.FieldErrors)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.ListPublic(r.Context(), filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": newAPISnippets(snippets), "metadata": newAPIMetadata(metadata)}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Return a page of public, unexpired snippets as JSON, newest first, using a cursor instead of a page number. This
+// stays fast however far through the snippets the page is, and pages don't skip or repeat snippets as new ones are
+// created, but the snippets can't be sorted or filtered and the total number isn't counted. An empty cursor gets the
+// first page, and each page's metadata has the cursor for the next page, unless it is the last.
+func (app *application) apiSnippetListCursor(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	qs := r.URL.Query()
+
+	after := readCursor(qs, "cursor", &v)
+	pageSize := readInt(qs, "page_size", app.config.defaultPageSize, &v)
+
+	v.CheckField(validator.Between(pageSize, 1, app.config.maxPageSize), "page_size", fmt.Sprintf("must be between 1 and %d", app.config.maxPageSize))
+
+	for _, key := range []string{"page", "sort", "author", "created_after"} {
+		v.CheckField(!qs.Has(key), key, "cannot be used with cursor")
+	}
+
+	if !v.Valid() {
+		app.failedValidation(w, r, v.FieldErrors)
+		return
+	}
+
+	snippets, err := app.snippets.Latest(r.Context(), after, pageSize+1)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	metadata := apiCursorMetadata{PageSize: pageSize}
+	snippets, metadata.NextCursor = nextCursor(snippets, pageSize)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": newAPISnippets(snippets), "metadata": metadata}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Function used to fetch the snippet with the ID given in the URL for an API request. Private snippets are treated
+// as though they don't exist for anyone but their owner. If there is no such snippet, an error response is sent and
+// false is returned, in which case the calling handler should return immediately.
+func (app *application) apiSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.Private && snippet.UserID != app.apiRequestUserID(r) {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Return a single snippet as JSON. Clients polling for a snippet can send the ETag or Last-Modified time of their
+// copy to get an HTTP 304 Not Modified response instead.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	// httprouter can't route /api/v1/snippets/stream separately from this route, so the stream is handed over here.
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") == "stream" {
+		app.apiSnippetStream(w, r)
+		return
+	}
+
+	snippet, ok := app.apiSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := envelope{"snippet": newAPISnippet(snippet)}
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Make clients revalidate their copy of the snippet on every request, since it may have been deleted.
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", "application/json")
+
+	if notModified(w, r, strongETag(js), snippetModified(snippet)) {
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Create a snippet owned by the authenticated user from a JSON request body, and return it with an HTTP 201 Created
+// response.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetInput
+
+	err := app.readJSON(w, r, &input, apiMaxBodyBytes)
+	if err != nil {
+		app.invalidJSON(w, r, err)
+		return
+	}
+
+	var v validator.Validator
+
+	err = app.checkSnippet(r.Context(), &v, input.Title, input.Content, input.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidation(w, r, v.FieldErrors)
+		return
+	}
+
+	userID := app.apiRequestUserID(r)
+
+	webhooks, err := app.webhooks.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	newJobs := newSnippetJobs(webhooks, models.EventSnippetCreated, &models.Snippet{
+		Title:   input.Title,
+		Content: input.Content,
+		Created: now,
+		Expires: now.AddDate(0, 0, input.Expires),
+		UserID:  userID,
+		Private: input.Private,
+	})
+
+	id, err := app.snippets.Insert(r.Context(), userID, input.Title, input.Content, input.Expires, input.Private, newJobs)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(models.WithPrimary(r.Context()), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.snippetCreated(snippet)
+	app.readOwnWrites(r)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": newAPISnippet(snippet)}, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Define the result of creating one of the snippets in a batch. Exactly one of the fields is set: the ID of the new
+// snippet, or the reasons it failed validation.
+type apiBatchResult struct {
+	ID     int               `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Create several snippets owned by the authenticated user from a JSON request body holding an array of snippets,
+// e.g. for a command-line tool syncing a directory of files. Each snippet is validated independently, and the
+// valid ones are inserted in a single transaction. The response holds one result per snippet, in the same order,
+// with either the ID of the new snippet or its validation errors.
+func (app *application) apiSnippetBatchCreate(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Snippets []apiSnippetInput `json:"snippets"`
+	}
+
+	err := app.readJSON(w, r, &input, apiMaxBatchBodySize)
+	if err != nil {
+		app.invalidJSON(w, r, err)
+		return
+	}
+
+	if len(input.Snippets) == 0 || len(input.Snippets) > apiMaxBatchSize {
+		message := fmt.Sprintf("The batch must contain between 1 and %d snippets", apiMaxBatchSize)
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, message, nil)
+		return
+	}
+
+	results := make([]apiBatchResult, len(input.Snippets))
+
+	userID := app.apiRequestUserID(r)
+
+	webhooks, err := app.webhooks.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// Validate each snippet, keeping track of which results the valid snippets' IDs belong to.
+	var (
+		valid    []models.NewSnippet
+		snippets []*models.Snippet
+		indexes  []int
+	)
+
+	for i, s := range input.Snippets {
+		var v validator.Validator
+
+		err = app.checkSnippet(r.Context(), &v, s.Title, s.Content, s.Expires)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !v.Valid() {
+			results[i].Errors = v.FieldErrors
+			continue
+		}
+
+		snippet := &models.Snippet{
+			Title:   s.Title,
+			Content: s.Content,
+			Created: now,
+			Expires: now.AddDate(0, 0, s.Expires),
+			UserID:  userID,
+			Private: s.Private,
+		}
+
+		valid = append(valid, models.NewSnippet{
+			Title:   s.Title,
+			Content: s.Content,
+			Expires: s.Expires,
+			Private: s.Private,
+			Jobs:    newSnippetJobs(webhooks, models.EventSnippetCreated, snippet),
+		})
+		snippets = append(snippets, snippet)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) > 0 {
+		ids, err := app.snippets.InsertBatch(r.Context(), userID, valid)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		for j, id := range ids {
+			results[indexes[j]].ID = id
+			snippets[j].ID = id
+
+			app.snippetCreated(snippets[j])
+		}
+
+		app.readOwnWrites(r)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results, "created": len(valid)}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Delete a snippet owned by the authenticated user, and send an HTTP 204 No Content response.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Respond as though other users' snippets don't exist, as we do for private snippets.
+	if snippet.UserID != app.apiRequestUserID(r) {
+		app.notFound(w, r)
+		return
+	}
+
+	jobs, err := app.newWebhookJobs(r.Context(), models.EventSnippetDeleted, snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), snippet.ID, jobs...)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.fragments.Purge()
+	app.readOwnWrites(r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/models/mocks"
+)
+
+// createdSnippetModel wraps the mock snippet model so that the snippet created by Insert() can be fetched.
+type createdSnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *createdSnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if id == 2 {
+		return &models.Snippet{ID: 2, Title: "New snippet", Content: "Content", Created: time.Now(), Expires: time.Now(), UserID: 1}, nil
+	}
+
+	return m.SnippetModel.Get(ctx, id)
+}
+
+// Send an API request to the test server, with a bearer token if one is given, and return the response status
+// code, header, and body.
+func (ts *testServer) apiRequest(t *testing.T, method, urlPath, token, contentType, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(b)
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"Defaults", "", http.StatusOK, `"title": "An old silent pond"`},
+		{"Metadata", "?page=1&page_size=5", http.StatusOK, `"page_size": 5`},
+		{"Sorted and filtered", "?sort=-expires&author=1&created_after=2024-03-17T10:15:00Z", http.StatusOK, `"total_records": 1`},
+		{"Other author", "?author=2", http.StatusOK, `"snippets": []`},
+		{"Invalid page", "?page=0", http.StatusUnprocessableEntity, `"page": "must be between 1 and 10000000"`},
+		{"Invalid page size", "?page_size=1000", http.StatusUnprocessableEntity, `"page_size": "must be between 1 and 100"`},
+		{"Invalid sort", "?sort=content", http.StatusUnprocessableEntity, `"sort": "invalid sort value"`},
+		{"Invalid author", "?author=alice", http.StatusUnprocessableEntity, `"author": "must be an integer value"`},
+		{"Invalid time", "?created_after=yesterday", http.StatusUnprocessableEntity, `"created_after":`},
+		{"First cursor page", "?cursor=&page_size=5", http.StatusOK, `"title": "An old silent pond"`},
+		{"Last cursor page", "?cursor=" + encodeCursor(1), http.StatusOK, `"snippets": []`},
+		{"Invalid cursor", "?cursor=foo", http.StatusUnprocessableEntity, `"cursor": "must be a cursor returned by a previous request"`},
+		{"Cursor with page", "?cursor=&page=2", http.StatusUnprocessableEntity, `"page": "cannot be used with cursor"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets"+tt.query, "", "", "")
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), "application/json")
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid ID", "/api/v1/snippets/1", http.StatusOK, `"content": "An old silent pond..."`},
+		{"Non-existent ID", "/api/v1/snippets/2", http.StatusNotFound, `"message": "Not Found"`},
+		{"Negative ID", "/api/v1/snippets/-1", http.StatusNotFound, `"error": {`},
+		{"String ID", "/api/v1/snippets/foo", http.StatusNotFound, `"error": {`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.apiRequest(t, http.MethodGet, tt.urlPath, "", "", "")
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetGetConditional(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets/1", "", "", "")
+	assert.Equal(t, code, http.StatusOK)
+
+	etag := header.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag header")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"Current copy", etag, http.StatusNotModified},
+		{"Stale copy", `"0123456789abcdef"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/snippets/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("ETag"), etag)
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &createdSnippetModel{}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const token = "ALICEAPITOKENABCDEFGHIJKLM"
+
+	tests := []struct {
+		name         string
+		token        string
+		contentType  string
+		body         string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:         "Valid snippet",
+			token:        token,
+			contentType:  "application/json",
+			body:         `{"title": "New snippet", "content": "Content", "expires": 7}`,
+			wantCode:     http.StatusCreated,
+			wantBody:     `"id": 2`,
+			wantLocation: "/api/v1/snippets/2",
+		},
+		{
+			name:        "Unauthenticated",
+			contentType: "application/json",
+			body:        `{"title": "New snippet", "content": "Content", "expires": 7}`,
+			wantCode:    http.StatusUnauthorized,
+		},
+		{
+			name:        "Invalid fields",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"title": "", "content": "Content", "expires": 2}`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    `"expires": "This field must equal 1, 7, or 365"`,
+		},
+		{
+			name:        "Unknown field",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"title": "New snippet", "author": "Bob"}`,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    `unknown field`,
+		},
+		{
+			name:        "Malformed JSON",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"title": `,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Form body",
+			token:       token,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "title=New+snippet&content=Content&expires=7",
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", tt.token, tt.contentType, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		token    string
+		wantCode int
+	}{
+		{"Own snippet", "/api/v1/snippets/1", "ALICEAPITOKENABCDEFGHIJKLM", http.StatusNoContent},
+		{"Non-existent snippet", "/api/v1/snippets/2", "ALICEAPITOKENABCDEFGHIJKLM", http.StatusNotFound},
+		{"Unauthenticated", "/api/v1/snippets/1", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.apiRequest(t, http.MethodDelete, tt.urlPath, tt.token, "", "")
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	// Logged in users can use the API with their session cookie instead of a token.
+	t.Run("Session", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, _ := ts.apiRequest(t, http.MethodDelete, "/api/v1/snippets/1", "", "", "")
+		assert.Equal(t, code, http.StatusNoContent)
+	})
+}
+
+func TestAPISnippetBatchCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const token = "ALICEAPITOKENABCDEFGHIJKLM"
+
+	valid := `{"title": "O snail", "content": "Climb Mount Fuji", "expires": 7}`
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        string
+		wantCode    int
+		wantBody    string
+	}{
+		{
+			name:        "All valid",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"snippets": [` + valid + `, ` + valid + `]}`,
+			wantCode:    http.StatusOK,
+			wantBody:    `"created": 2`,
+		},
+		{
+			name:        "Some invalid",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"snippets": [{"title": "", "content": "Climb Mount Fuji", "expires": 7}, ` + valid + `]}`,
+			wantCode:    http.StatusOK,
+			wantBody:    `"title": "This field cannot be blank"`,
+		},
+		{
+			name:        "Empty batch",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"snippets": []}`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    "between 1 and 100 snippets",
+		},
+		{
+			name:        "Too many snippets",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"snippets": [` + strings.Repeat(valid+`, `, apiMaxBatchSize) + valid + `]}`,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantBody:    "between 1 and 100 snippets",
+		},
+		{
+			name:        "Not an array",
+			token:       token,
+			contentType: "application/json",
+			body:        `{"snippets": ` + valid + `}`,
+			wantCode:    http.StatusBadRequest,
+			wantBody:    `The request body contains the wrong type for the \"snippets\" field`,
+		},
+		{
+			name:        "Form body",
+			token:       token,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "title=O+snail",
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "Unauthenticated",
+			contentType: "application/json",
+			body:        `{"snippets": [` + valid + `]}`,
+			wantCode:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets/batch", tt.token, tt.contentType, tt.body)
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+type apiTokenForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the authenticated user's API tokens, along with the form for creating a new one.
+func (app *application) accountAPITokens(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = apiTokenForm{}
+
+	// A newly created token is only shown once, straight after it has been created.
+	data.NewAPIToken = app.sessionManager.PopString(r.Context(), "newAPIToken")
+
+	app.renderAPITokens(w, r, http.StatusOK, data)
+}
+
+func (app *application) accountAPITokensPost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.renderAPITokens(w, r, http.StatusUnprocessableEntity, data)
+		return
+	}
+
+	token, err := app.apiTokens.New(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), form.Name)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Store the plaintext token in the session so that it can be shown to the user once after the redirect. Only
+	// the token's hash is kept in the database, so it can't be shown again later.
+	app.sessionManager.Put(r.Context(), "newAPIToken", token)
+
+	http.Redirect(w, r, "/account/api-tokens", http.StatusSeeOther)
+}
+
+func (app *application) accountAPITokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.apiTokens.Revoke(r.Context(), id, app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.flash(r, flashSuccess, "The API token has been revoked.")
+
+	http.Redirect(w, r, "/account/api-tokens", http.StatusSeeOther)
+}
+
+// Function used to render the API tokens page with the authenticated user's current tokens.
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, data *templateData) {
+	tokens, err := app.apiTokens.ForUser(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.APITokens = tokens
+	app.render(w, r, status, "apitokens.tmpl", data)
+}
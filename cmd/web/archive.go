@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The maximum number of snippets which are moved to the archive in each transaction, and the number of archived
+// snippets listed on the admin archive page.
+const (
+	archiveBatchSize = 500
+	archivePageSize  = 50
+)
+
+// runArchiver() periodically moves snippets which expired longer ago than the retention period to the archive
+// table, so that the snippets table only holds the snippets which can still be viewed and the recently expired
+// ones, until the context is cancelled. A retention period of 0 disables archiving. It is intended to be launched
+// in its own goroutine when the application starts.
+func (app *application) runArchiver(ctx context.Context) {
+	if app.config.archiveAfter <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(app.config.archiveInterval)
+	defer ticker.Stop()
+
+	for {
+		app.archiveExpiredSnippets(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveExpiredSnippets() archives batches of expired snippets until there are none left to archive, or the
+// context is cancelled. A batch which is in progress when the context is cancelled is finished rather than
+// abandoned part way.
+func (app *application) archiveExpiredSnippets(ctx context.Context) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := app.snippets.Archive(context.WithoutCancel(ctx), app.config.archiveAfter, archiveBatchSize)
+		if err != nil {
+			app.logger.Error(err.Error())
+			break
+		}
+
+		total += n
+
+		if n < archiveBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.Info("archived expired snippets", "count", total)
+	}
+}
+
+// Display the most recently archived snippets, each with a form for restoring it.
+func (app *application) adminArchive(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Archived(r.Context(), archivePageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "adminarchive.tmpl", data)
+}
+
+type archiveRestoreForm struct {
+	Expires int `form:"expires"`
+}
+
+// Move an archived snippet back to the snippets table, with a new expiry time chosen in the same way as when a
+// snippet is created.
+func (app *application) adminArchiveRestorePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	var form archiveRestoreForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedValue(form.Expires, 1, 7, 365) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.Restore(r.Context(), id, form.Expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.fragments.Purge()
+	app.readOwnWrites(r)
+
+	app.flash(r, flashSuccess, "Snippet restored.")
+
+	http.Redirect(w, r, "/admin/archive", http.StatusSeeOther)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// The number of hex digits of a static file's SHA-256 hash which are added to its fingerprinted name. Eight digits
+// are plenty to tell apart the versions of a file.
+const assetHashLen = 8
+
+// Define an assetManifest type to map the static files under ui/static to fingerprinted names which include a hash
+// of their contents (e.g. css/main.css to css/main.3f2a9c1b.css), and back again. Pages link to the fingerprinted
+// names, so browsers can cache the files forever and still fetch a new version as soon as it is deployed.
+type assetManifest struct {
+	fingerprinted map[string]string
+	original      map[string]string
+}
+
+// Function used to hash the files in the static directory of fsys, which holds the contents of the ui directory.
+// The embedded files can't change while the application is running, so they are hashed once at startup.
+func newAssetManifest(fsys fs.FS) (*assetManifest, error) {
+	m := &assetManifest{fingerprinted: map[string]string{}, original: map[string]string{}}
+
+	err := fs.WalkDir(fsys, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(b)
+		hash := hex.EncodeToString(sum[:])[:assetHashLen]
+
+		name := strings.TrimPrefix(p, "static/")
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+		m.fingerprinted[name] = fingerprinted
+		m.original[fingerprinted] = name
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Path returns the URL path of the fingerprinted version of a static file, e.g. {{assetPath "css/main.css"}} gives
+// "/static/css/main.3f2a9c1b.css". Files which aren't in the manifest are linked to under their own names.
+func (m *assetManifest) Path(name string) string {
+	if fingerprinted, ok := m.fingerprinted[name]; ok {
+		return "/static/" + fingerprinted
+	}
+
+	return "/static/" + name
+}
+
+// Function used to wrap the file server for the static files, so that it serves the fingerprinted names too. Since
+// the content at a fingerprinted name never changes, browsers are told to cache it for a year without checking for
+// a new version. Requests for the files' own names are served as before, for anything which still links to them.
+func (app *application) staticFiles(fileServer http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := app.assets.original[strings.TrimPrefix(r.URL.Path, "/static/")]
+		if !ok {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+		// Serve the file under its own name, without changing the request which other middleware sees.
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/static/" + name
+		r2.URL.RawPath = ""
+
+		fileServer.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAssetManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"static/css/main.css": {Data: []byte("body {}")},
+		"static/LICENSE":      {Data: []byte("MIT")},
+		"html/base.tmpl":      {Data: []byte("{{define \"base\"}}{{end}}")},
+	}
+
+	assets, err := newAssetManifest(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"With extension", "css/main.css", "/static/css/main.62368a1a.css"},
+		{"Without extension", "LICENSE", "/static/LICENSE.e5dcffe8"},
+		{"Unknown", "js/missing.js", "/static/js/missing.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, assets.Path(tt.file), tt.want)
+		})
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	mainCSS := app.assets.Path("css/main.css")
+
+	// Pages link to the fingerprinted names.
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<link rel='stylesheet' href='"+mainCSS+"'>")
+
+	tests := []struct {
+		name             string
+		urlPath          string
+		wantCode         int
+		wantCacheControl string
+	}{
+		{"Fingerprinted", mainCSS, http.StatusOK, "public, max-age=31536000, immutable"},
+		{"Own name", "/static/css/main.css", http.StatusOK, ""},
+		{"Stale fingerprint", "/static/css/main.00000000.css", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Cache-Control"), tt.wantCacheControl)
+
+			if code == http.StatusOK {
+				assert.StringContains(t, body, "box-sizing: border-box;")
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/image/draw"
+
+	// Register the decoders for the image formats which can be uploaded as avatars.
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// The sizes (in pixels) at which avatars are stored and served. Every avatar is square. The largest size is the
+// default.
+var avatarSizes = []int{64, 256}
+
+const (
+	// The maximum size of an avatar upload request, in bytes.
+	avatarMaxBytes = 5 << 20
+
+	// The minimum and maximum width and height of an uploaded image. The maximum stops images which decompress to
+	// an enormous size from using up the server's memory.
+	avatarMinDimension = 64
+	avatarMaxDimension = 4096
+)
+
+// The errors returned by processAvatar when an uploaded image is not acceptable.
+var (
+	errAvatarFormat     = errors.New("avatar: unsupported image format")
+	errAvatarDimensions = errors.New("avatar: unsupported image dimensions")
+)
+
+// Function used to validate an uploaded avatar image and resize it to each of the avatar sizes, returning the
+// resized images encoded as PNGs.
+func processAvatar(data []byte) (map[int][]byte, error) {
+	// Check the content type from the file's contents, rather than trusting the file name or the Content-Type
+	// sent by the client.
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, errAvatarFormat
+	}
+
+	// Check the image's dimensions before decoding it, since decoding allocates memory for every pixel.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errAvatarFormat
+	}
+
+	if config.Width < avatarMinDimension || config.Height < avatarMinDimension ||
+		config.Width > avatarMaxDimension || config.Height > avatarMaxDimension {
+		return nil, errAvatarDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errAvatarFormat
+	}
+
+	images := make(map[int][]byte, len(avatarSizes))
+
+	for _, size := range avatarSizes {
+		var buf bytes.Buffer
+
+		err = png.Encode(&buf, resizeAvatar(img, size))
+		if err != nil {
+			return nil, err
+		}
+
+		images[size] = buf.Bytes()
+	}
+
+	return images, nil
+}
+
+// Function used to crop an image to a centred square and scale it to size x size pixels.
+func resizeAvatar(img image.Image, size int) image.Image {
+	b := img.Bounds()
+
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, image.Rect(x, y, x+side, y+side), draw.Over, nil)
+
+	return dst
+}
+
+func (app *application) accountAvatarPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			app.renderAvatarError(w, r, userID, "Please choose an image to upload")
+		} else {
+			app.clientError(w, r, http.StatusBadRequest)
+		}
+		return
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	images, err := processAvatar(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, errAvatarFormat):
+			app.renderAvatarError(w, r, userID, "The image must be a JPEG, PNG or GIF file")
+		case errors.Is(err, errAvatarDimensions):
+			app.renderAvatarError(w, r, userID, fmt.Sprintf("The image must be between %d and %d pixels wide and high",
+				avatarMinDimension, avatarMaxDimension))
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.avatars.Set(r.Context(), userID, images)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your avatar has been updated!")
+
+	http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+}
+
+func (app *application) accountAvatarDeletePost(w http.ResponseWriter, r *http.Request) {
+	err := app.avatars.Delete(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your avatar has been removed.")
+
+	http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+}
+
+// Function used to redisplay the account settings form with an error message about an avatar upload.
+func (app *application) renderAvatarError(w http.ResponseWriter, r *http.Request, userID int, message string) {
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form := accountSettingsForm{
+		Name:  user.Name,
+		Email: user.Email,
+	}
+	form.AddFieldError("avatar", message)
+
+	app.renderSettings(w, r, http.StatusUnprocessableEntity, form)
+}
+
+// Serve the avatar of the user with the ID given in the URL, at the size given by the ?size= query string
+// parameter. Links to avatars include the time the avatar was last changed (see avatarURL), so responses can be
+// cached indefinitely; requests without it are only cached briefly, and can be revalidated.
+func (app *application) avatar(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	size := avatarSizes[len(avatarSizes)-1]
+
+	if s := r.URL.Query().Get("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil {
+			app.notFound(w, r)
+			return
+		}
+	}
+
+	avatar, err := app.avatars.Get(r.Context(), id, size)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if r.URL.Query().Has("v") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d-%d-%d"`, avatar.UserID, avatar.Size, avatar.Updated.Unix()))
+
+	// ServeContent() sets the Content-Type and Last-Modified headers, and responds to conditional requests with an
+	// HTTP 304 Not Modified response.
+	http.ServeContent(w, r, "avatar.png", avatar.Updated, bytes.NewReader(avatar.Image))
+}
+
+// Function used to build the URL of a user's avatar at the given size, which changes whenever the avatar does.
+func avatarURL(userID, size int, updated int64) string {
+	return fmt.Sprintf("/avatar/%d?size=%d&v=%d", userID, size, updated)
+}
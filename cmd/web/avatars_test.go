@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+// Function used to create a PNG image with the given dimensions.
+func newTestImage(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"Square", newTestImage(t, 300, 300), nil},
+		{"Landscape", newTestImage(t, 640, 480), nil},
+		{"Too small", newTestImage(t, 32, 32), errAvatarDimensions},
+		{"Too large", newTestImage(t, 5000, 100), errAvatarDimensions},
+		{"Not an image", []byte("<html><body>Hello</body></html>"), errAvatarFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := processAvatar(tt.data)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			for _, size := range avatarSizes {
+				img, err := png.Decode(bytes.NewReader(images[size]))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, img.Bounds(), image.Rect(0, 0, size, size))
+			}
+		})
+	}
+}
+
+func TestAccountAvatarPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/settings")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid image",
+			file:     newTestImage(t, 100, 100),
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Invalid image",
+			file:     []byte("This is not an image"),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "The image must be a JPEG, PNG or GIF file",
+		},
+		{
+			name:     "No file",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Please choose an image to upload",
+		},
+		{
+			name:     "Too large",
+			file:     make([]byte, avatarMaxBytes+1),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			mw := multipart.NewWriter(&buf)
+			mw.WriteField("csrf_token", validCSRFToken)
+
+			if tt.file != nil {
+				fw, err := mw.CreateFormFile("avatar", "avatar.png")
+				if err != nil {
+					t.Fatal(err)
+				}
+				fw.Write(tt.file)
+			}
+
+			mw.Close()
+
+			rs, err := ts.Client().Post(ts.URL+"/account/avatar", mw.FormDataContentType(), &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, string(body), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAvatar(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name             string
+		urlPath          string
+		wantCode         int
+		wantCacheControl string
+	}{
+		{"Default size", "/avatar/1", http.StatusOK, "public, max-age=300"},
+		{"Versioned", "/avatar/1?size=64&v=1710670500", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"No avatar", "/avatar/2", http.StatusNotFound, ""},
+		{"String ID", "/avatar/foo", http.StatusNotFound, ""},
+		{"String size", "/avatar/1?size=foo", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCacheControl != "" {
+				assert.Equal(t, header.Get("Cache-Control"), tt.wantCacheControl)
+				assert.Equal(t, header.Get("Content-Type"), "image/png")
+			}
+		})
+	}
+
+	t.Run("Not modified", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/avatar/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("If-None-Match", `"1-256-1710670500"`)
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Define a branding type to hold the custom logo and footer which self-hosters can give in the -site-logo and
+// -site-footer settings. They are read once at startup, so the application has to be restarted to change them.
+type branding struct {
+	logo        []byte
+	logoType    string
+	logoVersion string
+	loaded      time.Time
+	footer      template.HTML
+}
+
+// Function used to read the custom logo and footer from the files named in the configuration. The zero value is
+// returned for whichever isn't set, in which case the default logo is shown and nothing is added to the footer.
+func loadBranding(cfg *config) (branding, error) {
+	b := branding{loaded: time.Now()}
+
+	if cfg.siteLogo != "" {
+		logo, err := os.ReadFile(cfg.siteLogo)
+		if err != nil {
+			return branding{}, fmt.Errorf("site-logo: %w", err)
+		}
+
+		// DetectContentType() doesn't recognise SVG files, which are the most likely format for a logo, so they are
+		// recognised by their extension instead.
+		logoType := http.DetectContentType(logo)
+		if strings.EqualFold(filepath.Ext(cfg.siteLogo), ".svg") {
+			logoType = "image/svg+xml"
+		}
+
+		if !strings.HasPrefix(logoType, "image/") {
+			return branding{}, fmt.Errorf("site-logo: %s is not an image (%s)", cfg.siteLogo, logoType)
+		}
+
+		sum := sha256.Sum256(logo)
+
+		b.logo = logo
+		b.logoType = logoType
+		b.logoVersion = hex.EncodeToString(sum[:4])
+	}
+
+	if cfg.siteFooter != "" {
+		footer, err := os.ReadFile(cfg.siteFooter)
+		if err != nil {
+			return branding{}, fmt.Errorf("site-footer: %w", err)
+		}
+
+		// The footer is trusted HTML from the operator, so it isn't escaped.
+		b.footer = template.HTML(strings.TrimSpace(string(footer)))
+	}
+
+	return b, nil
+}
+
+// Function used to return the URL of the custom logo, which changes whenever the logo does, or an empty string if
+// the default logo is used.
+func (b branding) logoURL() string {
+	if b.logo == nil {
+		return ""
+	}
+
+	return "/branding/logo?v=" + b.logoVersion
+}
+
+// Serve the custom logo. The default logo is a static file, so this responds with a 404 if there is no custom one.
+// Like avatars, the logo can be cached forever when it is requested by its versioned URL.
+func (app *application) brandingLogo(w http.ResponseWriter, r *http.Request) {
+	if app.branding.logo == nil {
+		app.notFound(w, r)
+		return
+	}
+
+	if r.URL.Query().Get("v") == app.branding.logoVersion {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+
+	w.Header().Set("Content-Type", app.branding.logoType)
+	w.Header().Set("ETag", `"`+app.branding.logoVersion+`"`)
+
+	http.ServeContent(w, r, "", app.branding.loaded, bytes.NewReader(app.branding.logo))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestLoadBranding(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		cfg          config
+		wantLogoType string
+		wantFooter   string
+		wantErr      bool
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name:         "PNG logo",
+			cfg:          config{siteLogo: writeFile("logo.png", logo.Bytes())},
+			wantLogoType: "image/png",
+		},
+		{
+			name:         "SVG logo",
+			cfg:          config{siteLogo: writeFile("logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))},
+			wantLogoType: "image/svg+xml",
+		},
+		{
+			name:    "Logo which isn't an image",
+			cfg:     config{siteLogo: writeFile("logo.txt", []byte("Not an image"))},
+			wantErr: true,
+		},
+		{
+			name:    "Missing logo",
+			cfg:     config{siteLogo: filepath.Join(dir, "missing.png")},
+			wantErr: true,
+		},
+		{
+			name:       "Footer",
+			cfg:        config{siteFooter: writeFile("footer.html", []byte("<a href=\"/imprint\">Imprint</a>\n"))},
+			wantFooter: `<a href="/imprint">Imprint</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := loadBranding(&tt.cfg)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, b.logoType, tt.wantLogoType)
+			assert.Equal(t, string(b.footer), tt.wantFooter)
+			assert.Equal(t, b.logoURL() != "", tt.wantLogoType != "")
+		})
+	}
+}
+
+func TestBranding(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.siteName = "Pastebin & Co"
+	app.config.siteTagline = "Share your snippets"
+	app.branding = branding{
+		logo:        []byte("<svg></svg>"),
+		logoType:    "image/svg+xml",
+		logoVersion: "0123abcd",
+		footer:      `<a href="/imprint">Imprint</a>`,
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Pages", func(t *testing.T) {
+		code, _, body := ts.get(t, "/")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<title>Home - Pastebin &amp; Co</title>")
+		assert.StringContains(t, body, "<h1><a href='/' class='custom-logo'><img src='/branding/logo?v=0123abcd' alt=''>Pastebin &amp; Co</a></h1>")
+		assert.StringContains(t, body, "<p class='tagline'>Share your snippets</p>")
+		assert.StringContains(t, body, `<div class='custom-footer'><a href="/imprint">Imprint</a></div>`)
+	})
+
+	t.Run("Logo", func(t *testing.T) {
+		code, header, body := ts.get(t, "/branding/logo?v=0123abcd")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Type"), "image/svg+xml")
+		assert.Equal(t, header.Get("Cache-Control"), "public, max-age=31536000, immutable")
+		assert.Equal(t, body, "<svg></svg>")
+	})
+
+	t.Run("No custom logo", func(t *testing.T) {
+		app.branding.logo = nil
+
+		code, _, _ := ts.get(t, "/branding/logo")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
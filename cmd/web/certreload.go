@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How often the TLS certificate and key files are checked for changes.
+const certCheckInterval = time.Minute
+
+// Define a certReloader type which holds the server's TLS certificate and reloads it from the certificate and key
+// files when they change, so that renewed certificates are picked up without restarting the server. It is used as
+// the GetCertificate function of the server's tls.Config, so new TLS handshakes use the latest certificate while
+// existing connections carry on undisturbed.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// Function used to create a new certReloader, loading the certificate straight away so that a problem with the
+// files stops the server from starting.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	err := c.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// Function used to load the certificate from the files. If they can't be loaded (e.g. because only one of them has
+// been replaced so far), the current certificate is kept.
+func (c *certReloader) reload() error {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Function used to report whether either of the files has been modified since the certificate was last loaded.
+func (c *certReloader) changed() bool {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return !modTime.Equal(c.modTime)
+}
+
+// Function used to return the latest modification time of the certificate and key files.
+func (c *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// Function used to reload the certificate whenever the files change, or the process receives a SIGHUP signal (e.g.
+// from a certificate renewal hook), until ctx is cancelled.
+func (app *application) watchCertificate(ctx context.Context, c *certReloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+		case <-hup:
+		}
+
+		err := c.reload()
+		if err != nil {
+			app.logger.Error("TLS certificate reload failed, keeping the current certificate", "error", err)
+			continue
+		}
+
+		app.logger.Info("reloaded TLS certificate", "cert", c.certFile)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+
+	first := time.Now().Add(time.Hour).Truncate(time.Second)
+	path := writeTestCertificate(t, dir, "server", first)
+
+	c, err := newCertReloader(path+".pem", path+".key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := func() time.Time {
+		cert, err := c.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return leaf.NotAfter
+	}
+
+	assert.Equal(t, notAfter().Equal(first), true)
+	assert.Equal(t, c.changed(), false)
+
+	// Renew the certificate, making sure that the files' modification time moves on.
+	second := first.Add(24 * time.Hour)
+	writeTestCertificate(t, dir, "server", second)
+
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{path + ".pem", path + ".key"} {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assert.Equal(t, c.changed(), true)
+	assert.Equal(t, c.reload(), nil)
+	assert.Equal(t, notAfter().Equal(second), true)
+	assert.Equal(t, c.changed(), false)
+
+	// A broken certificate file is reported, and the current certificate is kept.
+	err = os.WriteFile(path+".pem", []byte("not a certificate"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, c.reload() != nil, true)
+	assert.Equal(t, notAfter().Equal(second), true)
+}
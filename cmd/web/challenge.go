@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/cache"
+)
+
+// The challenges which anonymous forms can require to be solved before they are accepted, to stop bots (see the
+// -captcha-provider flag). hCaptcha and Turnstile are CAPTCHA services whose answers are verified with the service,
+// and the proof-of-work challenge is self-hosted: the browser has to find a hash with enough leading zero bits,
+// which takes a second or two for a visitor but makes sending many forms expensive.
+const (
+	challengeNone      = "none"
+	challengeHCaptcha  = "hcaptcha"
+	challengeTurnstile = "turnstile"
+	challengePoW       = "pow"
+)
+
+// How long a proof-of-work token is valid for after the form is shown, and the most spent tokens which are
+// remembered so that they can't be used again.
+const (
+	powTokenLifetime = 30 * time.Minute
+	powSpentSize     = 10000
+)
+
+// How long the CAPTCHA services are given to verify an answer.
+const captchaVerifyTimeout = 10 * time.Second
+
+// Define a captchaService type to hold the details of one of the CAPTCHA services: the script which renders its
+// widget, the URL which answers are verified at, the class of the element which the widget is rendered into, the
+// form field which the widget posts its answer in, and the sources which the page's CSP has to allow for it.
+type captchaService struct {
+	scriptURL     string
+	verifyURL     string
+	widgetClass   string
+	responseField string
+	cspSources    string
+}
+
+var captchaServices = map[string]captchaService{
+	challengeHCaptcha: {
+		scriptURL:     "https://js.hcaptcha.com/1/api.js",
+		verifyURL:     "https://api.hcaptcha.com/siteverify",
+		widgetClass:   "h-captcha",
+		responseField: "h-captcha-response",
+		cspSources:    "https://hcaptcha.com https://*.hcaptcha.com",
+	},
+	challengeTurnstile: {
+		scriptURL:     "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		verifyURL:     "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		widgetClass:   "cf-turnstile",
+		responseField: "cf-turnstile-response",
+		cspSources:    "https://challenges.cloudflare.com",
+	},
+}
+
+// Define a challenge type to hold the configured challenge. For the CAPTCHA services, the secret is the one which
+// answers are verified with, and for the proof-of-work challenge it is the key which tokens are signed with.
+type challenge struct {
+	provider   string
+	siteKey    string
+	secret     string
+	difficulty int
+	service    captchaService
+	client     *http.Client
+
+	// The proof-of-work tokens which have been used, until they expire.
+	mu    sync.Mutex
+	spent *cache.LRU[string, struct{}]
+}
+
+// Function used to return the challenge described by the configuration, or nil if forms don't need one.
+func newChallenge(cfg *config) *challenge {
+	if cfg.captchaProvider == "" || cfg.captchaProvider == challengeNone {
+		return nil
+	}
+
+	return &challenge{
+		provider:   cfg.captchaProvider,
+		siteKey:    cfg.captchaSiteKey,
+		secret:     cfg.captchaSecret,
+		difficulty: cfg.powDifficulty,
+		service:    captchaServices[cfg.captchaProvider],
+		client:     &http.Client{Timeout: captchaVerifyTimeout},
+		spent:      cache.New[string, struct{}](powSpentSize, powTokenLifetime),
+	}
+}
+
+// Define a challengeWidget type to hold what the "challenge" partial (see ui/html/partials/challenge.tmpl) needs to
+// show the challenge on a form, along with the error if the last answer was rejected.
+type challengeWidget struct {
+	Provider    string
+	SiteKey     string
+	ScriptURL   string
+	WidgetClass string
+	Token       string
+	Difficulty  int
+	Error       string
+}
+
+// Function used to add the challenge to the data of a page with an anonymous form, and allow the CAPTCHA service's
+// widget in the page's CSP. It does nothing if forms don't need a challenge.
+func (app *application) addChallenge(w http.ResponseWriter, data *templateData, errorMessage string) {
+	c := app.challenge
+	if c == nil {
+		return
+	}
+
+	widget := &challengeWidget{Provider: c.provider, Error: errorMessage}
+
+	if c.provider == challengePoW {
+		widget.Token = c.newPoWToken(time.Now())
+		widget.Difficulty = c.difficulty
+	} else {
+		widget.SiteKey = c.siteKey
+		widget.ScriptURL = c.service.scriptURL
+		widget.WidgetClass = c.service.widgetClass
+
+		sources := c.service.cspSources
+		w.Header().Set("Content-Security-Policy", fmt.Sprintf("default-src 'self'; script-src 'self' %[1]s; frame-src %[1]s; "+
+			"connect-src 'self' %[1]s; style-src 'self' fonts.googleapis.com %[1]s; font-src fonts.gstatic.com", sources))
+	}
+
+	data.Challenge = widget
+}
+
+// Function used to check the answer to the challenge in a form which has been parsed. It returns true if the
+// challenge was passed or forms don't need one. If the answer can't be verified, e.g. because the CAPTCHA service
+// is down, the form is rejected and the error is logged.
+func (app *application) verifyChallenge(r *http.Request) bool {
+	c := app.challenge
+	if c == nil {
+		return true
+	}
+
+	if c.provider == challengePoW {
+		return c.verifyPoW(r.PostForm.Get("pow-token"), r.PostForm.Get("pow-nonce"), time.Now())
+	}
+
+	ok, err := c.verifyCaptcha(r.Context(), r.PostForm.Get(c.service.responseField), clientIP(r))
+	if err != nil {
+		app.logger.WarnContext(r.Context(), "could not verify CAPTCHA", "provider", c.provider, "error", err)
+		return false
+	}
+
+	return ok
+}
+
+// Function used to verify an answer to a CAPTCHA with the service. An error is returned if the service couldn't be
+// asked, rather than if the answer was wrong.
+func (c *challenge) verifyCaptcha(ctx context.Context, response, remoteIP string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+
+	form := url.Values{
+		"secret":   {c.secret},
+		"response": {response},
+		"remoteip": {remoteIP},
+		"sitekey":  {c.siteKey},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.service.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("verification failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return false, err
+	}
+
+	return result.Success, nil
+}
+
+// Function used to create a proof-of-work token, which is valid until powTokenLifetime after now. Tokens are
+// signed, so they don't have to be stored until they are used, and include the difficulty which they were issued
+// with, so that changing it doesn't invalidate the tokens of forms which are already open.
+func (c *challenge) newPoWToken(now time.Time) string {
+	random := make([]byte, 12)
+	rand.Read(random)
+
+	payload := fmt.Sprintf("%d.%d.%s", now.Add(powTokenLifetime).Unix(), c.difficulty, hex.EncodeToString(random))
+
+	return payload + "." + c.sign(payload)
+}
+
+// Function used to return the signature of a proof-of-work token's payload.
+func (c *challenge) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function used to check a solution to a proof-of-work challenge: the token has to have been signed by us and not
+// have expired or been used before, and the SHA-256 hash of the token and the nonce, separated by a colon, has to
+// start with at least as many zero bits as the token's difficulty.
+func (c *challenge) verifyPoW(token, nonce string, now time.Time) bool {
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || nonce == "" || !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return false
+	}
+
+	fields := strings.Split(payload, ".")
+	if len(fields) != 3 {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+
+	difficulty, err := strconv.Atoi(fields[1])
+	if err != nil || leadingZeroBits(sha256.Sum256([]byte(token+":"+nonce))) < difficulty {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, used := c.spent.Get(token); used {
+		return false
+	}
+
+	c.spent.Set(token, struct{}{}, time.Unix(expires, 0))
+
+	return true
+}
+
+// Function used to count the zero bits at the start of a hash.
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+
+	return n
+}
+
+// Function used to split s around the last instance of sep, like strings.Cut() does around the first.
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+// Function used to solve a proof-of-work challenge, as challenge.js does in the browser.
+func solvePoW(t *testing.T, token string, difficulty int) string {
+	t.Helper()
+
+	for nonce := 0; nonce < 1<<24; nonce++ {
+		if leadingZeroBits(sha256.Sum256([]byte(token+":"+strconv.Itoa(nonce)))) >= difficulty {
+			return strconv.Itoa(nonce)
+		}
+	}
+
+	t.Fatal("no nonce found")
+	return ""
+}
+
+func TestVerifyPoW(t *testing.T) {
+	c := newChallenge(&config{captchaProvider: challengePoW, captchaSecret: "secret", powDifficulty: 8})
+	now := time.Now()
+
+	token := c.newPoWToken(now)
+	nonce := solvePoW(t, token, 8)
+
+	wrongNonce := nonce
+	for wrongNonce == nonce || leadingZeroBits(sha256.Sum256([]byte(token+":"+wrongNonce))) >= 8 {
+		n, _ := strconv.Atoi(wrongNonce)
+		wrongNonce = strconv.Itoa(n + 1)
+	}
+
+	other := newChallenge(&config{captchaProvider: challengePoW, captchaSecret: "other", powDifficulty: 8})
+	forged := other.newPoWToken(now)
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		now   time.Time
+		want  bool
+	}{
+		{"Valid", token, nonce, now, true},
+		{"Reused", token, nonce, now, false},
+		{"Wrong nonce", c.newPoWToken(now), wrongNonce, now, false},
+		{"Missing nonce", c.newPoWToken(now), "", now, false},
+		{"Signed with another key", forged, solvePoW(t, forged, 8), now, false},
+		{"Tampered", token + "0", nonce, now, false},
+		{"Malformed", "not-a-token", "1", now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, c.verifyPoW(tt.token, tt.nonce, tt.now), tt.want)
+		})
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		token := c.newPoWToken(now)
+		nonce := solvePoW(t, token, 8)
+
+		assert.Equal(t, c.verifyPoW(token, nonce, now.Add(powTokenLifetime+time.Minute)), false)
+	})
+}
+
+func TestVerifyCaptcha(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch {
+		case r.PostForm.Get("response") == "down":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.PostForm.Get("secret") == "secret" && r.PostForm.Get("response") == "passed":
+			fmt.Fprint(w, `{"success": true}`)
+		default:
+			fmt.Fprint(w, `{"success": false, "error-codes": ["invalid-input-response"]}`)
+		}
+	}))
+	defer service.Close()
+
+	c := newChallenge(&config{captchaProvider: challengeTurnstile, captchaSiteKey: "site-key", captchaSecret: "secret"})
+	c.service.verifyURL = service.URL
+
+	tests := []struct {
+		name     string
+		response string
+		want     bool
+		wantErr  bool
+	}{
+		{"Passed", "passed", true, false},
+		{"Failed", "failed", false, false},
+		{"Missing", "", false, false},
+		{"Service down", "down", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := c.verifyCaptcha(context.Background(), tt.response, "192.0.2.1")
+
+			assert.Equal(t, ok, tt.want)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestUserSignupChallenge(t *testing.T) {
+	app := newTestApplication(t)
+	app.challenge = newChallenge(&config{captchaProvider: challengePoW, captchaSecret: "secret", powDifficulty: 8})
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tokenRX := regexp.MustCompile(`name="pow-token" value="([^"]+)"`)
+
+	_, _, body := ts.get(t, "/user/signup")
+	assert.StringContains(t, body, `data-difficulty="8"`)
+
+	match := tokenRX.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("no proof-of-work token in the signup form")
+	}
+
+	form := url.Values{
+		"name":       {"Bob"},
+		"email":      {"bob@example.com"},
+		"password":   {"validPa$$word"},
+		"csrf_token": {extractCSRFToken(t, body)},
+	}
+
+	t.Run("Unsolved", func(t *testing.T) {
+		code, _, body := ts.postForm(t, "/user/signup", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Please complete the check that you are not a robot")
+		// The form is shown again with a new token.
+		assert.Equal(t, tokenRX.MatchString(body), true)
+	})
+
+	t.Run("Solved", func(t *testing.T) {
+		form.Set("pow-token", match[1])
+		form.Set("pow-nonce", solvePoW(t, match[1], 8))
+
+		code, _, _ := ts.postForm(t, "/user/signup", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+
+	t.Run("CAPTCHA widget", func(t *testing.T) {
+		app.challenge = newChallenge(&config{captchaProvider: challengeHCaptcha, captchaSiteKey: "site-key", captchaSecret: "secret"})
+
+		_, header, body := ts.get(t, "/user/signup")
+
+		assert.StringContains(t, body, `<div class="h-captcha" data-sitekey="site-key"></div>`)
+		assert.StringContains(t, header.Get("Content-Security-Policy"), "script-src 'self' https://hcaptcha.com")
+	})
+}
+
+func TestContactChallenge(t *testing.T) {
+	app := newTestApplication(t)
+	app.challenge = newChallenge(&config{captchaProvider: challengePoW, captchaSecret: "secret", powDifficulty: 8})
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tokenRX := regexp.MustCompile(`name="pow-token" value="([^"]+)"`)
+
+	_, _, body := ts.get(t, "/contact")
+
+	match := tokenRX.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("no proof-of-work token in the contact form")
+	}
+
+	form := url.Values{
+		"name":       {"Bob"},
+		"email":      {"bob@example.com"},
+		"message":    {"Hello!"},
+		"csrf_token": {extractCSRFToken(t, body)},
+	}
+
+	t.Run("Unsolved", func(t *testing.T) {
+		code, _, body := ts.postForm(t, "/contact", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Please complete the check that you are not a robot")
+		assert.Equal(t, tokenRX.MatchString(body), true)
+	})
+
+	t.Run("Solved", func(t *testing.T) {
+		form.Set("pow-token", match[1])
+		form.Set("pow-nonce", solvePoW(t, match[1], 8))
+
+		code, _, _ := ts.postForm(t, "/contact", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+}
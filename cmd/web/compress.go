@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The content types which are worth compressing. Everything else (e.g. images, fonts and archives) is either
+// already compressed or too small to benefit. Event streams are left alone too, so that each event reaches the
+// client as soon as it is flushed, without waiting for the compressor.
+var compressibleTypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/plain":             true,
+	"text/javascript":        true,
+	"text/xml":               true,
+	"application/javascript": true,
+	"application/json":       true,
+	"application/xml":        true,
+	"application/rss+xml":    true,
+	"application/atom+xml":   true,
+	"image/svg+xml":          true,
+}
+
+// Pools of compressors, which are reset for each response rather than allocated afresh, since each one holds
+// several hundred kilobytes of buffers.
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	}}
+)
+
+// A middleware which compresses responses with gzip (or deflate, for clients which only accept that) when the
+// client's Accept-Encoding header allows it and the content type is worth compressing (see compressibleTypes).
+// The decision is made when the response headers are written, so handlers don't need to know about it.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Caches need to store a separate copy of the response for each encoding.
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response isn't finished if the handler panics, so that recoverPanic can still send an error page.
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		next.ServeHTTP(cw, r)
+		cw.close()
+	})
+}
+
+// Function used to choose the encoding for a response from the Accept-Encoding header values of the request. gzip
+// is preferred to deflate when both are accepted, and an empty string is returned if neither is.
+func negotiateEncoding(values []string) string {
+	accepted := map[string]bool{}
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+
+			// A quality of 0 means that the coding is not acceptable.
+			if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+				if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+					continue
+				}
+			}
+
+			accepted[coding] = true
+		}
+	}
+
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// Define a compressWriter type which compresses the body of a response when it is worth it. The status code is held
+// back until the first write, so that, as with net/http, the content type can be detected from the start of the
+// body if the handler didn't set one. Like statusWriter, it passes hijacking through (so that WebSocket handshakes
+// still work) and can be unwrapped by http.ResponseController.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int
+	wroteHeader bool
+	started     bool
+
+	// The compressor, if the response is being compressed, and the pool which it is returned to.
+	cw   compressor
+	pool *sync.Pool
+}
+
+// Define a compressor interface, which both *gzip.Writer and *flate.Writer satisfy.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	// Informational responses are sent straight away, and don't affect the final response.
+	if w.started || (code >= 100 && code <= 199) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+}
+
+// Function used to write the response headers, deciding whether to compress the body from the status code, the
+// headers and the start of the body.
+func (w *compressWriter) start(b []byte) {
+	w.started = true
+
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && len(b) > 0 {
+		h.Set("Content-Type", http.DetectContentType(b))
+	}
+
+	// Responses without a body, partial content (whose ranges refer to the uncompressed body), responses which
+	// already have an encoding and content types which don't compress well are sent as they are.
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	compressible := h.Get("Content-Encoding") == "" && compressibleTypes[mediaType]
+
+	// A Not Modified response has to have the same ETag as the compressed response which the client is revalidating
+	// (see notModified()).
+	if w.status == http.StatusNotModified && compressible {
+		weakenETag(h)
+	}
+
+	if len(b) == 0 || w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusPartialContent || !compressible {
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+
+	switch w.encoding {
+	case "gzip":
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		w.cw, w.pool = gz, &gzipWriters
+	case "deflate":
+		fl := flateWriters.Get().(*flate.Writer)
+		fl.Reset(w.ResponseWriter)
+		w.cw, w.pool = fl, &flateWriters
+	}
+
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	weakenETag(h)
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Function used to weaken the strong ETag of a compressed response, since its body isn't byte-for-byte the same as
+// the one which the handler computed the ETag from. Conditional requests still match, since If-None-Match uses the
+// weak comparison.
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if !w.started {
+		w.start(b)
+	}
+
+	if w.cw == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.cw.Write(b)
+}
+
+// Flush sends any data held by the compressor to the client. If nothing has been written yet, the headers are sent
+// and the response isn't compressed.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		w.start(nil)
+	}
+
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that http.ResponseController can reach it.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Function used to finish the response once the handler has returned, sending the headers of responses without a
+// body and returning the compressor to its pool.
+func (w *compressWriter) close() {
+	if !w.started && w.wroteHeader {
+		w.start(nil)
+	}
+
+	if w.cw == nil {
+		return
+	}
+
+	w.cw.Close()
+	w.pool.Put(w.cw)
+	w.cw = nil
+}
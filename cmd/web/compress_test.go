@@ -0,0 +1,194 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{name: "None", values: nil, want: ""},
+		{name: "Gzip", values: []string{"gzip, deflate, br"}, want: "gzip"},
+		{name: "Deflate only", values: []string{"deflate"}, want: "deflate"},
+		{name: "Gzip refused", values: []string{"gzip;q=0, deflate;q=0.5"}, want: "deflate"},
+		{name: "Wildcard", values: []string{"*"}, want: "gzip"},
+		{name: "Identity", values: []string{"identity"}, want: ""},
+		{name: "Multiple headers", values: []string{"br", "GZIP;q=0.8"}, want: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, negotiateEncoding(tt.values), tt.want)
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("An old silent pond... ", 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		wantEncoding   string
+	}{
+		{
+			name:           "HTML with gzip",
+			acceptEncoding: "gzip",
+			contentType:    "text/html; charset=utf-8",
+			status:         http.StatusOK,
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "JSON with deflate",
+			acceptEncoding: "deflate",
+			contentType:    "application/json",
+			status:         http.StatusCreated,
+			wantEncoding:   "deflate",
+		},
+		{
+			name:           "Sniffed content type",
+			acceptEncoding: "gzip",
+			contentType:    "",
+			status:         http.StatusOK,
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "Image",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			status:         http.StatusOK,
+			wantEncoding:   "",
+		},
+		{
+			name:           "Not accepted",
+			acceptEncoding: "",
+			contentType:    "text/html; charset=utf-8",
+			status:         http.StatusOK,
+			wantEncoding:   "",
+		},
+		{
+			name:           "Event stream",
+			acceptEncoding: "gzip",
+			contentType:    "text/event-stream",
+			status:         http.StatusOK,
+			wantEncoding:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("Content-Length", "2200")
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, body[:len(body)/2])
+				http.NewResponseController(w).Flush()
+				io.WriteString(w, body[len(body)/2:])
+			})
+
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			compress(next).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			defer rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.status)
+			assert.Equal(t, rs.Header.Get("Vary"), "Accept-Encoding")
+			assert.Equal(t, rs.Header.Get("Content-Encoding"), tt.wantEncoding)
+
+			// Compressed responses only have a weak ETag, since their bodies differ from the uncompressed ones.
+			if tt.wantEncoding != "" {
+				assert.Equal(t, rs.Header.Get("ETag"), `W/"abc"`)
+			} else {
+				assert.Equal(t, rs.Header.Get("ETag"), `"abc"`)
+			}
+
+			var reader io.Reader = rs.Body
+
+			switch tt.wantEncoding {
+			case "gzip":
+				assert.Equal(t, rs.Header.Get("Content-Length"), "")
+
+				reader, err = gzip.NewReader(rs.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+			case "deflate":
+				reader = flate.NewReader(rs.Body)
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, string(got), body)
+		})
+	}
+}
+
+func TestCompressNotModified(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		wantETag       string
+	}{
+		{"Compressible", "gzip", "application/json", `W/"abc"`},
+		{"Not accepted", "", "application/json", `"abc"`},
+		{"Image", "gzip", "image/png", `"abc"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(http.StatusNotModified)
+			})
+
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			compress(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusNotModified)
+			assert.Equal(t, rr.Header().Get("Content-Encoding"), "")
+			assert.Equal(t, rr.Header().Get("ETag"), tt.wantETag)
+		})
+	}
+}
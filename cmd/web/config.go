@@ -0,0 +1,764 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// The prefix of the environment variables which settings can be given in, e.g. SNIPPETBOX_ADDR for -addr.
+const envPrefix = "SNIPPETBOX_"
+
+// Define a config type to hold the application's settings. Each setting is a command-line flag, and can also be
+// given in an environment variable or the configuration file (see loadConfig).
+type config struct {
+	addr         string
+	internalAddr string
+	pprof        bool
+	dbDriver     string
+	dsn          string
+	replicaDSN   string
+	tlsCert      string
+	tlsKey       string
+	sessionStore string
+	redisURL     string
+
+	dbMaxOpenConns    int
+	dbMaxIdleConns    int
+	dbConnMaxLifetime time.Duration
+	dbConnMaxIdleTime time.Duration
+	dbStatsInterval   time.Duration
+
+	snippetCacheSize int
+	snippetCacheTTL  time.Duration
+	fragmentCacheTTL time.Duration
+
+	archiveAfter    time.Duration
+	archiveInterval time.Duration
+
+	smtpHost     string
+	smtpPort     int
+	smtpUsername string
+	smtpPassword string
+	smtpSender   string
+	contactEmail string
+
+	maxInflight        int
+	sessionLifetime    time.Duration
+	sessionIdleTimeout time.Duration
+	rememberMeLifetime time.Duration
+	sudoWindow         time.Duration
+	defaultPageSize    int
+	maxPageSize        int
+	lockoutThreshold   int
+	lockoutDuration    time.Duration
+	shedLatency        time.Duration
+	shedDBWait         time.Duration
+	authRateLimit      float64
+	authRateBurst      int
+	apiRateLimits      string
+	corsTrustedOrigins string
+	trustedProxies     string
+	signupMode         string
+	captchaProvider    string
+	captchaSiteKey     string
+	captchaSecret      string
+	powDifficulty      int
+	formMinSubmitTime  time.Duration
+	formSecret         string
+
+	oauthRedirectBase  string
+	githubClientID     string
+	githubClientSecret string
+	googleClientID     string
+	googleClientSecret string
+
+	baseURL          string
+	siteName         string
+	siteTagline      string
+	siteLogo         string
+	siteFooter       string
+	otlpEndpoint     string
+	traceSampleRatio float64
+	logFormat        string
+	errorReportURL   string
+	shutdownTimeout  time.Duration
+	requestTimeout   time.Duration
+	downloadTimeout  time.Duration
+	maintenance      bool
+	migrate          bool
+	dev              bool
+	minifyHTML       bool
+	vaultAddr        string
+
+	// The secret settings which were given on the command line, where other users of the machine can see them.
+	secretArgs []string
+}
+
+// Function used to load the configuration from the command-line arguments, environment variables and an optional
+// YAML configuration file, in that order of precedence, falling back to the defaults. Environment variables are
+// named after the flags with the SNIPPETBOX_ prefix (e.g. SNIPPETBOX_SESSION_LIFETIME for -session-lifetime), and
+// keys in the configuration file are the flag names, e.g. "session-lifetime: 24h". The configuration file is named
+// by the -config flag or the SNIPPETBOX_CONFIG environment variable.
+//
+// The flag set is returned along with the configuration, so that the resolved values can be listed.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*config, *flag.FlagSet, error) {
+	var cfg config
+
+	getenv := func(key string) string {
+		value, _ := lookupEnv(key)
+		return value
+	}
+
+	fs := flag.NewFlagSet("snippetbox", flag.ContinueOnError)
+
+	configFile := fs.String("config", getenv(envPrefix+"CONFIG"), "Path to a YAML configuration file (optional)")
+
+	fs.StringVar(&cfg.addr, "addr", ":4000", "HTTP Network Address")
+
+	// The network address of a second, plain HTTP listener for operational endpoints (health checks and metrics),
+	// which should only be reachable from inside the network. When it is set, those endpoints are only served on
+	// it (see internalRoutes). Otherwise the metrics are served to admins on the public server.
+	fs.StringVar(&cfg.internalAddr, "internal-addr", "", "Internal HTTP network address for operational endpoints (optional)")
+
+	// Whether admins can capture profiles from the public server at /debug/pprof/. The profiling endpoints are
+	// always served by the internal listener.
+	fs.BoolVar(&cfg.pprof, "pprof", false, "Serve profiling endpoints to admins on the public server")
+
+	// The database system which the snippetbox database is kept in, either "mysql", "postgres" or "sqlite" (see
+	// internal/models/dialect.go). SQLite needs no server, so it is the easiest to develop with.
+	fs.StringVar(&cfg.dbDriver, "db-driver", "mysql", "Database driver (mysql, postgres or sqlite)")
+
+	// The DSN string for the snippetbox database, in the form used by the database driver, e.g.
+	// "postgres://web@localhost/snippetbox" for PostgreSQL, or a file name such as "snippetbox.db" for SQLite
+	// (":memory:" keeps the database in memory until the application exits). The default (for MYSQL) has no
+	// password, which should be given in the SNIPPETBOX_DSN environment variable or the configuration file rather
+	// than on the command line, where other users of the machine can see it.
+	fs.StringVar(&cfg.dsn, "dsn", "web@/snippetbox?parseTime=true", "Database Data Source Name")
+
+	// The DSN string for an optional read-only replica of the database, in the same form. Reads of snippets which
+	// can tolerate replication lag (viewing and listing them) are sent to it, and go to the primary database while
+	// the replica can't be reached, or for a few seconds after the user has changed a snippet (see replica.go).
+	fs.StringVar(&cfg.replicaDSN, "replica-dsn", "", "Read-only replica Data Source Name (optional)")
+
+	// The limits of the database connection pool (see sql.DB). Connections are closed once they reach the maximum
+	// lifetime or have been idle for the maximum idle time, so that they are spread across the database servers
+	// behind a load balancer and don't outlive the servers' own timeouts. A value of 0 means no limit, except that
+	// a maximum of 0 idle connections keeps none.
+	fs.IntVar(&cfg.dbMaxOpenConns, "db-max-open-conns", 25, "Maximum open database connections (0 for no limit)")
+	fs.IntVar(&cfg.dbMaxIdleConns, "db-max-idle-conns", 25, "Maximum idle database connections")
+	fs.DurationVar(&cfg.dbConnMaxLifetime, "db-conn-max-lifetime", time.Hour, "Maximum lifetime of a database connection (0 for no limit)")
+	fs.DurationVar(&cfg.dbConnMaxIdleTime, "db-conn-max-idle-time", 15*time.Minute, "Maximum idle time of a database connection (0 for no limit)")
+
+	// How often the statistics of the database connection pool are logged.
+	fs.DurationVar(&cfg.dbStatsInterval, "db-stats-interval", 5*time.Minute, "Interval between database pool statistics logs (0 to disable)")
+
+	// Where sessions are stored: in the database, in Redis, or in memory. Sessions stored in memory are lost when
+	// the application restarts and aren't shared between instances, so it is only suitable for development and
+	// single-instance deployments.
+	fs.StringVar(&cfg.sessionStore, "session-store", "database", "Session store (database, redis or memory)")
+	fs.StringVar(&cfg.redisURL, "redis-url", "", "Redis URL for the redis session store, e.g. redis://localhost:6379/0")
+
+	// The size of the in-memory cache of snippets read by the snippet and home pages, and how long they are cached
+	// for. Snippets changed by another instance of the application can be served stale for up to the time to live.
+	fs.IntVar(&cfg.snippetCacheSize, "snippet-cache-size", 1000, "Maximum snippets cached in memory (0 to disable)")
+	fs.DurationVar(&cfg.snippetCacheTTL, "snippet-cache-ttl", time.Minute, "How long snippets are cached in memory")
+
+	// How long rendered fragments of pages, such as the list of snippets on the home page, are cached for (see
+	// fragments.go). They are rendered again as soon as snippets change on this instance.
+	fs.DurationVar(&cfg.fragmentCacheTTL, "fragment-cache-ttl", 30*time.Second, "How long rendered page fragments are cached (0 to disable)")
+
+	// How long after they expire snippets are moved to the archive table, and how often the archiver runs.
+	fs.DurationVar(&cfg.archiveAfter, "archive-after", 90*24*time.Hour, "Time after expiry when snippets are archived (0 to disable)")
+	fs.DurationVar(&cfg.archiveInterval, "archive-interval", time.Hour, "Interval between runs of the archiver")
+
+	// The TLS certificate and private key which the server uses.
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "./tls/cert.pem", "TLS certificate file")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "./tls/key.pem", "TLS private key file")
+
+	// SMTP server settings used by the mailer to send emails to site operators.
+	fs.StringVar(&cfg.smtpHost, "smtp-host", "localhost", "SMTP host")
+	fs.IntVar(&cfg.smtpPort, "smtp-port", 25, "SMTP port")
+	fs.StringVar(&cfg.smtpUsername, "smtp-username", "", "SMTP username")
+	fs.StringVar(&cfg.smtpPassword, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.smtpSender, "smtp-sender", "Snippetbox <no-reply@snippetbox.example.com>", "SMTP sender")
+
+	// The email address which receives messages submitted through the contact form.
+	fs.StringVar(&cfg.contactEmail, "contact-email", "support@snippetbox.example.com", "Contact form recipient email address")
+
+	// The maximum number of simultaneous in-flight requests allowed for each client.
+	fs.IntVar(&cfg.maxInflight, "max-inflight", 10, "Maximum concurrent requests per client to slow routes, such as searches and the API (0 to disable)")
+
+	// The absolute lifetime of sessions, and how long they can go without any requests before they expire. Sessions
+	// are refreshed on every request, so that the idle timeout only catches abandoned sessions.
+	fs.DurationVar(&cfg.sessionLifetime, "session-lifetime", 12*time.Hour, "Maximum session lifetime")
+	fs.DurationVar(&cfg.sessionIdleTimeout, "session-idle-timeout", time.Hour, "Session lifetime without activity (0 to disable)")
+
+	// The lifetime of the sessions of users who tick "Remember me" when logging in. The idle timeout doesn't apply
+	// to them, so they last this long however rarely the user visits.
+	fs.DurationVar(&cfg.rememberMeLifetime, "remember-me-lifetime", 30*24*time.Hour, "Session lifetime when \"Remember me\" is ticked")
+
+	// How recently users must have entered their password to change their password, email address or delete their
+	// account.
+	fs.DurationVar(&cfg.sudoWindow, "sudo-window", 10*time.Minute, "How long re-entering a password allows sensitive actions")
+
+	// The number of items shown in listings by default, and the largest number which can be requested with ?limit=.
+	fs.IntVar(&cfg.defaultPageSize, "page-size-default", 10, "Default number of items in listings")
+	fs.IntVar(&cfg.maxPageSize, "page-size-max", 100, "Maximum number of items in listings")
+
+	// The number of consecutive failed login attempts after which an account is locked, and for how long.
+	fs.IntVar(&cfg.lockoutThreshold, "lockout-threshold", 5, "Failed login attempts before an account is locked (0 to disable)")
+	fs.DurationVar(&cfg.lockoutDuration, "lockout-duration", 15*time.Minute, "How long accounts are locked after too many failed logins")
+
+	// The average request latency and database connection wait time above which anonymous requests for listing
+	// pages start being rejected.
+	fs.DurationVar(&cfg.shedLatency, "shed-latency", time.Second, "Request latency above which listing requests are shed (0 to disable)")
+	fs.DurationVar(&cfg.shedDBWait, "shed-db-wait", 250*time.Millisecond, "Database wait time above which listing requests are shed (0 to disable)")
+
+	// The rate at which each client IP address can attempt to log in or sign up, in requests per second, and the
+	// size of the bursts allowed above that rate.
+	fs.Float64Var(&cfg.authRateLimit, "auth-rate-limit", 0.2, "Login and signup attempts per second per IP address (0 to disable)")
+	fs.IntVar(&cfg.authRateBurst, "auth-rate-burst", 10, "Burst of login and signup attempts allowed per IP address")
+
+	// The number of JSON API requests allowed per minute for each API token tier, and for requests without a token
+	// ("anonymous"), which are limited by IP address. A limit of 0 disables the limit for that tier.
+	fs.StringVar(&cfg.apiRateLimits, "api-rate-limits", "anonymous=60 standard=300 elevated=3000", "API requests per minute for each token tier")
+
+	// The origins of browser-based clients which are allowed to call the JSON API, e.g. "https://app.example.com".
+	fs.StringVar(&cfg.corsTrustedOrigins, "cors-trusted-origins", "", "Trusted CORS origins for the API (space separated)")
+
+	// The IP addresses or CIDR ranges of the reverse proxies in front of the application, e.g. "10.0.0.0/8". The
+	// X-Forwarded-For and X-Real-IP headers of requests from these addresses are used to find the client's IP
+	// address, which is used for logging, rate limiting and recording logins (see proxy.go).
+	fs.StringVar(&cfg.trustedProxies, "trusted-proxies", "", "Trusted reverse proxy IP addresses or CIDR ranges (space separated)")
+
+	// Whether anyone can sign up ("open"), only people with an invite code created by an admin ("invite"), or
+	// nobody ("closed").
+	fs.StringVar(&cfg.signupMode, "signup-mode", signupModeOpen, "Signup mode (open|invite|closed)")
+
+	// The challenge which has to be solved to sign up, to stop bots (see challenge.go): a CAPTCHA from hCaptcha or
+	// Turnstile, which needs the site key and secret from the service, or a self-hosted proof-of-work challenge,
+	// whose tokens are signed with the secret. The difficulty is the number of leading zero bits which the
+	// browser has to find, so each extra bit doubles the time it takes.
+	fs.StringVar(&cfg.captchaProvider, "captcha-provider", challengeNone, "Challenge on signup forms (none|hcaptcha|turnstile|pow)")
+	fs.StringVar(&cfg.captchaSiteKey, "captcha-site-key", "", "Site key of the hCaptcha or Turnstile account")
+	fs.StringVar(&cfg.captchaSecret, "captcha-secret", "", "Secret of the hCaptcha or Turnstile account, or the key to sign proof-of-work tokens with")
+	fs.IntVar(&cfg.powDifficulty, "pow-difficulty", 16, "Leading zero bits required by the proof-of-work challenge")
+
+	// How soon after they are shown the signup, snippet and contact forms can be submitted, since bots tend to
+	// submit them straight away (see spam.go), and the key which the time they were shown is signed with. Without
+	// a key a random one is used, so it has to be set when more than one instance of the application is running.
+	fs.DurationVar(&cfg.formMinSubmitTime, "form-min-submit-time", 2*time.Second, "Minimum time between showing a form and submitting it (0 to disable)")
+	fs.StringVar(&cfg.formSecret, "form-secret", "", "Key to sign form timestamps with (random if empty)")
+
+	// OAuth client credentials for logging in with GitHub and Google. A provider is only enabled when its client ID
+	// is set. The credentials also default to the values of the GITHUB_* and GOOGLE_* environment variables which
+	// were used before the SNIPPETBOX_ ones.
+	fs.StringVar(&cfg.oauthRedirectBase, "oauth-redirect-base", "https://localhost:4000", "External base URL used in OAuth redirect URLs")
+	fs.StringVar(&cfg.githubClientID, "github-client-id", getenv("GITHUB_CLIENT_ID"), "GitHub OAuth client ID")
+	fs.StringVar(&cfg.githubClientSecret, "github-client-secret", getenv("GITHUB_CLIENT_SECRET"), "GitHub OAuth client secret")
+	fs.StringVar(&cfg.googleClientID, "google-client-id", getenv("GOOGLE_CLIENT_ID"), "Google OAuth client ID")
+	fs.StringVar(&cfg.googleClientSecret, "google-client-secret", getenv("GOOGLE_CLIENT_SECRET"), "Google OAuth client secret")
+
+	// The external base URL of the application, used to build links in emails sent by background jobs, which
+	// (unlike handlers) have no request to take the host from, and the absolute links in feeds.
+	fs.StringVar(&cfg.baseURL, "base-url", "https://localhost:4000", "External base URL used in links in emails and feeds")
+
+	// How the site is branded (see branding.go), so that it can be renamed without changing the templates. The logo
+	// replaces the default one in the header, and the contents of the footer file are added to the footer of every
+	// page as they are, so the file must be trusted.
+	fs.StringVar(&cfg.siteName, "site-name", "Snippetbox", "Name of the site shown in page titles, the header and feeds")
+	fs.StringVar(&cfg.siteTagline, "site-tagline", "", "Tagline shown below the site name in the header (optional)")
+	fs.StringVar(&cfg.siteLogo, "site-logo", "", "Path to an image file to use as the site logo (optional)")
+	fs.StringVar(&cfg.siteFooter, "site-footer", "", "Path to an HTML file to add to the footer of every page (optional)")
+
+	// The OTLP/HTTP endpoint of the OpenTelemetry collector which traces are sent to, e.g. "http://localhost:4318",
+	// and the fraction of requests which are traced. Tracing is disabled if no endpoint is set.
+	fs.StringVar(&cfg.otlpEndpoint, "otlp-endpoint", "", "OpenTelemetry collector OTLP/HTTP endpoint for traces (empty to disable)")
+	fs.Float64Var(&cfg.traceSampleRatio, "trace-sample-ratio", 1, "Fraction of requests to trace (between 0 and 1)")
+
+	// The format of the log output: "text" for logfmt-style lines which are easy to read in a terminal, or "json"
+	// for log aggregators.
+	fs.StringVar(&cfg.logFormat, "log-format", "text", "Log output format (text|json)")
+
+	// The URL which a JSON report of every server-side panic is POSTed to, e.g. the ingestion endpoint of an error
+	// tracking service. Panics are always logged, whether or not it is set.
+	fs.StringVar(&cfg.errorReportURL, "error-report-url", "", "URL to POST a JSON report of every server-side panic to (optional)")
+
+	// How long in-flight requests are given to complete when the server is shut down (on SIGINT or SIGTERM) or
+	// upgraded (on SIGUSR2), before their connections are closed.
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 30*time.Second, "Grace period for in-flight requests on shutdown")
+
+	// How long requests may take before their context is cancelled, and the longer limit for routes which send
+	// large responses (e.g. raw snippets and feeds). The request timeout should be shorter than the server's write
+	// timeout (10 seconds), so that there is time to send the response.
+	fs.DurationVar(&cfg.requestTimeout, "request-timeout", 8*time.Second, "Maximum time to handle a request (0 to disable)")
+	fs.DurationVar(&cfg.downloadTimeout, "download-timeout", time.Minute, "Maximum time to handle a download or feed request")
+
+	// Whether to apply any pending database migrations at startup. They can also be applied with the migrate
+	// command (see migrate.go).
+	fs.BoolVar(&cfg.migrate, "migrate", false, "Apply pending database migrations at startup")
+
+	// Whether to start in maintenance mode, in which visitors get a maintenance page instead of the site (see
+	// maintenance.go). Admins can also switch it on and off from the admin dashboard while the application runs.
+	fs.BoolVar(&cfg.maintenance, "maintenance", false, "Start in maintenance mode")
+
+	// Whether to run in development mode, in which templates are parsed from the ui directory (see devUIDir) on
+	// every render rather than once from the copies embedded in the binary, so that changes to them show up on the
+	// next page load.
+	fs.BoolVar(&cfg.dev, "dev", false, "Development mode: re-parse templates from disk on every render")
+
+	// Whether to minify rendered pages before they are sent (see minify.go), which saves bytes on every response at
+	// the cost of a little CPU time. Pages are buffered before they are written anyway, so it adds no latency.
+	fs.BoolVar(&cfg.minifyHTML, "minify-html", false, "Minify rendered HTML pages")
+
+	// The address of the Vault server which secrets can be looked up in (see secrets.go). The token is read from
+	// the VAULT_TOKEN environment variable, as the Vault CLI does.
+	fs.StringVar(&cfg.vaultAddr, "vault-addr", getenv("VAULT_ADDR"), "Vault server address for secret references (optional)")
+
+	for _, name := range secretSettings {
+		fs.String(name+"-file", "", fmt.Sprintf("File containing the %s setting", name))
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The only commands are migrate and seed (see migrate.go and seed.go). Anything else is probably a mistyped
+	// flag.
+	if fs.NArg() > 0 && fs.Arg(0) != "migrate" && fs.Arg(0) != "seed" {
+		return nil, nil, fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+
+	// Record which flags were given on the command line, since they take precedence over everything else.
+	onCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+
+	for _, name := range secretSettings {
+		if onCommandLine[name] {
+			cfg.secretArgs = append(cfg.secretArgs, name)
+		}
+	}
+
+	// Record which settings were given anywhere, so that secrets which are also given in a file can be detected.
+	given := maps.Clone(onCommandLine)
+
+	var file map[string]string
+	if *configFile != "" {
+		file, err = readConfigFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for name := range file {
+			if fs.Lookup(name) == nil || name == "config" {
+				return nil, nil, fmt.Errorf("%s: unknown setting %q", *configFile, name)
+			}
+		}
+	}
+
+	// Fill in the settings which weren't given on the command line from the environment and then the file, using
+	// the flags to parse the values so that they are parsed in the same way as on the command line.
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || onCommandLine[f.Name] || f.Name == "config" {
+			return
+		}
+
+		key := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+
+		if value, ok := lookupEnv(key); ok {
+			given[f.Name] = true
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, key, setErr)
+			}
+		} else if value, ok := file[f.Name]; ok {
+			given[f.Name] = true
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("%s: invalid value %q for %s: %w", *configFile, value, f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var store secretStore
+	if cfg.vaultAddr != "" {
+		token := getenv("VAULT_TOKEN")
+		if token == "" {
+			return nil, nil, errors.New("vault-addr is set, but VAULT_TOKEN isn't")
+		}
+
+		store = newVaultStore(cfg.vaultAddr, token)
+	}
+
+	err = resolveSecrets(fs, given, store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, fs, nil
+}
+
+// Function used to read a YAML configuration file of settings, which must all be scalars (strings, numbers or
+// booleans). Their values are returned as strings, to be parsed by the corresponding flags.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+
+	err = yaml.Unmarshal(b, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		switch value.(type) {
+		case string, int, float64, bool:
+			settings[name] = fmt.Sprint(value)
+		case nil:
+			settings[name] = ""
+		default:
+			return nil, fmt.Errorf("%s: setting %q must be a single value", path, name)
+		}
+	}
+
+	return settings, nil
+}
+
+// Function used to check that the settings are consistent with each other and within their allowed ranges, and
+// that the credentials which are needed have been given.
+func (cfg *config) validate() error {
+	switch cfg.signupMode {
+	case signupModeOpen, signupModeInvite, signupModeClosed:
+	default:
+		return fmt.Errorf("invalid signup mode %q", cfg.signupMode)
+	}
+
+	switch cfg.logFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid log format %q", cfg.logFormat)
+	}
+
+	if !validator.Between(cfg.defaultPageSize, 1, cfg.maxPageSize) {
+		return fmt.Errorf("page-size-default must be between 1 and page-size-max (%d)", cfg.maxPageSize)
+	}
+
+	if cfg.internalAddr != "" && cfg.internalAddr == cfg.addr {
+		return errors.New("internal-addr must be different from addr")
+	}
+
+	if cfg.errorReportURL != "" {
+		u, err := url.Parse(cfg.errorReportURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid error-report-url %q", redactURL(cfg.errorReportURL))
+		}
+	}
+
+	if cfg.traceSampleRatio < 0 || cfg.traceSampleRatio > 1 {
+		return errors.New("trace-sample-ratio must be between 0 and 1")
+	}
+
+	if _, err := models.DialectFor(cfg.dbDriver); err != nil {
+		return fmt.Errorf("invalid db-driver %q", cfg.dbDriver)
+	}
+
+	if err := parseDSN(cfg.dbDriver, cfg.dsn); err != nil {
+		return fmt.Errorf("invalid dsn: %w", err)
+	}
+
+	if cfg.replicaDSN != "" {
+		if err := parseDSN(cfg.dbDriver, cfg.replicaDSN); err != nil {
+			return fmt.Errorf("invalid replica-dsn: %w", err)
+		}
+	}
+
+	if cfg.dbMaxOpenConns < 0 || cfg.dbMaxIdleConns < 0 {
+		return errors.New("db-max-open-conns and db-max-idle-conns must not be negative")
+	}
+
+	if cfg.dbMaxOpenConns > 0 && cfg.dbMaxIdleConns > cfg.dbMaxOpenConns {
+		return fmt.Errorf("db-max-idle-conns must not be more than db-max-open-conns (%d)", cfg.dbMaxOpenConns)
+	}
+
+	if cfg.dbConnMaxLifetime < 0 || cfg.dbConnMaxIdleTime < 0 || cfg.dbStatsInterval < 0 {
+		return errors.New("db-conn-max-lifetime, db-conn-max-idle-time and db-stats-interval must not be negative")
+	}
+
+	switch cfg.sessionStore {
+	case "database", "memory":
+	case "redis":
+		u, err := url.Parse(cfg.redisURL)
+		if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			return errors.New("redis-url must be a redis:// or rediss:// URL when session-store is redis")
+		}
+	default:
+		return fmt.Errorf("invalid session-store %q", cfg.sessionStore)
+	}
+
+	if cfg.snippetCacheSize < 0 {
+		return errors.New("snippet-cache-size must not be negative")
+	}
+
+	if cfg.snippetCacheSize > 0 && cfg.snippetCacheTTL <= 0 {
+		return errors.New("snippet-cache-ttl must be positive")
+	}
+
+	if cfg.fragmentCacheTTL < 0 {
+		return errors.New("fragment-cache-ttl must not be negative")
+	}
+
+	if cfg.formMinSubmitTime < 0 {
+		return errors.New("form-min-submit-time must not be negative")
+	}
+
+	if cfg.archiveAfter < 0 {
+		return errors.New("archive-after must not be negative")
+	}
+
+	if cfg.archiveAfter > 0 && cfg.archiveInterval <= 0 {
+		return errors.New("archive-interval must be positive")
+	}
+
+	if cfg.smtpUsername != "" && cfg.smtpPassword == "" {
+		return errors.New("smtp-username is set, but smtp-password isn't")
+	}
+
+	switch cfg.captchaProvider {
+	case challengeNone:
+	case challengeHCaptcha, challengeTurnstile:
+		if cfg.captchaSiteKey == "" || cfg.captchaSecret == "" {
+			return fmt.Errorf("captcha-site-key and captcha-secret must be set when captcha-provider is %s", cfg.captchaProvider)
+		}
+	case challengePoW:
+		if cfg.captchaSecret == "" {
+			return errors.New("captcha-secret must be set when captcha-provider is pow")
+		}
+
+		if cfg.powDifficulty < 1 || cfg.powDifficulty > 32 {
+			return errors.New("pow-difficulty must be between 1 and 32")
+		}
+	default:
+		return fmt.Errorf("invalid captcha-provider %q", cfg.captchaProvider)
+	}
+
+	if cfg.githubClientID != "" && cfg.githubClientSecret == "" {
+		return errors.New("github-client-id is set, but github-client-secret isn't")
+	}
+
+	if cfg.googleClientID != "" && cfg.googleClientSecret == "" {
+		return errors.New("google-client-id is set, but google-client-secret isn't")
+	}
+
+	if strings.TrimSpace(cfg.siteName) == "" {
+		return errors.New("site-name must not be empty")
+	}
+
+	cfg.baseURL = strings.TrimSuffix(cfg.baseURL, "/")
+
+	return nil
+}
+
+// The value shown in place of secrets in the configuration snapshot.
+const redacted = "[REDACTED]"
+
+// Define a configEntry type to hold a single named setting in the snapshot of the application's configuration
+// which is logged at startup and shown on the /admin/config page.
+type configEntry struct {
+	Name  string
+	Value string
+}
+
+// Function used to return the resolved value of every flag (including defaults), in alphabetical order, with
+// secrets redacted. Flags with "password" or "secret" in their name are redacted entirely, and only the password
+// is redacted from the database DSN and the Redis URL.
+func resolvedConfig(fs *flag.FlagSet) []configEntry {
+	var entries []configEntry
+
+	driver := "mysql"
+	if f := fs.Lookup("db-driver"); f != nil {
+		driver = f.Value.String()
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+
+		switch {
+		case strings.HasSuffix(f.Name, "-file"):
+			// The paths of files which hold secrets aren't secret themselves.
+		case strings.Contains(f.Name, "password") || strings.Contains(f.Name, "secret"):
+			if value != "" {
+				value = redacted
+			}
+		case f.Name == "dsn" || f.Name == "replica-dsn":
+			value = redactDSN(driver, value)
+		case (f.Name == "redis-url" || f.Name == "error-report-url") && value != "":
+			value = redactURL(value)
+		}
+
+		entries = append(entries, configEntry{Name: f.Name, Value: value})
+	})
+
+	return entries
+}
+
+// Function used to check that a DSN is in the form used by the given database driver.
+func parseDSN(driver, dsn string) error {
+	var err error
+
+	switch driver {
+	case "postgres":
+		_, err = pgx.ParseConfig(dsn)
+	case "sqlite":
+		// SQLite DSNs are file names, optionally followed by query parameters, so any name which isn't a MySQL
+		// DSN left over from the default configuration is accepted.
+		if dsn == "" || strings.Contains(dsn, "@") {
+			err = fmt.Errorf("invalid SQLite DSN %q", dsn)
+		}
+	default:
+		_, err = mysql.ParseDSN(dsn)
+	}
+
+	return err
+}
+
+// Matches the password in a PostgreSQL DSN in keyword/value form, e.g. "host=localhost password='secret'".
+var rxPostgresPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// Function used to replace the password in a DSN for the given database driver. If the DSN can't be parsed, the
+// whole DSN is redacted in case it contains a password.
+func redactDSN(driver, dsn string) string {
+	if parseDSN(driver, dsn) != nil {
+		return redacted
+	}
+
+	if driver == "postgres" {
+		// PostgreSQL DSNs are either URLs, which can give the password in the user info or a query parameter, or a
+		// list of keyword/value pairs.
+		if !strings.Contains(dsn, "://") {
+			return rxPostgresPassword.ReplaceAllString(dsn, "${1}"+redacted)
+		}
+
+		return redactURL(dsn)
+	}
+
+	// SQLite DSNs are file names, which don't have passwords.
+	if driver == "sqlite" {
+		return dsn
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return redacted
+	}
+
+	if cfg.Passwd != "" {
+		cfg.Passwd = redacted
+	}
+
+	return cfg.FormatDSN()
+}
+
+// Function used to replace the password in a URL, which can be given in the user info or a "password" query
+// parameter. If the URL can't be parsed, it is redacted entirely in case it contains a password.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	query := u.Query()
+	if query.Has("password") {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+
+	// The brackets in the placeholder are escaped in URLs.
+	return strings.ReplaceAll(u.String(), url.QueryEscape(redacted), redacted)
+}
+
+// Function used to return the features and optional middleware which are enabled by the configuration, so that
+// operators don't need to work them out from the flag values. Their names are prefixed with "feature.".
+func (app *application) enabledFeatures() []configEntry {
+	enabled := func(ok bool) string {
+		if ok {
+			return "enabled"
+		}
+		return "disabled"
+	}
+
+	oauth := "disabled"
+	if names := app.oauthProviderNames(); len(names) > 0 {
+		oauth = strings.Join(names, ", ")
+	}
+
+	// Tracing is enabled when main() has installed an SDK tracer provider in place of the default no-op one.
+	_, tracing := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+
+	challenge := "disabled"
+	if app.challenge != nil {
+		challenge = app.challenge.provider
+	}
+
+	return []configEntry{
+		{Name: "feature.archiving", Value: enabled(app.config.archiveAfter > 0)},
+		{Name: "feature.challenge", Value: challenge},
+		{Name: "feature.concurrency-limit", Value: enabled(app.config.maxInflight > 0)},
+		{Name: "feature.custom-footer", Value: enabled(app.branding.footer != "")},
+		{Name: "feature.custom-logo", Value: enabled(app.branding.logo != nil)},
+		{Name: "feature.cors", Value: enabled(len(app.corsTrustedOrigins) > 0)},
+		{Name: "feature.dev-mode", Value: enabled(app.templateFS != nil)},
+		{Name: "feature.error-reporting-hook", Value: enabled(app.reportError != nil)},
+		{Name: "feature.fragment-cache", Value: enabled(app.fragments != nil)},
+		{Name: "feature.html-minification", Value: enabled(app.config.minifyHTML)},
+		{Name: "feature.load-shedding", Value: enabled(app.shedder.latencyThreshold > 0 || app.shedder.dbWaitThreshold > 0)},
+		{Name: "feature.oauth-providers", Value: oauth},
+		{Name: "feature.session-version", Value: fmt.Sprint(sessionVersion(app.sessionMigrations))},
+		{Name: "feature.snippet-cache", Value: enabled(app.snippetCache != nil)},
+		{Name: "feature.spam-timing-check", Value: enabled(app.spam != nil && app.spam.minSubmitTime > 0)},
+		{Name: "feature.tracing", Value: enabled(tracing)},
+	}
+}
+
+// Function used to write the configuration snapshot to the log, with an attribute for each entry.
+func (app *application) logConfig() {
+	attrs := make([]slog.Attr, 0, len(app.configEntries))
+	for _, entry := range app.configEntries {
+		attrs = append(attrs, slog.String(entry.Name, entry.Value))
+	}
+
+	app.logger.LogAttrs(context.Background(), slog.LevelInfo, "configuration", attrs...)
+}
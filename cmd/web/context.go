@@ -1,5 +1,59 @@
 package main
 
+import (
+	"net/http"
+	"time"
+)
+
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const userRoleContextKey = contextKey("userRole")
+
+const userTimezoneContextKey = contextKey("userTimezone")
+
+const requestInfoContextKey = contextKey("requestInfo")
+
+const apiUserIDContextKey = contextKey("apiUserID")
+
+const apiTokenContextKey = contextKey("apiToken")
+
+const requestIDContextKey = contextKey("requestID")
+
+const clientIPContextKey = contextKey("clientIP")
+
+const localeContextKey = contextKey("locale")
+
+// Define a requestInfo type to hold details about a request which are discovered by middleware as the request is
+// handled. A pointer to it is added to the request context by recoverPanic, so that the details are still available
+// to recoverPanic if a panic occurs further down the chain.
+type requestInfo struct {
+	UserID int
+
+	// Set by handlers which hold the connection open (e.g. event streams and WebSockets), so that the time they
+	// spend open isn't mistaken for latency by measureLatency.
+	Streaming bool
+
+	// The timer which cancels the request's context when it runs out of time (see requestTimeout).
+	timeout *time.Timer
+}
+
+// Function used to retrieve the requestInfo from the request context. It returns nil if there is none (e.g. in
+// tests which call a handler directly).
+func requestInfoFromRequest(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	return info
+}
+
+// Function used by handlers which hold the connection open to record that they do so in the request's requestInfo.
+// The request timeout no longer applies to them.
+func markStreaming(r *http.Request) {
+	if info := requestInfoFromRequest(r); info != nil {
+		info.Streaming = true
+
+		if info.timeout != nil {
+			info.timeout.Stop()
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Define a dbStatser interface, which is satisfied by *sql.DB, so that the logging of the connection pool
+// statistics can be tested without a database.
+type dbStatser interface {
+	Stats() sql.DBStats
+}
+
+// logDBStats() logs the statistics of the database connection pool every interval until the context is
+// cancelled, so that operators can see whether its limits need tuning. The wait and close counts are for the
+// interval since the previous log. An interval of 0 disables the logging. It is intended to be launched in its own
+// goroutine when the application starts.
+func (app *application) logDBStats(ctx context.Context, db dbStatser, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := db.Stats()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := db.Stats()
+
+		app.logger.Info("database pool statistics",
+			"max_open", current.MaxOpenConnections,
+			"open", current.OpenConnections,
+			"in_use", current.InUse,
+			"idle", current.Idle,
+			"wait_count", current.WaitCount-previous.WaitCount,
+			"wait_duration", current.WaitDuration-previous.WaitDuration,
+			"max_idle_closed", current.MaxIdleClosed-previous.MaxIdleClosed,
+			"max_idle_time_closed", current.MaxIdleTimeClosed-previous.MaxIdleTimeClosed,
+			"max_lifetime_closed", current.MaxLifetimeClosed-previous.MaxLifetimeClosed,
+		)
+
+		previous = current
+	}
+}
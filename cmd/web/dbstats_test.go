@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+// Define a fakeStatser type which returns pool statistics with ten more waits each time they are read, and
+// reports each read on a channel.
+type fakeStatser struct {
+	stats sql.DBStats
+	reads chan int
+	n     int
+}
+
+func (s *fakeStatser) Stats() sql.DBStats {
+	s.n++
+	s.stats.OpenConnections = 3
+	s.stats.WaitCount += 10
+
+	select {
+	case s.reads <- s.n:
+	default:
+	}
+
+	return s.stats
+}
+
+func TestLogDBStats(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	db := &fakeStatser{reads: make(chan int, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		app.logDBStats(ctx, db, time.Millisecond)
+		close(done)
+	}()
+
+	// Wait for the statistics to be read at the first tick, after they were read at the start.
+	for n := range db.reads {
+		if n >= 2 {
+			break
+		}
+	}
+
+	cancel()
+	<-done
+
+	assert.StringContains(t, buf.String(), `msg="database pool statistics"`)
+	assert.StringContains(t, buf.String(), "open=3")
+	assert.StringContains(t, buf.String(), "wait_count=10")
+}
+
+func TestLogDBStatsDisabled(t *testing.T) {
+	app := newTestApplication(t)
+
+	// With an interval of 0, the function returns straight away without reading the statistics.
+	db := &fakeStatser{reads: make(chan int, 1)}
+	app.logDBStats(context.Background(), db, 0)
+
+	assert.Equal(t, db.n, 0)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// How long the error reporting endpoint has to accept a report.
+const errorReportTimeout = 5 * time.Second
+
+// Define an errorReportPayload type to hold the JSON body of an error report.
+type errorReportPayload struct {
+	Error     string    `json:"error"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	UserID    int       `json:"user_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Stack     string    `json:"stack"`
+	Time      time.Time `json:"time"`
+}
+
+// Function used to return the error reporting hook set by the error-report-url setting, which POSTs every report to
+// the URL as JSON, e.g. for an external error tracking service to collect. Reports are sent in the background, so
+// that a slow endpoint doesn't hold up the error page, and failures to send them are logged. Nil is returned if no
+// URL is set.
+func newErrorReporter(logger *slog.Logger, url string) func(report errorReport) {
+	if url == "" {
+		return nil
+	}
+
+	client := &http.Client{Timeout: errorReportTimeout}
+
+	return func(report errorReport) {
+		body, err := json.Marshal(errorReportPayload{
+			Error:     report.Err.Error(),
+			Method:    report.Method,
+			Path:      report.Path,
+			UserID:    report.UserID,
+			RequestID: report.RequestID,
+			Stack:     string(report.Stack),
+			Time:      time.Now().UTC(),
+		})
+		if err != nil {
+			logger.Error("error report failed", "error", err)
+			return
+		}
+
+		go func() {
+			err := sendErrorReport(client, url, body)
+			if err != nil {
+				logger.Error("error report failed", "error", err, "request_id", report.RequestID)
+			}
+		}()
+	}
+}
+
+// Function used to POST an error report to the error reporting endpoint.
+func sendErrorReport(client *http.Client, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), errorReportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rs, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode < 200 || rs.StatusCode > 299 {
+		return fmt.Errorf("error reporting endpoint responded with status %d", rs.StatusCode)
+	}
+
+	return nil
+}
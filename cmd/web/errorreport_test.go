@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestNewErrorReporter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	assert.Equal(t, newErrorReporter(logger, "") == nil, true)
+
+	payloads := make(chan errorReportPayload, 1)
+
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload errorReportPayload
+
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Equal(t, r.Header.Get("Content-Type"), "application/json")
+
+		payloads <- payload
+	}))
+	defer endpoint.Close()
+
+	reportError := newErrorReporter(logger, endpoint.URL)
+	reportError(errorReport{
+		Err:       errors.New("something broke"),
+		Method:    http.MethodPost,
+		Path:      "/snippet/create",
+		UserID:    1,
+		RequestID: "abc123",
+		Stack:     []byte("goroutine 1 [running]:"),
+	})
+
+	select {
+	case payload := <-payloads:
+		assert.Equal(t, payload.Error, "something broke")
+		assert.Equal(t, payload.Method, http.MethodPost)
+		assert.Equal(t, payload.Path, "/snippet/create")
+		assert.Equal(t, payload.UserID, 1)
+		assert.Equal(t, payload.RequestID, "abc123")
+		assert.Equal(t, payload.Stack, "goroutine 1 [running]:")
+	case <-time.After(5 * time.Second):
+		t.Fatal("error report was not sent")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The interval at which we check for snippets which are about to expire, how long before a snippet expires its
+// owner is notified, and the maximum number of notifications which are queued on each check.
+const (
+	expiryCheckInterval = 10 * time.Minute
+	expiryNoticeWindow  = 24 * time.Hour
+	expiryBatchSize     = 100
+)
+
+// How long the unsubscribe links in expiry notifications are valid for.
+const unsubscribeLifetime = 30 * 24 * time.Hour
+
+// runExpiryNotifier() periodically queues emails to the owners of snippets which are about to expire, and webhook
+// deliveries for snippets which have expired, until the context is cancelled. It is intended to be launched in its
+// own goroutine when the application starts.
+func (app *application) runExpiryNotifier(ctx context.Context) {
+	ticker := time.NewTicker(expiryCheckInterval)
+	defer ticker.Stop()
+
+	// Batches which are in progress when the context is cancelled are finished rather than abandoned part way.
+	batchCtx := context.WithoutCancel(ctx)
+
+	for {
+		app.sendExpiryNotices(batchCtx)
+		app.announceExpiredSnippets(batchCtx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendExpiryNotices() queues a notification email for a single batch of snippets which are about to expire. Each
+// email is written to the outbox in the same transaction which marks its snippet as notified, so a snippet's owner
+// is only ever emailed about it once.
+func (app *application) sendExpiryNotices(ctx context.Context) {
+	snippets, err := app.snippets.ExpiringSoon(ctx, expiryNoticeWindow, expiryBatchSize)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, snippet := range snippets {
+		err := app.sendExpiryNotice(ctx, snippet)
+		if err != nil {
+			app.logger.Error("expiry notice failed", "snippet_id", snippet.ID, "error", err)
+		}
+	}
+}
+
+func (app *application) sendExpiryNotice(ctx context.Context, snippet *models.Snippet) error {
+	user, err := app.users.Get(ctx, snippet.UserID)
+	if err != nil {
+		return err
+	}
+
+	token, err := app.tokens.New(ctx, user.ID, unsubscribeLifetime, models.ScopeUnsubscribe)
+	if err != nil {
+		return err
+	}
+
+	job, err := models.NewJob(models.JobKindMail, mailJob{
+		Recipient: user.Email,
+		Template:  "expirynotice.tmpl",
+		Data: map[string]string{
+			"Name":           user.Name,
+			"Title":          snippet.Title,
+			"Expires":        humanDate(snippet.Expires),
+			"URL":            app.config.baseURL + mustURLFor("snippet.view", snippet.ID),
+			"UnsubscribeURL": fmt.Sprintf("%s/user/unsubscribe/%s", app.config.baseURL, token),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.snippets.MarkExpiryNotified(ctx, snippet.ID, job)
+}
+
+// Display a page asking the user to confirm that they want to stop receiving expiry notifications. Unsubscribing
+// needs a POST request, so that email scanners which follow links don't unsubscribe people.
+func (app *application) userUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	data := app.newTemplateData(r)
+	data.Form = struct{ Token string }{Token: params.ByName("token")}
+	app.render(w, r, http.StatusOK, "unsubscribe.tmpl", data)
+}
+
+func (app *application) userUnsubscribePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := app.tokens.UserID(r.Context(), models.ScopeUnsubscribe, params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.flash(r, flashError, "This unsubscribe link is invalid or has expired.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.SetNotifyExpiry(r.Context(), userID, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "You will no longer be emailed when your snippets are about to expire.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+type notificationsForm struct {
+	NotifyExpiry bool `form:"notifyExpiry"`
+}
+
+func (app *application) accountNotificationsPost(w http.ResponseWriter, r *http.Request) {
+	var form notificationsForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.users.SetNotifyExpiry(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), form.NotifyExpiry)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your notification preferences have been saved.")
+
+	http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+}
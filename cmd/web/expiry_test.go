@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/models/mocks"
+)
+
+// notifiedSnippetModel wraps the mock snippet model to record the jobs passed to MarkExpiryNotified().
+type notifiedSnippetModel struct {
+	mocks.SnippetModel
+	jobs map[int][]*models.Job
+}
+
+func (m *notifiedSnippetModel) MarkExpiryNotified(ctx context.Context, id int, jobs ...*models.Job) error {
+	m.jobs[id] = append(m.jobs[id], jobs...)
+	return nil
+}
+
+func TestSendExpiryNotices(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &notifiedSnippetModel{jobs: map[int][]*models.Job{}}
+	app.snippets = snippets
+
+	app.sendExpiryNotices(context.Background())
+
+	// The mock snippet which is expiring belongs to alice, who should be sent a single email about it.
+	assert.Equal(t, len(snippets.jobs[1]), 1)
+
+	job := snippets.jobs[1][0]
+	assert.Equal(t, job.Kind, models.JobKindMail)
+
+	var payload struct {
+		Recipient string
+		Template  string
+		Data      map[string]string
+	}
+
+	err := json.Unmarshal(job.Payload, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, payload.Recipient, "alice@example.com")
+	assert.Equal(t, payload.Template, "expirynotice.tmpl")
+	assert.Equal(t, payload.Data["URL"], "https://snippetbox.example.com/snippet/view/1")
+	assert.Equal(t, payload.Data["UnsubscribeURL"], "https://snippetbox.example.com/user/unsubscribe/ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+func TestUserUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		wantFlash string
+	}{
+		{"Valid token", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "You will no longer be emailed when your snippets are about to expire."},
+		{"Invalid token", "ZYXWVUTSRQPONMLKJIHGFEDCBA", "This unsubscribe link is invalid or has expired."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// Following the link only displays a confirmation page.
+			code, _, body := ts.get(t, "/user/unsubscribe/"+tt.token)
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, `<form action="/user/unsubscribe/`+tt.token+`" method="POST">`)
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, "/user/unsubscribe/"+tt.token, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/")
+
+			_, _, body = ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestAccountNotificationsPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/profile")
+
+	form := url.Values{}
+	form.Add("notifyExpiry", "true")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := ts.postForm(t, "/account/notifications", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/profile")
+
+	_, _, body = ts.get(t, "/account/profile")
+	assert.StringContains(t, body, "Your notification preferences have been saved.")
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The number of snippets included in the feeds.
+const feedSize = 20
+
+// Define a feed type to hold the contents of a feed of snippets, independently of the format it's served in, so
+// that the site-wide feeds and each user's feeds are built the same way. Path is the path of the feed without its
+// extension, e.g. "/feed", to which the extension of each format is added.
+type feed struct {
+	Title       string
+	Description string
+	HomeURL     string
+	Path        string
+	Author      string
+	Updated     time.Time
+	Snippets    []*models.Snippet
+}
+
+// Define a feedFormat type to describe one of the formats which feeds are served in.
+type feedFormat struct {
+	Ext         string
+	Name        string
+	ContentType string
+	encode      func(app *application, f *feed, self string) ([]byte, error)
+}
+
+// The formats which feeds are served in. Every feed is available in each of them, by adding its extension to the
+// feed's path.
+var (
+	feedFormatRSS  = feedFormat{Ext: ".rss", Name: "RSS", ContentType: "application/rss+xml", encode: (*application).rssFeed}
+	feedFormatAtom = feedFormat{Ext: ".atom", Name: "Atom", ContentType: "application/atom+xml", encode: (*application).atomFeed}
+	feedFormatJSON = feedFormat{Ext: ".json", Name: "JSON", ContentType: "application/feed+json", encode: (*application).jsonFeed}
+
+	feedFormats = []feedFormat{feedFormatRSS, feedFormatAtom, feedFormatJSON}
+)
+
+// Define a feedLink type to hold a link to a feed for the <link rel="alternate"> tags in the head of each page, which
+// let browsers and feed readers discover the feeds.
+type feedLink struct {
+	Href  string
+	Type  string
+	Title string
+}
+
+// Function used to return links to a feed in each of its formats, titled with title and the name of the format.
+func feedLinks(title, path string) []feedLink {
+	links := make([]feedLink, 0, len(feedFormats))
+
+	for _, format := range feedFormats {
+		links = append(links, feedLink{Href: path + format.Ext, Type: format.ContentType, Title: title + " (" + format.Name + ")"})
+	}
+
+	return links
+}
+
+// Define the parts of an RSS 2.0 document which we use. The atom:link element is recommended by the RSS Advisory
+// Board, so that feed readers know the canonical URL of the feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Define the parts of an Atom document which we use.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Define the parts of a JSON Feed 1.1 document (see https://www.jsonfeed.org/version/1.1/) which we use.
+type jsonFeedDocument struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentText   string `json:"content_text"`
+	DatePublished string `json:"date_published"`
+}
+
+// Function used to encode a feed as an RSS document. Each of the encoders is given the feed's own URL in that format,
+// which feed readers treat as its canonical URL.
+func (app *application) rssFeed(f *feed, self string) ([]byte, error) {
+	doc := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.HomeURL,
+			Description:   f.Description,
+			AtomLink:      atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+		},
+	}
+
+	for _, snippet := range f.Snippets {
+		url := app.config.baseURL + mustURLFor("snippet.view", snippet.ID)
+
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       snippet.Title,
+			Link:        url,
+			GUID:        rssGUID{IsPermaLink: true, Value: url},
+			PubDate:     snippet.Created.UTC().Format(time.RFC1123Z),
+			Description: snippet.Content,
+		})
+	}
+
+	return marshalFeedXML(doc)
+}
+
+// Function used to encode a feed as an Atom document.
+func (app *application) atomFeed(f *feed, self string) ([]byte, error) {
+	doc := atomFeed{
+		Title: f.Title,
+		ID:    f.HomeURL,
+		Links: []atomLink{
+			{Href: f.HomeURL},
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+		},
+		Updated: f.Updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.Author},
+	}
+
+	for _, snippet := range f.Snippets {
+		url := app.config.baseURL + mustURLFor("snippet.view", snippet.ID)
+		created := snippet.Created.UTC().Format(time.RFC3339)
+
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:     snippet.Title,
+			ID:        url,
+			Link:      atomLink{Href: url},
+			Published: created,
+			Updated:   created,
+			Content:   atomContent{Type: "text", Value: snippet.Content},
+		})
+	}
+
+	return marshalFeedXML(doc)
+}
+
+// Function used to encode a feed as a JSON Feed document.
+func (app *application) jsonFeed(f *feed, self string) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     self,
+		Description: f.Description,
+		Authors:     []jsonFeedAuthor{{Name: f.Author}},
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, snippet := range f.Snippets {
+		url := app.config.baseURL + mustURLFor("snippet.view", snippet.ID)
+
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            url,
+			URL:           url,
+			Title:         snippet.Title,
+			ContentText:   snippet.Content,
+			DatePublished: snippet.Created.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Function used to encode an RSS or Atom document as XML, with the XML declaration.
+func marshalFeedXML(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Serve the feeds of the latest public snippets.
+func (app *application) feedRSS(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatRSS, app.latestFeed)
+}
+
+func (app *application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatAtom, app.latestFeed)
+}
+
+func (app *application) feedJSON(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatJSON, app.latestFeed)
+}
+
+// Serve the feeds of a user's latest public snippets.
+func (app *application) userFeedRSS(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatRSS, app.userFeed)
+}
+
+func (app *application) userFeedAtom(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatAtom, app.userFeed)
+}
+
+func (app *application) userFeedJSON(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, feedFormatJSON, app.userFeed)
+}
+
+// Function used to fetch the contents of the site-wide feed of the latest public snippets.
+func (app *application) latestFeed(r *http.Request) (*feed, error) {
+	snippets, err := app.snippets.Latest(r.Context(), 0, feedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feed{
+		Title:       app.config.siteName,
+		Description: "The latest snippets on " + app.config.siteName,
+		HomeURL:     app.config.baseURL + "/",
+		Path:        "/feed",
+		Author:      app.config.siteName,
+		Updated:     feedUpdated(snippets),
+		Snippets:    snippets,
+	}, nil
+}
+
+// Function used to fetch the contents of the feed of the public snippets of the user in the :id parameter. It
+// returns models.ErrNoRecord if there is no such user.
+func (app *application) userFeed(r *http.Request) (*feed, error) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Private snippets are never included, even if the user is requesting their own feed, since feeds are public
+	// and cached.
+	snippets, err := app.snippets.ByUser(r.Context(), user.ID, false, feedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feed{
+		Title:       user.Name + " on " + app.config.siteName,
+		Description: "The latest snippets by " + user.Name + " on " + app.config.siteName,
+		HomeURL:     app.config.baseURL + mustURLFor("user.profile", user.ID),
+		Path:        mustURLFor("user.profile", user.ID) + "/feed",
+		Author:      user.Name,
+		Updated:     feedUpdated(snippets),
+		Snippets:    snippets,
+	}, nil
+}
+
+// Function used to return the time a feed was last updated, i.e. when the newest of its snippets was created.
+// Edits to snippets aren't timestamped, so the feed is dated by its newest snippet (its ETag still changes when a
+// snippet is edited). If there are no snippets, use the start of the current hour, so that the feed's timestamps are
+// stable for a while.
+func feedUpdated(snippets []*models.Snippet) time.Time {
+	if len(snippets) > 0 {
+		return snippets[0].Created.UTC()
+	}
+
+	return time.Now().UTC().Truncate(time.Hour)
+}
+
+// Function used to fetch a feed with load, encode it in the given format and send it to the client. Feed readers
+// poll feeds regularly, so feeds can be cached for a few minutes and conditional requests are supported.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, format feedFormat, load func(r *http.Request) (*feed, error)) {
+	f, err := load(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	body, err := format.encode(app, f, app.config.baseURL+f.Path+format.Ext)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", format.ContentType+"; charset=utf-8")
+
+	if notModified(w, r, strongETag(body), f.Updated) {
+		return
+	}
+
+	w.Write(body)
+}
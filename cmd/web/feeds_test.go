@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantContentType string
+		wantTitle       string
+		wantBody        string
+	}{
+		{"RSS", "/feed.rss", "application/rss+xml; charset=utf-8", "<title>An old silent pond</title>", "<link>https://snippetbox.example.com/snippet/view/1</link>"},
+		{"Atom", "/feed.atom", "application/atom+xml; charset=utf-8", "<title>An old silent pond</title>", `<link href="https://snippetbox.example.com/snippet/view/1"></link>`},
+		{"JSON", "/feed.json", "application/feed+json; charset=utf-8", `"title": "An old silent pond"`, `"url": "https://snippetbox.example.com/snippet/view/1"`},
+		{"User Atom", "/user/profile/1/feed.atom", "application/atom+xml; charset=utf-8", "<title>An old silent pond</title>", `<title>Alice on Snippetbox</title>`},
+		{"User JSON", "/user/profile/1/feed.json", "application/feed+json; charset=utf-8", `"title": "An old silent pond"`, `"feed_url": "https://snippetbox.example.com/user/profile/1/feed.json"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantContentType)
+			assert.Equal(t, header.Get("Cache-Control"), "public, max-age=300")
+			assert.StringContains(t, body, tt.wantTitle)
+			assert.StringContains(t, body, tt.wantBody)
+
+			// Make sure that the feed is well-formed.
+			var v struct{}
+			unmarshal := xml.Unmarshal
+			if strings.HasSuffix(tt.urlPath, ".json") {
+				unmarshal = json.Unmarshal
+			}
+			if err := unmarshal([]byte(body), &v); err != nil {
+				t.Fatal(err)
+			}
+
+			// A feed reader polling with the ETag of its copy is told that the feed hasn't changed.
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", header.Get("ETag"))
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+		})
+	}
+}
+
+func TestUserFeedNotFound(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for _, urlPath := range []string{"/user/profile/2/feed.atom", "/user/profile/foo/feed.json", "/user/profile/0/feed.rss"} {
+		t.Run(urlPath, func(t *testing.T) {
+			code, _, _ := ts.get(t, urlPath)
+
+			assert.Equal(t, code, http.StatusNotFound)
+		})
+	}
+}
+
+func TestFeedAutodiscovery(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/profile/1")
+
+	// The site-wide feeds are linked from every page, and the profile page also links to the user's feeds.
+	assert.StringContains(t, body, `<link rel='alternate' href='/feed.atom' type='application/atom&#43;xml' title='Snippetbox (Atom)'>`)
+	assert.StringContains(t, body, `<link rel='alternate' href='/user/profile/1/feed.atom' type='application/atom&#43;xml' title='Alice on Snippetbox (Atom)'>`)
+	assert.StringContains(t, body, `<link rel='alternate' href='/user/profile/1/feed.json' type='application/feed&#43;json' title='Alice on Snippetbox (JSON)'>`)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"net/http"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// The levels of flash messages, which are styled differently (see main.css).
+const (
+	flashSuccess = "success"
+	flashInfo    = "info"
+	flashWarning = "warning"
+	flashError   = "error"
+)
+
+// Define a FlashMessage type to hold a message which is shown at the top of the next page which the user sees,
+// e.g. to confirm that a form was submitted. The fields are exported so that it can be stored in the session.
+type FlashMessage struct {
+	Level string
+	Text  string
+}
+
+// The session is encoded with encoding/gob, which has to be told about the types stored in it other than the
+// basic ones.
+func init() {
+	gob.Register([]FlashMessage{})
+}
+
+// The session key under which the flash messages waiting to be shown are stored.
+const flashSessionKey = "flashes"
+
+// Function used to add a flash message with the given level to those waiting to be shown at the top of the next
+// page which the user sees. Messages are shown in the order they were added.
+func (app *application) flash(r *http.Request, level, text string) {
+	flashes, _ := app.sessionManager.Get(r.Context(), flashSessionKey).([]FlashMessage)
+
+	app.sessionManager.Put(r.Context(), flashSessionKey, append(flashes, FlashMessage{Level: level, Text: text}))
+}
+
+// Function used to remove and return the flash messages waiting to be shown.
+func (app *application) popFlashes(r *http.Request) []FlashMessage {
+	flashes, _ := app.sessionManager.Pop(r.Context(), flashSessionKey).([]FlashMessage)
+	return flashes
+}
+
+// Session migration which converts the single flash message which older versions of the application stored as a
+// string under the "flash" key into the list of flash messages.
+func migrateFlash(ctx context.Context, sm *scs.SessionManager) error {
+	if !sm.Exists(ctx, "flash") {
+		return nil
+	}
+
+	text, ok := sm.Pop(ctx, "flash").(string)
+	if !ok {
+		return errors.New("flash message is not a string")
+	}
+
+	sm.Put(ctx, flashSessionKey, []FlashMessage{{Level: flashInfo, Text: text}})
+
+	return nil
+}
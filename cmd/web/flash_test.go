@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestFlash(t *testing.T) {
+	app := newTestApplication(t)
+
+	var flashes []FlashMessage
+
+	handler := app.sessionManager.LoadAndSave(app.migrateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/add" {
+			app.flash(r, flashSuccess, "Snippet successfully created!")
+			app.flash(r, flashWarning, "Your snippet expires in one day.")
+			return
+		}
+
+		flashes = app.popFlashes(r)
+	})))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/add", nil))
+	cookie := rr.Result().Cookies()[0]
+
+	// The messages are shown in the order they were added, and only once.
+	for _, want := range [][]FlashMessage{
+		{{Level: flashSuccess, Text: "Snippet successfully created!"}, {Level: flashWarning, Text: "Your snippet expires in one day."}},
+		nil,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(cookie)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		assert.Equal(t, len(flashes), len(want))
+		for i := range want {
+			assert.Equal(t, flashes[i], want[i])
+		}
+	}
+}
+
+func TestMigrateFlash(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Create a session holding a flash message the way the first version of the session schema did.
+	legacy := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	}))
+
+	rr := httptest.NewRecorder()
+	legacy.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := rr.Result().Cookies()[0]
+
+	var flashes []FlashMessage
+	var oldExists bool
+
+	handler := app.sessionManager.LoadAndSave(app.migrateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flashes = app.popFlashes(r)
+		oldExists = app.sessionManager.Exists(r.Context(), "flash")
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, len(flashes), 1)
+	assert.Equal(t, flashes[0], FlashMessage{Level: flashInfo, Text: "Snippet successfully created!"})
+	assert.Equal(t, oldExists, false)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/declanlin/snippetbox/internal/i18n"
+)
+
+// Define a FormField type to describe one field of a form for the "field" partial (see ui/html/partials/field.tmpl),
+// so that every form renders its labels, re-populated values and errors the same way. The label and error are
+// messages, which the partial translates into the locale.
+type FormField struct {
+	Name        string
+	Label       string
+	Type        string // "text", "email", "password", "textarea", "checkbox" or "radio".
+	Value       string
+	Checked     bool         // For checkboxes.
+	Options     []FormOption // For radio buttons, one of which is checked if its value is the field's value.
+	Placeholder string
+	Hint        string // Extra text shown below the field, which isn't translated.
+	Error       string
+	Locale      *i18n.Localizer
+}
+
+// Define a FormOption type to hold one of the radio buttons of a FormField.
+type FormOption struct {
+	Value string
+	Label string
+}
+
+// Function used to return the ID of a field's input, which its label points to.
+func (f FormField) ID() string {
+	return "field-" + f.Name
+}
+
+// Function used to return the ID of the element which holds a field's error.
+func (f FormField) ErrorID() string {
+	return f.ID() + "-error"
+}
+
+// Function used to return the ID of the element which holds a field's hint.
+func (f FormField) HintID() string {
+	return f.ID() + "-hint"
+}
+
+// Function used to return the value of a field's aria-describedby attribute, which points screen readers at its
+// error and hint, or an empty string if it has neither.
+func (f FormField) DescribedBy() string {
+	var ids []string
+
+	if f.Error != "" {
+		ids = append(ids, f.ErrorID())
+	}
+
+	if f.Hint != "" {
+		ids = append(ids, f.HintID())
+	}
+
+	return strings.Join(ids, " ")
+}
+
+// Define a fieldsForm interface for the forms which can describe their fields. The page's data is passed in for
+// fields which depend on more than the form, e.g. the invite code field on the signup form.
+type fieldsForm interface {
+	fields(data *templateData) []FormField
+}
+
+// FormFields() returns the fields of the page's form, translated into the page's locale, for templates to range
+// over. It returns nil if the form doesn't describe its fields.
+func (data *templateData) FormFields() []FormField {
+	form, ok := data.Form.(fieldsForm)
+	if !ok {
+		return nil
+	}
+
+	fields := form.fields(data)
+	for i := range fields {
+		fields[i].Locale = data.Locale
+	}
+
+	return fields
+}
+
+func (form snippetCreateForm) fields(data *templateData) []FormField {
+	return []FormField{
+		{Name: "title", Label: "Title:", Type: "text", Value: form.Title, Error: form.FieldErrors["title"]},
+		{Name: "content", Label: "Content:", Type: "textarea", Value: form.Content, Error: form.FieldErrors["content"]},
+		{
+			Name:  "expires",
+			Label: "Delete In:",
+			Type:  "radio",
+			Value: strconv.Itoa(form.Expires),
+			Options: []FormOption{
+				{Value: "365", Label: "One Year"},
+				{Value: "7", Label: "One Week"},
+				{Value: "1", Label: "One Day"},
+			},
+			Error: form.FieldErrors["expires"],
+		},
+		// Private snippets are left out of public listings and can be shared with preview links.
+		{Name: "private", Label: "Private", Type: "checkbox", Checked: form.Private},
+	}
+}
+
+func (form userSignupForm) fields(data *templateData) []FormField {
+	fields := []FormField{
+		{Name: "name", Label: "Name:", Type: "text", Value: form.Name, Error: form.FieldErrors["name"]},
+		{Name: "email", Label: "Email:", Type: "email", Value: form.Email, Error: form.FieldErrors["email"]},
+		{Name: "password", Label: "Password:", Type: "password", Error: form.FieldErrors["password"]},
+	}
+
+	if data.SignupMode == signupModeInvite {
+		fields = append(fields, FormField{Name: "invite", Label: "Invite code:", Type: "text", Value: form.InviteCode, Error: form.FieldErrors["invite"]})
+	}
+
+	return fields
+}
+
+func (form userLoginForm) fields(data *templateData) []FormField {
+	return []FormField{
+		{Name: "email", Label: "Email:", Type: "email", Value: form.Email, Error: form.FieldErrors["email"]},
+		{Name: "password", Label: "Password:", Type: "password", Error: form.FieldErrors["password"]},
+		{Name: "rememberMe", Label: "Remember me", Type: "checkbox", Checked: form.RememberMe},
+	}
+}
+
+func (form accountSettingsForm) fields(data *templateData) []FormField {
+	email := FormField{Name: "email", Label: "Email:", Type: "email", Value: form.Email, Error: form.FieldErrors["email"]}
+	if data.PendingEmail != "" {
+		email.Hint = "A change to " + data.PendingEmail + " is waiting to be confirmed. Please check the inbox of that address."
+	}
+
+	return []FormField{
+		{Name: "name", Label: "Name:", Type: "text", Value: form.Name, Error: form.FieldErrors["name"]},
+		email,
+		{
+			Name:        "timezone",
+			Label:       "Time zone (leave blank to use your browser's):",
+			Type:        "text",
+			Value:       form.Timezone,
+			Placeholder: "Europe/London",
+			Error:       form.FieldErrors["timezone"],
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/validator"
+)
+
+func TestFormFieldDescribedBy(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FormField
+		want  string
+	}{
+		{"Neither", FormField{Name: "email"}, ""},
+		{"Error", FormField{Name: "email", Error: "This field cannot be blank"}, "field-email-error"},
+		{"Hint", FormField{Name: "email", Hint: "A change is waiting"}, "field-email-hint"},
+		{"Both", FormField{Name: "email", Error: "This field cannot be blank", Hint: "A change is waiting"}, "field-email-error field-email-hint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.field.DescribedBy(), tt.want)
+		})
+	}
+}
+
+func TestFormFields(t *testing.T) {
+	form := userSignupForm{Name: "Bob", Password: "pa$$word", InviteCode: "abc"}
+	form.AddFieldError("email", "This field cannot be blank")
+
+	// The invite code field is only shown when signups need an invite.
+	data := &templateData{Form: form}
+	assert.Equal(t, len(data.FormFields()), 3)
+
+	data.SignupMode = signupModeInvite
+	fields := data.FormFields()
+	assert.Equal(t, len(fields), 4)
+
+	assert.Equal(t, fields[0].Value, "Bob")
+	assert.Equal(t, fields[1].Error, "This field cannot be blank")
+	assert.Equal(t, fields[2].Value, "")
+	assert.Equal(t, fields[3].Value, "abc")
+
+	// Forms which don't describe their fields have none.
+	data.Form = struct{ validator.Validator }{}
+	assert.Equal(t, len(data.FormFields()), 0)
+}
+
+func TestFormFieldMarkup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+	assert.StringContains(t, body, `<label for="field-name">Name:</label>`)
+	assert.StringContains(t, body, `<input type="password" id="field-password" name="password" value="">`)
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, body := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, `<span class="error" id="field-email-error">This field cannot be blank</span>`)
+	assert.StringContains(t, body, `<input type="email" id="field-email" name="email" value="" aria-invalid="true" aria-describedby="field-email-error">`)
+	assert.StringContains(t, body, `<input type="text" id="field-name" name="name" value="Bob">`)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/cache"
+)
+
+// The most rendered fragments which are kept in memory. A fragment is cached separately for each combination of
+// the things which change how it looks (e.g. the locale and time zone), so there are a few of each.
+const fragmentCacheSize = 256
+
+// Define a fragmentCache type to hold partials which are expensive to render (see renderFragment), so that they
+// can be reused across requests for up to the -fragment-cache-ttl. The cache is emptied whenever snippets change.
+// As with the snippet cache, other instances of the application don't see the invalidations.
+type fragmentCache struct {
+	fragments *cache.LRU[string, template.HTML]
+}
+
+// Function used to return a new fragmentCache which keeps each fragment for up to ttl.
+func newFragmentCache(ttl time.Duration) *fragmentCache {
+	return &fragmentCache{fragments: cache.New[string, template.HTML](fragmentCacheSize, ttl)}
+}
+
+// Function used to empty the cache after snippets have changed. It does nothing if c is nil, so that it can be
+// called whether or not caching is enabled.
+func (c *fragmentCache) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.fragments.Purge()
+}
+
+// Define a fragmentLoader type for the functions which fetch the data for a fragment when it isn't cached. Along
+// with the data, they return when the fragment goes out of date (e.g. when the first snippet in it expires), or the
+// zero time if it only goes out of date when snippets change.
+type fragmentLoader func() (data *templateData, expires time.Time, err error)
+
+// Function used to render the named template from a page's template set, using the cached copy for key if there
+// is one. An empty key means that the fragment isn't cached, e.g. because it depends on who is asking for it.
+// The loader is only called when the fragment has to be rendered, so that cached fragments save the database
+// queries as well as the rendering.
+func (app *application) renderFragment(r *http.Request, page, name, key string, load fragmentLoader) (template.HTML, error) {
+	cacheKey := fmt.Sprintf("%s/%s/%s", page, name, key)
+
+	if app.fragments != nil && key != "" {
+		if html, ok := app.fragments.fragments.Get(cacheKey); ok {
+			return html, nil
+		}
+	}
+
+	data, expires, err := load()
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := app.executeTemplate(r, page, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	html := template.HTML(buf.String())
+
+	if app.fragments != nil && key != "" {
+		app.fragments.fragments.Set(cacheKey, html, expires)
+	}
+
+	return html, nil
+}
+
+// Function used to return the part of a fragment's cache key which identifies how a request wants it shown: its
+// locale and time zone.
+func (app *application) fragmentVariant(r *http.Request) string {
+	return fmt.Sprintf("%s/%s", app.localizer(r).Locale(), app.timezone(r))
+}
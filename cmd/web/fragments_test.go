@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+func TestRenderFragment(t *testing.T) {
+	app := newTestApplication(t)
+	app.fragments = newFragmentCache(time.Minute)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	loads := 0
+	load := func() (*templateData, time.Time, error) {
+		loads++
+		return &templateData{Snippets: []*models.Snippet{{ID: 1, Title: "An old silent pond"}}, Limit: 10}, time.Time{}, nil
+	}
+
+	render := func(key string) {
+		html, err := app.renderFragment(r, "home.tmpl", "latest-snippets", key, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.StringContains(t, string(html), "An old silent pond")
+	}
+
+	// The second render of a key comes from the cache.
+	render("en/UTC/10")
+	render("en/UTC/10")
+	assert.Equal(t, loads, 1)
+
+	// Other keys are cached separately, and an empty key isn't cached at all.
+	render("fr/UTC/10")
+	assert.Equal(t, loads, 2)
+
+	render("")
+	render("")
+	assert.Equal(t, loads, 4)
+
+	// Purging the cache makes the next request render the fragment again.
+	app.fragments.Purge()
+	render("en/UTC/10")
+	assert.Equal(t, loads, 5)
+}
+
+func TestHomeFragmentCache(t *testing.T) {
+	app := newTestApplication(t)
+	app.fragments = newFragmentCache(time.Minute)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for range 2 {
+		code, _, body := ts.get(t, "/")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<a href="/snippet/view/1">An old silent pond</a>`)
+		assert.StringContains(t, body, `<p class="excerpt">An old silent pond...</p>`)
+	}
+}
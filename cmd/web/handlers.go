@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/declanlin/snippetbox/internal/models"
 	"github.com/declanlin/snippetbox/internal/validator"
@@ -12,22 +15,62 @@ import (
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
+	// Read the number of snippets to display from the query string, rejecting out of range values.
+	var v validator.Validator
 
-	// Fetch a slice of the 10 most recently created snippets.
-	snippets, err := app.snippets.Latest()
+	limit := app.readLimit(r, &v)
 
-	// If there is an error in fetching the slice, log a server error and return.
-	if err != nil {
-		app.serverError(w, err)
+	// Read the cursor for the page of snippets to display, which the "load more" link sets.
+	cursor := r.URL.Query().Get("cursor")
+	after := readCursor(r.URL.Query(), "cursor", &v)
+
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
 	// Initialize a new templateData struct to store the slice of snippets.
 	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	data.Limit = limit
+	data.Cursor = cursor
+
+	// The first page of snippets is the same for everyone who shares a locale and time zone, so it is rendered
+	// from the fragment cache. Later pages are read far less often, so they aren't cached.
+	key := ""
+	if cursor == "" {
+		key = fmt.Sprintf("%s/%d", app.fragmentVariant(r), limit)
+	}
+
+	latest, err := app.renderFragment(r, "home.tmpl", "latest-snippets", key, func() (*templateData, time.Time, error) {
+		// Fetch a slice of the most recently created snippets, with one extra to find out whether there are more.
+		snippets, err := app.snippets.Latest(r.Context(), after, limit+1)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		data.Snippets, data.NextCursor = nextCursor(snippets, limit)
+
+		// The fragment is out of date once the first of its snippets expires.
+		var expires time.Time
+		for _, s := range data.Snippets {
+			if expires.IsZero() || s.Expires.Before(expires) {
+				expires = s.Expires
+			}
+		}
+
+		return data, expires, nil
+	})
+
+	// If there is an error in fetching the snippets or rendering them, log a server error and return.
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.Fragments = map[string]template.HTML{"latest-snippets": latest}
 
 	// Render the templates code associated with the specified template page.
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
@@ -40,29 +83,173 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// If there is an error parsing the string id as an integer, or the parsed id is less than 1, we will consider
 	// the resource to not exist.
 	if err != nil || id < 1 {
-		app.notFound(w)
+		app.notFound(w, r)
 		return
 	}
 
 	// Query the database for a snippet with the specified ID. Remember that we have specially returned a custom
 	// ErrNoRecord error from the Get function for a snippet. We will want to check this, and handle it by returning
 	// an HTTP 404 Not Found response, as opposed to a server error.
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+			app.notFound(w, r)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// Initialize a new templateData struct to store the snippet.
+	// Private snippets can only be viewed by their owner (or through a preview link), so respond as though the
+	// snippet does not exist for anyone else.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.Private && snippet.UserID != userID {
+		app.notFound(w, r)
+		return
+	}
+
+	// Initialize a new templateData struct to store the snippet. Public snippets get the meta tags which let sites
+	// show a preview of links to them.
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
 
+	if !snippet.Private {
+		data.Meta = app.snippetMeta(snippet)
+	}
+
+	// Show the owner of a private snippet the preview links which are currently active for it.
+	if snippet.Private {
+		data.PreviewLinks, err = app.previews.ForSnippet(r.Context(), snippet.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	// Render the template code associated with the specified template page.
-	app.render(w, http.StatusOK, "view.tmpl", data)
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+// Display a snippet on its own, without the nav bar and footer, for printing or saving as a PDF. The same rules as
+// snippetView() decide who can see it.
+func (app *application) snippetPrint(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	app.render(w, r, http.StatusOK, "print.tmpl", data)
+}
+
+// Send the content of a snippet as plain text, e.g. for downloading it or fetching it with curl. The same rules as
+// snippetView() decide who can see it. Conditional requests are supported, so clients polling for a snippet get an
+// HTTP 304 Not Modified response if their copy is current.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if notModified(w, r, strongETag([]byte(snippet.Content)), snippetModified(snippet)) {
+		return
+	}
+
+	w.Write([]byte(snippet.Content))
+}
+
+// Display a snippet through a preview link. No login is required, so anyone holding an unexpired link can view
+// the snippet even if it is private.
+func (app *application) snippetPreview(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.previews.Get(r.Context(), params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
+type snippetPreviewForm struct {
+	Hours               int `form:"hours"`
+	validator.Validator `form:"-"`
+}
+
+// Create a new preview link for a snippet owned by the authenticated user. The link is shown to the user once in
+// a flash message, since only a hash of its token is stored.
+func (app *application) snippetPreviewCreatePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetPreviewForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Preview links can be valid for one hour, one day or one week.
+	if !validator.PermittedValue(form.Hours, 1, 24, 168) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	token, err := app.previews.Insert(r.Context(), snippet.ID, time.Duration(form.Hours)*time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Preview link created: "+app.config.baseURL+mustURLFor("snippet.preview", token))
+
+	http.Redirect(w, r, mustURLFor("snippet.view", snippet.ID), http.StatusSeeOther)
+}
+
+type snippetPreviewRevokeForm struct {
+	LinkID int `form:"link"`
+}
+
+// Revoke one of the preview links for a snippet owned by the authenticated user.
+func (app *application) snippetPreviewRevokePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetPreviewRevokeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.previews.Revoke(r.Context(), form.LinkID, snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Preview link revoked.")
+
+	http.Redirect(w, r, mustURLFor("snippet.view", snippet.ID), http.StatusSeeOther)
 }
 
 // Define a struct to represent the form data and validation errors for the form fields.
@@ -73,6 +260,7 @@ type snippetCreateForm struct {
 	Title               string `form:"title"`
 	Content             string `form:"content"`
 	Expires             int    `form:"expires"`
+	Private             bool   `form:"private"`
 	validator.Validator `form:"-"`
 }
 
@@ -91,7 +279,7 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Render the template code associated with the specified template page.
-	app.render(w, http.StatusOK, "create.tmpl", data)
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
@@ -103,19 +291,21 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		// The client entered form data that was not valid.
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// Check that the title is not blank and not more than 100 characters in length.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-
-	// Check that the content is not blank.
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	// Check that the snippet wasn't submitted by a bot.
+	if app.rejectSpam(r, "create") {
+		form.AddNonFieldError(spamRejectedMessage)
+	}
 
-	// Check that the expires value matches one of the permitted values (1, 7, 365).
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7, or 365")
+	// Validate the form fields.
+	err = app.checkSnippet(r.Context(), &form.Validator, form.Title, form.Content, form.Expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// If there are any validation errors in the form data, dump them into a plain HTTP response and return from the handler.
 	if !form.Valid() {
@@ -127,29 +317,214 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 		// Re-render the create.tmpl template in the case of any validation errors.
 		// Use the HTTP 422 Unprocessable Entity when sending the response to indicate that their was a form data validation error.
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+
+		return
+	}
 
+	// Retrieve the ID of the authenticated user, who will be recorded as the owner of the snippet.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	// Look up the user's webhooks, so that they can be sent the new snippet in the same transaction as it is inserted.
+	webhooks, err := app.webhooks.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	// Using the parsed values for the client form data, insert a new user into the database using these provided values.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	now := time.Now().UTC()
+	snippet := &models.Snippet{
+		Title:   form.Title,
+		Content: form.Content,
+		Created: now,
+		Expires: now.AddDate(0, 0, form.Expires),
+		UserID:  userID,
+		Private: form.Private,
+	}
+
+	// Using the parsed values for the client form data, insert a new snippet into the database using these provided values.
+	id, err := app.snippets.Insert(r.Context(), userID, form.Title, form.Content, form.Expires, form.Private,
+		newSnippetJobs(webhooks, models.EventSnippetCreated, snippet))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	snippet.ID = id
+
+	app.snippetCreated(snippet)
+	app.readOwnWrites(r)
+
 	// Use the Put() function to add a string value and corresponding key to the session data.
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	app.flash(r, flashSuccess, "Snippet successfully created!")
 
 	// After inserting a new user into the database, redirect the user to the viewing page for the snippet they just created.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, mustURLFor("snippet.view", id), http.StatusSeeOther)
+}
+
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Version             int    `form:"version"`
+	validator.Validator `form:"-"`
+}
+
+// Display the form for editing a snippet owned by the authenticated user. The version of the snippet is sent with
+// the form, so that saving it can't overwrite an edit made in the meantime, e.g. in another tab.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+		Version: snippet.Version,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.checkSnippetText(r.Context(), &form.Validator, form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.Valid() {
+		// Copy the snippet rather than changing it, since it may be shared with the snippet cache.
+		updated := *snippet
+		updated.Title = form.Title
+		updated.Content = form.Content
+		updated.Version = form.Version + 1
+
+		jobs, err := app.newWebhookJobs(r.Context(), models.EventSnippetUpdated, &updated)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.snippets.Update(r.Context(), snippet.ID, form.Title, form.Content, form.Version, jobs...)
+		if err == nil {
+			app.fragments.Purge()
+			app.readOwnWrites(r)
+			app.flash(r, flashSuccess, "Snippet successfully updated!")
+
+			http.Redirect(w, r, mustURLFor("snippet.view", snippet.ID), http.StatusSeeOther)
+			return
+		}
+
+		if !errors.Is(err, models.ErrEditConflict) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// Someone else saved the snippet since the form was loaded. Rather than overwriting their edit, show the
+		// form again with the current version of the snippet alongside, so that the user can merge the changes
+		// and save again.
+		snippet, err = app.snippets.Get(models.WithPrimary(r.Context()), snippet.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		form.Version = snippet.Version
+		form.AddNonFieldError("This snippet has been changed since you started editing it. Check the current version below, then save again to replace it.")
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = form
+
+	status := http.StatusUnprocessableEntity
+	if len(form.FieldErrors) == 0 {
+		status = http.StatusConflict
+	}
+
+	app.render(w, r, status, "edit.tmpl", data)
+}
+
+// Function used to check the fields of a new snippet, adding an error to the validator for each field which is
+// invalid. The same rules apply to snippets created with the HTML form and with the API. An error is only returned
+// if the denylist of words couldn't be fetched.
+func (app *application) checkSnippet(ctx context.Context, v *validator.Validator, title, content string, expires int) error {
+	err := app.checkSnippetText(ctx, v, title, content)
+	if err != nil {
+		return err
+	}
+
+	// Check that the expires value matches one of the permitted values (1, 7, 365).
+	v.CheckField(validator.PermittedValue(expires, 1, 7, 365), "expires", "This field must equal 1, 7, or 365")
+
+	return nil
+}
+
+// Function used to check the title and content of a snippet, which are all that can be changed when it is edited.
+func (app *application) checkSnippetText(ctx context.Context, v *validator.Validator, title, content string) error {
+	// Check that the title is not blank and not more than 100 characters in length.
+	v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+
+	// Check that the title does not contain any words from the denylist.
+	blockedWords, err := app.blockedWords.Words(ctx)
+	if err != nil {
+		return err
+	}
+	v.CheckField(validator.NoBlockedWords(title, blockedWords), "title", "This field contains a word which is not allowed")
+
+	// Check that the content is not blank.
+	v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+
+	return nil
+}
+
+// Function used to validate the details of a new user, recording any problems in v.
+func checkSignup(v *validator.Validator, name, email, password string) {
+	// Check that the name and email are not blank.
+	v.CheckField(validator.NotBlank(name), "name", "This field cannot be blank")
+	v.CheckField(validator.NotBlank(email), "email", "This field cannot be blank")
+
+	// Check that the email address is in a valid format.
+	v.CheckField(validator.Matches(email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	// Check that the password is not blank and at least 8 characters long.
+	v.CheckField(validator.NotBlank(password), "password", "This field cannot be blank")
+	v.CheckField(validator.MinChars(password, 8), "password", "This field must be at least 8 characters long")
 }
 
+// The signup modes which the application can run in (see the -signup-mode flag). In invite mode, signing up
+// requires an invite code created by an admin, and in closed mode nobody can sign up.
+const (
+	signupModeOpen   = "open"
+	signupModeInvite = "invite"
+	signupModeClosed = "closed"
+)
+
+// How long invite codes created by admins are valid for.
+const inviteLifetime = 7 * 24 * time.Hour
+
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	InviteCode          string `form:"invite"`
 	validator.Validator `form:"-"`
 }
 
@@ -159,11 +534,17 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	// Initialize a new templateData struct to store additional resources for the template execution.
 	data := app.newTemplateData(r)
 
-	// Intialize the data.Form field as a zeroed userSignupForm instance.
-	data.Form = userSignupForm{}
+	// Intialize the data.Form field as a userSignupForm instance. Invite links include the invite code in the
+	// query string, so pre-fill it.
+	data.Form = userSignupForm{
+		InviteCode: r.URL.Query().Get("invite"),
+	}
+
+	// Ask visitors to prove that they aren't bots, if the site requires it.
+	app.addChallenge(w, data, "")
 
 	// Render the template for the signup.tmpl template.
-	app.render(w, http.StatusOK, "signup.tmpl", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -174,22 +555,24 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	// Return an HTTP 400 Response if the user attempts to sign up with data that cannot be decoded.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// Validate the form fields.
+	// Check that the form wasn't submitted by a bot, then validate the form fields.
+	if app.rejectSpam(r, "signup") {
+		form.AddNonFieldError(spamRejectedMessage)
+	}
 
-	// Check that the name and email are not blank.
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	checkSignup(&form.Validator, form.Name, form.Email, form.Password)
 
-	// Check that the email address is in a valid format.
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	// Check that an invite code was given if signups are invite-only.
+	if app.config.signupMode == signupModeInvite {
+		form.CheckField(validator.NotBlank(form.InviteCode), "invite", "This field cannot be blank")
+	}
 
-	// Check that the password is not blank and at least 8 characters long.
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	// Check the answer to the challenge, if the site requires one.
+	form.CheckField(app.verifyChallenge(r), "challenge", "Please complete the check that you are not a robot")
 
 	// If there are any validation errors in the form data, dump them into a plain HTTP response and return from the handler.
 	if !form.Valid() {
@@ -199,31 +582,57 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		// Pass the userSignupForm instance as dynamic data in the Form field.
 		data.Form = form
 
+		// Each answer to the challenge can only be used once, so a new challenge has to be solved.
+		app.addChallenge(w, data, form.FieldErrors["challenge"])
+
 		// Re-render the singup.tmpl template in the case of any validation errors.
 		// Use the HTTP 422 Unprocessable Entity when sending the response to indicate that their was a form data validation error.
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 
 		return
 	}
 
-	// Attempt to create a new user in the database.
-	// If there is a duplicate email error, add an error message to the form and redisplay it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	// Redeem the invite code and create the user in a single transaction, so that the same code can't be used for
+	// two signups at once, and the code is still unused if the signup fails.
+	err = app.txRunner.WithTx(r.Context(), func(ctx context.Context) error {
+		if app.config.signupMode == signupModeInvite {
+			err := app.invites.Redeem(ctx, form.InviteCode)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Attempt to create a new user in the database.
+		userID, err := app.users.Insert(ctx, form.Name, form.Email, form.Password)
+		if err != nil {
+			return err
+		}
+
+		// Send a link to the new address, so that the user can prove that it belongs to them.
+		return app.sendVerificationEmail(ctx, userID, form.Name, form.Email)
+	})
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		// If the invite code can't be used or there is a duplicate email error, add an error message to the form
+		// and redisplay it.
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("invite", "This invite code is invalid, has expired or has already been used")
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
-		} else {
-			app.serverError(w, err)
+		default:
+			app.serverError(w, r, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.addChallenge(w, data, "")
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
 	// Add a confirmation flash message to the session confirming their signup worked.
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.flash(r, flashSuccess, "Your signup was successful. Please check your inbox to verify your email address, then log in.")
 
 	// Redirect the user to the login page.
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -232,13 +641,14 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"rememberMe"`
 	validator.Validator `form:"-"`
 }
 
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl", data)
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -249,7 +659,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	// Return an HTTP 400 Response if the user attempts to log in with data that cannot be decoded.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -262,47 +672,78 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		return
 	}
 
-	// Authenticate the user credentials. If the credentials are invalid, add a generic non-field error message
-	// and re-display the login page.
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	// Extract the client's IP address from the request, so that it can be recorded against the login.
+	ip := clientIP(r)
+
+	// Authenticate the user credentials. If the credentials are invalid or the account has been locked, add a
+	// non-field error message and re-display the login page.
+	user, err := app.users.Authenticate(r.Context(), form.Email, form.Password, ip)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Incorrect email or password")
+		if errors.Is(err, models.ErrInvalidCredentials) || errors.Is(err, models.ErrAccountLocked) ||
+			errors.Is(err, models.ErrAccountDeactivated) {
+			switch {
+			case errors.Is(err, models.ErrAccountLocked):
+				form.AddNonFieldError("This account has been locked after too many failed login attempts. Please try again later.")
+			case errors.Is(err, models.ErrAccountDeactivated):
+				form.AddNonFieldError("This account has been deactivated.")
+			default:
+				form.AddNonFieldError("Incorrect email or password")
+			}
 
 			// Re-display the login page after modifying the form in the template data.
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusOK, "login.tmpl", data)
+			app.render(w, r, http.StatusOK, "login.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// Use the RenewToken() method on the current session to change the session ID.
-	// It's good practice to generate a new session ID when the authentication state or privilege level changes
-	// for the user, e.g. login and logout operations.
-	err = app.sessionManager.RenewToken(r.Context())
+	// Log the user in on the current session (see logIn in helpers.go).
+	err = app.logIn(r, user.ID)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	// Add the ID of the current user to the session so that they are considered "logged in".
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	// If the user asked to be remembered, make the session cookie persistent (so that it survives the browser being
+	// closed) and extend the session's expiry beyond the default lifetime.
+	if form.RememberMe {
+		app.sessionManager.RememberMe(r.Context(), true)
+		app.sessionManager.SetDeadline(r.Context(), time.Now().Add(app.config.rememberMeLifetime))
+	}
+
+	// Let the user know when and where they last logged in, and warn them about any failed attempts since, so that
+	// they can spot unexpected activity on their account.
+	if !user.LastLogin.IsZero() {
+		app.flash(r, flashInfo, fmt.Sprintf("Welcome back! Your last login was from %s on %s.", user.LastLoginIP, humanDate(user.LastLogin)))
+
+		if user.FailedLogins > 0 {
+			app.flash(r, flashWarning, fmt.Sprintf("There have been %d failed login attempts since then.", user.FailedLogins))
+		}
+	}
 
 	// Redirect the logged in user to the snippet create page.
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	// Remove the session from the user's list of active sessions.
+	err := app.sessions.Delete(r.Context(), app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Use the RenewToken() method on the current session ID to change the session ID.
-	err := app.sessionManager.RenewToken(r.Context())
+	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -310,12 +751,668 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
 
 	// Add a flash message indicating that the user has been successfully logged out.
-	app.sessionManager.Put(r.Context(), "flash", "You have been logged out successfully!")
+	app.flash(r, flashInfo, "You have been logged out successfully!")
 
 	// Redirect the user to the application homepage.
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func ping(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("OK"))
+// Display the public profile page for the user with the ID given in the URL.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	app.renderProfile(w, r, id)
+}
+
+// Display the profile page for the currently authenticated user.
+func (app *application) accountProfile(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	app.renderProfile(w, r, id)
+}
+
+// Fetch a user along with their unexpired snippets and render them using the profile.tmpl template.
+func (app *application) renderProfile(w http.ResponseWriter, r *http.Request, id int) {
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var v validator.Validator
+
+	limit := app.readLimit(r, &v)
+	if !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Only include private snippets when users are viewing their own profile.
+	ownProfile := user.ID == app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippets, err := app.snippets.ByUser(r.Context(), user.ID, ownProfile, limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Fetch the time the user's avatar was last changed, which is used to build its URL.
+	avatarUpdated, err := app.avatars.Updated(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Snippets = snippets
+
+	// Let feed readers discover the feeds of the user's snippets as well as the site-wide ones.
+	data.Feeds = append(data.Feeds, feedLinks(user.Name+" on "+app.config.siteName, mustURLFor("user.profile", user.ID)+"/feed")...)
+
+	if !avatarUpdated.IsZero() {
+		data.AvatarVersion = avatarUpdated.Unix()
+	}
+
+	app.render(w, r, http.StatusOK, "profile.tmpl", data)
+}
+
+// Display the authenticated user's active sessions, so that they can revoke any they don't recognise.
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	records, err := app.sessions.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+
+	// Sessions which have expired are still recorded until they are revoked, so check which ones the session
+	// store still knows about, and tidy up the rest.
+	for _, s := range records {
+		_, found, err := app.sessionManager.Store.Find(s.Token)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !found {
+			err = app.sessions.Delete(r.Context(), s.Token)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			continue
+		}
+
+		if s.Token == app.sessionManager.Token(r.Context()) {
+			data.CurrentSessionID = s.ID
+		}
+
+		data.Sessions = append(data.Sessions, s)
+	}
+
+	app.render(w, r, http.StatusOK, "sessions.tmpl", data)
+}
+
+// Revoke one of the authenticated user's sessions, logging out whoever is using it.
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	token, err := app.sessions.Revoke(r.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Remove the session from the session store, so that its cookie no longer logs anyone in.
+	err = app.sessionManager.Store.Delete(token)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "The session has been revoked.")
+
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+type accountReauthenticateForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the form which asks the authenticated user to confirm their password before a sensitive
+// action.
+func (app *application) accountReauthenticate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountReauthenticateForm{}
+	app.render(w, r, http.StatusOK, "reauthenticate.tmpl", data)
+}
+
+func (app *application) accountReauthenticatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountReauthenticateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reauthenticate.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.users.CheckPassword(r.Context(), userID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "reauthenticate.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "authenticatedAt", time.Now().Unix())
+
+	// Send the user back to the page which asked them to re-authenticate.
+	redirect := app.sessionManager.PopString(r.Context(), "reauthRedirect")
+	if redirect == "" {
+		redirect = "/account/profile"
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+// Render and display the change password form for the authenticated user.
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+	app.render(w, r, http.StatusOK, "password.tmpl", data)
+}
+
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form fields, using the same password rules as the signup form.
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	// Attempt to update the password. If the current password is wrong, add an error message to the form and
+	// redisplay it.
+	err = app.users.PasswordUpdate(r.Context(), userID, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your password has been updated!")
+
+	http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+}
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the account deletion confirmation form for the authenticated user.
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{}
+	app.render(w, r, http.StatusOK, "delete.tmpl", data)
+}
+
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl", data)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	// Find all of the user's sessions so that they can be removed from the session store along with the
+	// rest of their data.
+	tokens, err := app.userSessionTokens(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.users.Delete(r.Context(), userID, form.Password, tokens)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The sessions were deleted from the database along with the user, but the session store may be elsewhere
+	// (e.g. Redis), so delete them from the store too.
+	for _, token := range tokens {
+		err = app.sessionManager.Store.Delete(token)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// The user's snippets were deleted along with them, so make sure that none of them are served from the caches.
+	app.snippetCache.Purge()
+	app.fragments.Purge()
+
+	// Destroy the current session, so that it is not written back to the session store at the end of the
+	// request. The flash message below is stored in a brand new session.
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your account and all of your snippets have been deleted.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+type accountSettingsForm struct {
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	Timezone            string `form:"timezone"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the account settings form, pre-populated with the authenticated user's current details.
+func (app *application) accountSettings(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form := accountSettingsForm{
+		Name:     user.Name,
+		Email:    user.Email,
+		Timezone: user.Timezone,
+	}
+
+	app.renderSettings(w, r, http.StatusOK, form)
+}
+
+// Function used to render the account settings form. The address in the user's pending email change, if they have
+// one, is shown as well so that we can remind them to confirm it.
+func (app *application) renderSettings(w http.ResponseWriter, r *http.Request, status int, form accountSettingsForm) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	pendingEmail, err := app.emailChanges.Get(r.Context(), userID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The user is needed for their notification preferences.
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.PendingEmail = pendingEmail
+	data.User = user
+	data.AvatarMaxBytes = avatarMaxBytes
+	app.render(w, r, status, "settings.tmpl", data)
+}
+
+func (app *application) accountSettingsPost(w http.ResponseWriter, r *http.Request) {
+	var form accountSettingsForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Validate the form fields, using the same rules as the signup form.
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	// The time zone can be left blank to use the browser's time zone.
+	if form.Timezone != "" {
+		_, err = loadTimezone(form.Timezone)
+		form.CheckField(err == nil, "timezone", "This field must be a time zone name, e.g. Europe/London")
+	}
+
+	if !form.Valid() {
+		app.renderSettings(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	// Fetch the user's current details so that we can tell whether their email address is changing.
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Apply the changes in a single transaction, so that either all of them are made or none of them are.
+	err = app.txRunner.WithTx(r.Context(), func(ctx context.Context) error {
+		// Update the user's name and time zone straight away. Their email address is left unchanged until the new
+		// address has been confirmed.
+		err := app.users.Update(ctx, userID, form.Name, user.Email)
+		if err != nil {
+			return err
+		}
+
+		err = app.users.SetTimezone(ctx, userID, form.Timezone)
+		if err != nil || form.Email == user.Email {
+			return err
+		}
+
+		// Record the new email address as a pending change.
+		err = app.emailChanges.Insert(ctx, userID, form.Email)
+		if err != nil {
+			return err
+		}
+
+		// Invalidate the links sent for any earlier change, since they would now confirm this one, then send a
+		// confirmation link to the new address.
+		err = app.tokens.DeleteAllForUser(ctx, models.ScopeEmailChange, userID)
+		if err != nil {
+			return err
+		}
+
+		token, err := app.tokens.New(ctx, userID, 24*time.Hour, models.ScopeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		err = app.queueMail(ctx, form.Email, "emailchange.tmpl", map[string]string{
+			"Name": form.Name,
+			"URL":  fmt.Sprintf("%s/user/confirm-email/%s", app.config.baseURL, token),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Let the owner of the old address know about the change, so that they can react if it wasn't them.
+		return app.queueMail(ctx, user.Email, "emailchangenotice.tmpl", map[string]string{
+			"Name":     form.Name,
+			"NewEmail": form.Email,
+		})
+	})
+	if err != nil {
+		// If the new email address belongs to another user, add an error message to the form and redisplay it.
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email address is already in use")
+
+			app.renderSettings(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if form.Email == user.Email {
+		app.flash(r, flashSuccess, "Your details have been updated!")
+		http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your details have been updated. Please check the inbox of your new email address to confirm the change.")
+
+	http.Redirect(w, r, "/account/profile", http.StatusSeeOther)
+}
+
+// Apply the pending email change of the user which the token in the URL was issued to.
+func (app *application) userConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := app.tokens.UserID(r.Context(), models.ScopeEmailChange, params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.flash(r, flashError, "This confirmation link is invalid or has expired.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Apply the change and delete all of the user's email change tokens in a single transaction, since
+	// confirmation tokens are single-use.
+	err = app.txRunner.WithTx(r.Context(), func(ctx context.Context) error {
+		err := app.emailChanges.Confirm(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return app.tokens.DeleteAllForUser(ctx, models.ScopeEmailChange, userID)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.flash(r, flashError, "This confirmation link is invalid or has expired.")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			app.flash(r, flashError, "That email address is now in use by another account.")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your email address has been changed!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Verify the email address of the user which the token in the URL was issued to.
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := app.tokens.UserID(r.Context(), models.ScopeVerification, params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.flash(r, flashError, "This verification link is invalid or has expired.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Verify the user and delete all of their verification tokens in a single transaction, since verification
+	// tokens are single-use.
+	err = app.txRunner.WithTx(r.Context(), func(ctx context.Context) error {
+		err := app.users.Verify(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return app.tokens.DeleteAllForUser(ctx, models.ScopeVerification, userID)
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your email address has been verified!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+type contactForm struct {
+	Name                string `form:"name"`
+	Email               string `form:"email"`
+	Message             string `form:"message"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the contact form for the client.
+func (app *application) contact(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = contactForm{}
+
+	// Ask visitors to prove that they aren't bots, if the site requires it.
+	app.addChallenge(w, data, "")
+
+	app.render(w, r, http.StatusOK, "contact.tmpl", data)
+}
+
+func (app *application) contactPost(w http.ResponseWriter, r *http.Request) {
+	// Declare a zeroed instance of the contactForm struct to store form data and access the validator.
+	var form contactForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// If the submission looks automated, pretend that the message was sent successfully so that the bot gets no
+	// signal that its submission was discarded.
+	if app.rejectSpam(r, "contact") {
+		app.flash(r, flashSuccess, "Thanks for getting in touch! We'll get back to you soon.")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Validate the form fields.
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Message), "message", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Message, 5000), "message", "This field cannot be more than 5000 characters long")
+
+	// Check the answer to the challenge, if the site requires one.
+	form.CheckField(app.verifyChallenge(r), "challenge", "Please complete the check that you are not a robot")
+
+	// If there are any validation errors, re-display the contact form with the errors, and a new challenge since
+	// each answer can only be used once.
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.addChallenge(w, data, form.FieldErrors["challenge"])
+		app.render(w, r, http.StatusUnprocessableEntity, "contact.tmpl", data)
+		return
+	}
+
+	// Queue an email to the site operators containing the message. The email is sent by the outbox dispatcher, so
+	// that the client does not have to wait for the SMTP round trip and the email is retried if sending fails.
+	job, err := models.NewJob(models.JobKindMail, mailJob{
+		Recipient: app.config.contactEmail,
+		Template:  "contact.tmpl",
+		Data: map[string]string{
+			"Name":    form.Name,
+			"Email":   form.Email,
+			"Message": form.Message,
+		},
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Store the message in the database along with the queued email, in a single transaction.
+	_, err = app.contacts.Insert(r.Context(), form.Name, form.Email, form.Message, job)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Thanks for getting in touch! We'll get back to you soon.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
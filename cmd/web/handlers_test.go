@@ -1,14 +1,20 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models/mocks"
 )
 
-func TestPing(t *testing.T) {
+func TestHealthz(t *testing.T) {
 
 	// Create a new instance of our application struct.
 	app := newTestApplication(t)
@@ -20,11 +26,108 @@ func TestPing(t *testing.T) {
 	defer ts.Close()
 
 	// The network address that the server is listening on is contained in the ts.URL field.
-	// We can use this along with ts.Client().Get() to make a GET /ping request against the test server.
-	// This ts.Client().Get() returns a http.Response struct containing the response to the GET /ping request.
-	code, _, body := ts.get(t, "/ping")
+	// We can use this along with ts.Client().Get() to make a GET /healthz request against the test server.
+	// This ts.Client().Get() returns a http.Response struct containing the response to the GET /healthz request.
+	code, header, body := ts.get(t, "/healthz")
 	assert.Equal(t, code, http.StatusOK)
-	assert.Equal(t, string(body), "OK")
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+	assert.StringContains(t, body, `"status": "ok"`)
+	assert.StringContains(t, body, `"uptime": "0s"`)
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name          string
+		dbErr         error
+		noTemplates   bool
+		wantCode      int
+		wantDatabase  string
+		wantTemplates string
+	}{
+		{
+			name:          "Ready",
+			wantCode:      http.StatusOK,
+			wantDatabase:  "ok",
+			wantTemplates: "ok",
+		},
+		{
+			name:          "Database unavailable",
+			dbErr:         errors.New("connection refused"),
+			wantCode:      http.StatusServiceUnavailable,
+			wantDatabase:  "unavailable",
+			wantTemplates: "ok",
+		},
+		{
+			name:          "No templates",
+			noTemplates:   true,
+			wantCode:      http.StatusServiceUnavailable,
+			wantDatabase:  "ok",
+			wantTemplates: "unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.db = &mocks.DB{Err: tt.dbErr}
+			if tt.noTemplates {
+				app.templateCache = nil
+			}
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, _, body := ts.get(t, "/readyz")
+			assert.Equal(t, code, tt.wantCode)
+
+			var resp struct {
+				Checks map[string]healthCheck `json:"checks"`
+			}
+
+			err := json.Unmarshal([]byte(body), &resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, resp.Checks["database"].Status, tt.wantDatabase)
+			assert.Equal(t, resp.Checks["templates"].Status, tt.wantTemplates)
+		})
+	}
+}
+
+func TestInternalRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.internalAddr = "localhost:4001"
+
+	public := newTestServer(t, app.routes())
+	defer public.Close()
+
+	internal := newTestServer(t, app.internalRoutes())
+	defer internal.Close()
+
+	// The operational endpoints are only served by the internal listener when it is configured.
+	tests := []struct {
+		name     string
+		ts       *testServer
+		urlPath  string
+		wantCode int
+	}{
+		{name: "Internal liveness probe", ts: internal, urlPath: "/healthz", wantCode: http.StatusOK},
+		{name: "Internal readiness probe", ts: internal, urlPath: "/readyz", wantCode: http.StatusOK},
+		{name: "Internal metrics", ts: internal, urlPath: "/debug/vars", wantCode: http.StatusOK},
+		{name: "Internal Prometheus metrics", ts: internal, urlPath: "/metrics", wantCode: http.StatusOK},
+		{name: "Public Prometheus metrics", ts: public, urlPath: "/metrics", wantCode: http.StatusNotFound},
+		{name: "Public liveness probe", ts: public, urlPath: "/healthz", wantCode: http.StatusNotFound},
+		{name: "Public readiness probe", ts: public, urlPath: "/readyz", wantCode: http.StatusNotFound},
+		{name: "Internal home page", ts: internal, urlPath: "/", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := tt.ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
 }
 
 func TestSnippetView(t *testing.T) {
@@ -90,6 +193,92 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		ifNoneMatch string
+		wantCode    int
+		wantBody    string
+	}{
+		{"Valid ID", "/snippet/raw/1", "", http.StatusOK, "An old silent pond..."},
+		{"Current copy", "/snippet/raw/1", strongETag([]byte("An old silent pond...")), http.StatusNotModified, ""},
+		{"Stale copy", "/snippet/raw/1", strongETag([]byte("An old pond...")), http.StatusOK, "An old silent pond..."},
+		{"Non-existent ID", "/snippet/raw/2", "", http.StatusNotFound, ""},
+		{"String ID", "/snippet/raw/foo", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, string(body), tt.wantBody)
+				assert.Equal(t, rs.Header.Get("Content-Type"), "text/plain; charset=utf-8")
+			}
+		})
+	}
+}
+
+func TestSnippetPrint(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Valid ID", "/snippet/print/1", http.StatusOK},
+		{"Non-existent ID", "/snippet/print/2", http.StatusNotFound},
+		{"String ID", "/snippet/print/foo", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.StringContains(t, body, "<h1>An old silent pond</h1>")
+				assert.StringContains(t, body, "<pre><code>An old silent pond...</code></pre>")
+				assert.StringContains(t, body, "https://snippetbox.example.com/snippet/view/1")
+				assert.Equal(t, strings.Contains(body, "<nav>"), false)
+			}
+		})
+	}
+
+	// The snippet page links to the print view.
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, `<a href="/snippet/print/1">Print</a>`)
+}
+
 func TestUserSignup(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
@@ -203,3 +392,1056 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSignupVerification(t *testing.T) {
+	app := newTestApplication(t)
+
+	outbox := &recordingOutboxModel{}
+	app.outbox = outbox
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postFormWithHost(t, "attacker.example.com", "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// A verification link is sent to the new address, pointing at the configured base URL.
+	mails := outbox.mails(t, "verify.tmpl")
+	assert.Equal(t, len(mails), 1)
+	assert.Equal(t, mails[0]["URL"], "https://snippetbox.example.com/user/verify/ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash string
+	}{
+		{
+			name:      "Valid token",
+			urlPath:   "/user/verify/ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Your email address has been verified!",
+		},
+		{
+			name:      "Invalid token",
+			urlPath:   "/user/verify/ZYXWVUTSRQPONMLKJIHGFEDCBA",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "This verification link is invalid or has expired.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			_, _, body := ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestUserSignupModes(t *testing.T) {
+	t.Run("Closed", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.signupMode = signupModeClosed
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/user/signup")
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.StringContains(t, body, "Signups are closed")
+		assert.Equal(t, strings.Contains(body, `<a href="/user/signup">`), false)
+	})
+
+	app := newTestApplication(t)
+	app.config.signupMode = signupModeInvite
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/signup?invite=VALIDINVITECODEABCDEFGHIJK")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<input type="text" id="field-invite" name="invite" value="VALIDINVITECODEABCDEFGHIJK">`)
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		inviteCode string
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "Valid invite",
+			inviteCode: "VALIDINVITECODEABCDEFGHIJK",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Invalid invite",
+			inviteCode: "USEDINVITECODEABCDEFGHIJKL",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "This invite code is invalid, has expired or has already been used",
+		},
+		{
+			name:     "Missing invite",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", "Bob")
+			form.Add("email", "bob@example.com")
+			form.Add("password", "validPa$$word")
+			form.Add("invite", tt.inviteCode)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestContactPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/contact")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName    = "Bob"
+		validEmail   = "bob@example.com"
+		validMessage = "Hello there!"
+		formTag      = `<form action="/contact" method="POST" novalidate>`
+	)
+
+	tests := []struct {
+		name        string
+		userName    string
+		userEmail   string
+		message     string
+		website     string
+		wantCode    int
+		wantFormTag string
+	}{
+		{
+			name:      "Valid submission",
+			userName:  validName,
+			userEmail: validEmail,
+			message:   validMessage,
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Honeypot filled in",
+			userName:  validName,
+			userEmail: validEmail,
+			message:   validMessage,
+			website:   "http://spam.example.com",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:        "Invalid email",
+			userName:    validName,
+			userEmail:   "bob@example.",
+			message:     validMessage,
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("message", tt.message)
+			form.Add("website", tt.website)
+			form.Add("csrf_token", validCSRFToken)
+			code, _, body := ts.postForm(t, "/contact", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid ID",
+			urlPath:  "/user/profile/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/user/profile/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/user/profile/foo",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Own profile when unauthenticated",
+			urlPath:  "/account/profile",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountPasswordUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/password/update")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const formTag = `<form action="/account/password/update" method="POST" novalidate>`
+
+	tests := []struct {
+		name                    string
+		currentPassword         string
+		newPassword             string
+		newPasswordConfirmation string
+		wantCode                int
+		wantBody                string
+	}{
+		{
+			name:                    "Valid submission",
+			currentPassword:         "pa$$word",
+			newPassword:             "newPa$$word",
+			newPasswordConfirmation: "newPa$$word",
+			wantCode:                http.StatusSeeOther,
+		},
+		{
+			name:                    "Wrong current password",
+			currentPassword:         "wrongPa$$word",
+			newPassword:             "newPa$$word",
+			newPasswordConfirmation: "newPa$$word",
+			wantCode:                http.StatusUnprocessableEntity,
+			wantBody:                "Current password is incorrect",
+		},
+		{
+			name:                    "Mismatched confirmation",
+			currentPassword:         "pa$$word",
+			newPassword:             "newPa$$word",
+			newPasswordConfirmation: "otherPa$$word",
+			wantCode:                http.StatusUnprocessableEntity,
+			wantBody:                "Passwords do not match",
+		},
+		{
+			name:                    "Short new password",
+			currentPassword:         "pa$$word",
+			newPassword:             "pa$$",
+			newPasswordConfirmation: "pa$$",
+			wantCode:                http.StatusUnprocessableEntity,
+			wantBody:                formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("currentPassword", tt.currentPassword)
+			form.Add("newPassword", tt.newPassword)
+			form.Add("newPasswordConfirmation", tt.newPasswordConfirmation)
+			form.Add("csrf_token", validCSRFToken)
+			code, _, body := ts.postForm(t, "/account/password/update", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Wrong password",
+			password: "wrongPa$$word",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:     "Empty password",
+			password: "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Valid password",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use a fresh server for each case, since a successful deletion logs the user out.
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/account/delete")
+
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			code, _, body := ts.postForm(t, "/account/delete", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		expires      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			title:        "O snail",
+			content:      "Climb Mount Fuji, but slowly, slowly!",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:     "Empty title",
+			title:    "",
+			content:  "Climb Mount Fuji, but slowly, slowly!",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Blocked word in title",
+			title:    "Buy SPAM now",
+			content:  "Climb Mount Fuji, but slowly, slowly!",
+			expires:  "7",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field contains a word which is not allowed",
+		},
+		{
+			name:     "Invalid expiry",
+			title:    "O snail",
+			content:  "Climb Mount Fuji, but slowly, slowly!",
+			expires:  "3",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must equal 1, 7, or 365",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", validCSRFToken)
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetEditPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<input type="hidden" name="version" value="1">`)
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		title        string
+		version      string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			urlPath:      "/snippet/edit/1",
+			title:        "O snail",
+			version:      "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:     "Edit conflict",
+			urlPath:  "/snippet/edit/1",
+			title:    "O snail",
+			version:  "0",
+			wantCode: http.StatusConflict,
+			wantBody: "This snippet has been changed since you started editing it.",
+		},
+		{
+			name:     "Empty title",
+			urlPath:  "/snippet/edit/1",
+			title:    "",
+			version:  "1",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/edit/2",
+			title:    "O snail",
+			version:  "1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "Climb Mount Fuji, but slowly, slowly!")
+			form.Add("version", tt.version)
+			form.Add("csrf_token", validCSRFToken)
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetPreview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid token",
+			urlPath:  "/preview/ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Unknown token",
+			urlPath:  "/preview/ZYXWVUTSRQPONMLKJIHGFEDCBA",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetPreviewCreatePostForgedHost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/1")
+
+	form := url.Values{}
+	form.Add("hours", "24")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postFormWithHost(t, "attacker.example.com", "/snippet/previews/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The link shown to the user points at the configured base URL, whatever the request's Host header says.
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Preview link created: https://snippetbox.example.com/preview/ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+func TestAccountSettingsPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/settings")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		userName  string
+		userEmail string
+		timezone  string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Unchanged email",
+			userName:  "Alice Smith",
+			userEmail: "alice@example.com",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Changed email",
+			userName:  "Alice",
+			userEmail: "alice@example.org",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Duplicate email",
+			userName:  "Alice",
+			userEmail: "dupe@example.com",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Email address is already in use",
+		},
+		{
+			name:      "Empty name",
+			userName:  "",
+			userEmail: "alice@example.com",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field cannot be blank",
+		},
+		{
+			name:      "Time zone",
+			userName:  "Alice",
+			userEmail: "alice@example.com",
+			timezone:  "Europe/Paris",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Invalid time zone",
+			userName:  "Alice",
+			userEmail: "alice@example.com",
+			timezone:  "Mars/Olympus_Mons",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field must be a time zone name",
+		},
+		{
+			name:      "Server time zone",
+			userName:  "Alice",
+			userEmail: "alice@example.com",
+			timezone:  "Local",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field must be a time zone name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("timezone", tt.timezone)
+			form.Add("csrf_token", validCSRFToken)
+			code, _, body := ts.postForm(t, "/account/settings", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountSettingsPostForgedHost(t *testing.T) {
+	app := newTestApplication(t)
+
+	outbox := &recordingOutboxModel{}
+	app.outbox = outbox
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/settings")
+
+	form := url.Values{}
+	form.Add("name", "Alice")
+	form.Add("email", "alice@example.org")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postFormWithHost(t, "attacker.example.com", "/account/settings", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The confirmation link points at the configured base URL, whatever the request's Host header says.
+	mails := outbox.mails(t, "emailchange.tmpl")
+	assert.Equal(t, len(mails), 1)
+	assert.Equal(t, mails[0]["URL"], "https://snippetbox.example.com/user/confirm-email/ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+func TestUserConfirmEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantFlash string
+	}{
+		{
+			name:      "Valid token",
+			urlPath:   "/user/confirm-email/ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "Your email address has been changed!",
+		},
+		{
+			name:      "Invalid token",
+			urlPath:   "/user/confirm-email/ZYXWVUTSRQPONMLKJIHGFEDCBA",
+			wantCode:  http.StatusSeeOther,
+			wantFlash: "This confirmation link is invalid or has expired.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			_, _, body := ts.get(t, "/")
+			assert.StringContains(t, body, tt.wantFlash)
+		})
+	}
+}
+
+func TestHomeLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Default", "/", http.StatusOK},
+		{"Valid limit", "/?limit=5", http.StatusOK},
+		{"Maximum limit", "/?limit=100", http.StatusOK},
+		{"Zero limit", "/?limit=0", http.StatusBadRequest},
+		{"Limit too large", "/?limit=10000", http.StatusBadRequest},
+		{"Non-integer limit", "/?limit=ten", http.StatusBadRequest},
+		{"Cursor", "/?cursor=" + encodeCursor(1), http.StatusOK},
+		{"Invalid cursor", "/?cursor=foo", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestUserOAuthLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Enabled provider", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/user/oauth/github")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		location, err := url.Parse(header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, location.Host, "github.com")
+		assert.Equal(t, location.Query().Get("client_id"), "github-id")
+		assert.Equal(t, location.Query().Get("redirect_uri"), "https://snippetbox.example.com/user/oauth/github/callback")
+		assert.Equal(t, location.Query().Get("state") != "", true)
+	})
+
+	t.Run("Disabled provider", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/user/oauth/google")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Callback with mismatched state", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/user/oauth/github")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, _, _ = ts.get(t, "/user/oauth/github/callback?state=wrong&code=abc")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("Callback without login", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/user/oauth/github/callback?state=&code=abc")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	t.Run("Login page links", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/login")
+		assert.StringContains(t, body, `<a href="/user/oauth/github">GitHub</a>`)
+	})
+}
+
+func TestUserLoginLastLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// The first page after logging in shows the details of the previous login in a flash message, along with a
+	// warning about the failed attempts since.
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, `<div class="flash flash-info">Welcome back! Your last login was from 192.0.2.1 on 17 Mar 2024 at 10:15.</div>`)
+	assert.StringContains(t, body, `<div class="flash flash-warning">There have been 2 failed login attempts since then.</div>`)
+
+	// The account page shows the last login too.
+	_, _, body = ts.get(t, "/account/profile")
+	assert.StringContains(t, body, "Last login: 17 Mar 2024 at 10:15 from 192.0.2.1")
+}
+
+func TestUserLoginPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid credentials",
+			email:    "alice@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Wrong password",
+			email:    "alice@example.com",
+			password: "wrong",
+			wantCode: http.StatusOK,
+			wantBody: "Incorrect email or password",
+		},
+		{
+			name:     "Locked account",
+			email:    "locked@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusOK,
+			wantBody: "This account has been locked after too many failed login attempts.",
+		},
+		{
+			name:     "Deactivated account",
+			email:    "deactivated@example.com",
+			password: "pa$$word",
+			wantCode: http.StatusOK,
+			wantBody: "This account has been deactivated.",
+		},
+		{
+			name:     "Blank password",
+			email:    "alice@example.com",
+			password: "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserLoginRememberMe(t *testing.T) {
+	tests := []struct {
+		name           string
+		rememberMe     string
+		wantPersistent bool
+	}{
+		{
+			name:           "Ticked",
+			rememberMe:     "true",
+			wantPersistent: true,
+		},
+		{
+			name:           "Not ticked",
+			rememberMe:     "",
+			wantPersistent: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.rememberMeLifetime = 30 * 24 * time.Hour
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+			assert.StringContains(t, body, `name="rememberMe"`)
+
+			form := url.Values{}
+			form.Add("email", "alice@example.com")
+			form.Add("password", "pa$$word")
+			form.Add("rememberMe", tt.rememberMe)
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			var session *http.Cookie
+			for _, cookie := range (&http.Response{Header: header}).Cookies() {
+				if cookie.Name == app.sessionManager.Cookie.Name {
+					session = cookie
+				}
+			}
+
+			if session == nil {
+				t.Fatal("no session cookie was set")
+			}
+
+			// A remembered session's cookie outlives the browser session, until the end of the remember me
+			// lifetime. Otherwise the cookie is deleted when the browser is closed.
+			assert.Equal(t, session.MaxAge > 0, tt.wantPersistent)
+			assert.Equal(t, !session.Expires.IsZero(), tt.wantPersistent)
+
+			if tt.wantPersistent {
+				assert.Equal(t, session.Expires.After(time.Now().Add(29*24*time.Hour)), true)
+			}
+		})
+	}
+}
+
+func TestAccountSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// Add the mock user's other session to the session store, so that it is treated as active.
+	err := app.sessionManager.Store.Commit("other-device-token", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Firefox on Windows")
+	assert.StringContains(t, body, "198.51.100.7")
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Own session",
+			urlPath:  "/account/sessions/1/revoke",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Someone else's session",
+			urlPath:  "/account/sessions/2/revoke",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	// The revoked session should have been removed from the session store.
+	_, found, err := app.sessionManager.Store.Find("other-device-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, found, false)
+}
+
+func TestAccountReauthenticate(t *testing.T) {
+	app := newTestApplication(t)
+
+	// With an empty window, every sensitive action requires the password to be entered again.
+	app.config.sudoWindow = 0
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, header, _ := ts.get(t, "/account/delete")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/reauthenticate")
+
+	_, _, body := ts.get(t, "/account/reauthenticate")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:     "Wrong password",
+			password: "wrong",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Password is incorrect",
+		},
+		{
+			name:         "Correct password",
+			password:     "pa$$word",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/delete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/account/reauthenticate", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
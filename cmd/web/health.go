@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// How long the readiness check waits for the database to respond.
+const readinessDBTimeout = 2 * time.Second
+
+// The version of the application, which can be set when building it with:
+//
+//	go build -ldflags "-X main.version=v1.2.3" ./cmd/web
+//
+// If it isn't set, the VCS revision recorded by the Go toolchain is used instead.
+var version string
+
+// Define a pinger interface, which is satisfied by *sql.DB, so that the readiness check can be tested without a
+// database.
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Define the result of one of the readiness checks.
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Function used to return the version of the application.
+func appVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// Report that the application is alive, for liveness probes. This doesn't check the database, since restarting the
+// application won't fix a database outage.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	err := app.writeJSON(w, http.StatusOK, envelope{
+		"status":  "ok",
+		"version": appVersion(),
+		"uptime":  time.Since(app.started).Round(time.Second).String(),
+	}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Report whether the application is ready to serve requests, for readiness probes: the database must respond
+// within readinessDBTimeout and the templates must be loaded. An HTTP 503 Service Unavailable response is sent if
+// any of the checks fail, along with the result of each one.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]healthCheck{
+		"database":  {Status: "ok"},
+		"templates": {Status: "ok"},
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessDBTimeout)
+	defer cancel()
+
+	if err := app.db.PingContext(ctx); err != nil {
+		checks["database"] = healthCheck{Status: "unavailable", Error: err.Error()}
+	}
+
+	if len(app.templateCache) == 0 {
+		checks["templates"] = healthCheck{Status: "unavailable", Error: "no templates are loaded"}
+	}
+
+	status, code := "ok", http.StatusOK
+
+	for _, check := range checks {
+		if check.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	err := app.writeJSON(w, code, envelope{
+		"status":  status,
+		"checks":  checks,
+		"version": appVersion(),
+		"uptime":  time.Since(app.started).Round(time.Second).String(),
+	}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -2,77 +2,311 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	// Generated the formatted text for the provided server error and the debugging stack trace for the
-	// call sequence which produced that error.
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	// If the request failed because it ran out of time, tell the client so rather than reporting an error.
+	if timedOut(r) {
+		app.requestTimedOut(w, r)
+		return
+	}
+
+	// Errors which mean that the request conflicts with the current state of the database (e.g. it refers to a
+	// record which another request has just deleted) are the client's to resolve, so they aren't server errors.
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w, r)
+		return
+	case errors.Is(err, models.ErrConflict) || errors.Is(err, models.ErrForeignKey):
+		app.logger.WarnContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+		app.clientError(w, r, http.StatusConflict)
+		return
+	}
 
-	// Log the server error using our custom error logger.
-	app.errorLog.Output(2, trace)
+	// Log the server error along with the stack trace of the handler which called serverError().
+	app.logServerError(r, err)
 
 	// Send a generic HTTP 500 Internal Server Error response to the client.
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.errorResponse(w, r, http.StatusInternalServerError, "", nil)
+}
+
+// Function used to log a server error, along with the request which produced it and the debugging stack trace for
+// the call sequence which handled it.
+func (app *application) logServerError(r *http.Request, err error) {
+	app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI(), "trace", string(debug.Stack()))
 }
 
-func (app *application) clientError(w http.ResponseWriter, status int) {
+// Define an errorReport type to hold the details of a server-side panic, along with the request which caused it.
+type errorReport struct {
+	Err       error
+	Method    string
+	Path      string
+	UserID    int
+	RequestID string
+	Stack     []byte
+}
+
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
 	// Send an HTTP response associated with the specified status code to the client.
-	http.Error(w, http.StatusText(status), status)
+	app.errorResponse(w, r, status, "", nil)
 }
 
 // Wrapper around clientError helper for the particular case in which we want to return an
-// HTTP 400 Not Found response to the client.
-func (app *application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+// HTTP 404 Not Found response to the client.
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// Wrapper around the errorResponse helper for the case in which a request failed validation, sending an HTTP 422
+// Unprocessable Entity response with the error for each invalid field.
+func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, "", fieldErrors)
+}
+
+// Define the body of JSON error responses, which are sent wrapped in an envelope as {"error": {...}}. Fields holds
+// the error message for each invalid field when a request fails validation. RequestID is only set for server
+// errors, so that the client can quote it when reporting the problem.
+type jsonError struct {
+	Status    int               `json:"status"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// Function used to send an error response with the given status code and message, which defaults to the status
+// text if it is empty. Requests which want JSON (see wantsJSON) are sent a JSON error, including the error for each
+// invalid field if there are any; other requests are sent the error page for the status (see renderError). Server
+// errors include the request ID as a reference, which can be used to find the details in the log.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message string, fieldErrors map[string]string) {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	var reference string
+	if status >= http.StatusInternalServerError {
+		reference = requestIDFromContext(r.Context())
+	}
+
+	if !wantsJSON(r) {
+		app.renderError(w, r, status, message, reference)
+		return
+	}
+
+	body := jsonError{
+		Status:    status,
+		Message:   message,
+		Fields:    fieldErrors,
+		RequestID: reference,
+	}
+
+	err := app.writeJSON(w, status, envelope{"error": body}, nil)
+	if err != nil {
+		app.logServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// Map the statuses which have their own error page to the page's template. Errors with any other status are sent
+// as plain text.
+var errorPages = map[int]string{
+	http.StatusForbidden:           "forbidden.tmpl",
+	http.StatusNotFound:            "notfound.tmpl",
+	http.StatusTooManyRequests:     "ratelimited.tmpl",
+	http.StatusInternalServerError: "error.tmpl",
+}
+
+// Function used to send the error page for the status to a browser. Like the other error pages, it is rendered
+// without the session data, since errors can be sent before the session has been loaded (e.g. by recoverPanic, or
+// for requests which don't match a route). If there's no page for the status, or the page can't be rendered, the
+// message is sent as plain text instead.
+func (app *application) renderError(w http.ResponseWriter, r *http.Request, status int, message, reference string) {
+	if page, ok := errorPages[status]; ok {
+		data := app.newBareTemplateData()
+		data.RequestID = reference
+
+		buf, err := app.executePage(r, page, data)
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			buf.WriteTo(w)
+			return
+		}
+
+		app.logServerError(r, err)
+	}
+
+	if reference != "" {
+		message += "\nreference: " + reference
+	}
+
+	http.Error(w, message, status)
+}
+
+// Function used to decide whether an error response should be sent as JSON rather than plain text. This is the
+// case for all requests to the JSON API, and for other requests whose Accept header prefers JSON to HTML.
+func wantsJSON(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+
+	var jsonQ, htmlQ float64
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(s, 64)
+				if err != nil {
+					continue
+				}
+			}
+
+			switch mediaType {
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			case "text/html":
+				htmlQ = max(htmlQ, q)
+			}
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
 }
 
 // Function used to initialize a new templateData struct. As of now, all values are zeroed beside CurrentYear.
 func (app *application) newTemplateData(r *http.Request) *templateData {
+	// Look up the request's route, for the nav bar and breadcrumbs.
+	route, _ := matchRoute(r.URL.Path)
+
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flashes:             app.popFlashes(r),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.sessionManager.GetInt(r.Context(), "authenticatedUserID"),
+		IsAdmin:             models.HasRole(app.userRole(r), models.RoleAdmin),
+		CSRFToken:           nosurf.Token(r),
+		FormStarted:         app.spam.stamp(time.Now()),
+		OAuthProviders:      app.oauthProviderNames(),
+		SignupMode:          app.config.signupMode,
+		BaseURL:             app.config.baseURL,
+		SiteName:            app.config.siteName,
+		SiteTagline:         app.config.siteTagline,
+		LogoURL:             app.branding.logoURL(),
+		CustomFooter:        app.branding.footer,
+		Maintenance:         app.maintenance.Load(),
+		Theme:               app.theme(r),
+		Themes:              themes,
+		Timezone:            app.timezone(r),
+		RouteName:           route.Name,
+		Section:             route.Section,
+		Breadcrumbs:         breadcrumbs(route),
+		Meta:                pageMeta{URL: app.config.baseURL + r.URL.Path},
+		Feeds:               feedLinks(app.config.siteName, "/feed"),
 	}
 }
 
-// Function used to help render a page being served at the client.
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
-	// Retrieve the template set for the specified page.
-	ts, ok := app.templateCache[page]
+// Function used to return the data for pages which are rendered without the session data (e.g. error pages), which
+// only holds what the base layout needs.
+func (app *application) newBareTemplateData() *templateData {
+	return &templateData{
+		CurrentYear:  time.Now().Year(),
+		SiteName:     app.config.siteName,
+		SiteTagline:  app.config.siteTagline,
+		LogoURL:      app.branding.logoURL(),
+		CustomFooter: app.branding.footer,
+	}
+}
 
-	// If the requested page does not exist and our handler does not properly respond to this situation,
-	// indicate that a server error has occurred.
-	if !ok {
-		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
+// Function used to help render a page being served at the client. If the page can't be rendered, a plain text
+// 500 error is sent instead, since pages are only rendered for browsers.
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
+	buf, err := app.executePage(r, page, data)
+	if err != nil {
+		app.logServerError(r, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
+	// If the template is executed and written to the buffer without errors, proceed to setting the response header
+	// and writing the contents of the buffer to the http.ResponseWriter.
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// Function used to execute the template set for a page into a buffer, so that nothing has been written to the
+// response if it fails.
+func (app *application) executePage(r *http.Request, page string, data *templateData) (*bytes.Buffer, error) {
+	buf, err := app.executeTemplate(r, page, "base", data)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.config.minifyHTML {
+		return bytes.NewBuffer(minifyHTML(buf.Bytes())), nil
+	}
+
+	return buf, nil
+}
+
+// Function used to execute the named template from the template set for a page into a buffer, e.g. "base" for the
+// whole page, or a fragment of it (see renderFragment).
+func (app *application) executeTemplate(r *http.Request, page, name string, data *templateData) (*bytes.Buffer, error) {
+	// Retrieve the template sets, which are parsed again in development mode.
+	templates, err := app.templates()
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the template set for the specified page.
+	ts, ok := templates[page]
+	if !ok {
+		return nil, fmt.Errorf("the template %s does not exist", page)
+	}
+
+	// Every page is translated into the request's locale, including pages whose data wasn't made by
+	// newTemplateData(), such as the error pages.
+	if data.Locale == nil {
+		data.Locale = app.localizer(r)
+		data.Languages = app.translations.Languages()
+	}
+
 	// Instead of writing the template straight to the http.ResponseWriter, write it to a byte buffer first.
-	// If there is an error in executing the template, we can call the serverError() helper and return, instead of
+	// If there is an error in executing the template, we can send an error response and return, instead of
 	// writing the response to the http.ResponseWriter.
 	buf := new(bytes.Buffer)
 
-	err := ts.ExecuteTemplate(buf, "base", data)
+	err = ts.ExecuteTemplate(buf, name, data)
 	if err != nil {
-		app.serverError(w, err)
-		return
+		return nil, err
 	}
 
-	// If the template is executed and written to the buffer without errors, proceed to setting the response header
-	// and writing the contents of the buffer to the http.ResponseWriter.
-	w.WriteHeader(status)
-	buf.WriteTo(w)
+	return buf, nil
 }
 
 // Function to decode HTML request form data into a target destination.
@@ -112,3 +346,480 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// Function used to return the role of the authenticated user, or an empty string if the user is not authenticated.
+func (app *application) userRole(r *http.Request) string {
+	role, _ := r.Context().Value(userRoleContextKey).(string)
+	return role
+}
+
+// Function used to return the ID of the user authenticated by an API token, or zero if the request was not
+// authenticated by one (see authenticateAPI).
+func (app *application) apiUserID(r *http.Request) int {
+	id, _ := r.Context().Value(apiUserIDContextKey).(int)
+	return id
+}
+
+// Function used to send an HTTP 401 Unauthorized response to an API client, asking for a bearer token.
+func (app *application) invalidAPIToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.clientError(w, r, http.StatusUnauthorized)
+}
+
+// Function used to log in the user with the specified ID on the current session. The session token is renewed
+// (since the user's authentication state has changed), and the session is recorded against the user so that it
+// appears in their list of active sessions.
+func (app *application) logIn(r *http.Request, userID int) error {
+	// It's good practice to generate a new session ID when the authentication state or privilege level changes
+	// for the user, e.g. login and logout operations.
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// Add the ID of the current user to the session so that they are considered "logged in", along with the
+	// time (as a Unix timestamp, which the session codec can store), which is used to check whether they have
+	// authenticated recently (see requireRecentAuth).
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+	app.sessionManager.Put(r.Context(), "authenticatedAt", time.Now().Unix())
+
+	ip := clientIP(r)
+
+	return app.sessions.Insert(r.Context(), app.sessionManager.Token(r.Context()), userID, ip, r.UserAgent())
+}
+
+// Function used to find the tokens of every session in the session store which belongs to the specified user.
+func (app *application) userSessionTokens(r *http.Request, userID int) ([]string, error) {
+	var tokens []string
+
+	// Iterate() loads each session in the store into a new context and calls the function with it.
+	err := app.sessionManager.Iterate(r.Context(), func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") == userID {
+			tokens = append(tokens, app.sessionManager.Token(ctx))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Function used to fetch the snippet with the ID given in the URL, making sure that the client can see it: private
+// snippets can only be viewed by their owner (or through a preview link), so for anyone else an HTTP 404 Not Found
+// response is sent, as though the snippet does not exist. If false is returned, the calling handler should return
+// immediately.
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	return app.viewableSnippetByID(w, r, params.ByName("id"))
+}
+
+// Function used to fetch the snippet with the given ID, as viewableSnippet() does, for routes where the ID is only
+// part of a URL parameter (e.g. "1.png").
+func (app *application) viewableSnippetByID(w http.ResponseWriter, r *http.Request, s string) (*models.Snippet, bool) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.Private && snippet.UserID != app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Function used to fetch the snippet with the ID given in the URL, making sure that it belongs to the authenticated
+// user. If the snippet does not exist or belongs to someone else, an HTTP 404 Not Found response is sent and
+// false is returned, in which case the calling handler should return immediately.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	// The snippet is read from the primary database, since it is about to be changed, and a snippet which the user
+	// has just created or edited may not have reached the replica yet.
+	snippet, err := app.snippets.Get(models.WithPrimary(r.Context()), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.UserID != app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Function used to queue an email, rendered from the given template in the mailer's templates directory, to be sent
+// to the recipient by the outbox dispatcher.
+func (app *application) queueMail(ctx context.Context, recipient, template string, data map[string]string) error {
+	job, err := models.NewJob(models.JobKindMail, mailJob{
+		Recipient: recipient,
+		Template:  template,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.outbox.Enqueue(ctx, job)
+}
+
+// Function used to issue a new verification token for a user and queue an email containing the verification link
+// to be sent to the given address.
+func (app *application) sendVerificationEmail(ctx context.Context, userID int, name, email string) error {
+	token, err := app.tokens.New(ctx, userID, 3*24*time.Hour, models.ScopeVerification)
+	if err != nil {
+		return err
+	}
+
+	return app.queueMail(ctx, email, "verify.tmpl", map[string]string{
+		"Name": name,
+		"URL":  fmt.Sprintf("%s/user/verify/%s", app.config.baseURL, token),
+	})
+}
+
+// Function used to send a JSON response to the client with the given status code and any additional headers.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	// Encode the data as indented JSON, so that responses are easy to read in a terminal.
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// Define a jsonBodyError type for problems with the JSON body of a request which are the client's fault. The status
+// is the HTTP status code to respond with, and the message is safe to show to the client.
+type jsonBodyError struct {
+	status  int
+	message string
+}
+
+func (e *jsonBodyError) Error() string {
+	return e.message
+}
+
+// Function to decode the JSON body of an API request into a target destination, in the same way that
+// decodePostForm() decodes HTML forms. The body must have a JSON content type, be at most maxBytes long, and hold a
+// single JSON value with no fields that dst doesn't have. Problems with the body are returned as a *jsonBodyError,
+// which invalidJSON() turns into an error response; any other error is a server error.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	// Only accept JSON bodies. Besides being the documented format, this means that requests authenticated by a
+	// session cookie can't be forged by a form on another site, since cross-origin requests with a JSON content
+	// type have to be allowed by a CORS preflight first.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return &jsonBodyError{http.StatusUnsupportedMediaType, "The request body must be JSON"}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var (
+			syntaxError           *json.SyntaxError
+			unmarshalTypeError    *json.UnmarshalTypeError
+			invalidUnmarshalError *json.InvalidUnmarshalError
+			maxBytesError         *http.MaxBytesError
+		)
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return &jsonBodyError{http.StatusBadRequest, fmt.Sprintf("The request body contains badly-formed JSON (at character %d)", syntaxError.Offset)}
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return &jsonBodyError{http.StatusBadRequest, "The request body contains badly-formed JSON"}
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return &jsonBodyError{http.StatusBadRequest, fmt.Sprintf("The request body contains the wrong type for the %q field", unmarshalTypeError.Field)}
+			}
+			return &jsonBodyError{http.StatusBadRequest, fmt.Sprintf("The request body contains the wrong JSON type (at character %d)", unmarshalTypeError.Offset)}
+		case errors.Is(err, io.EOF):
+			return &jsonBodyError{http.StatusBadRequest, "The request body must not be empty"}
+		// The json package doesn't have a typed error for unknown fields, so we have to check the error message.
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return &jsonBodyError{http.StatusBadRequest, fmt.Sprintf("The request body contains the unknown field %s", field)}
+		case errors.As(err, &maxBytesError):
+			return &jsonBodyError{http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", maxBytesError.Limit)}
+		// As with decodePostForm(), an invalid target destination is a bug in the handler, so we panic.
+		case errors.As(err, &invalidUnmarshalError):
+			panic(err)
+		default:
+			return err
+		}
+	}
+
+	// Make sure that there is nothing after the JSON value, e.g. a second value.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return &jsonBodyError{http.StatusBadRequest, "The request body must only contain a single JSON value"}
+	}
+
+	return nil
+}
+
+// Function used to send the error response for an error returned by readJSON().
+func (app *application) invalidJSON(w http.ResponseWriter, r *http.Request, err error) {
+	var bodyError *jsonBodyError
+	if errors.As(err, &bodyError) {
+		app.errorResponse(w, r, bodyError.status, bodyError.message, nil)
+		return
+	}
+
+	app.serverError(w, r, err)
+}
+
+// Function used to compute a strong ETag for a response body, from a hash of its bytes.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Function used to return the time at which a snippet fetched by Get() was last modified, for notModified(). The
+// time at which a snippet was edited isn't recorded, so it is only known for snippets which haven't been edited.
+func snippetModified(s *models.Snippet) time.Time {
+	if s.Version > 1 {
+		return time.Time{}
+	}
+
+	return s.Created
+}
+
+// Function used to support conditional GET requests for a resource with the given ETag and last modified time. The
+// ETag and Last-Modified headers are set on the response; any Cache-Control header should be set by the caller
+// beforehand, since it has to be sent with 304 responses too, as should the Content-Type header, so that the
+// compress middleware weakens the ETag of 304 responses in the same way as it does for compressed responses. If the request's If-None-Match header matches the ETag (or, when there
+// is no If-None-Match header, the resource hasn't changed since the time in If-Modified-Since), an HTTP 304 Not
+// Modified response is sent and true is returned, in which case the calling handler should return immediately. If
+// the last modified time isn't known, modified should be the zero time, and only the ETag is used.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// If-None-Match uses the weak comparison, so a W/ prefix on the client's copy of the ETag is ignored.
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() {
+		// HTTP dates only have a resolution of one second.
+		if !modified.Truncate(time.Second).After(ims) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Function used to read the page size for a listing from the ?limit= query string parameter. If the parameter is
+// missing, the configured default page size is returned. If it is not an integer between 1 and the configured
+// maximum page size, an error is added to the validator.
+func (app *application) readLimit(r *http.Request, v *validator.Validator) int {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return app.config.defaultPageSize
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError("limit", "must be an integer value")
+		return app.config.defaultPageSize
+	}
+
+	v.CheckField(validator.Between(limit, 1, app.config.maxPageSize), "limit", fmt.Sprintf("must be between 1 and %d", app.config.maxPageSize))
+
+	return limit
+}
+
+// Function used to encode the ID of the last snippet on a page as an opaque cursor, which is passed back to fetch
+// the next page. Clients shouldn't depend on what's in a cursor, so that it can be changed later.
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// Function used to read a cursor created by encodeCursor() from the query string, returning the ID encoded in it,
+// or 0 if the parameter is missing or empty. If the cursor isn't valid, an error is recorded in v and 0 is
+// returned.
+func readCursor(qs url.Values, key string, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return 0
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		v.AddFieldError(key, "must be a cursor returned by a previous request")
+		return 0
+	}
+
+	id, err := strconv.Atoi(string(b))
+	if err != nil || id < 1 {
+		v.AddFieldError(key, "must be a cursor returned by a previous request")
+		return 0
+	}
+
+	return id
+}
+
+// Function used to cut a page of snippets, fetched with one more than the page size to find out whether there's
+// another page, down to the page size. The cursor for the next page is returned, or "" if this is the last page.
+func nextCursor(snippets []*models.Snippet, pageSize int) ([]*models.Snippet, string) {
+	if len(snippets) <= pageSize {
+		return snippets, ""
+	}
+
+	snippets = snippets[:pageSize]
+
+	return snippets, encodeCursor(snippets[pageSize-1].ID)
+}
+
+// Function used to read the search term and page number for a paginated listing from the query string. Any
+// problems with the page number are recorded in v. The page size is the default page size.
+func (app *application) readFilters(r *http.Request, v *validator.Validator) models.Filters {
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Search:   strings.TrimSpace(qs.Get("q")),
+		Page:     readInt(qs, "page", 1, v),
+		PageSize: app.config.defaultPageSize,
+	}
+
+	v.CheckField(validator.Between(filters.Page, 1, 10_000_000), "page", "must be between 1 and 10000000")
+
+	return filters
+}
+
+// Function used to read the page, page size, sort order and filters for a paginated API listing from the query
+// string. The sort order must be one of the values in sortSafelist, and defaults to defaultSort. Any problems with
+// the parameters are recorded in v.
+func (app *application) readAPIFilters(r *http.Request, v *validator.Validator, defaultSort string, sortSafelist ...string) models.Filters {
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:         readInt(qs, "page", 1, v),
+		PageSize:     readInt(qs, "page_size", app.config.defaultPageSize, v),
+		Sort:         readString(qs, "sort", defaultSort),
+		SortSafelist: sortSafelist,
+		Author:       readInt(qs, "author", 0, v),
+		CreatedAfter: readTime(qs, "created_after", time.Time{}, v),
+	}
+
+	v.CheckField(validator.Between(filters.Page, 1, 10_000_000), "page", "must be between 1 and 10000000")
+	v.CheckField(validator.Between(filters.PageSize, 1, app.config.maxPageSize), "page_size", fmt.Sprintf("must be between 1 and %d", app.config.maxPageSize))
+	v.CheckField(validator.PermittedValue(filters.Sort, sortSafelist...), "sort", "invalid sort value")
+	v.CheckField(filters.Author >= 0, "author", "must be a user ID")
+
+	return filters
+}
+
+// Function used to read a string from the query string, returning defaultValue if the parameter is missing.
+func readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// Function used to read an integer from the query string, returning defaultValue if the parameter is missing. If
+// the parameter isn't an integer, an error is recorded in v and defaultValue is returned.
+func readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// Function used to read an RFC 3339 timestamp (e.g. 2024-03-17T10:15:00Z) from the query string, returning
+// defaultValue if the parameter is missing. If the parameter isn't a valid timestamp, an error is recorded in v and
+// defaultValue is returned.
+func readTime(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddFieldError(key, "must be an RFC 3339 timestamp, e.g. 2024-03-17T10:15:00Z")
+		return defaultValue
+	}
+
+	return t
+}
+
+// Function used to return the path of the page which a form was submitted from (e.g. the language picker), taken
+// from the Referer header. Only pages on this site are returned, so that the redirect can't send visitors elsewhere;
+// otherwise the home page is returned.
+func refererRedirect(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Host != r.Host || u.Path == "" || u.Path[0] != '/' {
+		return "/"
+	}
+
+	u.Scheme, u.Host, u.User, u.Fragment = "", "", nil, ""
+
+	return u.RequestURI()
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		urlPath string
+		accept  string
+		want    bool
+	}{
+		{"API route", "/api/v1/snippets", "", true},
+		{"Page without Accept", "/snippet/view/1", "", false},
+		{"Browser", "/snippet/view/1", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"JSON", "/snippet/view/1", "application/json", true},
+		{"JSON preferred", "/snippet/view/1", "text/html;q=0.5, application/json", true},
+		{"HTML preferred", "/snippet/view/1", "application/json;q=0.5, text/html", false},
+		{"JSON refused", "/snippet/view/1", "application/json;q=0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, wantsJSON(r), tt.want)
+		})
+	}
+}
+
+func TestErrorResponseFormat(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		method          string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"Error page", http.MethodGet, "", http.StatusNotFound, "text/html; charset=utf-8", "<h2>Page not found</h2>"},
+		{"JSON", http.MethodGet, "application/json", http.StatusNotFound, "application/json", `"error": {`},
+		{"Plain text", http.MethodPut, "", http.StatusMethodNotAllowed, "text/plain; charset=utf-8", "Method Not Allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+"/snippet/view/2", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantStatus)
+			assert.Equal(t, rs.Header.Get("Content-Type"), tt.wantContentType)
+			assert.StringContains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		broken   bool
+		wantType string
+		wantBody string
+	}{
+		{"Forbidden", http.StatusForbidden, false, "text/html; charset=utf-8", "<h2>Access denied</h2>"},
+		{"Not found", http.StatusNotFound, false, "text/html; charset=utf-8", "<h2>Page not found</h2>"},
+		{"Too many requests", http.StatusTooManyRequests, false, "text/html; charset=utf-8", "<h2>Slow down</h2>"},
+		{"Server error", http.StatusInternalServerError, false, "text/html; charset=utf-8", "<h2>Something went wrong</h2>"},
+		{"No page", http.StatusConflict, false, "text/plain; charset=utf-8", "Conflict"},
+		{"Broken page", http.StatusNotFound, true, "text/plain; charset=utf-8", "Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			// Without any templates, the error pages can't be rendered, so the error is sent as plain text.
+			if tt.broken {
+				app.templateCache = map[string]*template.Template{}
+			}
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			app.errorResponse(rr, r, tt.status, "", nil)
+
+			assert.Equal(t, rr.Code, tt.status)
+			assert.Equal(t, rr.Header().Get("Content-Type"), tt.wantType)
+			assert.StringContains(t, rr.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestServerErrorStatus(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"Unexpected error", errors.New("boom"), http.StatusInternalServerError},
+		{"No record", fmt.Errorf("getting snippet: %w", models.ErrNoRecord), http.StatusNotFound},
+		{"Conflict", fmt.Errorf("inserting word: %w", models.ErrConflict), http.StatusConflict},
+		{"Foreign key", fmt.Errorf("inserting link: %w", models.ErrForeignKey), http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			app.serverError(rr, r, tt.err)
+
+			assert.Equal(t, rr.Code, tt.wantStatus)
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	etag := `"0123456789abcdef"`
+
+	tests := []struct {
+		name            string
+		method          string
+		ifNoneMatch     string
+		ifModifiedSince string
+		want            bool
+	}{
+		{"No conditions", http.MethodGet, "", "", false},
+		{"Matching ETag", http.MethodGet, etag, "", true},
+		{"Weak matching ETag", http.MethodGet, "W/" + etag, "", true},
+		{"ETag in list", http.MethodGet, `"other", ` + etag, "", true},
+		{"Wildcard", http.MethodGet, "*", "", true},
+		{"Different ETag", http.MethodGet, `"other"`, "", false},
+		{"Different ETag takes precedence", http.MethodGet, `"other"`, "Fri, 02 Jan 2026 03:04:05 GMT", false},
+		{"Same modification time", http.MethodGet, "", "Fri, 02 Jan 2026 03:04:05 GMT", true},
+		{"Later modification time", http.MethodGet, "", "Sat, 03 Jan 2026 00:00:00 GMT", true},
+		{"Earlier modification time", http.MethodGet, "", "Thu, 01 Jan 2026 00:00:00 GMT", false},
+		{"Invalid modification time", http.MethodGet, "", "yesterday", false},
+		{"Unsafe method", http.MethodPost, etag, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(tt.method, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			if tt.ifModifiedSince != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+
+			assert.Equal(t, notModified(rr, r, etag, modified), tt.want)
+			assert.Equal(t, rr.Header().Get("ETag"), etag)
+			assert.Equal(t, rr.Header().Get("Last-Modified"), "Fri, 02 Jan 2026 03:04:05 GMT")
+
+			if tt.want {
+				assert.Equal(t, rr.Code, http.StatusNotModified)
+			}
+		})
+	}
+
+	// When the modification time isn't known, If-Modified-Since is ignored.
+	t.Run("Unknown modification time", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("If-Modified-Since", "Sat, 03 Jan 2026 00:00:00 GMT")
+
+		assert.Equal(t, notModified(rr, r, etag, time.Time{}), false)
+		assert.Equal(t, rr.Header().Get("Last-Modified"), "")
+	})
+}
+
+func TestReadJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"Valid", "application/json", `{"title": "O snail"}`, 0, ""},
+		{"Content type with charset", "application/json; charset=utf-8", `{"title": "O snail"}`, 0, ""},
+		{"Form content type", "application/x-www-form-urlencoded", `{"title": "O snail"}`, http.StatusUnsupportedMediaType, "The request body must be JSON"},
+		{"Syntax error", "application/json", `{"title" "O snail"}`, http.StatusBadRequest, "The request body contains badly-formed JSON (at character 10)"},
+		{"Truncated", "application/json", `{"title": "O snail"`, http.StatusBadRequest, "The request body contains badly-formed JSON"},
+		{"Wrong field type", "application/json", `{"title": 7}`, http.StatusBadRequest, `The request body contains the wrong type for the "title" field`},
+		{"Wrong value type", "application/json", `["O snail"]`, http.StatusBadRequest, "The request body contains the wrong JSON type (at character 1)"},
+		{"Empty", "application/json", ``, http.StatusBadRequest, "The request body must not be empty"},
+		{"Unknown field", "application/json", `{"author": "Bob"}`, http.StatusBadRequest, `The request body contains the unknown field "author"`},
+		{"Too large", "application/json", `{"title": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, "The request body must not be larger than 64 bytes"},
+		{"Multiple values", "application/json", `{"title": "O snail"} {}`, http.StatusBadRequest, "The request body must only contain a single JSON value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodPost, "/api/v1/snippets", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Content-Type", tt.contentType)
+
+			var dst struct {
+				Title string `json:"title"`
+			}
+
+			err = app.readJSON(rr, r, &dst, 64)
+
+			if tt.wantStatus == 0 {
+				assert.Equal(t, err, nil)
+				assert.Equal(t, dst.Title, "O snail")
+				return
+			}
+
+			bodyError, ok := err.(*jsonBodyError)
+			if !ok {
+				t.Fatalf("got error %v; want a *jsonBodyError", err)
+			}
+
+			assert.Equal(t, bodyError.status, tt.wantStatus)
+			assert.Equal(t, bodyError.message, tt.wantMessage)
+		})
+	}
+}
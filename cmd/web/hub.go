@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The number of snippets which can be waiting to be sent to a subscriber before further snippets are dropped for
+// it, so that one slow client can't hold up publishing to everyone else.
+const hubBufferSize = 16
+
+// Define a snippetHub type which is an in-process publish/subscribe hub for newly created public snippets. Live
+// views of new snippets (e.g. the server-sent events stream) subscribe to it, and the handlers which create
+// snippets publish to it.
+type snippetHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *models.Snippet]struct{}
+	closed      bool
+}
+
+func newSnippetHub() *snippetHub {
+	return &snippetHub{subscribers: make(map[chan *models.Snippet]struct{})}
+}
+
+// subscribe() returns a channel which receives each snippet published from now on, and a function which must be
+// called to unsubscribe once the subscriber is finished with it. The channel is closed when the subscriber
+// unsubscribes or the hub is closed.
+func (h *snippetHub) subscribe() (<-chan *models.Snippet, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *models.Snippet, hubBufferSize)
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	h.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish() sends a newly created snippet to every subscriber. Private snippets aren't published. It never blocks:
+// subscribers whose buffers are full miss the snippet.
+func (h *snippetHub) publish(snippet *models.Snippet) {
+	if snippet.Private {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- snippet:
+		default:
+		}
+	}
+}
+
+// close() closes every subscriber's channel, and the channels of any later subscribers, so that long-lived
+// connections watching the hub finish when the server shuts down.
+func (h *snippetHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
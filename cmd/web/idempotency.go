@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// How long the response to a request with an Idempotency-Key header is kept for retries of the request, and the
+// maximum length of a key.
+const (
+	idempotencyKeyTTL       = 24 * time.Hour
+	idempotencyKeyMaxLength = 255
+)
+
+// Define an idempotencyRecorder type which wraps an http.ResponseWriter to keep a copy of the response status and
+// body as they are written.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *idempotencyRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// A middleware which lets clients safely retry API requests which create resources, by sending a unique
+// Idempotency-Key header with each request. The first response to a request with a key is stored, and retries with
+// the same key are sent the stored response (with an Idempotent-Replayed header) instead of being handled again.
+// Keys belong to the authenticated user, and reusing one for a different request is an error. Responses to requests
+// which failed with a server error aren't stored, so that they can be retried. It must come after
+// requireAPIAuthentication.
+func (app *application) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLength {
+			app.errorResponse(w, r, http.StatusBadRequest, "The Idempotency-Key header is too long", nil)
+			return
+		}
+
+		// Read the body so that it can be hashed to identify the request, and replace it for the handler. Reading
+		// one byte more than the API's limit is enough for the handler to reject bodies which are too large.
+		body, err := io.ReadAll(io.LimitReader(r.Body, apiMaxBatchBodySize+1))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+		hash.Write(body)
+		requestHash := hash.Sum(nil)
+
+		userID := app.apiRequestUserID(r)
+
+		stored, err := app.idempotency.Get(r.Context(), userID, key)
+		if err == nil {
+			if !bytes.Equal(stored.RequestHash, requestHash) {
+				app.errorResponse(w, r, http.StatusUnprocessableEntity, "The Idempotency-Key header was already used for a different request", nil)
+				return
+			}
+
+			if stored.Location != "" {
+				w.Header().Set("Location", stored.Location)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		rw := &idempotencyRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 || rw.status >= http.StatusInternalServerError {
+			return
+		}
+
+		err = app.idempotency.Insert(r.Context(), userID, key, &models.IdempotentResponse{
+			RequestHash: requestHash,
+			Status:      rw.status,
+			Location:    rw.Header().Get("Location"),
+			Body:        rw.body.Bytes(),
+		}, idempotencyKeyTTL)
+		if err != nil {
+			app.logger.ErrorContext(r.Context(), err.Error())
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+
+	"github.com/declanlin/snippetbox/internal/i18n"
+	"github.com/declanlin/snippetbox/ui"
+)
+
+// The locale which pages are shown in when the visitor hasn't asked for one which is supported.
+const defaultLocale = "en"
+
+// Function used to load the translation catalogs embedded under ui/locales.
+func newTranslations() (*i18n.Bundle, error) {
+	locales, err := fs.Sub(ui.Files, "locales")
+	if err != nil {
+		return nil, err
+	}
+
+	return i18n.Load(locales, defaultLocale)
+}
+
+// Middleware used to pick the locale which a page is shown in: the one chosen with the language picker, which is
+// kept in the session, or else the best match for the browser's Accept-Language header. It has to come after
+// LoadAndSave in the middleware chain.
+func (app *application) localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := app.sessionManager.GetString(r.Context(), "locale")
+		if !app.translations.Supported(locale) {
+			locale = app.translations.Match(r.Header.Get("Accept-Language"))
+		}
+
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+
+		ctx := context.WithValue(r.Context(), localeContextKey, locale)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Function used to return a Localizer for the request's locale. Pages which are rendered outside of the localize
+// middleware (e.g. the maintenance page) fall back to the Accept-Language header.
+func (app *application) localizer(r *http.Request) *i18n.Localizer {
+	locale, ok := r.Context().Value(localeContextKey).(string)
+	if !ok {
+		locale = app.translations.Match(r.Header.Get("Accept-Language"))
+	}
+
+	return app.translations.Localizer(locale)
+}
+
+type localeForm struct {
+	Locale string `form:"locale"`
+}
+
+// Save the locale chosen with the language picker in the session, and send the visitor back to the page which
+// they chose it on.
+func (app *application) setLocalePost(w http.ResponseWriter, r *http.Request) {
+	var form localeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !app.translations.Supported(form.Locale) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "locale", form.Locale)
+
+	http.Redirect(w, r, refererRedirect(r), http.StatusSeeOther)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestLocalize(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		wantLocale     string
+		wantBody       string
+	}{
+		{"No header", "", "en", "<h2>Latest Snippets</h2>"},
+		{"Supported language", "fr", "fr", "<h2>Derniers extraits</h2>"},
+		{"Regional variant", "fr-CA", "fr", "<h2>Derniers extraits</h2>"},
+		{"Preferred language", "de;q=0.9, fr;q=0.8, en;q=0.7", "fr", "<h2>Derniers extraits</h2>"},
+		{"Order of preference", "en;q=0.5, fr", "fr", `<html lang='fr'>`},
+		{"Unsupported language", "de", "en", "<h2>Latest Snippets</h2>"},
+		{"Invalid quality", "fr;q=high", "en", "<h2>Latest Snippets</h2>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, http.StatusOK)
+			assert.Equal(t, rs.Header.Get("Content-Language"), tt.wantLocale)
+			assert.StringContains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestSetLocalePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		locale       string
+		referer      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Back to the page", "fr", ts.URL + "/snippet/view/1?limit=5", http.StatusSeeOther, "/snippet/view/1?limit=5"},
+		{"No referer", "fr", "", http.StatusSeeOther, "/"},
+		{"Other site", "fr", "https://example.com/snippet/view/1", http.StatusSeeOther, "/"},
+		{"Unsupported locale", "de", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"locale": {tt.locale}, "csrf_token": {validCSRFToken}}
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/locale", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Referer", tt.referer)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	// The chosen locale is kept in the session, and applies to validation errors too.
+	form := url.Values{"title": {""}, "content": {"Content"}, "expires": {"7"}, "csrf_token": {validCSRFToken}}
+	code, header, body := ts.postForm(t, "/snippet/create", form)
+
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.Equal(t, header.Get("Content-Language"), "fr")
+	assert.StringContains(t, body, "Ce champ ne peut pas être vide")
+}
+
+func TestLocalizePages(t *testing.T) {
+	tests := []struct {
+		name        string
+		urlPath     string
+		maintenance bool
+		wantCode    int
+		wantBody    []string
+	}{
+		{
+			name:     "Snippet",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<title>Extrait n°1 - Snippetbox</title>", "<h2>Partager</h2>", ">Imprimer</a>"},
+		},
+		{
+			name:        "Maintenance",
+			urlPath:     "/",
+			maintenance: true,
+			wantCode:    http.StatusServiceUnavailable,
+			wantBody:    []string{"<h2>En maintenance</h2>", "Snippetbox est en cours de maintenance programmée."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.maintenance.Store(tt.maintenance)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Language", "fr")
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, string(body), want)
+			}
+		})
+	}
+}
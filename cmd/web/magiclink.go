@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// How long the login links sent by email are valid for.
+const magicLinkLifetime = 15 * time.Minute
+
+type magicLinkForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the form for requesting a login link by email.
+func (app *application) userMagicLink(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = magicLinkForm{}
+	app.render(w, r, http.StatusOK, "magiclink.tmpl", data)
+}
+
+func (app *application) userMagicLinkPost(w http.ResponseWriter, r *http.Request) {
+	var form magicLinkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "magiclink.tmpl", data)
+		return
+	}
+
+	// The response is the same whether or not there is an active account for the email address, so that the form
+	// can't be used to find out which addresses are registered.
+	user, err := app.users.GetByEmail(r.Context(), form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err == nil && user.Active {
+		token, err := app.tokens.New(r.Context(), user.ID, magicLinkLifetime, models.ScopeMagicLink)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.queueMail(r.Context(), user.Email, "magiclink.tmpl", map[string]string{
+			"Name": user.Name,
+			"URL":  fmt.Sprintf("%s/user/login/link/%s", app.config.baseURL, token),
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.flash(r, flashInfo, "If there is an account for that email address, we've sent it a login link.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// Display a page asking the user to confirm that they want to log in with the login link in the URL. Logging in
+// needs a POST request, so that email scanners which follow links don't use up the single-use token.
+func (app *application) userMagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	data := app.newTemplateData(r)
+	data.Form = struct{ Token string }{Token: params.ByName("token")}
+	app.render(w, r, http.StatusOK, "magiclinklogin.tmpl", data)
+}
+
+func (app *application) userMagicLinkLoginPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := app.tokens.UserID(r.Context(), models.ScopeMagicLink, params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.flash(r, flashError, "This login link is invalid or has expired.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Login links are single-use, so delete all of the user's login link tokens.
+	err = app.tokens.DeleteAllForUser(r.Context(), models.ScopeMagicLink, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The account may have been deactivated since the link was sent.
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !user.Active {
+		app.flash(r, flashError, "This account has been deactivated.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err = app.logIn(r, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
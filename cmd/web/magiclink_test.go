@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestUserMagicLinkPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login/link")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		userEmail string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Registered email",
+			userEmail: "alice@example.com",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Unregistered email",
+			userEmail: "nobody@example.com",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name:      "Invalid email",
+			userEmail: "alice@",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field must be a valid email address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.userEmail)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/user/login/link", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				return
+			}
+
+			// Registered and unregistered addresses get the same response.
+			assert.Equal(t, header.Get("Location"), "/user/login")
+
+			_, _, body = ts.get(t, "/user/login")
+			assert.StringContains(t, body, "If there is an account for that email address, we&#39;ve sent it a login link.")
+		})
+	}
+}
+
+func TestUserMagicLinkPostForgedHost(t *testing.T) {
+	app := newTestApplication(t)
+
+	outbox := &recordingOutboxModel{}
+	app.outbox = outbox
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/login/link")
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postFormWithHost(t, "attacker.example.com", "/user/login/link", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The link in the email points at the configured base URL, whatever the request's Host header says.
+	mails := outbox.mails(t, "magiclink.tmpl")
+	assert.Equal(t, len(mails), 1)
+	assert.Equal(t, strings.HasPrefix(mails[0]["URL"], "https://snippetbox.example.com/user/login/link/"), true)
+}
+
+func TestUserMagicLinkLogin(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		wantLocation string
+	}{
+		{"Valid token", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "/snippet/create"},
+		{"Invalid token", "ZYXWVUTSRQPONMLKJIHGFEDCBA", "/user/login"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// Following the link only shows a confirmation page.
+			code, _, body := ts.get(t, "/user/login/link/"+tt.token)
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, `<form action="/user/login/link/`+tt.token+`" method="POST">`)
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, "/user/login/link/"+tt.token, form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			// Only a valid token logs the user in.
+			code, _, _ = ts.get(t, "/account/profile")
+			if tt.wantLocation == "/snippet/create" {
+				assert.Equal(t, code, http.StatusOK)
+			} else {
+				assert.Equal(t, code, http.StatusSeeOther)
+			}
+		})
+	}
+}
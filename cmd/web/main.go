@@ -1,45 +1,121 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/declanlin/snippetbox/internal/dbtrace"
+	"github.com/declanlin/snippetbox/internal/i18n"
+	"github.com/declanlin/snippetbox/internal/mailer"
+	"github.com/declanlin/snippetbox/internal/migrations"
 	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/pgxstore"
+	"github.com/declanlin/snippetbox/internal/redisstore"
+	"github.com/declanlin/snippetbox/internal/sqlite3store"
+	"github.com/declanlin/snippetbox/ui"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gomodule/redigo/redis"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Define a structure which stores application-specific dependencies for the execution of server-side operations.
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
+	logger         *slog.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	contacts       models.ContactModelInterface
+	outbox         models.OutboxModelInterface
+	blockedWords   models.BlockedWordModelInterface
+	previews       models.PreviewModelInterface
+	tokens         models.TokenModelInterface
+	emailChanges   models.EmailChangeModelInterface
+	apiTokens      models.APITokenModelInterface
+	avatars        models.AvatarModelInterface
+	invites        models.InviteModelInterface
+	identities     models.UserIdentityModelInterface
+	sessions       models.SessionModelInterface
+	webhooks       models.WebhookModelInterface
+	idempotency    models.IdempotencyModelInterface
+	txRunner       models.TxRunner
+	snippetCache   *models.CachedSnippetModel
+	fragments      *fragmentCache
+	hub            *snippetHub
 	templateCache  map[string]*template.Template
+	templateFS     fs.FS // Only set in development mode (see templates()).
+	assets         *assetManifest
+	branding       branding
+	challenge      *challenge
+	spam           *spamGuard
+	translations   *i18n.Bundle
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	mailer         mailer.MailerInterface
+	config         *config
+
+	sessionMigrations  []sessionMigration
+	shedder            *loadShedder
+	oauthProviders     map[string]*oauthProvider
+	configEntries      []configEntry
+	apiRateLimits      map[string]int
+	corsTrustedOrigins []string
+	trustedProxies     []netip.Prefix
+	webhookClient      *http.Client
+	db                 pinger
+	started            time.Time
+	tracer             trace.Tracer
+
+	// Whether the application is in maintenance mode, which admins can change at any time.
+	maintenance atomic.Bool
+
+	// An optional hook which is called with the details of every server-side panic, which forwards them to the
+	// error-report-url (see errorreport.go). Panics are always logged regardless.
+	reportError func(report errorReport)
 }
 
-// Define a function which wraps sql.Open() and returns a sql.DB connection pool for a given DSN.
-func openDB(dsn string) (*sql.DB, error) {
-	// Open a connection to the database with the specified database driver name and DSN.
-	db, err := sql.Open("mysql", dsn)
+// Define a poolConfig type to hold the limits of a database connection pool, which are described by the
+// corresponding methods of sql.DB.
+type poolConfig struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+}
 
-	// Return a nil database pointer if there is an issue connecting to the database.
+// Define a function which returns a sql.DB connection pool for a given database driver ("mysql", "postgres" or
+// "sqlite") and DSN, with the given limits, once the database has responded. Statements run with a traced context
+// are recorded as spans (see the dbtrace package).
+func openDB(dbDriver, dsn string, pool poolConfig) (*sql.DB, error) {
+	db, err := newDB(dbDriver, dsn, pool)
 	if err != nil {
 		return nil, err
 	}
 
 	// Verify that the connection to the database is still alive.
-	if err = db.Ping(); err != nil {
+	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -47,56 +123,216 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
+// Define a function which returns a sql.DB connection pool like openDB, but without connecting to the database.
+func newDB(dbDriver, dsn string, pool poolConfig) (*sql.DB, error) {
+	var connector driver.Connector
+
+	// Parse the DSN and create a connector for the database driver with it. PostgreSQL is used through pgx's
+	// database/sql driver.
+	switch dbDriver {
+	case "postgres":
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		connector = stdlib.GetConnector(*cfg)
+	case "sqlite":
+		connector = sqliteConnector{dsn: sqliteDSN(dsn)}
+	default:
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		connector, err = mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	db := sql.OpenDB(dbtrace.Wrap(connector, dbDriver))
+
+	db.SetMaxOpenConns(pool.maxOpenConns)
+	db.SetMaxIdleConns(pool.maxIdleConns)
+	db.SetConnMaxLifetime(pool.connMaxLifetime)
+	db.SetConnMaxIdleTime(pool.connMaxIdleTime)
+
+	// Each connection to an in-memory SQLite database has a database of its own, so the pool is limited to a
+	// single connection which is never closed.
+	if dbDriver == "sqlite" && isMemoryDSN(dsn) {
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+
+	return db, nil
+}
+
+// Define a function which returns a structured logger writing to w in the given format, either "text" or "json".
+// Records logged with the context of a request include its ID (see requestid.go).
+func newLogger(w io.Writer, format string) (*slog.Logger, error) {
+	var h slog.Handler
+
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(w, nil)
+	case "json":
+		h = slog.NewJSONHandler(w, nil)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return slog.New(requestIDHandler{h}), nil
+}
+
 func main() {
-	// flag.String() defines a string flag with the specified name, default value, and usage string.
-	// flag.String() returns the address of a string variable which stores the value of the flag.
-	addr := flag.String("addr", ":4000", "HTTP Network Address")
+	// Load the configuration from the command line, the environment and the configuration file. The logger's
+	// format is part of the configuration, so errors are written straight to the standard error stream.
+	cfg, fs, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Create a structured logger for our web application, which writes to the standard output stream in the
+	// requested format.
+	logger, err := newLogger(os.Stdout, cfg.logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, name := range cfg.secretArgs {
+		logger.Warn("secret given on the command line, where other users can see it", "setting", name,
+			"instead", name+"-file")
+	}
+
+	parsedAPIRateLimits, err := parseAPIRateLimits(cfg.apiRateLimits)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
-	// The DSN string for the snippetbox MYSQL database.
-	dsn := flag.String("dsn", "web:Pipluppy2003!@/snippetbox?parseTime=true", "MYSQL Data Source Name")
+	trustedProxies, err := parseTrustedProxies(cfg.trustedProxies)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Start sending traces to the OpenTelemetry collector, if one is configured. Spans which haven't been sent yet
+	// are flushed when the server has stopped.
+	if cfg.otlpEndpoint != "" {
+		tp, err := newTracerProvider(cfg.otlpEndpoint, cfg.traceSampleRatio)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
 
-	// Note: The following SQL statements can be used to create a new database for snippetbox along with
-	// a table for snippet objects.
+			if err := tp.Shutdown(ctx); err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+	}
+
+	// Create a connection pool for the database with the specified driver and DSN.
+	pool := poolConfig{
+		maxOpenConns:    cfg.dbMaxOpenConns,
+		maxIdleConns:    cfg.dbMaxIdleConns,
+		connMaxLifetime: cfg.dbConnMaxLifetime,
+		connMaxIdleTime: cfg.dbConnMaxIdleTime,
+	}
 
-	// -- Create a new UTF-8 `snippetbox` database.
-	// CREATE DATABASE snippetbox CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
-	// -- Switch to using the `snippetbox` database.
-	// USE snippetbox;
+	db, err := openDB(cfg.dbDriver, cfg.dsn, pool)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
-	// -- Create a `snippets` table.
-	// CREATE TABLE snippets (
-	// id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-	// title VARCHAR(100) NOT NULL,
-	// content TEXT NOT NULL,
-	// created DATETIME NOT NULL,
-	// expires DATETIME NOT NULL
-	// );
+	// Create a connection pool for the read-only replica, if there is one. The application starts even if the
+	// replica can't be reached, since reads go to the primary database until it can.
+	var replica *sql.DB
+	if cfg.replicaDSN != "" {
+		replica, err = newDB(cfg.dbDriver, cfg.replicaDSN, pool)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
 
-	// -- Add an index on the created column.
-	// CREATE INDEX idx_snippets_created ON snippets(created);
+		if err := replica.Ping(); err != nil {
+			logger.Warn("replica database unreachable, reading from the primary", "error", err)
+		}
+	}
 
-	// After all flags are defined, call flag.Parse() to parse the command line into the defined flags.
-	flag.Parse()
+	// The models write their statements once for every database, and the dialect fills in the differences.
+	dialect, err := models.DialectFor(cfg.dbDriver)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
-	// Define custom error and info loggers for our web application.
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ltime|log.Ldate|log.Lshortfile)
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ltime|log.Ldate)
+	modelDB := &models.DB{DB: db, Dialect: dialect}
+	if replica != nil {
+		modelDB.Replica = &models.Replica{DB: &models.DB{DB: replica, Dialect: dialect}}
+	}
 
-	// Create a connection pool for the database with the specified DSN, assuming that we have a supported driver
-	// for the database.
-	db, err := openDB(*dsn)
+	// The database schema is created and evolved by the embedded migrations (see internal/migrations).
+	migrator, err := migrations.New(db, cfg.dbDriver)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Run the migrate command instead of the server if it was given.
+	if fs.Arg(0) == "migrate" {
+		err = runMigrateCommand(context.Background(), migrator, fs.Args()[1:], os.Stdout)
+		db.Close()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Defer a call to db.Close() to ensure that the connection pool is closed before the main() function call exits,
-	// in the event that a panic occurs.
-	defer db.Close()
+	// Apply any pending migrations before starting, if asked to.
+	if cfg.migrate {
+		applied, err := migrator.Up(context.Background())
+		for _, migration := range applied {
+			logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Hash the static files, so that pages can link to fingerprinted versions of them.
+	assets, err := newAssetManifest(ui.Files)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
 	// Create a new template cache for the pages we are serving.
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(ui.Files, assets)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Load the translations of the pages into each supported language.
+	translations, err := newTranslations()
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
 
 	// Create a new instance of a *form.Decoder type to be used for decoding HTML form data.
@@ -104,23 +340,200 @@ func main() {
 
 	// Create a new instance of a *scs.SessionManager to be used as a session manager for stateful HTTP transactions.
 	sessionManager := scs.New()
-	// Configure the session manager to use the MYSQL database as the session store, and set a lifetime of 12 hours (so
-	// that sessions expire automatically 12 hours after their creation).
-	// mysqlstore.New() returns a new MYSQLstore instance with a background cleanup goroutine that runs every 5 minutes
-	// to remove expired session data.
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	// Configure the session manager to use the configured session store (the database by default), and set the
+	// lifetime (so that sessions expire automatically that long after their creation).
+	// mysqlstore.New(), pgxstore.New(), sqlite3store.New() and memstore.New() return a store with a background
+	// cleanup goroutine that runs every 5 minutes to remove expired session data. Redis expires sessions itself.
+	// The idle timeout isn't set on the session manager, since it would apply to sessions which have a longer
+	// lifetime because of "Remember me" too. The expireIdleSessions middleware enforces it instead.
+	var sessionStore interface {
+		scs.Store
+		scs.IterableStore
+		StopCleanup()
+	}
+
+	var redisPool *redis.Pool
+
+	switch {
+	case cfg.sessionStore == "redis":
+		redisPool = newRedisPool(cfg.redisURL)
+		sessionStore = redisstore.New(redisPool)
+	case cfg.sessionStore == "memory":
+		sessionStore = memstore.New()
+	case cfg.dbDriver == "postgres":
+		sessionStore = pgxstore.New(db)
+	case cfg.dbDriver == "sqlite":
+		sessionStore = sqlite3store.New(db)
+	default:
+		sessionStore = mysqlstore.New(db)
+	}
+
+	sessionManager.Store = sessionStore
+	sessionManager.Lifetime = cfg.sessionLifetime
+	// Only make the session cookie persistent (i.e. retained after the browser is closed) for users who ticked
+	// "Remember me" when logging in.
+	sessionManager.Cookie.Persist = false
+
+	// Read the custom logo and footer, if the site has been rebranded.
+	siteBranding, err := loadBranding(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Set up the checks for automated form submissions.
+	spam, err := newSpamGuard(cfg.formSecret, cfg.formMinSubmitTime)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 
 	// Create an instance of the application structure to store application-specific dependencies for
 	// the execution of server-side operations.
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		logger:         logger,
+		contacts:       &models.ContactModel{DB: modelDB},
+		outbox:         &models.OutboxModel{DB: modelDB},
+		blockedWords:   &models.BlockedWordModel{DB: modelDB},
+		previews:       &models.PreviewModel{DB: modelDB},
+		tokens:         &models.TokenModel{DB: modelDB},
+		emailChanges:   &models.EmailChangeModel{DB: modelDB},
+		apiTokens:      &models.APITokenModel{DB: modelDB},
+		avatars:        &models.AvatarModel{DB: modelDB},
+		invites:        &models.InviteModel{DB: modelDB},
+		identities:     &models.UserIdentityModel{DB: modelDB},
+		sessions:       &models.SessionModel{DB: modelDB},
+		webhooks:       &models.WebhookModel{DB: modelDB},
+		idempotency:    &models.IdempotencyModel{DB: modelDB},
+		txRunner:       modelDB,
+		hub:            newSnippetHub(),
 		templateCache:  templateCache,
+		assets:         assets,
+		branding:       siteBranding,
+		challenge:      newChallenge(cfg),
+		spam:           spam,
+		translations:   translations,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		mailer:         mailer.New(cfg.smtpHost, cfg.smtpPort, cfg.smtpUsername, cfg.smtpPassword, cfg.smtpSender),
+		config:         cfg,
+
+		sessionMigrations:  sessionMigrations,
+		shedder:            newLoadShedder(cfg.shedLatency, cfg.shedDBWait),
+		oauthProviders:     newOAuthProviders(cfg.oauthRedirectBase, cfg.githubClientID, cfg.githubClientSecret, cfg.googleClientID, cfg.googleClientSecret),
+		apiRateLimits:      parsedAPIRateLimits,
+		corsTrustedOrigins: strings.Fields(cfg.corsTrustedOrigins),
+		trustedProxies:     trustedProxies,
+		webhookClient:      newWebhookClient(),
+		db:                 db,
+		started:            time.Now(),
+		tracer:             otel.Tracer(tracerName),
+		reportError:        newErrorReporter(logger, cfg.errorReportURL),
+	}
+
+	app.maintenance.Store(cfg.maintenance)
+
+	// In development mode, templates are parsed from disk on every render instead of being taken from the cache.
+	if cfg.dev {
+		app.templateFS = os.DirFS(devUIDir)
+		logger.Warn("running in development mode, which re-parses templates from disk on every render", "dir", devUIDir)
+	}
+
+	// Run the seed command instead of the server if it was given. It only needs the database schema to be up to
+	// date, so the other startup checks are skipped.
+	if fs.Arg(0) == "seed" {
+		app.snippets = &models.SnippetModel{DB: modelDB}
+		app.users = &models.UserModel{DB: modelDB}
+
+		err = checkSchemaVersion(context.Background(), migrator)
+		if err == nil {
+			err = app.runSeedCommand(context.Background(), fs.Args()[1:], os.Stdout)
+		}
+		db.Close()
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check that the templates, the database schema version, the session store and the TLS files are all usable
+	// before starting, and report every problem at once if they aren't.
+	err = runStartupChecks(context.Background(), app.startupChecks(migrator))
+	if err != nil {
+		var checkErr *startupCheckError
+		if errors.As(err, &checkErr) {
+			for _, failure := range checkErr.failures {
+				logger.Error("startup check failed", "check", failure.name, "error", failure.err)
+			}
+			logger.Error("startup self-check failed, exiting", "failed", len(checkErr.failures))
+		} else {
+			logger.Error(err.Error())
+		}
+		os.Exit(1)
+	}
+
+	// Create the snippet and user models, which prepare the statements they run most often. This is done once the
+	// schema is known to be up to date, since the statements can't be prepared otherwise. The statements are closed
+	// when the application shuts down, before the connection pool.
+	snippets, err := models.NewSnippetModel(modelDB)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	users, err := models.NewUserModel(modelDB, cfg.lockoutThreshold, cfg.lockoutDuration)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	app.snippets = snippets
+	app.users = users
+
+	// Put the in-memory cache in front of the snippet model, unless it has been disabled.
+	if cfg.snippetCacheSize > 0 {
+		app.snippetCache = models.NewCachedSnippetModel(snippets, cfg.snippetCacheSize, cfg.snippetCacheTTL)
+		app.snippets = app.snippetCache
+	}
+
+	if cfg.fragmentCacheTTL > 0 {
+		app.fragments = newFragmentCache(cfg.fragmentCacheTTL)
+	}
+
+	// Take a snapshot of the resolved configuration (with secrets redacted) and the features it enables, log it,
+	// and keep it for the /admin/config page.
+	app.configEntries = append(resolvedConfig(fs), app.enabledFeatures()...)
+	app.logConfig()
+
+	// Load the TLS certificate, which is reloaded by a background worker when it is renewed.
+	certs, err := newCertReloader(cfg.tlsCert, cfg.tlsKey)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Start the background workers: the outbox dispatcher, so that queued jobs (including any left over from a
+	// previous run of the application) are run and webhooks are delivered; the expiry notifier; the sampling of
+	// the database connection pool statistics for the load shedder, and their periodic logging; the archiver; and
+	// the TLS certificate watcher. They are stopped once the server has shut down, and we wait for them to finish
+	// any batch in progress before exiting.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	for _, worker := range []func(context.Context){
+		app.runOutbox,
+		app.runExpiryNotifier,
+		func(ctx context.Context) { app.shedder.monitorDB(ctx, db) },
+		func(ctx context.Context) { app.logDBStats(ctx, db, cfg.dbStatsInterval) },
+		app.runArchiver,
+		func(ctx context.Context) { app.watchCertificate(ctx, certs) },
+	} {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(workerCtx)
+		}()
 	}
 
 	// Initialize a tls.Config struct to hold the non-default TLS settings we want the server to use.
@@ -128,15 +541,18 @@ func main() {
 	// elliptic curves with assembly implementations are used. We are selectively choosing to ignore all
 	// other curves beside the ones specified below due to the fact that they are very CPU intensive. Omitting them
 	// helps ensure that our server will perform well under heavy load.
+	// The certificate is taken from the certReloader for each handshake, so that a renewed certificate is used as
+	// soon as it has been reloaded.
 
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.CurveP256, tls.X25519},
+		GetCertificate:   certs.GetCertificate,
 	}
 
 	// Create an instance of an HTTP server which our application will run on.
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(),
 		TLSConfig:    tlsConfig,
 		IdleTimeout:  time.Minute,
@@ -144,14 +560,119 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	// Print an information log to the standard output stream indicating that the server is about to be started.
-	infoLog.Printf("Starting server on %s", *addr)
+	// End the long-lived event streams when the server shuts down, since Shutdown() would otherwise wait for them.
+	srv.RegisterOnShutdown(app.hub.close)
+
+	servers := []*http.Server{srv}
+	addrs := []string{cfg.addr}
+
+	// Create a second server for the operational endpoints if an internal listener is configured. It only serves
+	// internal clients, so it doesn't use TLS. Its write timeout is long enough for the default 30 second CPU
+	// profile.
+	var internalSrv *http.Server
+	if cfg.internalAddr != "" {
+		internalSrv = &http.Server{
+			Addr:         cfg.internalAddr,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			Handler:      app.internalRoutes(),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: time.Minute,
+		}
+
+		servers = append(servers, internalSrv)
+		addrs = append(addrs, cfg.internalAddr)
+	}
+
+	// Listen on the TCP network addresses of the servers, or take over the listening sockets of the previous
+	// process if this process was started by a binary upgrade (see upgrade_unix.go).
+	lns, err := listen(addrs...)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Wait for a signal to shut down (or upgrade) in a background goroutine. When one is received, the servers are
+	// shut down gracefully. The result of the shutdown is sent on the shutdownError channel.
+	shutdownError := make(chan error)
+	go func() {
+		shutdownError <- app.shutdownOnSignal(servers, lns)
+	}()
+
+	// Serve the internal listener in the background. It is shut down along with the main server, so it only stops
+	// early if something goes wrong.
+	if internalSrv != nil {
+		logger.Info("starting internal server", "addr", cfg.internalAddr)
+
+		go func() {
+			err := internalSrv.Serve(lns[1])
+			if !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("internal server stopped", "error", err)
+			}
+		}()
+	}
+
+	// If this process was started by a binary upgrade, let the parent know that it can shut down. The listeners are
+	// already accepting connections, which queue up until ServeTLS() handles them.
+	err = signalUpgradeReady()
+	if err != nil {
+		logger.Error("failed to signal readiness to the parent process", "error", err)
+	}
+
+	// Log that the server is about to be started.
+	logger.Info("starting server", "addr", cfg.addr)
 
-	// ListenAndServe() listens on the TCP network address srv.Addr and then calls Serve() to handle requests
-	// on incoming connections.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	// ServeTLS() accepts incoming connections on the listener and handles requests on them. The certificate comes
+	// from tlsConfig.GetCertificate, so no files are given.
+	err = srv.ServeTLS(lns[0], "", "")
+
+	// Calling Shutdown() on the server causes ServeTLS() to immediately return an http.ErrServerClosed error.
+	// If there is any other error, log the error and exit.
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Otherwise, wait for the in-flight requests on the servers to complete. If they didn't finish in time, their
+	// connections have been closed, so carry on shutting down regardless.
+	err = <-shutdownError
+	if err != nil {
+		logger.Error("server shutdown incomplete", "error", err)
+	}
+
+	logger.Info("stopped server", "addr", cfg.addr)
+
+	// Stop the background workers, so that nothing is using the database when the connection pool is closed.
+	stopWorkers()
+	workers.Wait()
+	sessionStore.StopCleanup()
+
+	logger.Info("stopped background workers")
+
+	for _, model := range []io.Closer{snippets, users} {
+		if err := model.Close(); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+
+	err = db.Close()
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	if replica != nil {
+		err = replica.Close()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+
+	if redisPool != nil {
+		err = redisPool.Close()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
 
-	// If there is an error listening on the network, log the error. Fatal() is equivalent to errorLog.Println()
-	// followed by a call to os.Exit(1).
-	errorLog.Fatal(err)
+	logger.Info("closed database connections")
 }
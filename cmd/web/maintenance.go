@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long clients are asked to wait before retrying while the application is in maintenance mode.
+const maintenanceRetryAfter = 5 * time.Minute
+
+// A middleware which turns requests away with an HTTP 503 Service Unavailable response and a maintenance page while
+// the application is in maintenance mode. The probes, static files and the custom logo (which the maintenance page
+// uses) are still served, and so are the login page and the admin pages, so that admins can switch maintenance mode
+// off again.
+//
+// Maintenance mode is held in memory, so switching it on or off from the admin dashboard only affects the instance
+// which served the request. When several instances run behind a load balancer, each of them has to be switched (or
+// restarted with the -maintenance flag). The readiness probe is exempt so that the load balancer doesn't take
+// instances in maintenance mode out of rotation, which would send all of the traffic to the others.
+func (app *application) maintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.maintenance.Load() || maintenanceExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+		w.Header().Set("Cache-Control", "no-store")
+
+		if wantsJSON(r) {
+			app.errorResponse(w, r, http.StatusServiceUnavailable, "the service is down for maintenance", nil)
+			return
+		}
+
+		// The page is rendered without the session data, since sessions aren't loaded until the router has
+		// matched a route.
+		data := app.newBareTemplateData()
+
+		app.render(w, r, http.StatusServiceUnavailable, "maintenance.tmpl", data)
+	})
+}
+
+// Function used to report whether the given path is still served in maintenance mode.
+func maintenanceExempt(path string) bool {
+	switch {
+	case path == "/healthz", path == "/readyz", path == "/branding/logo", path == "/user/login", path == "/admin":
+		return true
+	case strings.HasPrefix(path, "/static/"), strings.HasPrefix(path, "/admin/"):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	app := newTestApplication(t)
+	app.maintenance.Store(true)
+	app.branding = branding{logo: []byte("<svg></svg>"), logoType: "image/svg+xml", logoVersion: "0123abcd"}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Page",
+			urlPath:  "/",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "Down for maintenance",
+		},
+		{
+			name:     "API",
+			urlPath:  "/api/v1/snippets",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "the service is down for maintenance",
+		},
+		{
+			name:     "Liveness probe",
+			urlPath:  "/healthz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Readiness probe",
+			urlPath:  "/readyz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Custom logo",
+			urlPath:  "/branding/logo",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Static file",
+			urlPath:  "/static/css/main.css",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Login page",
+			urlPath:  "/user/login",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusServiceUnavailable {
+				assert.Equal(t, header.Get("Retry-After"), "300")
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminMaintenancePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.loginAs(t, "admin@example.com")
+
+	_, _, body := ts.get(t, "/admin")
+	csrfToken := extractCSRFToken(t, body)
+
+	for _, enabled := range []string{"true", "false"} {
+		form := url.Values{}
+		form.Add("enabled", enabled)
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/admin/maintenance", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, app.maintenance.Load(), enabled == "true")
+
+		// Admins can still use the admin pages while the site is in maintenance mode.
+		code, _, body = ts.get(t, "/admin")
+		assert.Equal(t, code, http.StatusOK)
+
+		homeCode, _, _ := ts.get(t, "/")
+
+		if enabled == "true" {
+			assert.StringContains(t, body, "Switch off maintenance mode")
+			assert.Equal(t, homeCode, http.StatusServiceUnavailable)
+		} else {
+			assert.StringContains(t, body, "Switch on maintenance mode")
+			assert.Equal(t, homeCode, http.StatusOK)
+		}
+	}
+}
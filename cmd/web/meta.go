@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The maximum length of the description in a page's meta tags, which sites showing a preview of a shared link
+// generally cut off at around this length anyway.
+const metaDescriptionLen = 200
+
+// Define a pageMeta type to hold the details of a page which are used by sites and apps to show a preview of a link
+// to it (through the Open Graph and Twitter card meta tags), along with its canonical URL. Pages without a Title
+// don't get the preview tags.
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string
+}
+
+// Function used to return the meta details for a snippet's page. The description is the start of the snippet's
+// content, with its whitespace collapsed so that the lines of a poem or some code run together.
+func (app *application) snippetMeta(snippet *models.Snippet) pageMeta {
+	return pageMeta{
+		Title:       snippet.Title,
+		Description: excerpt(strings.Join(strings.Fields(snippet.Content), " "), metaDescriptionLen),
+		URL:         app.config.baseURL + mustURLFor("snippet.view", snippet.ID),
+	}
+}
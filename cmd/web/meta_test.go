@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+func TestSnippetMeta(t *testing.T) {
+	app := newTestApplication(t)
+
+	meta := app.snippetMeta(&models.Snippet{
+		ID:      7,
+		Title:   "Over the wintry forest",
+		Content: "Over the wintry\n  forest, winds howl in rage\nwith no leaves to blow.\n" + strings.Repeat("More ", 100),
+	})
+
+	assert.Equal(t, meta.Title, "Over the wintry forest")
+	assert.Equal(t, meta.URL, "https://snippetbox.example.com/snippet/view/7")
+	assert.Equal(t, strings.HasPrefix(meta.Description, "Over the wintry forest, winds howl in rage with no leaves to blow. More"), true)
+	assert.Equal(t, strings.HasSuffix(meta.Description, "…"), true)
+	assert.Equal(t, len([]rune(meta.Description)), metaDescriptionLen)
+}
+
+func TestPageMetaTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Snippet pages describe the snippet for link previews.
+	code, _, body := ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<link rel='canonical' href='https://snippetbox.example.com/snippet/view/1'>")
+	assert.StringContains(t, body, "<meta property='og:title' content='An old silent pond'>")
+	assert.StringContains(t, body, "<meta property='og:description' content='An old silent pond...'>")
+	assert.StringContains(t, body, "<meta property='og:url' content='https://snippetbox.example.com/snippet/view/1'>")
+	assert.StringContains(t, body, "<meta name='twitter:card' content='summary'>")
+
+	// Other pages only get a canonical URL, which leaves out the query string.
+	code, _, body = ts.get(t, "/?limit=5")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<link rel='canonical' href='https://snippetbox.example.com/'>")
+	assert.Equal(t, strings.Contains(body, "og:title"), false)
+}
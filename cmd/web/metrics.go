@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// How long the statistics queries run by a scrape are allowed to take.
+const statsCollectTimeout = 5 * time.Second
+
+// Define a statsCollector type which satisfies prometheus.Collector, exporting the snippet and user counts as
+// gauges. The counts are queried on every scrape rather than kept up to date, so that they are always right
+// however many instances of the application there are.
+type statsCollector struct {
+	app *application
+
+	snippets *prometheus.Desc
+	created  *prometheus.Desc
+	users    *prometheus.Desc
+}
+
+func newStatsCollector(app *application) *statsCollector {
+	return &statsCollector{
+		app:      app,
+		snippets: prometheus.NewDesc("snippetbox_snippets", "Number of snippets, by state.", []string{"state"}, nil),
+		created:  prometheus.NewDesc("snippetbox_snippets_created", "Number of snippets created in the window.", []string{"window"}, nil),
+		users:    prometheus.NewDesc("snippetbox_users", "Number of users.", nil, nil),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.snippets
+	ch <- c.created
+	ch <- c.users
+}
+
+// Collect() queries the counts, reporting an invalid metric for any which can't be queried so that the scrape
+// fails visibly rather than reporting stale or zero values.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statsCollectTimeout)
+	defer cancel()
+
+	stats, err := c.app.snippets.Stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.snippets, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.snippets, prometheus.GaugeValue, float64(stats.Active), "active")
+		ch <- prometheus.MustNewConstMetric(c.snippets, prometheus.GaugeValue, float64(stats.Expired), "expired")
+		ch <- prometheus.MustNewConstMetric(c.snippets, prometheus.GaugeValue, float64(stats.Archived), "archived")
+		ch <- prometheus.MustNewConstMetric(c.created, prometheus.GaugeValue, float64(stats.CreatedToday), "1d")
+		ch <- prometheus.MustNewConstMetric(c.created, prometheus.GaugeValue, float64(stats.CreatedThisWeek), "7d")
+	}
+
+	users, err := c.app.users.Count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.users, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(users))
+	}
+}
+
+// Function used to return the handler for the /metrics endpoint, which serves the statistics along with the Go
+// runtime and process metrics in the Prometheus exposition format.
+func (app *application) metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		newStatsCollector(app),
+		spamRejections,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.internalRoutes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+
+	// The counts come from the mock models.
+	for _, want := range []string{
+		`snippetbox_snippets{state="active"} 1`,
+		`snippetbox_snippets{state="expired"} 2`,
+		`snippetbox_snippets{state="archived"} 3`,
+		`snippetbox_snippets_created{window="1d"} 1`,
+		`snippetbox_snippets_created{window="7d"} 1`,
+		"snippetbox_users 3",
+		"go_goroutines",
+	} {
+		assert.StringContains(t, body, want)
+	}
+}
+
+func TestPublicMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		internalAddr string
+		email        string
+		wantCode     int
+	}{
+		{
+			name:         "Internal listener",
+			internalAddr: "127.0.0.1:4001",
+			email:        "admin@example.com",
+			wantCode:     http.StatusNotFound,
+		},
+		{
+			name:     "Unauthenticated",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Regular user",
+			email:    "alice@example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			email:    "admin@example.com",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.internalAddr = tt.internalAddr
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, "/metrics")
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.StringContains(t, body, "snippetbox_users 3")
+			}
+		})
+	}
+}
@@ -2,10 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/declanlin/snippetbox/internal/models"
 	"github.com/justinas/nosurf"
+	"golang.org/x/time/rate"
 )
 
 // A middleware which can be attached to a router to automatically add HTTP security headers to every response,
@@ -42,26 +54,71 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// A middleware which can be attached to a router to log information about incoming HTTP requests.
+// A middleware which can be attached to a router to log information about HTTP requests. Each request is logged
+// once it has been handled, along with the status code and size of the response, how long it took, and the ID of
+// the user who made it (which authenticate records in the requestInfo), if they were logged in.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Log the formatted HTTP request information.
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
 
 		// Proceed with handling the request, passing control to the next middleware or to the final handler.
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(sw, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.RequestURI()),
+			slog.String("proto", r.Proto),
+			slog.String("client_ip", clientIP(r)),
+			slog.Int("status", sw.status),
+			slog.Int("bytes", sw.bytes),
+			slog.Duration("duration", time.Since(start)),
+		}
+
+		if info := requestInfoFromRequest(r); info != nil && info.UserID != 0 {
+			attrs = append(attrs, slog.Int("user_id", info.UserID))
+		}
+
+		app.logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
 	})
 }
 
-// A middleware which can be attached to a router to recover from server-side panics.
+// A middleware which can be attached to a router to recover from server-side panics. The panic is logged along with
+// details of the request which caused it, passed to the error reporting hook (if one is set), and the client is sent
+// the templated 500 error page.
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Add a requestInfo to the request context, which later middleware fills in (see authenticate).
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey, info))
+
 		// The following deferred function will execute any time a panic occurs during the execution of next.ServeHTTP(w, r).
 		// It will instruct the client to close their connection with the server and log an error message.
 		defer func() {
 			if err := recover(); err != nil {
+				// http.ErrAbortHandler is used to deliberately abort a response, so let net/http deal with it.
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				report := errorReport{
+					Err:       fmt.Errorf("%v", err),
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					UserID:    info.UserID,
+					RequestID: requestIDFromContext(r.Context()),
+					Stack:     debug.Stack(),
+				}
+
+				app.logger.ErrorContext(r.Context(), report.Err.Error(), "method", report.Method, "path", report.Path, "user_id", report.UserID,
+					"trace", string(report.Stack))
+
+				if app.reportError != nil {
+					app.reportError(report)
+				}
+
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.errorResponse(w, r, http.StatusInternalServerError, "", nil)
 			}
 		}()
 
@@ -89,6 +146,63 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// A middleware factory which returns a middleware only allowing authenticated users who have at least the
+// privileges of the given role to proceed. Other users are sent an HTTP 403 Forbidden response. It should be
+// appended to a chain which already includes requireAuthentication.
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !models.HasRole(app.userRole(r), role) {
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// A middleware which requires the authenticated user to have entered their password within the last
+// app.config.sudoWindow before they can carry out a sensitive action ("sudo mode"). Users who haven't are redirected to
+// the re-authentication page, which sends them back to the original page afterwards. It should be appended to a
+// chain which already includes requireAuthentication.
+func (app *application) requireRecentAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticatedAt := time.Unix(app.sessionManager.GetInt64(r.Context(), "authenticatedAt"), 0)
+
+		if time.Since(authenticatedAt) > app.config.sudoWindow {
+			// Only GET requests are remembered, since the data in other requests would be lost on the redirect.
+			redirect := "/account/profile"
+			if r.Method == http.MethodGet {
+				redirect = r.URL.RequestURI()
+			}
+
+			app.sessionManager.Put(r.Context(), "reauthRedirect", redirect)
+			http.Redirect(w, r, "/account/reauthenticate", http.StatusSeeOther)
+			return
+		}
+
+		// Proceed with handling the request, passing control to the next middleware or to the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// A middleware which sends an HTTP 403 Forbidden response with a page explaining that signups are closed when the
+// application is running in closed signup mode.
+func (app *application) requireSignupOpen(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.signupMode == signupModeClosed {
+			data := app.newTemplateData(r)
+			app.render(w, r, http.StatusForbidden, "signupclosed.tmpl", data)
+			return
+		}
+
+		// Proceed with handling the request, passing control to the next middleware or to the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
 func noSurf(next http.Handler) http.Handler {
 	// Create a NoSurf middleware function which uses a customized CSRF cookie with the
 	// Secure, Path, and HttpOnly attributes set.
@@ -114,19 +228,35 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check to see if a user with the session user's ID exists in the database.
-		exists, err := app.users.Exists(id)
-		if err != nil {
-			app.serverError(w, err)
+		// Fetch the user with the session user's ID from the database. Their role is read on every request (rather
+		// than being stored in the session) so that changes to it take effect immediately.
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
 			return
 		}
 
-		// If a matching user record is found, we know the request is coming from an authenticated user
-		// who exists in our database. Create a new copy of the request (with an isAuthenticated value of true in
-		// the request context) and assign it to r.
-		if exists {
+		// If a matching active user record is found, we know the request is coming from an authenticated user
+		// who exists in our database. Create a new copy of the request (with an isAuthenticated value of true and
+		// the user's role and time zone in the request context) and assign it to r. Deactivated users are treated
+		// as logged out.
+		if err == nil && user.Active {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
+			ctx = context.WithValue(ctx, userTimezoneContextKey, user.Timezone)
 			r = r.WithContext(ctx)
+
+			// Update the session's last activity time in the user's list of active sessions. This isn't essential,
+			// so a failure is logged rather than failing the request.
+			err = app.sessions.Touch(r.Context(), app.sessionManager.Token(r.Context()))
+			if err != nil {
+				app.logger.ErrorContext(r.Context(), err.Error())
+			}
+
+			// Record the user's ID so that it is included in the details of any panic.
+			if info := requestInfoFromRequest(r); info != nil {
+				info.UserID = id
+			}
 		}
 
 		// Proceed with handling the request, passing control to the next middleware or to the final handler.
@@ -134,3 +264,404 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 	})
 }
+
+// A middleware which authenticates API requests carrying a personal API token in an "Authorization: Bearer"
+// header. Requests without the header continue anonymously, while requests with a malformed or unknown token (or
+// a token belonging to a deactivated user) are sent an HTTP 401 Unauthorized response.
+func (app *application) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Authorization header, so caches must not share it between clients.
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			app.invalidAPIToken(w, r)
+			return
+		}
+
+		apiToken, err := app.apiTokens.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidAPIToken(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		user, err := app.users.Get(r.Context(), apiToken.UserID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err != nil || !user.Active {
+			app.invalidAPIToken(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, userRoleContextKey, user.Role)
+		ctx = context.WithValue(ctx, apiUserIDContextKey, user.ID)
+		ctx = context.WithValue(ctx, apiTokenContextKey, apiToken)
+		r = r.WithContext(ctx)
+
+		// Record the user's ID so that it is included in the details of any panic.
+		if info := requestInfoFromRequest(r); info != nil {
+			info.UserID = user.ID
+		}
+
+		// Proceed with handling the request, passing control to the next middleware or to the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// A middleware which only allows API requests authenticated by authenticateAPI (or, on API routes which load the
+// session, by a session cookie) to proceed. Other requests are sent an HTTP 401 Unauthorized response.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.apiRequestUserID(r) == 0 {
+			app.invalidAPIToken(w, r)
+			return
+		}
+
+		// Proceed with handling the request, passing control to the next middleware or to the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// A middleware factory which returns a middleware limiting the size of request bodies to n bytes. Requests which
+// declare a larger body are sent an HTTP 413 Content Too Large response straight away; for other requests, reading
+// past the limit fails. It must come before any middleware which reads the body (e.g. noSurf, which parses forms).
+func (app *application) limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				app.clientError(w, r, http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// A middleware factory which returns a middleware limiting each client IP address to rps requests per second,
+// with bursts of up to burst requests. Clients which exceed the limit are sent an HTTP 429 Too Many Requests
+// response with a Retry-After header saying how many seconds to wait. Every call to rateLimit() creates its own
+// independent set of limiters. An rps of zero or less disables the limit.
+func (app *application) rateLimit(rps float64, burst int) func(http.Handler) http.Handler {
+	if rps <= 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	// Define a client type to hold the token bucket rate limiter and the last seen time for each client.
+	type client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*client)
+	)
+
+	// Launch a background goroutine which removes clients that have not been seen in the last three minutes
+	// from the clients map once every minute, so that the map does not grow without bound.
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			mu.Lock()
+			for ip, client := range clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(clients, ip)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Extract the client's IP address from the request.
+			ip := clientIP(r)
+
+			mu.Lock()
+
+			// Initialize a new limiter for the client if this is the first time we have seen them.
+			if _, found := clients[ip]; !found {
+				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+			}
+
+			clients[ip].lastSeen = time.Now()
+
+			// If the client has used up their allowance, send an HTTP 429 Too Many Requests response. Reserving a
+			// token tells us how long the client would have to wait for it; the reservation is then cancelled, since
+			// the request isn't going to be handled.
+			reservation := clients[ip].limiter.Reserve()
+			if delay := reservation.Delay(); delay > 0 {
+				reservation.Cancel()
+				mu.Unlock()
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				app.clientError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			mu.Unlock()
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The key in the API rate limits for requests which aren't authenticated by an API token.
+const apiTierAnonymous = "anonymous"
+
+// A middleware factory which returns a middleware limiting the number of API requests each client can make in each
+// minute. Requests authenticated by an API token are counted against the token, with the limit for the token's tier
+// in limits; other requests are counted against their IP address, with the limit for apiTierAnonymous. A limit of
+// zero (or a tier missing from limits) means no limit. Every response is sent X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (the Unix time at which the current window ends) headers, and clients
+// which exceed their limit are sent an HTTP 429 Too Many Requests response. It must come after authenticateAPI.
+func (app *application) apiRateLimit(limits map[string]int) func(http.Handler) http.Handler {
+	// Define a window type to hold the number of requests a client has made in the current one-minute window.
+	type window struct {
+		start time.Time
+		count int
+	}
+
+	var (
+		mu      sync.Mutex
+		windows = make(map[string]*window)
+	)
+
+	// Launch a background goroutine which removes finished windows from the map once every minute, so that the map
+	// does not grow without bound.
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			mu.Lock()
+			for key, win := range windows {
+				if time.Since(win.start) > time.Minute {
+					delete(windows, key)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key, tier string
+
+			if apiToken, ok := r.Context().Value(apiTokenContextKey).(*models.APIToken); ok {
+				key = fmt.Sprintf("token:%d", apiToken.ID)
+				tier = apiToken.Tier
+			} else {
+				key = "ip:" + clientIP(r)
+				tier = apiTierAnonymous
+			}
+
+			limit := limits[tier]
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now()
+
+			mu.Lock()
+
+			// Start a new window for the client if this is the first time we have seen them, or their last window
+			// has finished.
+			win, found := windows[key]
+			if !found || now.Sub(win.start) >= time.Minute {
+				win = &window{start: now.Truncate(time.Minute)}
+				windows[key] = win
+			}
+
+			win.count++
+			count := win.count
+			reset := win.start.Add(time.Minute)
+
+			mu.Unlock()
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+			if count > limit {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+				app.clientError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Function used to parse the value of the -api-rate-limits flag, a space-separated list of tier=limit pairs giving
+// the number of API requests allowed per minute in each tier, e.g. "anonymous=60 standard=300".
+func parseAPIRateLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+
+	for _, field := range strings.Fields(s) {
+		tier, value, ok := strings.Cut(field, "=")
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid API rate limit %q: want tier=limit", field)
+		}
+
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid API rate limit %q: the limit must be a non-negative integer", field)
+		}
+
+		limits[tier] = limit
+	}
+
+	return limits, nil
+}
+
+// A middleware factory which returns a middleware allowing browser-based clients served from one of the
+// trustedOrigins (e.g. "https://app.example.com") to call the JSON API. Responses to requests from a trusted origin
+// are sent an Access-Control-Allow-Origin header, and CORS preflight requests from a trusted origin are answered
+// directly. Credentials aren't allowed, so cross-origin requests can't use the user's session cookie and have to
+// authenticate with an API token.
+func cors(trustedOrigins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The response depends on these request headers, so make sure that caches don't serve a response to a
+			// request from one origin to another.
+			w.Header().Add("Vary", "Origin")
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+
+			origin := r.Header.Get("Origin")
+
+			if origin != "" && slices.Contains(trustedOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+
+				// A preflight request is an OPTIONS request with an Access-Control-Request-Method header. Browsers
+				// send one before any request which isn't "simple", e.g. a DELETE or a POST with a JSON body.
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, GET, POST, DELETE")
+					w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+					w.Header().Set("Access-Control-Max-Age", "3600")
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				// Let scripts read the headers they need to follow Location headers and respect the rate limits.
+				w.Header().Set("Access-Control-Expose-Headers",
+					"Location, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
+			}
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// A middleware factory which returns a middleware limiting the number of simultaneous in-flight requests from each
+// client to max. Authenticated clients, with a session or an API token, are identified by their user ID (so that
+// the limit applies across all of their devices), and anonymous clients by their IP address. A max of zero or less
+// disables the limit.
+func (app *application) concurrencyLimit(max int) func(http.Handler) http.Handler {
+	var (
+		mu       sync.Mutex
+		inflight = make(map[string]int)
+	)
+
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key string
+
+			if id := app.apiRequestUserID(r); id != 0 {
+				key = fmt.Sprintf("user:%d", id)
+			} else {
+				key = "ip:" + clientIP(r)
+			}
+
+			mu.Lock()
+
+			// If the client already has the maximum number of requests in flight, send an HTTP 429 Too Many
+			// Requests response.
+			if inflight[key] >= max {
+				mu.Unlock()
+				app.clientError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			inflight[key]++
+			mu.Unlock()
+
+			// Decrement the client's count once the request has been handled, removing the entry altogether when
+			// it reaches zero so that the map only holds clients with requests in flight.
+			defer func() {
+				mu.Lock()
+				inflight[key]--
+				if inflight[key] == 0 {
+					delete(inflight, key)
+				}
+				mu.Unlock()
+			}()
+
+			// Proceed with handling the request, passing control to the next middleware or to the final handler.
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// A middleware which records the time taken to handle every request, so that the load shedder can tell when the
+// application is struggling.
+func (app *application) measureLatency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		next.ServeHTTP(w, r)
+
+		// Long-lived connections say nothing about how quickly we're serving requests.
+		if info := requestInfoFromRequest(r); info != nil && info.Streaming {
+			return
+		}
+
+		app.shedder.observeLatency(time.Since(start))
+	})
+}
+
+// A middleware which rejects a fraction of anonymous requests with an HTTP 503 Service Unavailable response while
+// the application is overloaded. It should only be attached to low-priority routes (e.g. listing pages), and after
+// authenticate so that authenticated users are never shed.
+func (app *application) shedLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) && rand.Float64() < app.shedder.fraction() {
+			w.Header().Set("Retry-After", "5")
+			app.clientError(w, r, http.StatusServiceUnavailable)
+			return
+		}
+
+		// Proceed with handling the request, passing control to the next middleware or to the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -52,3 +59,440 @@ func TestSecureHeaders(t *testing.T) {
 	bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestConcurrencyLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Create a handler which blocks until the release channel is closed, so that we can hold a request in flight.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("OK"))
+	})
+
+	handler := app.sessionManager.LoadAndSave(app.concurrencyLimit(1)(next))
+
+	newRequest := func() *http.Request {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = "192.0.2.1:1234"
+		return r
+	}
+
+	// Send a first request, which is held in flight by the blocking handler.
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest())
+		done <- rr.Code
+	}()
+	<-started
+
+	// A second simultaneous request from the same client should be rejected.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest())
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+
+	// Once the first request completes, the client should be allowed to make requests again.
+	close(release)
+	assert.Equal(t, <-done, http.StatusOK)
+}
+
+func TestConcurrencyLimitAPIUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("OK"))
+	})
+
+	handler := app.concurrencyLimit(1)(next)
+
+	// Requests authenticated with an API token are counted against the user, wherever they come from.
+	newRequest := func(remoteAddr string) *http.Request {
+		r, err := http.NewRequest(http.MethodGet, "/api/v1/snippets", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = remoteAddr
+		return r.WithContext(context.WithValue(r.Context(), apiUserIDContextKey, 1))
+	}
+
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest("192.0.2.1:1234"))
+		done <- rr.Code
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("198.51.100.1:1234"))
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+
+	close(release)
+	assert.Equal(t, <-done, http.StatusOK)
+}
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	send := func(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(http.MethodPost, "/user/login", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = remoteAddr
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	t.Run("Limited", func(t *testing.T) {
+		// Allow a burst of two requests, refilling at one request every ten seconds.
+		handler := app.rateLimit(0.1, 2)(next)
+
+		assert.Equal(t, send(handler, "192.0.2.1:1234").Code, http.StatusOK)
+		assert.Equal(t, send(handler, "192.0.2.1:1234").Code, http.StatusOK)
+
+		rr := send(handler, "192.0.2.1:1234")
+		assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+		assert.Equal(t, rr.Header().Get("Retry-After"), "10")
+
+		// Other clients have their own allowance.
+		assert.Equal(t, send(handler, "198.51.100.7:1234").Code, http.StatusOK)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		handler := app.rateLimit(0, 0)(next)
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, send(handler, "192.0.2.1:1234").Code, http.StatusOK)
+		}
+	})
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	handler := app.authenticateAPI(app.apiRateLimit(map[string]int{
+		apiTierAnonymous:       2,
+		models.APITierStandard: 3,
+		models.APITierElevated: 0,
+	})(next))
+
+	send := func(token, remoteAddr string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(http.MethodGet, "/api/v1/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = remoteAddr
+
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		rr := send("", "192.0.2.1:1234")
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Header().Get("X-RateLimit-Limit"), "2")
+		assert.Equal(t, rr.Header().Get("X-RateLimit-Remaining"), "1")
+
+		assert.Equal(t, send("", "192.0.2.1:1234").Code, http.StatusOK)
+
+		rr = send("", "192.0.2.1:1234")
+		assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+		assert.Equal(t, rr.Header().Get("X-RateLimit-Remaining"), "0")
+
+		// The window resets within the next minute.
+		reset, err := strconv.ParseInt(rr.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait := time.Until(time.Unix(reset, 0)); wait < 0 || wait > time.Minute {
+			t.Errorf("got X-RateLimit-Reset %d seconds away; want within the next minute", int(wait.Seconds()))
+		}
+		assert.StringContains(t, rr.Body.String(), `"status": 429`)
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		// Requests with a token are counted against the token and its tier's limit, rather than the IP address
+		// which has already used up its allowance.
+		for i := 0; i < 3; i++ {
+			rr := send("ALICEAPITOKENABCDEFGHIJKLM", "192.0.2.1:1234")
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, rr.Header().Get("X-RateLimit-Limit"), "3")
+		}
+
+		assert.Equal(t, send("ALICEAPITOKENABCDEFGHIJKLM", "198.51.100.7:1234").Code, http.StatusTooManyRequests)
+	})
+
+	t.Run("Unlimited tier", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			rr := send("ALICEELEVATEDTOKENABCDEFGH", "192.0.2.1:1234")
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, rr.Header().Get("X-RateLimit-Limit"), "")
+		}
+	})
+}
+
+func TestParseAPIRateLimits(t *testing.T) {
+	limits, err := parseAPIRateLimits("anonymous=60  standard=300 elevated=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(limits), 3)
+	assert.Equal(t, limits["anonymous"], 60)
+	assert.Equal(t, limits["standard"], 300)
+	assert.Equal(t, limits["elevated"], 0)
+
+	for _, s := range []string{"anonymous", "=60", "standard=lots", "standard=-1"} {
+		_, err := parseAPIRateLimits(s)
+		if err == nil {
+			t.Errorf("%q: want an error; got none", s)
+		}
+	}
+}
+
+func TestLoadShedderFraction(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		dbWait  time.Duration
+		want    float64
+	}{
+		{
+			name: "Healthy",
+			want: 0,
+		},
+		{
+			name:    "At threshold",
+			latency: time.Second,
+			want:    0,
+		},
+		{
+			name:    "Latency over threshold",
+			latency: 1500 * time.Millisecond,
+			want:    0.5,
+		},
+		{
+			name:   "DB wait over threshold",
+			dbWait: 300 * time.Millisecond,
+			want:   0.5,
+		},
+		{
+			name:    "Severely overloaded",
+			latency: 10 * time.Second,
+			want:    shedMaxFraction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLoadShedder(time.Second, 200*time.Millisecond)
+			s.latency = float64(tt.latency)
+			s.dbWait = float64(tt.dbWait)
+
+			assert.Equal(t, s.fraction(), tt.want)
+		})
+	}
+}
+
+func TestShedLoad(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// While the application is healthy, no requests should be shed.
+	code, _, _ := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Simulate a sustained overload, at which point most anonymous listing requests should be rejected.
+	app.shedder.mu.Lock()
+	app.shedder.latency = float64(time.Hour)
+	app.shedder.mu.Unlock()
+
+	var shed int
+	for range 50 {
+		code, header, _ := ts.get(t, "/")
+		if code == http.StatusServiceUnavailable {
+			assert.Equal(t, header.Get("Retry-After"), "5")
+			shed++
+		}
+	}
+
+	if shed == 0 {
+		t.Errorf("want some requests to be shed; got none")
+	}
+
+	// Pages which aren't listings, such as the login page, should never be shed.
+	for range 10 {
+		code, _, _ := ts.get(t, "/user/login")
+		assert.Equal(t, code, http.StatusOK)
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Record the user's ID as authenticate would.
+		requestInfoFromRequest(r).UserID = 1
+
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("OK"))
+	})
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/snippet/view/1?full=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey, &requestInfo{}))
+
+	app.logRequest(next).ServeHTTP(rr, r)
+
+	var entry struct {
+		Msg      string `json:"msg"`
+		Method   string `json:"method"`
+		Path     string `json:"path"`
+		Status   int    `json:"status"`
+		Bytes    int    `json:"bytes"`
+		Duration int64  `json:"duration"`
+		UserID   int    `json:"user_id"`
+	}
+
+	err = json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, entry.Msg, "request")
+	assert.Equal(t, entry.Method, http.MethodGet)
+	assert.Equal(t, entry.Path, "/snippet/view/1?full=true")
+	assert.Equal(t, entry.Status, http.StatusTeapot)
+	assert.Equal(t, entry.Bytes, 2)
+	assert.Equal(t, entry.Duration > 0, true)
+	assert.Equal(t, entry.UserID, 1)
+}
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	var report errorReport
+	app.reportError = func(r errorReport) {
+		report = r
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	})
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodPost, "/snippet/create", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.recoverPanic(next).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, rs.Header.Get("Connection"), "close")
+	assert.StringContains(t, string(body), "Something went wrong")
+
+	assert.Equal(t, report.Err.Error(), "something broke")
+	assert.Equal(t, report.Method, http.MethodPost)
+	assert.Equal(t, report.Path, "/snippet/create")
+	assert.Equal(t, report.UserID, 0)
+	assert.StringContains(t, string(report.Stack), "TestRecoverPanic")
+}
+
+func TestCORS(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		urlPath        string
+		origin         string
+		preflight      string
+		wantCode       int
+		wantOrigin     string
+		wantMethods    string
+		wantVaryOrigin bool
+	}{
+		{"Same origin", http.MethodGet, "/api/v1/snippets", "", "", http.StatusOK, "", "", true},
+		{"Trusted origin", http.MethodGet, "/api/v1/snippets", "https://app.example.com", "", http.StatusOK, "https://app.example.com", "", true},
+		{"Untrusted origin", http.MethodGet, "/api/v1/snippets", "https://evil.example.com", "", http.StatusOK, "", "", true},
+		{"Unauthenticated request", http.MethodGet, "/api/v1/me", "https://app.example.com", "", http.StatusUnauthorized, "https://app.example.com", "", true},
+		{"Trusted preflight", http.MethodOptions, "/api/v1/snippets/1", "https://app.example.com", "DELETE", http.StatusNoContent, "https://app.example.com", "OPTIONS, GET, POST, DELETE", true},
+		{"Untrusted preflight", http.MethodOptions, "/api/v1/snippets/1", "https://evil.example.com", "DELETE", http.StatusOK, "", "", true},
+		{"Non-API preflight", http.MethodOptions, "/snippet/create", "https://app.example.com", "POST", http.StatusOK, "", "", false},
+		{"Non-API route", http.MethodGet, "/", "https://app.example.com", "", http.StatusOK, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.preflight != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.preflight)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Access-Control-Allow-Origin"), tt.wantOrigin)
+			assert.Equal(t, rs.Header.Get("Access-Control-Allow-Methods"), tt.wantMethods)
+			assert.Equal(t, slices.Contains(rs.Header.Values("Vary"), "Origin"), tt.wantVaryOrigin)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/declanlin/snippetbox/internal/migrations"
+)
+
+// Define a migrator interface, which is satisfied by *migrations.Migrator, so that the migrate command and the
+// schema version check can be tested without a database.
+type migrator interface {
+	Latest() int
+	Version(ctx context.Context) (int, bool, error)
+	Up(ctx context.Context) ([]migrations.Migration, error)
+	Down(ctx context.Context, steps int) ([]migrations.Migration, error)
+	Force(ctx context.Context, version int) error
+}
+
+// Function used to run the migrate command, which manages the database schema from the command line:
+//
+//	web migrate up           apply every pending migration
+//	web migrate down [N]     revert the last N migrations (1 by default)
+//	web migrate version      print the current version of the schema
+//	web migrate force N      record the schema as being at version N, e.g. after repairing a failed migration
+//
+// The usual flags (e.g. -dsn) come before "migrate".
+func runMigrateCommand(ctx context.Context, m migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [N]|version|force N")
+	}
+
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(w, "applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no migrations to apply")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+			steps = n
+		}
+
+		reverted, err := m.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(w, "reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+	case "version":
+		version, dirty, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "version %d of %d", version, m.Latest())
+		if dirty {
+			fmt.Fprint(w, " (dirty)")
+		}
+		fmt.Fprintln(w)
+
+	case "force":
+		if len(args) == 0 {
+			return errors.New("usage: migrate force N")
+		}
+
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+
+		err = m.Force(ctx, version)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "forced version %d\n", version)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+
+	return nil
+}
+
+// Function used to check that the database schema is at the version which the migrations bring it up to, so that
+// the application doesn't start against a database which is missing tables or columns that it uses.
+func checkSchemaVersion(ctx context.Context, m migrator) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d failed partway through; repair the database and run \"migrate force\"", version)
+	case version < m.Latest():
+		return fmt.Errorf("the database schema is at version %d, but version %d is needed; run \"migrate up\" or start with -migrate", version, m.Latest())
+	case version > m.Latest():
+		return fmt.Errorf("the database schema is at version %d, which is newer than this build (version %d)", version, m.Latest())
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/migrations"
+)
+
+// Define a fakeMigrator type which records the schema version in memory, for testing the migrate command.
+type fakeMigrator struct {
+	version int
+	dirty   bool
+}
+
+func (m *fakeMigrator) Latest() int {
+	return 3
+}
+
+func (m *fakeMigrator) Version(ctx context.Context) (int, bool, error) {
+	return m.version, m.dirty, nil
+}
+
+func (m *fakeMigrator) Up(ctx context.Context) ([]migrations.Migration, error) {
+	var applied []migrations.Migration
+	for ; m.version < m.Latest(); m.version++ {
+		applied = append(applied, migrations.Migration{Version: m.version + 1, Name: "test"})
+	}
+	return applied, nil
+}
+
+func (m *fakeMigrator) Down(ctx context.Context, steps int) ([]migrations.Migration, error) {
+	var reverted []migrations.Migration
+	for ; steps > 0 && m.version > 0; steps-- {
+		reverted = append(reverted, migrations.Migration{Version: m.version, Name: "test"})
+		m.version--
+	}
+	return reverted, nil
+}
+
+func (m *fakeMigrator) Force(ctx context.Context, version int) error {
+	m.version, m.dirty = version, false
+	return nil
+}
+
+func TestRunMigrateCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		dirty       bool
+		args        []string
+		wantOutput  string
+		wantVersion int
+		wantErr     bool
+	}{
+		{
+			name:        "Up",
+			version:     1,
+			args:        []string{"up"},
+			wantOutput:  "applied 2_test\napplied 3_test\n",
+			wantVersion: 3,
+		},
+		{
+			name:        "Up to date",
+			version:     3,
+			args:        []string{"up"},
+			wantOutput:  "no migrations to apply\n",
+			wantVersion: 3,
+		},
+		{
+			name:        "Down",
+			version:     3,
+			args:        []string{"down"},
+			wantOutput:  "reverted 3_test\n",
+			wantVersion: 2,
+		},
+		{
+			name:        "Down several",
+			version:     3,
+			args:        []string{"down", "2"},
+			wantOutput:  "reverted 3_test\nreverted 2_test\n",
+			wantVersion: 1,
+		},
+		{
+			name:        "Version",
+			version:     2,
+			dirty:       true,
+			args:        []string{"version"},
+			wantOutput:  "version 2 of 3 (dirty)\n",
+			wantVersion: 2,
+		},
+		{
+			name:        "Force",
+			version:     2,
+			dirty:       true,
+			args:        []string{"force", "3"},
+			wantOutput:  "forced version 3\n",
+			wantVersion: 3,
+		},
+		{
+			name:        "Invalid steps",
+			version:     3,
+			args:        []string{"down", "zero"},
+			wantVersion: 3,
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown command",
+			version:     3,
+			args:        []string{"sideways"},
+			wantVersion: 3,
+			wantErr:     true,
+		},
+		{
+			name:        "No command",
+			version:     3,
+			wantVersion: 3,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeMigrator{version: tt.version, dirty: tt.dirty}
+
+			var buf bytes.Buffer
+			err := runMigrateCommand(context.Background(), m, tt.args, &buf)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, buf.String(), tt.wantOutput)
+			assert.Equal(t, m.version, tt.wantVersion)
+		})
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		dirty   bool
+		wantErr string
+	}{
+		{name: "Up to date", version: 3},
+		{name: "Behind", version: 2, wantErr: "run \"migrate up\""},
+		{name: "Ahead", version: 4, wantErr: "newer than this build"},
+		{name: "Dirty", version: 3, dirty: true, wantErr: "failed partway through"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSchemaVersion(context.Background(), &fakeMigrator{version: tt.version, dirty: tt.dirty})
+
+			if tt.wantErr == "" {
+				assert.Equal(t, err, nil)
+				return
+			}
+
+			assert.Equal(t, err != nil, true)
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := migrations.Load("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(all) > 0, true)
+	assert.Equal(t, all[0].Name, "create_snippets_users_sessions")
+
+	for i, migration := range all {
+		assert.Equal(t, migration.Version, i+1)
+	}
+
+	// Every database has the same migrations, so that a version means the same schema on each of them.
+	for _, driver := range []string{"postgres", "sqlite"} {
+		other, err := migrations.Load(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(other), len(all))
+
+		for i, migration := range other {
+			assert.Equal(t, migration.Version, all[i].Version)
+			assert.Equal(t, migration.Name, all[i].Name)
+		}
+	}
+
+	_, err = migrations.Load("oracle")
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+)
+
+// The elements whose contents are copied as they are by minifyHTML, since whitespace is significant in them (or,
+// for scripts, since it can't be removed safely without parsing JavaScript). Style sheets are minified as CSS.
+var rawTextElements = []string{"pre", "textarea", "script", "style"}
+
+// Define the patterns used by minifyCSS.
+var (
+	cssCommentRX     = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssWhitespaceRX  = regexp.MustCompile(`\s+`)
+	cssPunctuationRX = regexp.MustCompile(`\s*([{};,])\s*`)
+)
+
+// Function used to shrink a rendered page (see the -minify-html flag). Comments are removed and runs of whitespace
+// are collapsed to a single space, which browsers render the same way, except in the elements listed in
+// rawTextElements. Quoted attribute values are left alone, since they may hold the user's own text.
+func minifyHTML(src []byte) []byte {
+	dst := make([]byte, 0, len(src))
+
+	for i := 0; i < len(src); {
+		switch {
+		case bytes.HasPrefix(src[i:], []byte("<!--")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return dst
+			}
+			i += 4 + end + 3
+
+		case src[i] == '<':
+			var name string
+			dst, name, i = copyTag(dst, src, i)
+
+			if !slices.Contains(rawTextElements, name) {
+				continue
+			}
+
+			end := indexClosingTag(src[i:], name)
+			if name == "style" {
+				dst = append(dst, minifyCSS(src[i:i+end])...)
+			} else {
+				dst = append(dst, src[i:i+end]...)
+			}
+			i += end
+
+		case isHTMLSpace(src[i]):
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			dst = append(dst, ' ')
+
+		default:
+			dst = append(dst, src[i])
+			i++
+		}
+	}
+
+	return dst
+}
+
+// Function used to copy the tag starting at src[i] to dst, collapsing whitespace outside of quoted attribute
+// values. It returns the extended dst, the lower-case name of an opening tag (empty for closing tags, doctypes
+// etc.), and the index just after the tag.
+func copyTag(dst, src []byte, i int) ([]byte, string, int) {
+	start := i + 1
+	end := start
+	for end < len(src) && (isASCIILetter(src[end]) || (end > start && src[end] >= '0' && src[end] <= '9')) {
+		end++
+	}
+	name := string(bytes.ToLower(src[start:end]))
+
+	var quote byte
+	for ; i < len(src); i++ {
+		c := src[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return append(dst, c), name, i + 1
+		case isHTMLSpace(c):
+			for i+1 < len(src) && isHTMLSpace(src[i+1]) {
+				i++
+			}
+			c = ' '
+		}
+
+		dst = append(dst, c)
+	}
+
+	return dst, name, i
+}
+
+// Function used to return the index of the closing tag for the named element in src, or the length of src if it
+// isn't closed.
+func indexClosingTag(src []byte, name string) int {
+	closing := []byte("</" + name)
+
+	for i := 0; i+len(closing) <= len(src); i++ {
+		if src[i] == '<' && bytes.EqualFold(src[i:i+len(closing)], closing) {
+			return i
+		}
+	}
+
+	return len(src)
+}
+
+// Function used to shrink a style sheet by removing its comments and the whitespace which isn't needed.
+func minifyCSS(src []byte) []byte {
+	src = cssCommentRX.ReplaceAll(src, nil)
+	src = cssWhitespaceRX.ReplaceAll(src, []byte(" "))
+	src = cssPunctuationRX.ReplaceAll(src, []byte("$1"))
+
+	return bytes.TrimSpace(src)
+}
+
+// Function used to report whether a byte is whitespace, as HTML defines it.
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Function used to report whether a byte is an ASCII letter, which tag names start with.
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Whitespace",
+			src:  "<main>\n    <h2>Latest   Snippets</h2>\n</main>\n",
+			want: "<main> <h2>Latest Snippets</h2> </main> ",
+		},
+		{
+			name: "Comments",
+			src:  "<p>An old<!-- silent --> pond</p>",
+			want: "<p>An old pond</p>",
+		},
+		{
+			name: "Attributes",
+			src:  "<input  type=\"text\"\n    value=\"An  old\tpond\" title='a  >  b'>",
+			want: "<input type=\"text\" value=\"An  old\tpond\" title='a  >  b'>",
+		},
+		{
+			name: "Preformatted text",
+			src:  "<pre><code>func main() {\n    fmt.Println(\"hi\")\n}</code></pre>\n\n<p>Done</p>",
+			want: "<pre><code>func main() {\n    fmt.Println(\"hi\")\n}</code></pre> <p>Done</p>",
+		},
+		{
+			name: "Text area",
+			src:  "<TEXTAREA name=\"content\">  two\n\nlines</TEXTAREA>",
+			want: "<TEXTAREA name=\"content\">  two\n\nlines</TEXTAREA>",
+		},
+		{
+			name: "Script",
+			src:  "<script>\n  if (a  <  b) {}\n</script>",
+			want: "<script>\n  if (a  <  b) {}\n</script>",
+		},
+		{
+			name: "Style",
+			src:  "<style>\n  /* Headings */\n  h2 , h3 {\n    color: red;\n  }\n</style>",
+			want: "<style>h2,h3{color: red;}</style>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(minifyHTML([]byte(tt.src))), tt.want)
+		})
+	}
+}
+
+func TestMinifiedPages(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.minifyHTML = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<pre><code>An old silent pond...</code></pre>")
+	assert.Equal(t, strings.Contains(body, "\n"), false)
+}
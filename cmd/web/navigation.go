@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Define a routeMeta type to describe a page route for the navigation: its name, the section of the nav bar which
+// it belongs to (e.g. "account"), the title which breadcrumbs show for it, and the name of the route above it in the
+// breadcrumb trail. Routes which appear in another route's trail can't have parameters, since the breadcrumbs link
+// to them.
+type routeMeta struct {
+	Pattern string
+	Name    string
+	Section string
+	Title   string
+	Parent  string
+}
+
+// The metadata for the page routes, using the same patterns as routes(). Routes which aren't listed here (e.g. the
+// API, and redirects) have no nav section or breadcrumbs.
+var routeMetadata = []routeMeta{
+	{Pattern: "/", Name: "home", Section: "home", Title: "Home"},
+	{Pattern: "/snippet/view/:id", Name: "snippet.view", Section: "home", Title: "Snippet", Parent: "home"},
+	{Pattern: "/snippet/print/:id", Name: "snippet.print", Section: "home", Title: "Print snippet", Parent: "home"},
+	{Pattern: "/snippet/create", Name: "snippet.create", Section: "create", Title: "Create snippet", Parent: "home"},
+	{Pattern: "/snippet/edit/:id", Name: "snippet.edit", Section: "home", Title: "Edit snippet", Parent: "home"},
+	{Pattern: "/preview/:token", Name: "snippet.preview", Section: "home", Title: "Snippet", Parent: "home"},
+	{Pattern: "/user/profile/:id", Name: "user.profile", Section: "home", Title: "Profile", Parent: "home"},
+	{Pattern: "/user/signup", Name: "user.signup", Section: "signup", Title: "Signup"},
+	{Pattern: "/user/login", Name: "user.login", Section: "login", Title: "Login"},
+	{Pattern: "/user/login/link", Name: "user.login.link", Section: "login", Title: "Login link", Parent: "user.login"},
+	{Pattern: "/contact", Name: "contact", Section: "contact", Title: "Contact"},
+	{Pattern: "/api/docs", Name: "api.docs", Section: "home", Title: "API documentation", Parent: "home"},
+	{Pattern: "/account/profile", Name: "account.profile", Section: "account", Title: "Profile"},
+	{Pattern: "/account/reauthenticate", Name: "account.reauthenticate", Section: "account", Title: "Confirm your password", Parent: "account.profile"},
+	{Pattern: "/account/settings", Name: "account.settings", Section: "account", Title: "Settings", Parent: "account.profile"},
+	{Pattern: "/account/password/update", Name: "account.password", Section: "account", Title: "Change password", Parent: "account.profile"},
+	{Pattern: "/account/sessions", Name: "account.sessions", Section: "account", Title: "Active sessions", Parent: "account.profile"},
+	{Pattern: "/account/api-tokens", Name: "account.apitokens", Section: "account", Title: "API tokens", Parent: "account.profile"},
+	{Pattern: "/account/webhooks", Name: "account.webhooks", Section: "account", Title: "Webhooks", Parent: "account.profile"},
+	{Pattern: "/account/webhooks/:id/deliveries", Name: "account.webhooks.deliveries", Section: "account", Title: "Deliveries", Parent: "account.webhooks"},
+	{Pattern: "/account/delete", Name: "account.delete", Section: "account", Title: "Delete account", Parent: "account.profile"},
+	{Pattern: "/admin", Name: "admin", Section: "admin", Title: "Admin"},
+	{Pattern: "/admin/config", Name: "admin.config", Section: "admin", Title: "Configuration", Parent: "admin"},
+	{Pattern: "/admin/users", Name: "admin.users", Section: "admin", Title: "Users", Parent: "admin"},
+	{Pattern: "/admin/snippets", Name: "admin.snippets", Section: "admin", Title: "Snippets", Parent: "admin"},
+	{Pattern: "/admin/archive", Name: "admin.archive", Section: "admin", Title: "Archive", Parent: "admin"},
+	{Pattern: "/admin/invites", Name: "admin.invites", Section: "admin", Title: "Invites", Parent: "admin"},
+	{Pattern: "/admin/blocked-words", Name: "admin.blockedwords", Section: "admin", Title: "Blocked words", Parent: "admin"},
+}
+
+// Define a breadcrumb type for one link in a page's breadcrumb trail. The last breadcrumb is the page itself, and
+// has no URL.
+type breadcrumb struct {
+	Title string
+	URL   string
+}
+
+// Function used to return the metadata for the route which matches a request path, if it has any. As with the
+// router, a ":name" segment of a pattern matches any single segment of the path.
+func matchRoute(path string) (routeMeta, bool) {
+	segments := strings.Split(path, "/")
+
+	for _, route := range routeMetadata {
+		pattern := strings.Split(route.Pattern, "/")
+		if len(pattern) != len(segments) {
+			continue
+		}
+
+		matched := true
+		for i := range pattern {
+			if pattern[i] != segments[i] && (!strings.HasPrefix(pattern[i], ":") || segments[i] == "") {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return route, true
+		}
+	}
+
+	return routeMeta{}, false
+}
+
+// Function used to return the breadcrumb trail for a route, from the top-level route down to the route itself.
+// Routes without a parent don't have a trail, since it would only hold the page itself.
+func breadcrumbs(route routeMeta) []breadcrumb {
+	if route.Parent == "" {
+		return nil
+	}
+
+	trail := []breadcrumb{{Title: route.Title}}
+
+	for name := route.Parent; name != ""; {
+		parent, ok := routeByName(name)
+		if !ok {
+			break
+		}
+
+		trail = append([]breadcrumb{{Title: parent.Title, URL: parent.Pattern}}, trail...)
+		name = parent.Parent
+	}
+
+	return trail
+}
+
+// Function used to return the metadata for the route with the given name.
+func routeByName(name string) (routeMeta, bool) {
+	for _, route := range routeMetadata {
+		if route.Name == name {
+			return route, true
+		}
+	}
+
+	return routeMeta{}, false
+}
+
+// Function used to build the path of the route with the given name (see routeMetadata), filling in its parameters
+// in order, e.g. {{urlFor "snippet.view" .ID}} gives "/snippet/view/1". Rendering fails if there is no such route, or
+// the number of parameters is wrong, so that broken links are caught by the tests rather than by visitors.
+func urlFor(name string, params ...any) (string, error) {
+	route, ok := routeByName(name)
+	if !ok {
+		return "", fmt.Errorf("urlFor: no route named %q", name)
+	}
+
+	segments := strings.Split(route.Pattern, "/")
+
+	used := 0
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		if used == len(params) {
+			return "", fmt.Errorf("urlFor: route %q needs more than %d parameters", name, len(params))
+		}
+
+		segments[i] = url.PathEscape(fmt.Sprint(params[used]))
+		used++
+	}
+
+	if used != len(params) {
+		return "", fmt.Errorf("urlFor: route %q takes %d parameters, not %d", name, used, len(params))
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// Function used to build the path of a named route from a handler, e.g. for a redirect. The route names used by
+// handlers are fixed, so an error here is a programming mistake, and is raised as a panic for recoverPanic to report.
+func mustURLFor(name string, params ...any) string {
+	path, err := urlFor(name, params...)
+	if err != nil {
+		panic(err)
+	}
+
+	return path
+}
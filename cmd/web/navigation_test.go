@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestRouteMetadata(t *testing.T) {
+	names := map[string]bool{}
+
+	for _, route := range routeMetadata {
+		assert.Equal(t, names[route.Name], false)
+		names[route.Name] = true
+	}
+
+	// Every parent exists, and can be linked to since it has no parameters.
+	for _, route := range routeMetadata {
+		if route.Parent == "" {
+			continue
+		}
+
+		parent, ok := routeByName(route.Parent)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, strings.Contains(parent.Pattern, ":"), false)
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		wantName string
+	}{
+		{"Home", "/", "home"},
+		{"Static", "/account/sessions", "account.sessions"},
+		{"Parameter", "/snippet/view/1", "snippet.view"},
+		{"Parameter in the middle", "/account/webhooks/3/deliveries", "account.webhooks.deliveries"},
+		{"Empty parameter", "/snippet/view/", ""},
+		{"Too long", "/snippet/view/1/extra", ""},
+		{"Unknown", "/nowhere", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, ok := matchRoute(tt.path)
+			assert.Equal(t, ok, tt.wantName != "")
+			assert.Equal(t, route.Name, tt.wantName)
+		})
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	route, _ := routeByName("account.webhooks.deliveries")
+
+	assert.Equal(t, len(breadcrumbs(route)), 3)
+	assert.Equal(t, breadcrumbs(route)[0], breadcrumb{Title: "Profile", URL: "/account/profile"})
+	assert.Equal(t, breadcrumbs(route)[1], breadcrumb{Title: "Webhooks", URL: "/account/webhooks"})
+	assert.Equal(t, breadcrumbs(route)[2], breadcrumb{Title: "Deliveries"})
+
+	// Top-level routes don't have a trail.
+	route, _ = routeByName("home")
+	assert.Equal(t, len(breadcrumbs(route)), 0)
+}
+
+func TestNavigation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="/" class="live">Home</a>`)
+	assert.Equal(t, strings.Contains(body, `class="breadcrumbs"`), false)
+
+	ts.login(t)
+
+	code, _, body = ts.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href="/account/profile" class="live">Profile</a>`)
+	assert.StringContains(t, body, `<li><a href="/account/profile">Profile</a></li>`)
+	assert.StringContains(t, body, `<li aria-current="page">Active sessions</li>`)
+	assert.Equal(t, strings.Contains(body, `<a href="/" class="live">`), false)
+}
+
+func TestMustURLFor(t *testing.T) {
+	assert.Equal(t, mustURLFor("snippet.view", 1), "/snippet/view/1")
+
+	defer func() {
+		assert.Equal(t, recover() != nil, true)
+	}()
+
+	mustURLFor("snippet.view")
+	t.Fatal("expected a panic")
+}
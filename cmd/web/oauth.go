@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// Define an oauthUser type to hold the details of a user as reported by an OAuth provider. Subject is the
+// provider's own stable identifier for the user.
+type oauthUser struct {
+	Subject       string
+	Name          string
+	Email         string
+	EmailVerified bool
+}
+
+// Define an oauthProvider type which holds the OAuth2 client configuration for a provider, along with a function
+// used to fetch the authenticated user's details from the provider's API.
+type oauthProvider struct {
+	config    *oauth2.Config
+	fetchUser func(ctx context.Context, client *http.Client) (*oauthUser, error)
+}
+
+// Function used to build the map of enabled OAuth providers. A provider is only enabled when a client ID has been
+// configured for it. redirectBase is the external base URL of the application (e.g. "https://snippetbox.example.com"),
+// which the providers redirect back to once the user has logged in.
+func newOAuthProviders(redirectBase, githubClientID, githubClientSecret, googleClientID, googleClientSecret string) map[string]*oauthProvider {
+	providers := make(map[string]*oauthProvider)
+
+	if githubClientID != "" {
+		providers["github"] = &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     githubClientID,
+				ClientSecret: githubClientSecret,
+				Endpoint:     endpoints.GitHub,
+				RedirectURL:  redirectBase + "/user/oauth/github/callback",
+				Scopes:       []string{"read:user", "user:email"},
+			},
+			fetchUser: fetchGitHubUser,
+		}
+	}
+
+	if googleClientID != "" {
+		providers["google"] = &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     googleClientID,
+				ClientSecret: googleClientSecret,
+				Endpoint:     endpoints.Google,
+				RedirectURL:  redirectBase + "/user/oauth/google/callback",
+				Scopes:       []string{"openid", "profile", "email"},
+			},
+			fetchUser: fetchGoogleUser,
+		}
+	}
+
+	return providers
+}
+
+// Function used to send a GET request to a provider's API and decode the JSON response into dst.
+func getJSON(ctx context.Context, client *http.Client, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	rs, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", rs.StatusCode, url)
+	}
+
+	return json.NewDecoder(rs.Body).Decode(dst)
+}
+
+// Function used to fetch the authenticated user's details from the GitHub API. GitHub only includes the user's
+// public email address in their profile, so the primary verified email is looked up separately.
+func fetchGitHubUser(ctx context.Context, client *http.Client) (*oauthUser, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+
+	err := getJSON(ctx, client, "https://api.github.com/user", &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+
+	err = getJSON(ctx, client, "https://api.github.com/user/emails", &emails)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &oauthUser{
+		Subject: strconv.FormatInt(profile.ID, 10),
+		Name:    profile.Name,
+	}
+
+	// Not every GitHub user sets a display name, so fall back to their username.
+	if user.Name == "" {
+		user.Name = profile.Login
+	}
+
+	for _, e := range emails {
+		if e.Primary {
+			user.Email = e.Email
+			user.EmailVerified = e.Verified
+		}
+	}
+
+	if user.Email == "" {
+		return nil, errors.New("github user has no primary email address")
+	}
+
+	return user, nil
+}
+
+// Function used to fetch the authenticated user's details from Google's OpenID Connect userinfo endpoint.
+func fetchGoogleUser(ctx context.Context, client *http.Client) (*oauthUser, error) {
+	var profile struct {
+		Sub           string `json:"sub"`
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+
+	err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthUser{
+		Subject:       profile.Sub,
+		Name:          profile.Name,
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerified,
+	}, nil
+}
+
+// Function used to return the names of the enabled OAuth providers in alphabetical order, for display on the
+// login page.
+func (app *application) oauthProviderNames() []string {
+	names := make([]string, 0, len(app.oauthProviders))
+	for name := range app.oauthProviders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Redirect the user to the OAuth provider given in the URL so that they can log in there.
+func (app *application) userOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	provider, ok := app.oauthProviders[params.ByName("provider")]
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	app.redirectToOAuthProvider(w, r, provider)
+}
+
+// Redirect the authenticated user to the OAuth provider given in the URL, so that the identity they log in to there
+// is linked to their account when they come back. This lets users link identities which aren't linked automatically
+// when they log in with them, e.g. because the account's email address hasn't been verified (see
+// models.UserIdentityModel.Authenticate) or is different from the one the provider has.
+func (app *application) accountOAuthLinkPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	provider, ok := app.oauthProviders[params.ByName("provider")]
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "oauthLink", true)
+
+	app.redirectToOAuthProvider(w, r, provider)
+}
+
+// Function used to redirect the user to an OAuth provider's login page, which sends them back to the callback.
+func (app *application) redirectToOAuthProvider(w http.ResponseWriter, r *http.Request, provider *oauthProvider) {
+	// Generate a random state value, which the provider sends back to the callback. Storing it in the session lets
+	// the callback check that the login was started by this user, preventing login CSRF attacks. A PKCE verifier is
+	// stored alongside it so that an intercepted authorization code is useless on its own.
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	verifier := oauth2.GenerateVerifier()
+
+	app.sessionManager.Put(r.Context(), "oauthState", state)
+	app.sessionManager.Put(r.Context(), "oauthVerifier", verifier)
+
+	url := provider.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// Handle the redirect back from the OAuth provider, logging the user in to the account linked to their identity
+// with the provider (creating the account if necessary).
+func (app *application) userOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	name := params.ByName("provider")
+
+	provider, ok := app.oauthProviders[name]
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	// Read the state and verifier from the session, removing them so that they can't be used again, along with
+	// whether the identity is to be linked to the authenticated user rather than logged in with.
+	state := app.sessionManager.PopString(r.Context(), "oauthState")
+	verifier := app.sessionManager.PopString(r.Context(), "oauthVerifier")
+	link := app.sessionManager.PopBool(r.Context(), "oauthLink")
+
+	query := r.URL.Query()
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// The provider redirects back with an error (rather than a code) if the user declined to log in.
+	if query.Get("error") != "" || query.Get("code") == "" {
+		app.flash(r, flashInfo, "Login was cancelled.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	token, err := provider.config.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	user, err := provider.fetchUser(r.Context(), provider.config.Client(r.Context(), token))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if link {
+		app.linkOAuthIdentity(w, r, name, user)
+		return
+	}
+
+	// Only trust email addresses which the provider has verified, since the email address is used to link the
+	// identity to an existing account.
+	if !user.EmailVerified {
+		app.flash(r, flashWarning, "Please verify your email address with your provider before logging in.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	id, err := app.identities.Authenticate(r.Context(), name, user.Subject, user.Name, user.Email)
+	if err != nil {
+		// The identity isn't linked to an unverified account with the same email address, so its owner has to prove
+		// that the account is theirs by logging in with its password first.
+		if errors.Is(err, models.ErrAccountUnverified) {
+			app.flash(r, flashWarning, "An account with this email address already exists. Please log in with your password, then link your account with the provider from your account settings.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Deactivated users can't log in, whichever way they try.
+	account, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !account.Active {
+		app.flash(r, flashError, "This account has been deactivated.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err = app.logIn(r, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Logging in with a provider also counts as re-authenticating, so send users who were asked to re-authenticate
+	// back to where they came from.
+	redirect := app.sessionManager.PopString(r.Context(), "reauthRedirect")
+	if redirect == "" {
+		redirect = "/snippet/create"
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// Function used to link the identity which the user logged in to with an OAuth provider to the authenticated user,
+// once they come back to the callback after starting from their account settings.
+func (app *application) linkOAuthIdentity(w http.ResponseWriter, r *http.Request, provider string, user *oauthUser) {
+	// The session may have expired while the user was at the provider.
+	if !app.isAuthenticated(r) {
+		app.flash(r, flashWarning, "Please log in before linking an account.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.identities.Link(r.Context(), userID, provider, user.Subject)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateIdentity) {
+			app.flash(r, flashError, "This account with the provider is already linked to another user.")
+			http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.flash(r, flashSuccess, "Your account with the provider has been linked. You can now log in with it.")
+	http.Redirect(w, r, "/account/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestUserOAuthCallback(t *testing.T) {
+	// A fake provider's token endpoint, which issues a token for any authorization code.
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"bearer"}`))
+	}))
+	defer tokenServer.Close()
+
+	tests := []struct {
+		name         string
+		link         bool
+		user         oauthUser
+		wantLocation string
+		wantFlash    string
+	}{
+		{
+			name:         "Verified account",
+			user:         oauthUser{Subject: "1", Name: "Alice", Email: "alice@example.com", EmailVerified: true},
+			wantLocation: "/snippet/create",
+		},
+		{
+			name:         "Unverified provider email",
+			user:         oauthUser{Subject: "1", Name: "Alice", Email: "alice@example.com"},
+			wantLocation: "/user/login",
+			wantFlash:    "Please verify your email address with your provider before logging in.",
+		},
+		{
+			name:         "Unverified account",
+			user:         oauthUser{Subject: "1", Name: "Mallory", Email: "unverified@example.com", EmailVerified: true},
+			wantLocation: "/user/login",
+			wantFlash:    "Please log in with your password, then link your account with the provider from your account settings.",
+		},
+		{
+			name:         "Link",
+			link:         true,
+			user:         oauthUser{Subject: "1", Name: "Alice", Email: "alice@example.org"},
+			wantLocation: "/account/settings",
+			wantFlash:    "Your account with the provider has been linked.",
+		},
+		{
+			name:         "Link identity of another user",
+			link:         true,
+			user:         oauthUser{Subject: "linked-elsewhere", Name: "Bob", Email: "bob@example.com", EmailVerified: true},
+			wantLocation: "/account/settings",
+			wantFlash:    "This account with the provider is already linked to another user.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.oauthProviders["test"] = &oauthProvider{
+				config: &oauth2.Config{
+					ClientID:    "test-id",
+					Endpoint:    oauth2.Endpoint{AuthURL: "https://provider.example.com/auth", TokenURL: tokenServer.URL},
+					RedirectURL: "https://snippetbox.example.com/user/oauth/test/callback",
+				},
+				fetchUser: func(ctx context.Context, client *http.Client) (*oauthUser, error) {
+					user := tt.user
+					return &user, nil
+				},
+			}
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var header http.Header
+
+			if tt.link {
+				ts.login(t)
+
+				_, _, body := ts.get(t, "/account/settings")
+				assert.StringContains(t, body, `<form action="/account/oauth/test" method="POST">`)
+
+				form := url.Values{}
+				form.Add("csrf_token", extractCSRFToken(t, body))
+
+				_, header, _ = ts.postForm(t, "/account/oauth/test", form)
+			} else {
+				_, header, _ = ts.get(t, "/user/oauth/test")
+			}
+
+			location, err := url.Parse(header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, location.Host, "provider.example.com")
+
+			callback := "/user/oauth/test/callback?" + url.Values{
+				"state": {location.Query().Get("state")},
+				"code":  {"abc"},
+			}.Encode()
+
+			code, header, _ := ts.get(t, callback)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantFlash != "" {
+				_, _, body := ts.get(t, "/")
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The size of the embedded snippet when the consumer doesn't limit it, the number of characters of the snippet
+// shown in the embed, and how long consumers may cache the response for (in seconds).
+const (
+	oembedWidth      = 600
+	oembedHeight     = 300
+	oembedExcerptLen = 280
+	oembedCacheAge   = 3600
+)
+
+// Define the response to an oEmbed request (see https://oembed.com). Snippets are embedded with the rich type, as a
+// quote of the start of the snippet which links back to it.
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	AuthorURL    string `json:"author_url,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	CacheAge     int    `json:"cache_age"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// The HTML which is embedded for a snippet. It is parsed with html/template, so the snippet is escaped.
+var oembedTemplate = template.Must(template.New("oembed").Parse(
+	`<blockquote class="snippetbox-snippet"><p><a href="{{.URL}}">{{.Title}}</a>{{with .Author}} by {{.}}{{end}}</p>` +
+		`<pre>{{.Excerpt}}</pre></blockquote>`))
+
+// Respond to an oEmbed request for the URL of a snippet, so that sites like Slack and Discourse can show a preview
+// of links to snippets. Only public snippets can be embedded; for anything else an HTTP 404 Not Found response is
+// sent, as the oEmbed spec asks. Only the JSON format is supported.
+func (app *application) apiOEmbed(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	if format := qs.Get("format"); format != "" && format != "json" {
+		app.clientError(w, r, http.StatusNotImplemented)
+		return
+	}
+
+	if qs.Get("url") == "" {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	id, ok := app.snippetIDFromURL(qs.Get("url"))
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.Private {
+		app.notFound(w, r)
+		return
+	}
+
+	resp := oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        snippet.Title,
+		ProviderName: app.config.siteName,
+		ProviderURL:  app.config.baseURL + "/",
+		CacheAge:     oembedCacheAge,
+		Width:        readDimension(qs.Get("maxwidth"), oembedWidth),
+		Height:       readDimension(qs.Get("maxheight"), oembedHeight),
+	}
+
+	// The author is a nice-to-have, so the embed is still sent if their account has since been deleted.
+	user, err := app.users.Get(r.Context(), snippet.UserID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if user != nil {
+		resp.AuthorName = user.Name
+		resp.AuthorURL = app.config.baseURL + mustURLFor("user.profile", user.ID)
+	}
+
+	var buf bytes.Buffer
+
+	err = oembedTemplate.Execute(&buf, map[string]string{
+		"URL":     app.config.baseURL + mustURLFor("snippet.view", snippet.ID),
+		"Title":   snippet.Title,
+		"Author":  resp.AuthorName,
+		"Excerpt": excerpt(snippet.Content, oembedExcerptLen),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	resp.HTML = buf.String()
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", oembedCacheAge))
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// Function used to return the ID of the snippet which a URL links to. Only URLs of snippet pages on this site are
+// accepted, though either scheme is allowed since links are often shared without it being changed.
+func (app *application) snippetIDFromURL(rawURL string) (int, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, false
+	}
+
+	base, err := url.Parse(app.config.baseURL)
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return 0, false
+	}
+
+	s, found := strings.CutPrefix(u.Path, "/snippet/view/")
+	if !found {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Function used to read a maximum width or height from an oEmbed request, returning the smaller of it and the
+// default. Values which aren't positive integers are ignored.
+func readDimension(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return def
+	}
+
+	return min(n, def)
+}
+
+// Function used to shorten text to at most n characters, ending it with an ellipsis if anything was cut off.
+func excerpt(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
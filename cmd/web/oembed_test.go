@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAPIOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+	}{
+		{
+			name:     "Valid",
+			query:    "?url=" + url.QueryEscape("https://snippetbox.example.com/snippet/view/1"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Plain HTTP",
+			query:    "?url=" + url.QueryEscape("http://snippetbox.example.com/snippet/view/1") + "&format=json",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Missing URL",
+			query:    "",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "XML format",
+			query:    "?url=" + url.QueryEscape("https://snippetbox.example.com/snippet/view/1") + "&format=xml",
+			wantCode: http.StatusNotImplemented,
+		},
+		{
+			name:     "Other site",
+			query:    "?url=" + url.QueryEscape("https://example.com/snippet/view/1"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Not a snippet",
+			query:    "?url=" + url.QueryEscape("https://snippetbox.example.com/user/profile/1"),
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent snippet",
+			query:    "?url=" + url.QueryEscape("https://snippetbox.example.com/snippet/view/2"),
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, "/api/oembed"+tt.query)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+
+	t.Run("Response", func(t *testing.T) {
+		query := "?maxwidth=400&url=" + url.QueryEscape("https://snippetbox.example.com/snippet/view/1")
+
+		code, header, body := ts.get(t, "/api/oembed"+query)
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Cache-Control"), "public, max-age=3600")
+
+		var resp oembedResponse
+
+		err := json.Unmarshal([]byte(body), &resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, resp.Type, "rich")
+		assert.Equal(t, resp.Title, "An old silent pond")
+		assert.Equal(t, resp.AuthorName, "Alice")
+		assert.Equal(t, resp.AuthorURL, "https://snippetbox.example.com/user/profile/1")
+		assert.Equal(t, resp.Width, 400)
+		assert.Equal(t, resp.Height, oembedHeight)
+		assert.StringContains(t, resp.HTML, `<a href="https://snippetbox.example.com/snippet/view/1">An old silent pond</a> by Alice`)
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<link rel='alternate' type='application/json+oembed' href='https://snippetbox.example.com/api/oembed?url=https%3a%2f%2fsnippetbox.example.com%2fsnippet%2fview%2f1'")
+	})
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{
+			name: "Short",
+			s:    "  An old silent pond...\n",
+			n:    30,
+			want: "An old silent pond...",
+		},
+		{
+			name: "Long",
+			s:    "An old silent pond",
+			n:    8,
+			want: "An old…",
+		},
+		{
+			name: "Multibyte",
+			s:    "古池や蛙飛び込む水の音",
+			n:    4,
+			want: "古池や…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, excerpt(tt.s, tt.n), tt.want)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/declanlin/snippetbox/ui"
+)
+
+// Define the parts of the OpenAPI document which are shown on the API documentation page. The document itself is
+// maintained by hand in ui/api/openapi.json, and must be updated whenever the API changes.
+type openAPIDocument struct {
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Paths map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	Responses   map[string]json.RawMessage `json:"responses"`
+}
+
+// Serve the OpenAPI document describing the JSON API.
+func (app *application) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	js, err := ui.Files.ReadFile("api/openapi.json")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(js)
+}
+
+// Display a page documenting the JSON API, rendered from the OpenAPI document. The page is rendered by the
+// application rather than a JavaScript viewer like Swagger UI, so that it works with our Content-Security-Policy.
+func (app *application) apiDocs(w http.ResponseWriter, r *http.Request) {
+	js, err := ui.Files.ReadFile("api/openapi.json")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var doc openAPIDocument
+
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APIDocs = &doc
+	app.render(w, r, http.StatusOK, "apidocs.tmpl", data)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAPIOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/api/openapi.json")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	var doc openAPIDocument
+
+	err := json.Unmarshal([]byte(body), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every operation in the document should be routed, so that the document doesn't drift from the API.
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			urlPath := strings.ReplaceAll(path, "{id}", "1")
+
+			req, err := http.NewRequest(strings.ToUpper(method), ts.URL+urlPath, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			// Only the status code is checked, since the bodies of streaming operations never end.
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode == http.StatusNotFound || rs.StatusCode == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: got status %d; want the operation to be routed", strings.ToUpper(method), path, rs.StatusCode)
+			}
+		}
+	}
+}
+
+func TestAPIDocs(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/docs")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<code>GET /api/v1/snippets/{id}</code>")
+	assert.StringContains(t, body, "<code>DELETE /api/v1/snippets/{id}</code>")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The interval at which the outbox is polled for jobs which are due to be run, the maximum number of jobs which are
+// run on each poll, and how long the jobs in a batch are claimed for. The lease has to outlast a batch of webhook
+// deliveries which all time out, or another process could run the last of them again.
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 50
+	outboxLease        = 15 * time.Minute
+)
+
+// Define a mailJob type to hold the payload of a models.JobKindMail job.
+type mailJob struct {
+	Recipient string `json:"recipient"`
+	Template  string `json:"template"`
+	Data      any    `json:"data"`
+}
+
+// runOutbox() polls the outbox for pending jobs and runs them until the context is cancelled. It is intended to be
+// launched in its own goroutine when the application starts, so that jobs left over from a previous process are
+// picked up again.
+func (app *application) runOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	// As with the expiry notifier, a batch which is in progress when the context is cancelled is finished, so that
+	// a job which has been run (e.g. an email which has been sent) is still marked as completed.
+	batchCtx := context.WithoutCancel(ctx)
+
+	worker := outboxWorkerID()
+
+	for {
+		app.drainOutbox(batchCtx, worker)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// outboxWorkerID() returns a name for this process's outbox dispatcher, which is recorded against the jobs it
+// claims. It is made up of the host name and process ID, which identify the process to operators, and a random
+// suffix so that it is unique even if they aren't (e.g. in containers).
+func outboxWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// drainOutbox() claims a single batch of pending jobs for the worker and runs them, marking each one as completed
+// or failed.
+func (app *application) drainOutbox(ctx context.Context, worker string) {
+	jobs, err := app.outbox.Claim(ctx, worker, outboxBatchSize, outboxLease)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, job := range jobs {
+		err := app.runJob(ctx, job)
+		if err != nil {
+			// Back off exponentially between attempts (1 minute, 2 minutes, 4 minutes, ...).
+			retryIn := time.Duration(1<<job.Attempts) * time.Minute
+
+			app.logger.Error("outbox job failed", "job_id", job.ID, "kind", job.Kind, "error", err)
+
+			if err := app.outbox.Fail(ctx, job.ID, err, retryIn); err != nil {
+				app.logger.Error(err.Error())
+			}
+			continue
+		}
+
+		if err := app.outbox.Complete(ctx, job.ID); err != nil {
+			app.logger.Error(err.Error())
+		}
+	}
+}
+
+// runJob() decodes the payload of a job and performs the work associated with its kind.
+func (app *application) runJob(ctx context.Context, job *models.Job) error {
+	switch job.Kind {
+	case models.JobKindMail:
+		var payload mailJob
+
+		err := json.Unmarshal(job.Payload, &payload)
+		if err != nil {
+			return err
+		}
+
+		return app.mailer.Send(payload.Recipient, payload.Template, payload.Data)
+	case models.JobKindWebhook:
+		var payload webhookJob
+
+		err := json.Unmarshal(job.Payload, &payload)
+		if err != nil {
+			return err
+		}
+
+		return app.deliverWebhook(ctx, payload)
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunOutboxStops(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		app.runOutbox(ctx)
+		close(done)
+	}()
+
+	// The pending batch is still drained, but the dispatcher should return instead of waiting for the next poll.
+	select {
+	case <-done:
+	case <-time.After(outboxPollInterval / 2):
+		t.Fatal("runOutbox() didn't return after its context was cancelled")
+	}
+}
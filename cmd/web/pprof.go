@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Function used to serve the net/http/pprof profiling endpoints under /debug/pprof/, for a route with an "item"
+// catch-all parameter. The index page lists the available profiles, e.g. /debug/pprof/heap, and a CPU profile can
+// be captured with:
+//
+//	go tool pprof http://localhost:4001/debug/pprof/profile?seconds=30
+//
+// net/http/pprof refuses to capture profiles which last longer than the server's write timeout.
+func pprofHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	switch params.ByName("item") {
+	case "/cmdline":
+		pprof.Cmdline(w, r)
+	case "/profile":
+		pprof.Profile(w, r)
+	case "/symbol":
+		pprof.Symbol(w, r)
+	case "/trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestPprof(t *testing.T) {
+	tests := []struct {
+		name     string
+		pprof    bool
+		email    string
+		wantCode int
+	}{
+		{
+			name:     "Disabled",
+			pprof:    false,
+			email:    "admin@example.com",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unauthenticated",
+			pprof:    true,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Regular user",
+			pprof:    true,
+			email:    "alice@example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Admin",
+			pprof:    true,
+			email:    "admin@example.com",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.pprof = tt.pprof
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.loginAs(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, "/debug/pprof/")
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.StringContains(t, body, "goroutine")
+			}
+		})
+	}
+
+	t.Run("Internal listener", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.internalRoutes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/debug/pprof/cmdline")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body != "", true)
+
+		code, _, _ = ts.get(t, "/debug/pprof/heap?debug=1")
+		assert.Equal(t, code, http.StatusOK)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Function used to parse the value of the -trusted-proxies flag, a space-separated list of the IP addresses or CIDR
+// ranges of the reverse proxies in front of the application, e.g. "10.0.0.0/8 192.0.2.10".
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+
+	for _, field := range strings.Fields(s) {
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(field)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: want an IP address or CIDR range", field)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+// Function used to report whether the given address belongs to one of the trusted proxies.
+func (app *application) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range app.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A middleware which works out the IP address of the client which made the request. When the request comes from a
+// trusted proxy, the client is the last address in the X-Forwarded-For header which isn't itself a trusted proxy
+// (since a client can put anything at the start of the header), or failing that the address in the X-Real-IP
+// header. Otherwise the headers are ignored, so that clients can't spoof their address, and the client is the peer.
+// The address is stored in the request context for clientIP.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := peerIP(r)
+
+		if addr, err := netip.ParseAddr(ip); err == nil && app.trustedProxy(addr) {
+			if forwarded := forwardedClient(r.Header.Values("X-Forwarded-For"), app.trustedProxy); forwarded.IsValid() {
+				ip = forwarded.String()
+			} else if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+				ip = realIP.Unmap().String()
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Function used to find the client in the X-Forwarded-For header values of a request from a trusted proxy, by
+// working back from the end of the header past the addresses of trusted proxies. If every address is a trusted
+// proxy, the first one is the client. An invalid address is returned if the header is missing or malformed.
+func forwardedClient(values []string, trusted func(netip.Addr) bool) netip.Addr {
+	var addrs []netip.Addr
+
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			addr, err := netip.ParseAddr(strings.TrimSpace(part))
+			if err != nil {
+				return netip.Addr{}
+			}
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if !trusted(addrs[i]) || i == 0 {
+			return addrs[i]
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// Function used to return the IP address of the peer which sent the request, without the port.
+func peerIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// Function used to return the IP address of the client which made the request, as worked out by realIP. Requests
+// which didn't pass through realIP (e.g. in tests of individual middleware) fall back to the peer's address.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+
+	return peerIP(r)
+}
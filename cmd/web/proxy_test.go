@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		wantIP       string
+	}{
+		{
+			name:       "Direct client",
+			remoteAddr: "198.51.100.7:51234",
+			wantIP:     "198.51.100.7",
+		},
+		{
+			name:         "Untrusted peer",
+			remoteAddr:   "198.51.100.7:51234",
+			forwardedFor: []string{"203.0.113.9"},
+			wantIP:       "198.51.100.7",
+		},
+		{
+			name:         "Trusted proxy",
+			remoteAddr:   "10.0.0.2:51234",
+			forwardedFor: []string{"203.0.113.9"},
+			wantIP:       "203.0.113.9",
+		},
+		{
+			name:         "Spoofed header",
+			remoteAddr:   "10.0.0.2:51234",
+			forwardedFor: []string{"1.2.3.4, 203.0.113.9"},
+			wantIP:       "203.0.113.9",
+		},
+		{
+			name:         "Chain of proxies",
+			remoteAddr:   "10.0.0.2:51234",
+			forwardedFor: []string{"203.0.113.9, 192.0.2.10", "10.0.0.3"},
+			wantIP:       "203.0.113.9",
+		},
+		{
+			name:         "Only proxies",
+			remoteAddr:   "10.0.0.2:51234",
+			forwardedFor: []string{"10.0.0.4, 10.0.0.3"},
+			wantIP:       "10.0.0.4",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.2:51234",
+			realIP:     "203.0.113.9",
+			wantIP:     "203.0.113.9",
+		},
+		{
+			name:         "Malformed header",
+			remoteAddr:   "10.0.0.2:51234",
+			forwardedFor: []string{"not-an-ip"},
+			wantIP:       "10.0.0.2",
+		},
+		{
+			name:         "IPv6",
+			remoteAddr:   "[2001:db8::1]:51234",
+			forwardedFor: []string{"2001:db8:ffff::9"},
+			wantIP:       "2001:db8:ffff::9",
+		},
+	}
+
+	app := newTestApplication(t)
+
+	var err error
+	app.trustedProxies, err = parseTrustedProxies("10.0.0.0/8 192.0.2.10 2001:db8::/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			})
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			app.realIP(next).ServeHTTP(httptest.NewRecorder(), r)
+
+			assert.Equal(t, got, tt.wantIP)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.1.2.3/8 192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(proxies), 2)
+	assert.Equal(t, proxies[0].String(), "10.0.0.0/8")
+	assert.Equal(t, proxies[1].String(), "192.0.2.10/32")
+
+	_, err = parseTrustedProxies("10.0.0.0/8 proxy.example.com")
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/skip2/go-qrcode"
+)
+
+// The default and limits of the width (and height) of QR code images, in pixels, which can be chosen with the
+// ?size= query string parameter.
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 1024
+)
+
+// Serve a PNG image of a QR code holding the URL of the snippet with the ID given in the URL, e.g.
+// /snippet/qr/1.png?size=128, so that the snippet can be opened on a phone. The same rules as snippetView() decide
+// who can see it. A snippet's URL never changes, so the image can be cached for a day (only by the owner's browser,
+// for private snippets).
+func (app *application) snippetQR(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, ok := strings.CutSuffix(params.ByName("id"), ".png")
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+
+	size := qrDefaultSize
+
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < qrMinSize || n > qrMaxSize {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	snippet, ok := app.viewableSnippetByID(w, r, id)
+	if !ok {
+		return
+	}
+
+	image, err := qrcode.Encode(app.config.baseURL+mustURLFor("snippet.view", snippet.ID), qrcode.Medium, size)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if snippet.Private {
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+
+	w.Header().Set("ETag", strongETag(image))
+
+	// ServeContent() sets the Content-Type and Last-Modified headers, and responds to conditional requests with an
+	// HTTP 304 Not Modified response.
+	http.ServeContent(w, r, "qr.png", snippet.Created, bytes.NewReader(image))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestSnippetQR(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantSize int
+	}{
+		{"Default size", "/snippet/qr/1.png", http.StatusOK, qrDefaultSize},
+		{"Chosen size", "/snippet/qr/1.png?size=128", http.StatusOK, 128},
+		{"Size too small", "/snippet/qr/1.png?size=8", http.StatusBadRequest, 0},
+		{"Size too large", "/snippet/qr/1.png?size=5000", http.StatusBadRequest, 0},
+		{"Non-integer size", "/snippet/qr/1.png?size=big", http.StatusBadRequest, 0},
+		{"No extension", "/snippet/qr/1", http.StatusNotFound, 0},
+		{"Non-existent ID", "/snippet/qr/2.png", http.StatusNotFound, 0},
+		{"String ID", "/snippet/qr/foo.png", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, header.Get("Content-Type"), "image/png")
+			assert.Equal(t, header.Get("Cache-Control"), "public, max-age=86400")
+
+			img, err := png.Decode(bytes.NewReader([]byte(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, img.Bounds().Dx(), tt.wantSize)
+		})
+	}
+
+	t.Run("Current copy", func(t *testing.T) {
+		_, header, _ := ts.get(t, "/snippet/qr/1.png")
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/qr/1.png", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", header.Get("ETag"))
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+	})
+
+	// The snippet page shows the QR code in its share section.
+	_, _, body := ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, `<img src="/snippet/qr/1.png?size=192"`)
+}
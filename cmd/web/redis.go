@@ -0,0 +1,33 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Define a function which returns a Redis connection pool for the server at the given redis:// or rediss:// URL.
+// Like newDB, it doesn't connect to the server until the pool is first used.
+func newRedisPool(redisURL string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(redisURL,
+				redis.DialConnectTimeout(5*time.Second),
+				redis.DialReadTimeout(5*time.Second),
+				redis.DialWriteTimeout(5*time.Second),
+			)
+		},
+		// Check that connections which have been idle for a while are still alive before they are reused, since
+		// the server (or something in between) may have closed them.
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The session key under which the time (as a Unix timestamp in milliseconds) until which the user's reads go to
+// the primary database is stored, and how long that is after each write. It only needs to be longer than the
+// replica usually lags behind.
+const (
+	primaryUntilKey   = "primaryUntil"
+	primaryReadWindow = 10 * time.Second
+)
+
+// Function used to send the user's reads to the primary database for a short while after a write which they will
+// expect to see straight away, e.g. on the snippet page which they are redirected to after creating or editing a
+// snippet, since the replica may not have caught up with it yet. It does nothing if there is no replica, or for
+// API clients which authenticate with a token, so that no session is created for them.
+func (app *application) readOwnWrites(r *http.Request) {
+	if app.config.replicaDSN == "" || !app.sessionManager.Exists(r.Context(), "authenticatedUserID") {
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), primaryUntilKey, time.Now().Add(primaryReadWindow).UnixMilli())
+}
+
+// A middleware which makes the reads of users who have just written something go to the primary database (see
+// readOwnWrites()). It must come after LoadAndSave in the middleware chain.
+func (app *application) readFromPrimary(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if until := app.sessionManager.GetInt64(ctx, primaryUntilKey); until != 0 {
+			if time.Now().UnixMilli() < until {
+				r = r.WithContext(models.WithPrimary(ctx))
+			} else {
+				app.sessionManager.Remove(ctx, primaryUntilKey)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{
+			name:     "No ID",
+			incoming: "",
+			wantKept: false,
+		},
+		{
+			name:     "Valid ID",
+			incoming: "3f2a9c1e-7b4d-4e8a-9f6c-2d1b0a9e8f7c",
+			wantKept: true,
+		},
+		{
+			name:     "Unsafe characters",
+			incoming: "abc<script>",
+			wantKept: false,
+		},
+		{
+			name:     "Too long",
+			incoming: strings.Repeat("a", maxRequestIDLength+1),
+			wantKept: false,
+		},
+	}
+
+	app := newTestApplication(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestIDFromContext(r.Context())
+			})
+
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-ID", tt.incoming)
+			}
+
+			app.requestID(next).ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Header().Get("X-Request-ID"), got)
+			assert.Equal(t, got == tt.incoming, tt.wantKept)
+			assert.Equal(t, validRequestID(got), true)
+		})
+	}
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, err := newLogger(&buf, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), requestIDContextKey, "abc123")
+
+	logger.With("component", "test").InfoContext(ctx, "with request")
+	logger.Info("without request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.StringContains(t, lines[0], "component=test request_id=abc123")
+	assert.Equal(t, strings.Contains(lines[1], "request_id"), false)
+}
+
+func TestServerErrorReference(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.serverError(w, r, errors.New("something broke"))
+	})))
+	defer ts.Close()
+
+	t.Run("Error page", func(t *testing.T) {
+		code, header, body := ts.get(t, "/fail")
+		assert.Equal(t, code, http.StatusInternalServerError)
+		assert.StringContains(t, body, "reference: <code>"+header.Get("X-Request-ID")+"</code>")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		code, header, body := ts.get(t, "/api/fail")
+		assert.Equal(t, code, http.StatusInternalServerError)
+		assert.StringContains(t, body, `"request_id": "`+header.Get("X-Request-ID")+`"`)
+	})
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"expvar"
 	"net/http"
+	"strings"
 
+	"github.com/declanlin/snippetbox/internal/models"
 	"github.com/declanlin/snippetbox/ui"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -15,7 +18,13 @@ func (app *application) routes() http.Handler {
 	// Configure the handler on our router which is to be called when no matching route is found for the specified route.
 	// The router will be configured to use our custom error logger (see main.go and helpers.go).
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFound(w)
+		app.notFound(w, r)
+	})
+
+	// Likewise for requests using a method which the matched route doesn't support, so that API clients get a JSON
+	// error. The router sets the Allow header before calling this handler.
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.clientError(w, r, http.StatusMethodNotAllowed)
 	})
 
 	// Take the ui.Files embedded filesystem from the ui package and convert it to an http.FS type so that
@@ -24,10 +33,88 @@ func (app *application) routes() http.Handler {
 	fileServer := http.FileServer(http.FS(ui.Files))
 
 	// Our static files are contained in the "static" folder of the ui.Files embedded filesystem.
-	// For example, our CSS stylesheet is located at "static/css/main.css"
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
+	// For example, our CSS stylesheet is located at "static/css/main.css". They can also be requested under their
+	// fingerprinted names (see assetPath).
+	router.Handler(http.MethodGet, "/static/*filepath", app.staticFiles(fileServer))
+
+	// Configure the routes for liveness and readiness probes, e.g. from Kubernetes, unless they are served by the
+	// internal listener instead.
+	if app.config.internalAddr == "" {
+		router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
+		router.HandlerFunc(http.MethodGet, "/readyz", app.readyz)
+	}
+
+	// Routes which send large responses, and so may take a while to write to slow clients, are given longer than
+	// the default request timeout.
+	download := alice.New(app.extendTimeout(app.config.downloadTimeout))
+
+	// Configure the route for serving the custom logo, if the site has one (see branding.go).
+	router.HandlerFunc(http.MethodGet, "/branding/logo", app.brandingLogo)
+
+	// Configure the route for serving avatars. Like static files, avatars don't need sessions.
+	router.Handler(http.MethodGet, "/avatar/:id", download.ThenFunc(app.avatar))
+
+	// Configure the routes for the RSS, Atom and JSON feeds of the latest snippets, and of each user's latest
+	// snippets, which don't need sessions either. The router doesn't allow a parameter alongside the other /user/
+	// routes, so the user feeds are served under the user's profile page.
+	router.Handler(http.MethodGet, "/feed.rss", download.ThenFunc(app.feedRSS))
+	router.Handler(http.MethodGet, "/feed.atom", download.ThenFunc(app.feedAtom))
+	router.Handler(http.MethodGet, "/feed.json", download.ThenFunc(app.feedJSON))
+	router.Handler(http.MethodGet, "/user/profile/:id/feed.rss", download.ThenFunc(app.userFeedRSS))
+	router.Handler(http.MethodGet, "/user/profile/:id/feed.atom", download.ThenFunc(app.userFeedAtom))
+	router.Handler(http.MethodGet, "/user/profile/:id/feed.json", download.ThenFunc(app.userFeedJSON))
+
+	// Configure the routes for the sitemap of public snippets for search engines.
+	router.Handler(http.MethodGet, "/sitemap.xml", download.ThenFunc(app.sitemap))
+	router.Handler(http.MethodGet, "/sitemaps/:page", download.ThenFunc(app.sitemapPage))
+
+	// Configure the route for the WebSocket which refreshes the home page as new snippets are created.
+	router.HandlerFunc(http.MethodGet, "/ws/updates", app.wsUpdates)
+
+	// Slow routes, such as searches and downloads, limit the number of requests which each client can have in flight
+	// at once, so that a single client can't tie them up. The limit is shared by all of them, so it has to come
+	// after the middleware which authenticates the client (see concurrencyLimit()).
+	inflightLimit := app.concurrencyLimit(app.config.maxInflight)
+
+	// Browser-based clients on the trusted origins can call the JSON API, and only the JSON API. The router answers
+	// OPTIONS requests itself, so CORS preflight requests for the API are handled here rather than by the routes.
+	apiCORS := cors(app.corsTrustedOrigins)
+
+	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			apiCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+		}
+	})
+
+	// Configure the route for the OpenAPI document describing the JSON API.
+	router.Handler(http.MethodGet, "/api/openapi.json", apiCORS(http.HandlerFunc(app.apiOpenAPI)))
+
+	// Configure the routes for the JSON API. These routes don't use sessions, so they bypass the dynamic middleware
+	// chain. Clients authenticate with a personal API token instead of a session cookie. All of the API routes share
+	// one rate limiter, so that each client's requests are counted together.
+	apiLimit := app.apiRateLimit(app.apiRateLimits)
+
+	api := alice.New(apiCORS, app.authenticateAPI, apiLimit, inflightLimit)
+	apiProtected := api.Append(app.requireAPIAuthentication)
+
+	router.Handler(http.MethodGet, "/api/v1/me", apiProtected.ThenFunc(app.apiMe))
+	router.Handler(http.MethodGet, "/api/v1/users/:id/snippets", api.Append(app.shedLoad).ThenFunc(app.apiUserSnippets))
+	router.Handler(http.MethodGet, "/api/oembed", api.ThenFunc(app.apiOEmbed))
+
+	// The snippets resource can also be used from the browser by logged in users, so these routes load the session
+	// as well. noSurf isn't used: creating a snippet requires a JSON body, and deleting one requires the DELETE
+	// method, neither of which can be sent by another site without passing a CORS preflight. The event stream is
+	// left out of the in-flight limit, since it stays open for as long as the client is listening.
+	apiStream := alice.New(apiCORS, app.sessionManager.LoadAndSave, app.expireIdleSessions, app.migrateSession, app.readFromPrimary, app.authenticate, app.authenticateAPI, apiLimit)
+	apiSession := apiStream.Append(inflightLimit)
+	apiSessionProtected := apiSession.Append(app.requireAPIAuthentication)
 
-	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	router.Handler(http.MethodGet, "/api/v1/snippets", apiSession.Append(app.shedLoad).ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiSessionProtected.Append(app.idempotent).ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPost, "/api/v1/snippets/batch", apiSessionProtected.Append(app.idempotent).ThenFunc(app.apiSnippetBatchCreate))
+	// This route also serves the /api/v1/snippets/stream event stream (see apiSnippetGet()).
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", apiStream.ThenFunc(app.apiSnippetGet))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", apiSessionProtected.ThenFunc(app.apiSnippetDelete))
 
 	// Configure the middleware chain specific to our dynamic application routes.
 
@@ -36,20 +123,66 @@ func (app *application) routes() http.Handler {
 	// It checks each incoming request for a session cookie, and if the session cookie is present, it
 	// retrieves the corresponding session data from the database (while also checking that your session has not
 	// expired), and then adds the session data to the request context to be used in your handlers.
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// The expireIdleSessions middleware logs out users whose sessions have been idle for too long, and the
+	// migrateSession middleware upgrades sessions created by older versions of the application, so they come
+	// straight after LoadAndSave. The readFromPrimary middleware sends the reads of users who have just changed
+	// something to the primary database. The localize middleware reads the locale chosen by the visitor from the
+	// session.
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.expireIdleSessions, app.migrateSession, app.readFromPrimary, app.localize, noSurf, app.authenticate)
+
+	// The API documentation page is an ordinary page, so it uses the dynamic chain like the rest of the site.
+	router.Handler(http.MethodGet, "/api/docs", dynamic.ThenFunc(app.apiDocs))
+
+	// Listing pages are the cheapest to turn away, so anonymous requests for them are shed first when the
+	// application is overloaded. They include the search results, so they are also limited to a few requests in
+	// flight for each client.
+	listing := dynamic.Append(app.shedLoad, inflightLimit)
 
 	// Configure the route for the home page.
 	// alice.ThenFunc() returns an http.Handler.
-	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	router.Handler(http.MethodGet, "/", listing.ThenFunc(app.home))
 
 	// Configure the route for viewing a snippet with a specified ID.
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", download.Extend(dynamic).Append(inflightLimit).ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/print/:id", dynamic.ThenFunc(app.snippetPrint))
+	// The QR code route's parameter is the snippet ID followed by ".png", e.g. /snippet/qr/1.png.
+	router.Handler(http.MethodGet, "/snippet/qr/:id", dynamic.Append(inflightLimit).ThenFunc(app.snippetQR))
+
+	// Configure the route for viewing a snippet through a time-boxed preview link.
+	router.Handler(http.MethodGet, "/preview/:token", dynamic.ThenFunc(app.snippetPreview))
 
-	// Configure the user-related routes.
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
+	// Configure the user-related routes. Login and signup attempts are limited for each client IP address, which
+	// complements the per-account lockout by slowing down attempts spread across many accounts, and makes mass
+	// signups harder. Logins and signups have separate limits.
+	loginLimit := app.rateLimit(app.config.authRateLimit, app.config.authRateBurst)
+	signupLimit := app.rateLimit(app.config.authRateLimit, app.config.authRateBurst)
+
+	router.Handler(http.MethodGet, "/user/signup", dynamic.Append(app.requireSignupOpen).ThenFunc(app.userSignup))
+	router.Handler(http.MethodPost, "/user/signup", dynamic.Append(signupLimit, app.requireSignupOpen).ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodPost, "/user/login", dynamic.Append(loginLimit).ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/link", dynamic.ThenFunc(app.userMagicLink))
+	router.Handler(http.MethodPost, "/user/login/link", dynamic.Append(loginLimit).ThenFunc(app.userMagicLinkPost))
+	router.Handler(http.MethodGet, "/user/login/link/:token", dynamic.ThenFunc(app.userMagicLinkLogin))
+	router.Handler(http.MethodPost, "/user/login/link/:token", dynamic.ThenFunc(app.userMagicLinkLoginPost))
+	router.Handler(http.MethodGet, "/user/oauth/:provider", dynamic.ThenFunc(app.userOAuthLogin))
+	router.Handler(http.MethodGet, "/user/oauth/:provider/callback", dynamic.ThenFunc(app.userOAuthCallback))
+	router.Handler(http.MethodGet, "/user/profile/:id", listing.ThenFunc(app.userProfile))
+	router.Handler(http.MethodGet, "/user/verify/:token", dynamic.ThenFunc(app.userVerify))
+	router.Handler(http.MethodGet, "/user/confirm-email/:token", dynamic.ThenFunc(app.userConfirmEmail))
+	router.Handler(http.MethodGet, "/user/unsubscribe/:token", dynamic.ThenFunc(app.userUnsubscribe))
+	router.Handler(http.MethodPost, "/user/unsubscribe/:token", dynamic.ThenFunc(app.userUnsubscribePost))
+
+	// Configure the routes for the language and theme pickers. Both are kept in the session, so anonymous visitors
+	// can use them too.
+	router.Handler(http.MethodPost, "/locale", dynamic.ThenFunc(app.setLocalePost))
+	router.Handler(http.MethodPost, "/account/theme", dynamic.ThenFunc(app.accountThemePost))
+
+	// Configure the routes for the contact form. Submissions are limited to a handful per minute for each client
+	// IP address to make the form less attractive to spammers.
+	router.Handler(http.MethodGet, "/contact", dynamic.ThenFunc(app.contact))
+	router.Handler(http.MethodPost, "/contact", dynamic.Append(app.rateLimit(0.1, 3)).ThenFunc(app.contactPost))
 
 	// Protect routes using our custom authentication middleware.
 	protected := dynamic.Append(app.requireAuthentication)
@@ -58,12 +191,103 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	// Configure the route for create a new snippet via an HTTP POST request.
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// Configure the routes for editing a snippet owned by the authenticated user.
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	// Configure the routes for creating and revoking preview links for private snippets.
+	router.Handler(http.MethodPost, "/snippet/previews/:id", protected.ThenFunc(app.snippetPreviewCreatePost))
+	router.Handler(http.MethodPost, "/snippet/previews/:id/revoke", protected.ThenFunc(app.snippetPreviewRevokePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
 
+	// Sensitive account changes additionally require the user to have entered their password recently.
+	sensitive := protected.Append(app.requireRecentAuth)
+
+	// Configure the routes for the authenticated user's own account pages. The size limit for avatar uploads has to
+	// be applied before the dynamic chain, since noSurf reads the request body when checking the CSRF token.
+	router.Handler(http.MethodGet, "/account/reauthenticate", protected.ThenFunc(app.accountReauthenticate))
+	router.Handler(http.MethodPost, "/account/reauthenticate", protected.ThenFunc(app.accountReauthenticatePost))
+	router.Handler(http.MethodGet, "/account/profile", protected.ThenFunc(app.accountProfile))
+	router.Handler(http.MethodGet, "/account/password/update", sensitive.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", sensitive.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/settings", sensitive.ThenFunc(app.accountSettings))
+	router.Handler(http.MethodPost, "/account/settings", sensitive.ThenFunc(app.accountSettingsPost))
+	router.Handler(http.MethodPost, "/account/avatar", alice.New(app.limitBody(avatarMaxBytes)).Extend(protected).ThenFunc(app.accountAvatarPost))
+	router.Handler(http.MethodPost, "/account/avatar/delete", protected.ThenFunc(app.accountAvatarDeletePost))
+	router.Handler(http.MethodPost, "/account/notifications", protected.ThenFunc(app.accountNotificationsPost))
+	router.Handler(http.MethodPost, "/account/oauth/:provider", protected.ThenFunc(app.accountOAuthLinkPost))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(app.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/:id/revoke", protected.ThenFunc(app.accountSessionRevokePost))
+	router.Handler(http.MethodGet, "/account/api-tokens", sensitive.ThenFunc(app.accountAPITokens))
+	router.Handler(http.MethodPost, "/account/api-tokens", sensitive.ThenFunc(app.accountAPITokensPost))
+	router.Handler(http.MethodPost, "/account/api-tokens/:id/revoke", protected.ThenFunc(app.accountAPITokenRevokePost))
+	router.Handler(http.MethodGet, "/account/webhooks", sensitive.ThenFunc(app.accountWebhooks))
+	router.Handler(http.MethodPost, "/account/webhooks", sensitive.ThenFunc(app.accountWebhooksPost))
+	router.Handler(http.MethodPost, "/account/webhooks/:id/delete", protected.ThenFunc(app.accountWebhookDeletePost))
+	router.Handler(http.MethodGet, "/account/webhooks/:id/deliveries", protected.ThenFunc(app.accountWebhookDeliveries))
+	router.Handler(http.MethodGet, "/account/delete", sensitive.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", sensitive.ThenFunc(app.accountDeletePost))
+
+	// Protect the admin-only routes, which additionally require the user to have the admin role.
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+
+	// Configure the routes for the admin dashboard, for managing users, snippets and invites, and for reading the
+	// messages sent through the contact form.
+	router.Handler(http.MethodGet, "/admin", admin.ThenFunc(app.adminDashboard))
+	router.Handler(http.MethodGet, "/admin/config", admin.ThenFunc(app.adminConfig))
+	router.Handler(http.MethodPost, "/admin/maintenance", admin.ThenFunc(app.adminMaintenancePost))
+	router.Handler(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	router.Handler(http.MethodPost, "/admin/users/:id/deactivate", admin.ThenFunc(app.adminUserDeactivatePost))
+	router.Handler(http.MethodPost, "/admin/users/:id/activate", admin.ThenFunc(app.adminUserActivatePost))
+	router.Handler(http.MethodGet, "/admin/snippets", admin.ThenFunc(app.adminSnippets))
+	router.Handler(http.MethodPost, "/admin/snippets/:id/delete", admin.ThenFunc(app.adminSnippetDeletePost))
+	router.Handler(http.MethodGet, "/admin/archive", admin.ThenFunc(app.adminArchive))
+	router.Handler(http.MethodPost, "/admin/archive/:id/restore", admin.ThenFunc(app.adminArchiveRestorePost))
+	router.Handler(http.MethodGet, "/admin/contacts", admin.ThenFunc(app.adminContacts))
+	router.Handler(http.MethodGet, "/admin/invites", admin.ThenFunc(app.adminInvites))
+	router.Handler(http.MethodPost, "/admin/invites", admin.ThenFunc(app.adminInvitesPost))
+	router.Handler(http.MethodPost, "/admin/invites/:id/delete", admin.ThenFunc(app.adminInviteDeletePost))
+
+	// Configure the routes for managing the denylist of words which are not allowed in snippet titles.
+	router.Handler(http.MethodGet, "/admin/blocked-words", admin.ThenFunc(app.adminBlockedWords))
+	router.Handler(http.MethodPost, "/admin/blocked-words", admin.ThenFunc(app.adminBlockedWordsPost))
+	router.Handler(http.MethodPost, "/admin/blocked-words/:id/delete", admin.ThenFunc(app.adminBlockedWordsDeletePost))
+
+	// Configure the route for the Prometheus metrics (see metrics.go), unless they are served by the internal
+	// listener. On the public server, they are only served to admins, like the profiling endpoints.
+	if app.config.internalAddr == "" {
+		router.Handler(http.MethodGet, "/metrics", admin.Then(app.metricsHandler()))
+	}
+
+	// Configure the routes for the profiling endpoints, if admins are allowed to use them on the public server.
+	// Profiles are given as long as downloads to complete, but can't last longer than the server's write timeout.
+	if app.config.pprof {
+		router.Handler(http.MethodGet, "/debug/pprof/*item", admin.Append(app.extendTimeout(app.config.downloadTimeout)).ThenFunc(pprofHandler))
+	}
+
 	// Configure the standard middleware chain for the router, which requests and responses will pass through as they
-	// are handled by the server.
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// are handled by the server. The client's IP address is worked out first, so that every later middleware uses
+	// it. In maintenance mode, requests are turned away before they reach the router, but
+	// after the middleware which identifies, logs and secures every response.
+	standard := alice.New(app.requestID, app.realIP, app.recoverPanic, app.traceRequests(router), app.measureLatency, app.logRequest,
+		compress, secureHeaders, app.maintenanceMode, app.requestTimeout)
 
 	// Return the middleware chain followed by the router.
 	return standard.Then(router)
 }
+
+// Function used to return the handler for the internal listener (see the -internal-addr flag), which serves the
+// operational endpoints which shouldn't be exposed to the internet: the liveness and readiness probes, the Prometheus
+// metrics (see metrics.go), the runtime metrics published by the expvar package, and the profiling endpoints. Requests aren't logged, since probes are
+// frequent.
+func (app *application) internalRoutes() http.Handler {
+	router := httprouter.New()
+
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readyz)
+	router.Handler(http.MethodGet, "/metrics", app.metricsHandler())
+	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.HandlerFunc(http.MethodGet, "/debug/pprof/*item", pprofHandler)
+	router.HandlerFunc(http.MethodPost, "/debug/pprof/*item", pprofHandler)
+
+	return alice.New(app.requestID, app.recoverPanic).Then(router)
+}
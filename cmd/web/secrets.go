@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The settings which hold credentials. Each can also be read from a file named by the setting with a "-file"
+// suffix (e.g. -dsn-file), such as a Docker or Kubernetes secret, or looked up in a secret store by giving a
+// reference like "vault:secret/data/snippetbox#dsn" as its value, so that credentials don't need to appear in the
+// process arguments or the configuration file.
+var secretSettings = []string{"dsn", "replica-dsn", "redis-url", "smtp-password", "github-client-secret", "google-client-secret", "captcha-secret", "form-secret"}
+
+// The prefix of setting values which are references to secrets in Vault.
+const vaultRefPrefix = "vault:"
+
+// How long a lookup in a secret store may take at startup.
+const secretLookupTimeout = 10 * time.Second
+
+// Define a secretStore interface for external stores which secrets can be looked up in. The reference identifies a
+// secret in a store-specific way.
+type secretStore interface {
+	Secret(ctx context.Context, ref string) (string, error)
+}
+
+// Function used to resolve the secret settings once the rest of the configuration has been loaded. A setting which
+// has a file is set to the contents of the file (without a trailing newline), and a setting whose value is a
+// reference to a secret store is set to the secret. The given settings are the ones which were set explicitly, so
+// that it is an error to set a secret both directly and with a file.
+func resolveSecrets(fs *flag.FlagSet, given map[string]bool, store secretStore) error {
+	for _, name := range secretSettings {
+		value := fs.Lookup(name).Value.String()
+
+		if path := fs.Lookup(name + "-file").Value.String(); path != "" {
+			if given[name] {
+				return fmt.Errorf("%s and %s-file can't both be set", name, name)
+			}
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s-file: %w", name, err)
+			}
+
+			value = strings.TrimRight(string(b), "\r\n")
+			if value == "" {
+				return fmt.Errorf("%s-file: %s is empty", name, path)
+			}
+		}
+
+		if ref, ok := strings.CutPrefix(value, vaultRefPrefix); ok {
+			if store == nil {
+				return fmt.Errorf("%s refers to Vault, but vault-addr isn't set", name)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), secretLookupTimeout)
+			secret, err := store.Secret(ctx, ref)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			value = secret
+		}
+
+		err := fs.Set(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Define a vaultStore type which looks up secrets in HashiCorp Vault's key/value secrets engine over its HTTP API.
+// References are of the form "<path>#<key>", where the path is the API path of the secret without the "/v1/"
+// prefix, e.g. "secret/data/snippetbox#dsn" for version 2 of the engine mounted at "secret".
+type vaultStore struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+func newVaultStore(addr, token string) *vaultStore {
+	return &vaultStore{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: secretLookupTimeout},
+	}
+}
+
+func (v *vaultStore) Secret(ctx context.Context, ref string) (string, error) {
+	path, key, found := strings.Cut(ref, "#")
+	if !found || path == "" || key == "" {
+		return "", fmt.Errorf("invalid Vault reference %q (want path#key)", ref)
+	}
+
+	u, err := url.JoinPath(v.addr, "v1", path)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: reading %s: %s", path, resp.Status)
+	}
+
+	// Version 2 of the key/value engine nests the secret's data inside the response's data, along with its
+	// metadata, whereas version 1 returns the secret's data directly.
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	data := body.Data
+	if nested, ok := data["data"]; ok && data["metadata"] != nil {
+		data = nil
+		if err := json.Unmarshal(nested, &data); err != nil {
+			return "", err
+		}
+	}
+
+	var secret string
+	if raw, ok := data[key]; !ok || json.Unmarshal(raw, &secret) != nil || secret == "" {
+		return "", fmt.Errorf("vault: %s has no %q value", path, key)
+	}
+
+	return secret, nil
+}
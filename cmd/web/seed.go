@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+)
+
+// The words which the fake users and snippets created by the seed command are made up from.
+var (
+	seedFirstNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy",
+		"Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Yuki"}
+	seedLastNames = []string{"Anderson", "Brown", "Chen", "Davis", "Evans", "Garcia", "Hughes", "Ito", "Jones",
+		"Kowalski", "Lopez", "Murphy", "Nguyen", "Okafor", "Patel", "Rossi", "Smith", "Tanaka", "Walsh", "Young"}
+	seedAdjectives = []string{"old", "silent", "autumn", "winter", "quiet", "distant", "morning", "evening", "first",
+		"last", "little", "wandering", "frozen", "gentle", "ancient"}
+	seedNouns = []string{"pond", "moon", "river", "mountain", "crow", "blossom", "wind", "rain", "temple", "field",
+		"lantern", "frog", "snow", "cicada", "road"}
+)
+
+// Function used to run the seed command, which fills the database with fake users and snippets for demos and load
+// testing:
+//
+//	web seed [-users N] [-snippets N] [-password P]
+//
+// The snippets are shared out among the new users at random. The users are created through the users model, so
+// their passwords are hashed in the same way as at signup (which takes a moment for each user), and are verified
+// so that they can log in straight away. Both are validated in the same way as signups and new snippets.
+func (app *application) runSeedCommand(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(w)
+
+	numUsers := fs.Int("users", 10, "Number of users to create")
+	numSnippets := fs.Int("snippets", 50, "Number of snippets to create")
+	password := fs.String("password", "password123", "Password of the created users")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *numUsers < 1 || *numSnippets < 0 {
+		return errors.New("seed: -users must be at least 1 and -snippets must not be negative")
+	}
+
+	userIDs := make([]int, 0, *numUsers)
+
+	// Email addresses are numbered, so that running the command again creates more users rather than failing on
+	// the ones which already exist.
+	for n := 1; len(userIDs) < *numUsers; n++ {
+		first, last := seedPick(seedFirstNames), seedPick(seedLastNames)
+		name := first + " " + last
+		email := fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), n)
+
+		var v validator.Validator
+		checkSignup(&v, name, email, *password)
+		if !v.Valid() {
+			return fmt.Errorf("seed: invalid user %q: %v", email, v.FieldErrors)
+		}
+
+		userID, err := app.users.Insert(ctx, name, email, *password)
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = app.users.Verify(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		userIDs = append(userIDs, userID)
+		fmt.Fprintf(w, "created user %s\n", email)
+	}
+
+	created := 0
+
+	for range *numSnippets {
+		title := fmt.Sprintf("The %s %s", seedPick(seedAdjectives), seedPick(seedNouns))
+		content := fmt.Sprintf("The %s %s,\na %s %s by the %s,\nthen the %s %s.",
+			seedPick(seedAdjectives), seedPick(seedNouns), seedPick(seedAdjectives), seedPick(seedNouns),
+			seedPick(seedNouns), seedPick(seedAdjectives), seedPick(seedNouns))
+		expires := seedPick([]int{1, 7, 365})
+		private := rand.N(10) == 0
+
+		var v validator.Validator
+
+		err := app.checkSnippet(ctx, &v, title, content, expires)
+		if err != nil {
+			return err
+		}
+
+		// Titles made up of blocked words are skipped rather than failing the whole command.
+		if !v.Valid() {
+			continue
+		}
+
+		_, err = app.snippets.Insert(ctx, seedPick(userIDs), title, content, expires, private, nil)
+		if err != nil {
+			return err
+		}
+
+		created++
+	}
+
+	fmt.Fprintf(w, "created %d users with the password %q, and %d snippets\n", len(userIDs), *password, created)
+
+	return nil
+}
+
+// Function used to return a random element of a slice.
+func seedPick[T any](values []T) T {
+	return values[rand.N(len(values))]
+}
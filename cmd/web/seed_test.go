@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+func TestRunSeedCommand(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	app := newTestApplication(t)
+	app.users = &models.UserModel{DB: db}
+	app.snippets = &models.SnippetModel{DB: db}
+	app.blockedWords = &models.BlockedWordModel{DB: db}
+
+	count := func(table string) int {
+		var n int
+
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	var out bytes.Buffer
+
+	err := app.runSeedCommand(ctx, []string{"-users", "2", "-snippets", "5", "-password", "demo-password"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.StringContains(t, out.String(), `created 2 users with the password "demo-password", and 5 snippets`)
+	assert.Equal(t, count("users"), 2)
+	assert.Equal(t, count("snippets"), 5)
+
+	// The users can log in with the password, since it was hashed by the users model.
+	_, err = app.users.Authenticate(ctx, "x", "demo-password", "127.0.0.1")
+	assert.Equal(t, err, models.ErrInvalidCredentials)
+
+	var email string
+
+	err = db.QueryRowContext(ctx, `SELECT email FROM users ORDER BY id LIMIT 1`).Scan(&email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = app.users.Authenticate(ctx, email, "demo-password", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Running the command again adds more users rather than failing on the existing email addresses.
+	err = app.runSeedCommand(ctx, []string{"-users", "1", "-snippets", "0", "-password", "demo-password"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, count("users"), 3)
+
+	for _, args := range [][]string{{"-users", "0"}, {"-snippets", "-1"}, {"-password", "short"}, {"-bogus"}} {
+		err = app.runSeedCommand(ctx, args, &out)
+		assert.Equal(t, err != nil, true)
+	}
+}
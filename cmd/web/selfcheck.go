@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/declanlin/snippetbox/ui"
+)
+
+// How long the startup self-check may take altogether.
+const startupCheckTimeout = 10 * time.Second
+
+// Define a startupCheck type to hold one of the checks which are run before the server starts, so that problems
+// with the deployment are reported straight away rather than as obscure errors when the first request needs the
+// broken part.
+type startupCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// Define a startupCheckError type to hold the failures of the startup self-check, so that they can all be reported
+// together rather than one at a time.
+type startupCheckError struct {
+	failures []startupCheckFailure
+}
+
+type startupCheckFailure struct {
+	name string
+	err  error
+}
+
+func (e *startupCheckError) Error() string {
+	var parts []string
+	for _, failure := range e.failures {
+		parts = append(parts, fmt.Sprintf("%s: %v", failure.name, failure.err))
+	}
+
+	return "startup self-check failed: " + strings.Join(parts, "; ")
+}
+
+// Function used to return the startup checks for the application. The TLS check is only included when the server
+// uses TLS files. In development mode, the templates check parses the templates on disk, so that starting from the
+// wrong directory fails straight away.
+func (app *application) startupChecks(m migrator) []startupCheck {
+	checks := []startupCheck{
+		{name: "templates", check: func(ctx context.Context) error {
+			templates, err := app.templates()
+			if err != nil {
+				return err
+			}
+			return checkTemplates(templates)
+		}},
+		{name: "database schema", check: func(ctx context.Context) error { return checkSchemaVersion(ctx, m) }},
+		{name: "session store", check: func(ctx context.Context) error { return checkSessionStore(app.sessionManager.Store) }},
+	}
+
+	if app.config.tlsCert != "" || app.config.tlsKey != "" {
+		checks = append(checks, startupCheck{name: "TLS files", check: func(ctx context.Context) error {
+			return checkTLSFiles(app.config.tlsCert, app.config.tlsKey)
+		}})
+	}
+
+	return checks
+}
+
+// Function used to run each of the startup checks, returning a *startupCheckError listing every check which
+// failed, or nil if they all passed.
+func runStartupChecks(ctx context.Context, checks []startupCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, startupCheckTimeout)
+	defer cancel()
+
+	var failures []startupCheckFailure
+
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			failures = append(failures, startupCheckFailure{name: c.name, err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &startupCheckError{failures: failures}
+	}
+
+	return nil
+}
+
+// Function used to check that the template cache has a complete template set for every page.
+func checkTemplates(cache map[string]*template.Template) error {
+	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, page := range pages {
+		name := filepath.Base(page)
+		if ts, ok := cache[name]; !ok || ts.Lookup("base") == nil {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no template set for %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// Function used to check that the session store works, by saving, loading and deleting a throwaway session.
+func checkSessionStore(store scs.Store) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	token := "selfcheck-" + hex.EncodeToString(b)
+	data := []byte("ok")
+
+	err := store.Commit(token, data, time.Now().Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("saving a session: %w", err)
+	}
+
+	got, found, err := store.Find(token)
+	if err != nil {
+		return fmt.Errorf("loading a session: %w", err)
+	}
+	if !found || string(got) != string(data) {
+		return errors.New("a saved session couldn't be loaded")
+	}
+
+	err = store.Delete(token)
+	if err != nil {
+		return fmt.Errorf("deleting a session: %w", err)
+	}
+
+	return nil
+}
+
+// Function used to check that the TLS certificate and key files can be read, match each other, and that the
+// certificate hasn't expired.
+func checkTLSFiles(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("the certificate in %s expired on %s", certFile, leaf.NotAfter.Format(time.DateOnly))
+	}
+
+	return nil
+}
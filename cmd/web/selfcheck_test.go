@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"html/template"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestStartupChecks(t *testing.T) {
+	app := newTestApplication(t)
+
+	t.Run("Templates", func(t *testing.T) {
+		assert.Equal(t, checkTemplates(app.templateCache), nil)
+
+		incomplete := map[string]*template.Template{}
+		for name, ts := range app.templateCache {
+			if name != "home.tmpl" {
+				incomplete[name] = ts
+			}
+		}
+
+		err := checkTemplates(incomplete)
+		assert.Equal(t, err != nil, true)
+		assert.StringContains(t, err.Error(), "home.tmpl")
+	})
+
+	t.Run("Session store", func(t *testing.T) {
+		store := memstore.NewWithCleanupInterval(0)
+
+		assert.Equal(t, checkSessionStore(store), nil)
+	})
+
+	t.Run("TLS files", func(t *testing.T) {
+		dir := t.TempDir()
+
+		valid := writeTestCertificate(t, dir, "valid", time.Now().Add(time.Hour))
+		expired := writeTestCertificate(t, dir, "expired", time.Now().Add(-time.Hour))
+
+		assert.Equal(t, checkTLSFiles(valid+".pem", valid+".key"), nil)
+
+		err := checkTLSFiles(expired+".pem", expired+".key")
+		assert.Equal(t, err != nil, true)
+		assert.StringContains(t, err.Error(), "expired")
+
+		err = checkTLSFiles(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.key"))
+		assert.Equal(t, err != nil, true)
+
+		// A certificate with somebody else's key.
+		err = checkTLSFiles(valid+".pem", expired+".key")
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		err := runStartupChecks(context.Background(), []startupCheck{
+			{name: "first", check: func(ctx context.Context) error { return errors.New("broken") }},
+			{name: "second", check: func(ctx context.Context) error { return nil }},
+			{name: "third", check: func(ctx context.Context) error { return errors.New("also broken") }},
+		})
+
+		var checkErr *startupCheckError
+		assert.Equal(t, errors.As(err, &checkErr), true)
+		assert.Equal(t, len(checkErr.failures), 2)
+		assert.Equal(t, err.Error(), "startup self-check failed: first: broken; third: also broken")
+	})
+}
+
+// Function used to write a self-signed certificate which expires at the given time, and its key, to the files
+// <name>.pem and <name>.key in dir. It returns the path of the files without the extension.
+func writeTestCertificate(t *testing.T, dir, name string, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+
+	err = os.WriteFile(path+".pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path+".key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
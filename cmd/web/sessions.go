@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// The session key under which the version of the session data's schema is stored.
+const sessionVersionKey = "sessionVersion"
+
+// Define a sessionMigration type for a function which upgrades the data in a session from one version of the
+// schema to the next (e.g. renaming a key, or converting a value to a new type).
+type sessionMigration func(ctx context.Context, sm *scs.SessionManager) error
+
+// The migrations which upgrade sessions created by older versions of the application. The migration at index i
+// upgrades sessions from version i+1 to version i+2. Sessions created before versioning was introduced don't have
+// a version, and are treated as version 1.
+//
+// Whenever a change is made to what handlers store in the session, append a migration here which converts the
+// old data, so that users who are logged in when the change is deployed aren't affected.
+var sessionMigrations = []sessionMigration{
+	// Version 2 stores a list of flash messages with levels, rather than a single string.
+	migrateFlash,
+}
+
+// Function used to return the current version of the session schema, given the list of migrations.
+func sessionVersion(migrations []sessionMigration) int {
+	return len(migrations) + 1
+}
+
+// A middleware which upgrades the data in sessions created by older versions of the application to the current
+// schema, by running any migrations they haven't had yet. It must come after LoadAndSave in the middleware chain.
+// If a migration fails, the session is destroyed, logging the user out rather than leaving them with data which
+// the handlers can't understand.
+func (app *application) migrateSession(next http.Handler) http.Handler {
+	current := sessionVersion(app.sessionMigrations)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// Only sessions which hold data need migrating. Anonymous visitors without a session are left alone, so
+		// that a session isn't created for every request.
+		if len(app.sessionManager.Keys(ctx)) > 0 {
+			version := 1
+			if app.sessionManager.Exists(ctx, sessionVersionKey) {
+				version = app.sessionManager.GetInt(ctx, sessionVersionKey)
+			}
+
+			for ; version < current; version++ {
+				err := app.sessionMigrations[version-1](ctx, app.sessionManager)
+				if err != nil {
+					app.logger.ErrorContext(ctx, "session migration failed", "version", version+1, "error", err)
+
+					err = app.sessionManager.Destroy(ctx)
+					if err != nil {
+						app.serverError(w, r, err)
+						return
+					}
+					break
+				}
+
+				app.sessionManager.Put(ctx, sessionVersionKey, version+1)
+			}
+		}
+
+		// Sessions which are created (or first given data) while handling this request are stamped with the
+		// current version just before the session is saved. LoadAndSave saves the session when the response is
+		// first written, or once the handler returns if nothing was written.
+		sw := &sessionVersionWriter{ResponseWriter: w, stamp: func() {
+			if app.sessionManager.Status(ctx) == scs.Modified && !app.sessionManager.Exists(ctx, sessionVersionKey) {
+				app.sessionManager.Put(ctx, sessionVersionKey, current)
+			}
+		}}
+
+		next.ServeHTTP(sw, r)
+
+		sw.stampOnce()
+	})
+}
+
+// Define a sessionVersionWriter type which wraps an http.ResponseWriter, calling stamp once before the response
+// is first written.
+type sessionVersionWriter struct {
+	http.ResponseWriter
+	stamp   func()
+	stamped bool
+}
+
+func (w *sessionVersionWriter) stampOnce() {
+	if !w.stamped {
+		w.stamped = true
+		w.stamp()
+	}
+}
+
+func (w *sessionVersionWriter) WriteHeader(code int) {
+	w.stampOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionVersionWriter) Write(b []byte) (int, error) {
+	w.stampOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that http.ResponseController can reach it.
+func (w *sessionVersionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// The session key under which the time of the session's last request is stored, as a Unix timestamp, and how
+// often it is updated. Updating it at most once a minute saves the session from being written to the store on
+// every request, at the cost of the idle timeout being up to a minute late.
+const (
+	sessionLastSeenKey        = "lastSeen"
+	sessionLastSeenResolution = time.Minute
+)
+
+// The session key which scs uses to mark sessions whose cookie is persistent (see scs.SessionManager.RememberMe).
+const sessionRememberMeKey = "__rememberMe"
+
+// A middleware which destroys sessions which have had no requests for longer than the idle timeout (see the
+// -session-idle-timeout flag). It must come straight after LoadAndSave in the middleware chain. This is done here
+// rather than with the IdleTimeout setting of the session manager, which would apply to every session: the
+// sessions of users who ticked "Remember me" are kept until the end of their lifetime, however long they are idle.
+// Idle sessions stay in the store until they reach the end of their lifetime, but can't be used.
+func (app *application) expireIdleSessions(next http.Handler) http.Handler {
+	timeout := app.config.sessionIdleTimeout
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// Anonymous visitors without a session are left alone, as are sessions which are remembered.
+		if timeout <= 0 || len(app.sessionManager.Keys(ctx)) == 0 || app.sessionManager.GetBool(ctx, sessionRememberMeKey) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		lastSeen := time.Unix(app.sessionManager.GetInt64(ctx, sessionLastSeenKey), 0)
+
+		switch idle := now.Sub(lastSeen); {
+		case app.sessionManager.Exists(ctx, sessionLastSeenKey) && idle > timeout:
+			err := app.sessionManager.Destroy(ctx)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		case idle >= sessionLastSeenResolution:
+			app.sessionManager.Put(ctx, sessionLastSeenKey, now.Unix())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestMigrateSession(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Use a single migration which renames the "oldKey" key to "newKey", making the current version 2.
+	app.sessionMigrations = []sessionMigration{
+		func(ctx context.Context, sm *scs.SessionManager) error {
+			sm.Put(ctx, "newKey", sm.PopString(ctx, "oldKey"))
+			return nil
+		},
+	}
+
+	// Create a session the way an older version of the application would have, without a version.
+	legacy := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.sessionManager.Put(r.Context(), "oldKey", "value")
+	}))
+
+	rr := httptest.NewRecorder()
+	legacy.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := rr.Result().Cookies()[0]
+
+	// Requests handled with the migrateSession middleware see the upgraded session data.
+	var newValue string
+	var version int
+	var oldExists bool
+
+	handler := app.sessionManager.LoadAndSave(app.migrateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newValue = app.sessionManager.GetString(r.Context(), "newKey")
+		oldExists = app.sessionManager.Exists(r.Context(), "oldKey")
+		version = app.sessionManager.GetInt(r.Context(), sessionVersionKey)
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, newValue, "value")
+	assert.Equal(t, oldExists, false)
+	assert.Equal(t, version, 2)
+
+	// New sessions are stamped with the current version as soon as they are given data, so that the migrations
+	// aren't run on them later.
+	handler = app.sessionManager.LoadAndSave(app.migrateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.sessionManager.Put(r.Context(), "newKey", "fresh")
+		w.Write([]byte("OK"))
+	})))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie = rr.Result().Cookies()[0]
+
+	handler = app.sessionManager.LoadAndSave(app.migrateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newValue = app.sessionManager.GetString(r.Context(), "newKey")
+		version = app.sessionManager.GetInt(r.Context(), sessionVersionKey)
+	})))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, newValue, "fresh")
+	assert.Equal(t, version, 2)
+}
+
+// Function used to make every session in the test application's store look as if its last request was made d ago.
+func backdateSessions(t *testing.T, app *application, d time.Duration) {
+	t.Helper()
+
+	err := app.sessionManager.Iterate(context.Background(), func(ctx context.Context) error {
+		app.sessionManager.Put(ctx, sessionLastSeenKey, time.Now().Add(-d).Unix())
+		_, _, err := app.sessionManager.Commit(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		rememberMe bool
+		idle       time.Duration
+		wantCode   int
+	}{
+		{"Active", false, 30 * time.Minute, http.StatusOK},
+		{"Idle", false, 2 * time.Hour, http.StatusSeeOther},
+		{"Remembered and idle", true, 48 * time.Hour, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.sessionIdleTimeout = time.Hour
+			app.config.rememberMeLifetime = 30 * 24 * time.Hour
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/login")
+
+			form := url.Values{
+				"email":      {"alice@example.com"},
+				"password":   {"pa$$word"},
+				"csrf_token": {extractCSRFToken(t, body)},
+			}
+			if tt.rememberMe {
+				form.Set("rememberMe", "true")
+			}
+
+			code, _, _ := ts.postForm(t, "/user/login", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			backdateSessions(t, app, tt.idle)
+
+			code, header, _ := ts.get(t, "/account/profile")
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), "/user/login")
+			}
+		})
+	}
+}
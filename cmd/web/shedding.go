@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+const (
+	// The weight given to each new sample in the moving averages of request latency and database wait time.
+	// Smaller values smooth out short spikes, at the cost of reacting more slowly to sustained overload.
+	shedSmoothing = 0.1
+
+	// The largest fraction of low-priority requests which are rejected, so that some traffic always gets through
+	// and the moving averages keep being updated.
+	shedMaxFraction = 0.9
+
+	// How often the database connection pool statistics are sampled.
+	shedSampleInterval = time.Second
+)
+
+// Define a loadShedder type which tracks how healthy the application is, based on the average latency of requests
+// and the average time spent waiting for a database connection. When either exceeds its threshold, a fraction of
+// low-priority requests are rejected so that logins and writes continue to be served.
+type loadShedder struct {
+	latencyThreshold time.Duration
+	dbWaitThreshold  time.Duration
+
+	mu      sync.Mutex
+	latency float64
+	dbWait  float64
+}
+
+// Function used to create a new loadShedder. A threshold of zero disables the corresponding check.
+func newLoadShedder(latencyThreshold, dbWaitThreshold time.Duration) *loadShedder {
+	return &loadShedder{
+		latencyThreshold: latencyThreshold,
+		dbWaitThreshold:  dbWaitThreshold,
+	}
+}
+
+// Function used to add the duration of a completed request to the moving average of request latency.
+func (s *loadShedder) observeLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency += shedSmoothing * (float64(d) - s.latency)
+	s.mu.Unlock()
+}
+
+// Function used to add a sample of the average database connection wait time to its moving average.
+func (s *loadShedder) observeDBWait(d time.Duration) {
+	s.mu.Lock()
+	s.dbWait += shedSmoothing * (float64(d) - s.dbWait)
+	s.mu.Unlock()
+}
+
+// Function used to return the fraction of low-priority requests which should currently be rejected. This grows
+// in proportion to how far the worst of the two averages is over its threshold, e.g. a latency at 1.5 times its
+// threshold sheds half of the low-priority requests.
+func (s *loadShedder) fraction() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var overload float64
+
+	if s.latencyThreshold > 0 {
+		overload = max(overload, s.latency/float64(s.latencyThreshold))
+	}
+
+	if s.dbWaitThreshold > 0 {
+		overload = max(overload, s.dbWait/float64(s.dbWaitThreshold))
+	}
+
+	if overload <= 1 {
+		return 0
+	}
+
+	return min(overload-1, shedMaxFraction)
+}
+
+// Function used to periodically sample the average time which queries spent waiting for a connection from the
+// database connection pool. It is intended to be run in a background goroutine, and returns when the context is
+// cancelled.
+func (s *loadShedder) monitorDB(ctx context.Context, db *sql.DB) {
+	ticker := time.NewTicker(shedSampleInterval)
+	defer ticker.Stop()
+
+	previous := db.Stats()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := db.Stats()
+
+		// Work out the average wait of the connections which had to wait since the last sample. If none did, the
+		// pool is healthy and the sample is zero.
+		var wait time.Duration
+		if waits := current.WaitCount - previous.WaitCount; waits > 0 {
+			wait = (current.WaitDuration - previous.WaitDuration) / time.Duration(waits)
+		}
+
+		s.observeDBWait(wait)
+		previous = current
+	}
+}
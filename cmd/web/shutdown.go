@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+// shutdownOnSignal() blocks until the process receives a SIGINT or SIGTERM signal, or a SIGUSR2 signal on systems
+// which support binary upgrades, and then gracefully shuts down the servers so that in-flight requests are allowed
+// to complete. In-flight requests are given the shutdown timeout to finish, after which their connections are
+// closed.
+//
+// For SIGUSR2, a new copy of the application binary (which may have been replaced on disk since this process
+// started) is started first and handed the listening sockets (see upgrade_unix.go). This process only shuts down
+// once the new one has signalled that it is ready to serve requests. If it can't be started, or doesn't become
+// ready in time, this process keeps serving requests.
+func (app *application) shutdownOnSignal(servers []*http.Server, lns []net.Listener) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, upgradeSignals...)...)
+	defer signal.Stop(quit)
+
+	for s := range quit {
+		if !slices.Contains(upgradeSignals, s) {
+			app.logger.Info("received signal, shutting down", "signal", s.String())
+			break
+		}
+
+		app.logger.Info("received signal, starting upgraded process", "signal", s.String())
+
+		pid, err := startUpgradedProcess(lns)
+		if err != nil {
+			app.logger.Error("upgrade failed", "error", err)
+			continue
+		}
+
+		app.logger.Info("upgraded process ready, shutting down", "pid", pid)
+		break
+	}
+
+	app.logger.Info("waiting for in-flight requests", "timeout", app.config.shutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+	defer cancel()
+
+	// The servers are shut down at the same time, so that they share the shutdown timeout.
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers))
+
+	for i, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				// Close the connections of any requests which are still in flight, rather than leaving them to be
+				// cut off when the process exits.
+				srv.Close()
+				errs[i] = err
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
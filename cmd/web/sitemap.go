@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The XML namespace of the sitemaps protocol, and the maximum number of URLs it allows in a sitemap. Each sitemap
+// lists one fewer snippet than this, to leave room for the home page in the first one.
+const (
+	sitemapNS           = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapMaxURLs      = 50000
+	sitemapPageSnippets = sitemapMaxURLs - 1
+)
+
+// Define the parts of a sitemap and a sitemap index which we use.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// Serve the sitemap, which lists the URLs of the home page and every public snippet for search engines. If there
+// are too many snippets for one sitemap, a sitemap index is served instead, pointing at /sitemaps/1, /sitemaps/2
+// and so on.
+func (app *application) sitemap(w http.ResponseWriter, r *http.Request) {
+	count, err := app.snippets.CountPublic(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if count <= sitemapPageSnippets {
+		app.writeSitemapPage(w, r, 1)
+		return
+	}
+
+	index := sitemapIndex{XMLNS: sitemapNS}
+
+	pages := (count + sitemapPageSnippets - 1) / sitemapPageSnippets
+	for page := 1; page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemaps/%d", app.config.baseURL, page)})
+	}
+
+	app.writeSitemap(w, r, index)
+}
+
+// Serve one of the sitemaps listed in the sitemap index.
+func (app *application) sitemapPage(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	page, err := strconv.Atoi(params.ByName("page"))
+	if err != nil || page < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	app.writeSitemapPage(w, r, page)
+}
+
+// Function used to send the sitemap with the given page number, which lists a chunk of the public snippets. The
+// first page also lists the home page. Pages past the last snippet don't exist.
+func (app *application) writeSitemapPage(w http.ResponseWriter, r *http.Request, page int) {
+	snippets, err := app.snippets.PublicIndex(r.Context(), (page-1)*sitemapPageSnippets, sitemapPageSnippets)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	urlSet := sitemapURLSet{XMLNS: sitemapNS}
+
+	if page == 1 {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: app.config.baseURL + "/"})
+	} else if len(snippets) == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	// Edits to snippets aren't timestamped, so they are listed as last modified when they were created.
+	for _, snippet := range snippets {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{
+			Loc:     app.config.baseURL + mustURLFor("snippet.view", snippet.ID),
+			LastMod: snippet.Created.UTC().Format(time.RFC3339),
+		})
+	}
+
+	app.writeSitemap(w, r, urlSet)
+}
+
+// Function used to encode a sitemap or sitemap index as XML and send it to the client. Search engines don't need
+// the very latest snippets, so sitemaps can be cached for an hour.
+func (app *application) writeSitemap(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models/mocks"
+)
+
+// manySnippetModel wraps the mock snippet model to report more public snippets than fit in one sitemap.
+type manySnippetModel struct {
+	mocks.SnippetModel
+}
+
+func (m *manySnippetModel) CountPublic(ctx context.Context) (int, error) {
+	return 2*sitemapPageSnippets + 1, nil
+}
+
+func TestSitemap(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []string
+	}{
+		{
+			name:     "Single sitemap",
+			urlPath:  "/sitemap.xml",
+			wantCode: http.StatusOK,
+			wantBody: []string{
+				"<urlset",
+				"<loc>https://snippetbox.example.com/</loc>",
+				"<loc>https://snippetbox.example.com/snippet/view/1</loc>",
+				"<lastmod>",
+			},
+		},
+		{"First page", "/sitemaps/1", http.StatusOK, []string{"<loc>https://snippetbox.example.com/snippet/view/1</loc>"}},
+		{"Page past the end", "/sitemaps/2", http.StatusNotFound, nil},
+		{"Zero page", "/sitemaps/0", http.StatusNotFound, nil},
+		{"String page", "/sitemaps/foo", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, header.Get("Content-Type"), "application/xml; charset=utf-8")
+			}
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+
+	t.Run("Sitemap index", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.snippets = &manySnippetModel{}
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/sitemap.xml")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<sitemapindex")
+		assert.StringContains(t, body, "<loc>https://snippetbox.example.com/sitemaps/3</loc>")
+		assert.Equal(t, strings.Count(body, "<sitemap>"), 3)
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The names of the hidden fields which the "antispam" partial (see ui/html/partials/antispam.tmpl) adds to forms.
+// The honeypot field is hidden from human visitors with CSS, so only bots which fill in every field populate it,
+// and the timestamp field holds the signed time at which the form was shown.
+const (
+	honeypotField    = "website"
+	formStartedField = "form_started"
+)
+
+// How long after a form is shown it can still be submitted. Forms which are left open for longer have to be
+// submitted again.
+const formMaxAge = 24 * time.Hour
+
+// The reasons which form submissions are rejected as spam for, which are logged and used as a metric label.
+const (
+	spamHoneypot         = "honeypot"
+	spamMissingTimestamp = "missing-timestamp"
+	spamInvalidTimestamp = "invalid-timestamp"
+	spamTooFast          = "too-fast"
+	spamExpired          = "expired"
+)
+
+// The error shown on forms which are rejected as spam but can't pretend to have been accepted. It doesn't say why,
+// so that it doesn't help bots to get past the checks.
+const spamRejectedMessage = "Your submission couldn't be accepted. Please try again."
+
+// Count the form submissions which have been rejected as spam, for the /metrics endpoint (see metrics.go).
+var spamRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "snippetbox_form_spam_rejections_total",
+	Help: "Number of form submissions rejected as spam, by form and reason.",
+}, []string{"form", "reason"})
+
+// Define a spamGuard type to hold the settings of the checks for automated form submissions. Bots tend to submit
+// forms straight after loading them, so forms which are submitted less than minSubmitTime after they were shown
+// are rejected. The time is signed with key, so that bots can't backdate it.
+type spamGuard struct {
+	key           []byte
+	minSubmitTime time.Duration
+}
+
+// Function used to return a new spamGuard which signs timestamps with secret. If no secret is given a random key
+// is used, which only works for a single instance of the application, since the timestamps of forms shown by one
+// instance can't be checked by another.
+func newSpamGuard(secret string, minSubmitTime time.Duration) (*spamGuard, error) {
+	key := []byte(secret)
+
+	if secret == "" {
+		key = make([]byte, 32)
+
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &spamGuard{key: key, minSubmitTime: minSubmitTime}, nil
+}
+
+// Function used to return the signed timestamp for a form shown at now, or an empty string if the time taken to
+// submit forms isn't checked. It is safe to call on a nil spamGuard.
+func (g *spamGuard) stamp(now time.Time) string {
+	if g == nil || g.minSubmitTime <= 0 {
+		return ""
+	}
+
+	payload := strconv.FormatInt(now.UnixMilli(), 10)
+
+	return payload + "." + g.sign(payload)
+}
+
+// Function used to return the signature of a timestamp.
+func (g *spamGuard) sign(payload string) string {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function used to check a submitted form for signs that it was sent by a bot, returning the reason it should be
+// rejected, or an empty string if it looks genuine. The honeypot is always checked, even on a nil spamGuard.
+func (g *spamGuard) check(form url.Values, now time.Time) string {
+	if form.Get(honeypotField) != "" {
+		return spamHoneypot
+	}
+
+	if g == nil || g.minSubmitTime <= 0 {
+		return ""
+	}
+
+	stamp := form.Get(formStartedField)
+	if stamp == "" {
+		return spamMissingTimestamp
+	}
+
+	payload, signature, ok := strings.Cut(stamp, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(g.sign(payload))) {
+		return spamInvalidTimestamp
+	}
+
+	millis, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return spamInvalidTimestamp
+	}
+
+	elapsed := now.Sub(time.UnixMilli(millis))
+
+	switch {
+	case elapsed < g.minSubmitTime:
+		return spamTooFast
+	case elapsed > formMaxAge:
+		return spamExpired
+	}
+
+	return ""
+}
+
+// Function used to check a form which has been decoded with decodePostForm() for spam. Rejected submissions are
+// logged and counted, and it is up to the caller how to respond to them, e.g. by showing the form again or by
+// pretending that it was accepted, so that bots get no signal that they were caught.
+func (app *application) rejectSpam(r *http.Request, formName string) bool {
+	reason := app.spam.check(r.PostForm, time.Now())
+	if reason == "" {
+		return false
+	}
+
+	app.logger.InfoContext(r.Context(), "rejected form submission as spam", "form", formName, "reason", reason, "client_ip", clientIP(r))
+	spamRejections.WithLabelValues(formName, reason).Inc()
+
+	return true
+}
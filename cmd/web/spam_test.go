@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSpamGuardCheck(t *testing.T) {
+	g, err := newSpamGuard("secret", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := newSpamGuard("other", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		guard *spamGuard
+		form  url.Values
+		want  string
+	}{
+		{"Genuine", g, url.Values{formStartedField: {g.stamp(now.Add(-10 * time.Second))}}, ""},
+		{"Honeypot", g, url.Values{formStartedField: {g.stamp(now.Add(-10 * time.Second))}, honeypotField: {"http://spam.example.com"}}, spamHoneypot},
+		{"Missing timestamp", g, url.Values{}, spamMissingTimestamp},
+		{"Signed with another key", g, url.Values{formStartedField: {other.stamp(now.Add(-10 * time.Second))}}, spamInvalidTimestamp},
+		{"Malformed timestamp", g, url.Values{formStartedField: {"yesterday"}}, spamInvalidTimestamp},
+		{"Too fast", g, url.Values{formStartedField: {g.stamp(now.Add(-time.Second))}}, spamTooFast},
+		{"Expired", g, url.Values{formStartedField: {g.stamp(now.Add(-formMaxAge - time.Minute))}}, spamExpired},
+		{"Timing disabled", nil, url.Values{}, ""},
+		{"Honeypot with timing disabled", nil, url.Values{honeypotField: {"http://spam.example.com"}}, spamHoneypot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.guard.check(tt.form, now), tt.want)
+		})
+	}
+}
+
+func TestRejectSpam(t *testing.T) {
+	app := newTestApplication(t)
+
+	var err error
+	app.spam, err = newSpamGuard("secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+
+	match := regexp.MustCompile(`name="form_started" value="([^"]+)"`).FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("no timestamp in the signup form")
+	}
+
+	before := testutil.ToFloat64(spamRejections.WithLabelValues("signup", spamTooFast))
+
+	form := url.Values{
+		"name":           {"Bob"},
+		"email":          {"bob@example.com"},
+		"password":       {"validPa$$word"},
+		"csrf_token":     {extractCSRFToken(t, body)},
+		formStartedField: {match[1]},
+	}
+
+	code, _, body := ts.postForm(t, "/user/signup", form)
+
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Your submission couldn&#39;t be accepted. Please try again.")
+	assert.Equal(t, testutil.ToFloat64(spamRejections.WithLabelValues("signup", spamTooFast)), before+1)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Define a sqliteConnector type which satisfies driver.Connector for the SQLite driver, which only provides a
+// driver.Driver, so that its connections can be traced like those of the other drivers.
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// The connection parameters which the models rely on, added to SQLite DSNs which don't set them: foreign keys (and
+// so ON DELETE CASCADE) are only enforced when enabled, transactions take the write lock when they begin rather
+// than failing when they first write if another connection got there first, and connections wait for a lock
+// rather than failing straight away.
+var sqliteParams = [][2]string{
+	{"_foreign_keys", "on"},
+	{"_txlock", "immediate"},
+	{"_busy_timeout", "5000"},
+}
+
+// Function used to add the connection parameters in sqliteParams to an SQLite DSN.
+func sqliteDSN(dsn string) string {
+	for _, p := range sqliteParams {
+		if strings.Contains(dsn, p[0]+"=") {
+			continue
+		}
+
+		if strings.Contains(dsn, "?") {
+			dsn += "&"
+		} else {
+			dsn += "?"
+		}
+
+		dsn += p[0] + "=" + p[1]
+	}
+
+	return dsn
+}
+
+// Function used to report whether an SQLite DSN is for an in-memory database, e.g. ":memory:" or
+// "file:test.db?mode=memory".
+func isMemoryDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, ":memory:") || strings.HasPrefix(dsn, "file::memory:") ||
+		strings.Contains(dsn, "mode=memory")
+}
@@ -0,0 +1,1037 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/migrations"
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/sqlite3store"
+	"github.com/declanlin/snippetbox/internal/validator"
+)
+
+// Create an in-memory SQLite database with the schema brought up to date by the migrations, which is dropped when
+// the test finishes. It lets the SQL used by the models be tested without a database server.
+func newTestSQLiteDB(t *testing.T) *models.DB {
+	return newTestSQLiteDBAt(t, ":memory:")
+}
+
+// Create an SQLite database with the given DSN in the same way as newTestSQLiteDB. In-memory databases only have a
+// single connection, so tests of concurrent access use a file in a temporary directory instead.
+func newTestSQLiteDBAt(t *testing.T, dsn string) *models.DB {
+	db, err := openDB("sqlite", dsn, poolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.DB{DB: db, Dialect: models.SQLite}
+}
+
+func TestOpenDBPoolConfig(t *testing.T) {
+	pool := poolConfig{maxOpenConns: 5, maxIdleConns: 2, connMaxLifetime: time.Hour, connMaxIdleTime: time.Minute}
+
+	tests := []struct {
+		name    string
+		dsn     string
+		maxOpen int
+	}{
+		{"File", "file:" + t.TempDir() + "/snippetbox.db", 5},
+		{"In memory", ":memory:", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := openDB("sqlite", tt.dsn, pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			assert.Equal(t, db.Stats().MaxOpenConnections, tt.maxOpen)
+		})
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	migrator, err := migrations.New(db.DB, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, version, migrator.Latest())
+	assert.Equal(t, dirty, false)
+
+	// Every migration can be reverted, leaving only the schema_migrations table.
+	reverted, err := migrator.Down(ctx, migrator.Latest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(reverted), migrator.Latest())
+
+	var tables int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, tables, 1)
+}
+
+func TestSQLiteModels(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	users, err := models.NewUserModel(db, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets, err := models.NewSnippetModel(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	user, err := users.Authenticate(ctx, "alice@example.com", "pa$$word", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, user.Name, "Alice")
+	assert.Equal(t, user.Timezone, "")
+
+	err = users.SetTimezone(ctx, user.ID, "Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err = users.Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, user.Timezone, "Europe/Paris")
+
+	// Failed logins lock the account once MaxFailedLogins is reached.
+	for _, want := range []error{models.ErrInvalidCredentials, models.ErrInvalidCredentials, models.ErrAccountLocked} {
+		_, err = users.Authenticate(ctx, "alice@example.com", "wrong", "192.0.2.1")
+		assert.Equal(t, errors.Is(err, want), true)
+	}
+
+	id, err := snippets.Insert(ctx, user.ID, "An old silent pond", "A frog jumps into the pond.", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, snippet.Title, "An old silent pond")
+	assert.Equal(t, snippet.Expires.Sub(snippet.Created), 7*24*time.Hour)
+
+	latest, err := snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(latest), 1)
+
+	// Searches ignore case, and treat the wildcard characters of LIKE patterns literally.
+	tests := []struct {
+		search string
+		want   int
+	}{
+		{"SILENT", 1},
+		{"old silent", 1},
+		{"%", 0},
+		{"frog", 0},
+	}
+
+	for _, tt := range tests {
+		found, _, err := snippets.ListAll(ctx, models.Filters{Search: tt.search, Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(found), tt.want)
+	}
+
+	// Contact messages are listed newest first, and can be searched by the sender's name or email address.
+	contacts := &models.ContactModel{DB: db}
+
+	for _, name := range []string{"Bob", "Carol"} {
+		_, err = contacts.Insert(ctx, name, strings.ToLower(name)+"@example.com", "Hello!")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages, metadata, err := contacts.List(ctx, models.Filters{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(messages), 2)
+	assert.Equal(t, messages[0].Name, "Carol")
+	assert.Equal(t, metadata.TotalRecords, 2)
+
+	messages, _, err = contacts.List(ctx, models.Filters{Search: "bob@", Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(messages), 1)
+	assert.Equal(t, messages[0].Name, "Bob")
+
+	// Once their prepared statements have been closed, the models run the statements unprepared.
+	assert.Equal(t, snippets.Close(), nil)
+	assert.Equal(t, users.Close(), nil)
+
+	_, err = snippets.Get(ctx, id)
+	assert.Equal(t, err, nil)
+
+	exists, err := users.Exists(ctx, user.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, exists, true)
+}
+
+func TestSQLiteSessionStore(t *testing.T) {
+	db := newTestSQLiteDB(t)
+
+	store := sqlite3store.NewWithCleanupInterval(db.DB, 0)
+
+	err := store.Commit("live", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Commit("expired", []byte("data"), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find("live")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, found, true)
+	assert.Equal(t, string(b), "data")
+
+	_, found, err = store.Find("expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, found, false)
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(all), 1)
+}
+
+func TestSQLiteReplica(t *testing.T) {
+	primary := newTestSQLiteDB(t)
+	replica := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	// A replica which refuses connections, so that reads have to fall back to the primary.
+	unreachable, err := newDB("mysql", "web@tcp(127.0.0.1:1)/snippetbox", poolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unreachable.Close()
+
+	// The same snippet ID has a different title on each database, so that the database it was read from can be
+	// told apart.
+	_, err = (&models.SnippetModel{DB: primary}).Insert(ctx, 1, "Primary", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = (&models.SnippetModel{DB: replica}).Insert(ctx, 1, "Replica", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		replica   *sql.DB
+		ctx       context.Context
+		wantTitle string
+	}{
+		{"No replica", nil, ctx, "Primary"},
+		{"Replica", replica.DB, ctx, "Replica"},
+		{"Unreachable replica", unreachable, ctx, "Primary"},
+		{"Reading from the primary", replica.DB, models.WithPrimary(ctx), "Primary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &models.DB{DB: primary.DB, Dialect: models.SQLite}
+			if tt.replica != nil {
+				db.Replica = &models.Replica{DB: &models.DB{DB: tt.replica, Dialect: models.SQLite}}
+			}
+
+			snippets, err := models.NewSnippetModel(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snippets.Close()
+
+			snippet, err := snippets.Get(tt.ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, snippet.Title, tt.wantTitle)
+
+			latest, err := snippets.Latest(tt.ctx, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, len(latest), 1)
+			assert.Equal(t, latest[0].Title, tt.wantTitle)
+		})
+	}
+}
+
+func TestSQLiteReadOwnWrites(t *testing.T) {
+	primary := newTestSQLiteDB(t)
+	replica := newTestSQLiteDB(t)
+
+	// The replica never catches up, so snippets can only be seen if they are read from the primary.
+	db := &models.DB{DB: primary.DB, Dialect: models.SQLite}
+	db.Replica = &models.Replica{DB: &models.DB{DB: replica.DB, Dialect: models.SQLite}}
+
+	snippets, err := models.NewSnippetModel(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snippets.Close()
+
+	tests := []struct {
+		name       string
+		replicaDSN string
+		wantCode   int
+	}{
+		{"Replica configured", "replica", http.StatusOK},
+		// Without -replica-dsn the application doesn't know to pin reads, so the lagging replica is read.
+		{"Replica not configured", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.snippets = snippets
+			app.config.replicaDSN = tt.replicaDSN
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			ts.login(t)
+
+			_, _, body := ts.get(t, "/snippet/create")
+
+			form := url.Values{
+				"title":      {"Fresh"},
+				"content":    {"Content"},
+				"expires":    {"7"},
+				"csrf_token": {extractCSRFToken(t, body)},
+			}
+
+			code, header, _ := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			code, _, _ = ts.get(t, header.Get("Location"))
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSQLiteWithTx(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	users := &models.UserModel{DB: db}
+	invites := &models.InviteModel{DB: db}
+
+	_, err := users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := invites.New(ctx, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A signup with a duplicate email address is rolled back, so the invite code is still unused.
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		err := invites.Redeem(ctx, code)
+		if err != nil {
+			return err
+		}
+
+		_, err = users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+		return err
+	})
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateEmail), true)
+
+	// Models which start transactions of their own (like Authenticate) join the outer one, whose writes they see.
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		err := invites.Redeem(ctx, code)
+		if err != nil {
+			return err
+		}
+
+		_, err = users.Insert(ctx, "Bob", "bob@example.com", "pa$$word")
+		if err != nil {
+			return err
+		}
+
+		_, err = users.Authenticate(ctx, "bob@example.com", "pa$$word", "192.0.2.1")
+		return err
+	})
+	assert.Equal(t, err, nil)
+
+	// The committed transaction used the invite code.
+	err = invites.Redeem(ctx, code)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSQLiteSnippetCache(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := models.NewCachedSnippetModel(&models.SnippetModel{DB: db}, 10, time.Minute)
+
+	id, err := snippets.Insert(ctx, 1, "Cached", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Cached")
+
+	latest, err := snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+
+	// Change the snippet behind the model's back, so that reads which are served from the cache can be told
+	// apart from ones which aren't.
+	_, err = db.ExecContext(ctx, `UPDATE snippets SET title = 'Changed' WHERE id = ?`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Cached")
+
+	// Changing the returned snippet mustn't change the cached one.
+	s.Title = "Mutated"
+
+	latest, err = snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, latest[0].Title, "Cached")
+
+	// Inserting a snippet invalidates the latest snippets, but not the snippets cached by ID.
+	_, err = snippets.Insert(ctx, 1, "Newer", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err = snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 2)
+	assert.Equal(t, latest[1].Title, "Changed")
+
+	s, err = snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Cached")
+
+	// Deleting a snippet invalidates it.
+	err = snippets.Delete(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = snippets.Get(ctx, id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, err = snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 1)
+
+	// Purging the cache invalidates every snippet.
+	_, err = db.ExecContext(ctx, `DELETE FROM snippets`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets.Purge()
+
+	latest, err = snippets.Latest(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(latest), 0)
+}
+
+func TestSQLiteQueryErrors(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	t.Run("Failed insert", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		id, err := (&models.SnippetModel{DB: db}).Insert(canceled, 1, "Title", "Content", 7, false, nil)
+		assert.Equal(t, id, 0)
+		assert.Equal(t, errors.Is(err, context.Canceled), true)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		stmt := `INSERT INTO blocked_words (word, created) VALUES ('spam', datetime('now'))`
+
+		_, err := db.ExecContext(ctx, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.ExecContext(ctx, stmt)
+		assert.Equal(t, errors.Is(err, models.ErrConflict), true)
+		assert.Equal(t, errors.Is(err, models.ErrForeignKey), false)
+
+		var queryError *models.QueryError
+		assert.Equal(t, errors.As(err, &queryError), true)
+		assert.Equal(t, queryError.Query, stmt)
+	})
+
+	t.Run("Foreign key", func(t *testing.T) {
+		_, err := (&models.PreviewModel{DB: db}).Insert(ctx, 999, time.Hour)
+		assert.Equal(t, errors.Is(err, models.ErrForeignKey), true)
+		assert.Equal(t, errors.Is(err, models.ErrConflict), false)
+	})
+}
+
+func TestSQLiteArchive(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := &models.SnippetModel{DB: db}
+
+	// Insert a snippet which expired long ago, one which expired recently, and one which hasn't expired.
+	var ids []int
+
+	for _, expired := range []string{"-100 days", "-1 hours", "+7 days"} {
+		id, err := snippets.Insert(ctx, 1, "Title "+expired, "Content", 7, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.ExecContext(ctx, `UPDATE snippets SET expires = datetime('now', ?) WHERE id = ?`, expired, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	// The snippet which is archived has been edited, and keeps its version.
+	_, err := db.ExecContext(ctx, `UPDATE snippets SET version = 3 WHERE id = ?`, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := snippets.Archive(ctx, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	// Nothing is left to archive.
+	n, err = snippets.Archive(ctx, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 0)
+
+	archived, err := snippets.Archived(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(archived), 1)
+	assert.Equal(t, archived[0].ID, ids[0])
+	assert.Equal(t, archived[0].Version, 3)
+
+	var count int
+
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM snippets`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 2)
+
+	// Restoring the snippet moves it back with a new expiry time, so that it can be viewed again.
+	err = snippets.Restore(ctx, ids[0], 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "Title -100 days")
+	assert.Equal(t, s.Version, 3)
+
+	err = snippets.Restore(ctx, ids[0], 7)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	archived, err = snippets.Archived(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(archived), 0)
+
+	// Deleting a user deletes their archived snippets along with the rest.
+	users := &models.UserModel{DB: db}
+
+	_, err = users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := users.Authenticate(ctx, "alice@example.com", "pa$$word", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := snippets.Insert(ctx, alice.ID, "Alice's snippet", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE snippets SET expires = datetime('now', '-100 days') WHERE id = ?`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = snippets.Archive(ctx, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, n, 1)
+
+	err = users.Delete(ctx, alice.ID, "pa$$word", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = snippets.Restore(ctx, id, 7)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSQLiteStats(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := &models.SnippetModel{DB: db}
+	users := &models.UserModel{DB: db}
+
+	_, err := users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert snippets which were created at various times, one of which expired long enough ago to be archived.
+	for _, row := range []struct{ created, expires string }{
+		{"-1 hours", "+7 days"},
+		{"-3 days", "+7 days"},
+		{"-30 days", "-1 hours"},
+		{"-200 days", "-100 days"},
+	} {
+		id, err := snippets.Insert(ctx, 1, "Title", "Content", 7, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.ExecContext(ctx, `UPDATE snippets SET created = datetime('now', ?), expires = datetime('now', ?)
+		WHERE id = ?`, row.created, row.expires, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = snippets.Archive(ctx, 24*time.Hour, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := snippets.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, *stats, models.SnippetStats{Active: 2, Expired: 1, Archived: 1, CreatedToday: 1, CreatedThisWeek: 2})
+
+	count, err := users.Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 1)
+}
+
+func TestSQLiteSnippetUpdate(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := &models.SnippetModel{DB: db}
+
+	id, err := snippets.Insert(ctx, 1, "Title", "Content", 7, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Version, 1)
+
+	err = snippets.Update(ctx, id, "New title", "New content", s.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving another edit made to the version which has just been replaced fails.
+	err = snippets.Update(ctx, id, "Other title", "Other content", s.Version)
+	assert.Equal(t, errors.Is(err, models.ErrEditConflict), true)
+
+	s, err = snippets.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, s.Title, "New title")
+	assert.Equal(t, s.Version, 2)
+
+	err = snippets.Update(ctx, id+1, "Title", "Content", 1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestSQLiteSnippetJobs(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := &models.SnippetModel{DB: db}
+	outbox := &models.OutboxModel{DB: db}
+
+	newJob := func(id int) *models.Job {
+		job, err := models.NewJob(models.JobKindWebhook, map[string]int{"id": id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return job
+	}
+
+	// The jobs are created with the ID of the new snippet, and written in the same transaction.
+	id, err := snippets.Insert(ctx, 1, "Title", "Content", 7, false, func(id int) ([]*models.Job, error) {
+		return []*models.Job{newJob(id)}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A snippet isn't inserted without its jobs.
+	_, err = snippets.Insert(ctx, 1, "Title", "Content", 7, false, func(id int) ([]*models.Job, error) {
+		return nil, errors.New("no jobs")
+	})
+	assert.Equal(t, err != nil, true)
+
+	// Nor are the jobs written for changes which fail.
+	err = snippets.Update(ctx, id, "Other title", "Other content", 2, newJob(id))
+	assert.Equal(t, errors.Is(err, models.ErrEditConflict), true)
+
+	err = snippets.Update(ctx, id, "New title", "New content", 1, newJob(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = snippets.Delete(ctx, id, newJob(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = snippets.Delete(ctx, id, newJob(id))
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	jobs, err := outbox.Claim(ctx, "test", 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(jobs), 3)
+	assert.Equal(t, string(jobs[0].Payload), fmt.Sprintf(`{"id":%d}`, id))
+
+	count, err := snippets.CountPublic(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, count, 0)
+}
+
+func TestSQLiteOutboxClaim(t *testing.T) {
+	db := newTestSQLiteDBAt(t, "file:"+t.TempDir()+"/snippetbox.db")
+	ctx := context.Background()
+
+	outbox := &models.OutboxModel{DB: db}
+
+	for i := range 20 {
+		job, err := models.NewJob(models.JobKindMail, map[string]int{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = outbox.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two workers claim small batches at the same time until there are none left. Every job is claimed by exactly
+	// one of them.
+	var (
+		mu      sync.Mutex
+		claimed = map[int]string{}
+		wg      sync.WaitGroup
+	)
+
+	for _, worker := range []string{"worker-1", "worker-2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				jobs, err := outbox.Claim(ctx, worker, 3, time.Minute)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(jobs) == 0 {
+					return
+				}
+
+				mu.Lock()
+				for _, job := range jobs {
+					if other, ok := claimed[job.ID]; ok {
+						t.Errorf("job %d claimed by both %s and %s", job.ID, other, worker)
+						mu.Unlock()
+						return
+					}
+					claimed[job.ID] = worker
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, len(claimed), 20)
+
+	// A failed job is released, so that it can be claimed again once it is due.
+	err := outbox.Fail(ctx, 1, errors.New("connection refused"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := outbox.Claim(ctx, "worker-1", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(jobs), 1)
+	assert.Equal(t, jobs[0].ID, 1)
+	assert.Equal(t, jobs[0].Attempts, 1)
+
+	// The lease of that claim has already run out, as if the worker had crashed, so the job can be claimed again.
+	jobs, err = outbox.Claim(ctx, "worker-2", 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(jobs), 1)
+
+	// Completed jobs are never claimed again.
+	err = outbox.Complete(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err = outbox.Claim(ctx, "worker-1", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(jobs), 0)
+}
+
+func TestSQLiteUserIdentities(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	users, err := models.NewUserModel(db, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identities := &models.UserIdentityModel{DB: db}
+
+	aliceID, err := users.Insert(ctx, "Alice", "alice@example.com", "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone can sign up with an email address which isn't theirs, so an identity isn't linked to an account by its
+	// email address until the account's owner has verified it.
+	_, err = identities.Authenticate(ctx, "github", "1", "Alice", "alice@example.com")
+	assert.Equal(t, errors.Is(err, models.ErrAccountUnverified), true)
+
+	err = users.Verify(ctx, aliceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := identities.Authenticate(ctx, "github", "1", "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, aliceID)
+
+	// Users who sign up through a provider have had their email address verified by it.
+	bobID, err := identities.Authenticate(ctx, "github", "2", "Bob", "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := users.Get(ctx, bobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bob.Verified, true)
+
+	// Identities can be linked to any account by its owner, but not to two accounts.
+	err = identities.Link(ctx, aliceID, "google", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = identities.Link(ctx, aliceID, "google", "3")
+	assert.Equal(t, err, nil)
+
+	err = identities.Link(ctx, bobID, "google", "3")
+	assert.Equal(t, errors.Is(err, models.ErrDuplicateIdentity), true)
+
+	id, err = identities.Authenticate(ctx, "google", "3", "Alice", "alice@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, id, aliceID)
+}
+
+func TestSQLiteLatestCursor(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	snippets := &models.SnippetModel{DB: db}
+
+	for i := range 5 {
+		_, err := snippets.Insert(ctx, 1, fmt.Sprintf("Title %d", i+1), "Content", 7, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Page through the snippets two at a time, following the cursors in the same way as the handlers.
+	var titles []string
+	var pages int
+
+	for after := 0; ; {
+		pages++
+
+		page, err := snippets.Latest(ctx, after, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, cursor := nextCursor(page, 2)
+		for _, s := range page {
+			titles = append(titles, s.Title)
+		}
+
+		if cursor == "" {
+			break
+		}
+
+		var v validator.Validator
+		after = readCursor(url.Values{"cursor": {cursor}}, "cursor", &v)
+		assert.Equal(t, v.Valid(), true)
+	}
+
+	assert.Equal(t, pages, 3)
+	assert.Equal(t, strings.Join(titles, ","), "Title 5,Title 4,Title 3,Title 2,Title 1")
+}
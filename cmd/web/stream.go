@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// How long each write to an event stream may take before the client is considered gone, and how often a comment is
+// sent on an idle stream, so that proxies don't close it and disconnected clients are noticed.
+const (
+	streamWriteTimeout = 10 * time.Second
+	streamHeartbeat    = 30 * time.Second
+)
+
+// Stream newly created public snippets to the client as server-sent events, e.g. for a dashboard watching snippets
+// appear in real time. Each snippet is sent as a "snippet" event with the same JSON as the rest of the API. The
+// stream lasts until the client disconnects or the server shuts down.
+func (app *application) apiSnippetStream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	markStreaming(r)
+
+	events, unsubscribe := app.hub.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Tell nginx not to buffer the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// Function used to write to the stream and flush it to the client. The server's write timeout would otherwise
+	// end the stream, so each write gets its own deadline instead.
+	write := func(s string) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+
+		_, err = fmt.Fprint(w, s)
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	// Send the headers straight away, along with how long clients should wait before reconnecting.
+	if err := write("retry: 5000\n\n"); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case snippet, ok := <-events:
+			if !ok {
+				return
+			}
+
+			js, err := json.Marshal(envelope{"snippet": newAPISnippet(snippet)})
+			if err != nil {
+				app.logger.ErrorContext(r.Context(), err.Error())
+				return
+			}
+
+			if err := write(fmt.Sprintf("event: snippet\nid: %d\ndata: %s\n\n", snippet.ID, js)); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
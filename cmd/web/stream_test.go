@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+func TestSnippetHub(t *testing.T) {
+	hub := newSnippetHub()
+
+	events, unsubscribe := hub.subscribe()
+
+	hub.publish(&models.Snippet{ID: 1})
+	hub.publish(&models.Snippet{ID: 2, Private: true})
+	hub.publish(&models.Snippet{ID: 3})
+
+	// Private snippets aren't published.
+	assert.Equal(t, (<-events).ID, 1)
+	assert.Equal(t, (<-events).ID, 3)
+
+	// Publishing to a subscriber whose buffer is full drops the snippet rather than blocking.
+	for i := 0; i < hubBufferSize+1; i++ {
+		hub.publish(&models.Snippet{ID: 4})
+	}
+	assert.Equal(t, len(events), hubBufferSize)
+
+	unsubscribe()
+
+	// Unsubscribing closes the channel once the buffered snippets have been received, and is safe to repeat.
+	for range events {
+	}
+	unsubscribe()
+
+	// Closing the hub closes the channels of existing and later subscribers.
+	events, _ = hub.subscribe()
+	hub.close()
+
+	_, ok := <-events
+	assert.Equal(t, ok, false)
+
+	events, _ = hub.subscribe()
+
+	_, ok = <-events
+	assert.Equal(t, ok, false)
+}
+
+func TestAPISnippetStream(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	rs, err := ts.Client().Get(ts.URL + "/api/v1/snippets/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Type"), "text/event-stream")
+
+	// The headers have been received, so the handler has subscribed to the hub.
+	app.hub.publish(&models.Snippet{ID: 7, Title: "Private", Private: true})
+	app.hub.publish(&models.Snippet{ID: 8, Title: "O snail", Created: time.Now(), Expires: time.Now()})
+
+	var lines []string
+
+	scanner := bufio.NewScanner(rs.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := strings.Join(lines, "\n")
+	assert.StringContains(t, stream, "retry: 5000\n\nevent: snippet\nid: 8\ndata: {\"snippet\":{\"id\":8,")
+	assert.StringContains(t, stream, `"title":"O snail"`)
+}
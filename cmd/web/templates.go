@@ -1,25 +1,79 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/declanlin/snippetbox/internal/i18n"
 	"github.com/declanlin/snippetbox/internal/models"
-	"github.com/declanlin/snippetbox/ui"
 )
 
 // Define a type templateData which stores additional information that will be passed to ExecuteTemplate().
 // This data will be accessed by the HTML templates and used to render the necessary page(s) for a route.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Limit               int
+	Cursor              string
+	NextCursor          string
+	User                *models.User
+	PreviewLinks        []*models.PreviewLink
+	BlockedWords        []*models.BlockedWord
+	Users               []*models.User
+	Filters             models.Filters
+	Metadata            models.Metadata
+	Config              []configEntry
+	Sessions            []*models.Session
+	CurrentSessionID    int
+	PendingEmail        string
+	APITokens           []*models.APIToken
+	NewAPIToken         string
+	AvatarVersion       int64
+	Invites             []*models.Invite
+	ContactMessages     []*models.ContactMessage
+	NewInvite           string
+	SignupMode          string
+	APIDocs             *openAPIDocument
+	Webhooks            []*models.Webhook
+	Webhook             *models.Webhook
+	WebhookDeliveries   []*models.WebhookDelivery
+	Form                any
+	Flashes             []FlashMessage
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	IsAdmin             bool
+	CSRFToken           string
+	FormStarted         string
+	RequestID           string
+	OAuthProviders      []string
+	BaseURL             string
+	SiteName            string
+	SiteTagline         string
+	LogoURL             string
+	CustomFooter        template.HTML
+	Maintenance         bool
+	SnippetStats        *models.SnippetStats
+	UserCount           int
+	Locale              *i18n.Localizer
+	Languages           []i18n.Language
+	Theme               string
+	Themes              []themeOption
+	Timezone            *time.Location
+	RouteName           string
+	Section             string
+	Breadcrumbs         []breadcrumb
+	Meta                pageMeta
+	Feeds               []feedLink
+	Fragments           map[string]template.HTML
+	Challenge           *challengeWidget
+	AvatarMaxBytes      int64
 }
 
 // Converts a Go time.Time object to a human-readable string.
@@ -32,21 +86,238 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Converts a Go time.Time object to a human-readable string in the given time zone, e.g.
+// {{humanDateIn $.Timezone .Created}}. Pages whose data has no time zone show UTC, as humanDate does.
+func humanDateIn(loc *time.Location, t time.Time) string {
+	if loc == nil || t.IsZero() {
+		return humanDate(t)
+	}
+
+	return t.In(loc).Format("02 Jan 2006 at 15:04")
+}
+
+// Describes how long ago (or how far in the future) a time is, in the locale of the page being rendered, e.g.
+// {{timeAgo $.Locale .LastActivity}} gives "3 hours ago". The time is rounded down to the largest whole unit.
+func timeAgo(l *i18n.Localizer, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := time.Since(t)
+
+	past := d >= 0
+	if !past {
+		d = -d
+	}
+
+	units := []struct {
+		size           time.Duration
+		past, future   string
+		pastN, futureN string
+	}{
+		{365 * 24 * time.Hour, "a year ago", "in a year", "%d years ago", "in %d years"},
+		{30 * 24 * time.Hour, "a month ago", "in a month", "%d months ago", "in %d months"},
+		{24 * time.Hour, "a day ago", "in a day", "%d days ago", "in %d days"},
+		{time.Hour, "an hour ago", "in an hour", "%d hours ago", "in %d hours"},
+		{time.Minute, "a minute ago", "in a minute", "%d minutes ago", "in %d minutes"},
+	}
+
+	for _, unit := range units {
+		n := int(d / unit.size)
+
+		switch {
+		case n == 0:
+			continue
+		case n == 1 && past:
+			return l.T(unit.past)
+		case n == 1:
+			return l.T(unit.future)
+		case past:
+			return l.T(unit.pastN, n)
+		default:
+			return l.T(unit.futureN, n)
+		}
+	}
+
+	return l.T("just now")
+}
+
+// Returns a short description of the browser and operating system in a User-Agent header, e.g. "Firefox on
+// Windows". The checks are ordered so that browsers which include other browsers' names in their user agents (e.g.
+// Edge includes "Chrome") are recognised correctly.
+func describeDevice(userAgent string) string {
+	browser := "Unknown browser"
+
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	platform := "unknown device"
+
+	switch {
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// Translates a message into the locale of the page being rendered, e.g. {{t .Locale "Home"}}. Any arguments are
+// formatted into the translation as with fmt.Sprintf(), e.g. {{t .Locale "Snippet #%d" .Snippet.ID}}.
+func translate(l *i18n.Localizer, message string, args ...any) string {
+	return l.T(message, args...)
+}
+
+// Shortens text to at most n characters, ending it with an ellipsis if anything was cut off, e.g.
+// {{truncate .Title 40}}. Unlike slicing the string, it never splits a multi-byte character.
+func truncate(s string, n int) string {
+	if n < 1 {
+		return ""
+	}
+
+	return excerpt(s, n)
+}
+
+// Formats a count along with the singular or plural form of a noun to suit it, e.g. {{pluralize .Count "snippet"
+// "snippets"}} gives "1 snippet" or "3 snippets".
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// Define the patterns used by markdownExcerpt to strip Markdown syntax. Fenced code blocks are removed entirely,
+// including ones which are never closed.
+var (
+	markdownFenceRX    = regexp.MustCompile("(?ms)^\\s*```.*?(^\\s*```[^\\n]*$|\\z)")
+	markdownImageRX    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLinkRX     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownPrefixRX   = regexp.MustCompile(`(?m)^\s*(#{1,6}\s+|>\s?|[-*+]\s+|\d+[.)]\s+)`)
+	markdownEmphasisRX = regexp.MustCompile("\\*+|`+|~~|__")
+)
+
+// Shortens Markdown to at most n characters of plain text for listings, e.g. {{excerpt .Content 100}} (it is also
+// available as markdownExcerpt). Code blocks are left out, links and images are replaced by their text, line breaks
+// are collapsed, and the text is cut at a word boundary where possible (see truncateWords).
+func markdownExcerpt(s string, n int) string {
+	s = markdownFenceRX.ReplaceAllString(s, "")
+	s = markdownImageRX.ReplaceAllString(s, "$1")
+	s = markdownLinkRX.ReplaceAllString(s, "$1")
+	s = markdownPrefixRX.ReplaceAllString(s, "")
+	s = markdownEmphasisRX.ReplaceAllString(s, "")
+
+	return truncateWords(strings.Join(strings.Fields(s), " "), n)
+}
+
+// Shortens text like truncate, but cuts it after the last whole word which fits, unless that would lose more than
+// half of it (e.g. for languages which don't put spaces between words), in which case it is cut after the last
+// whole character.
+func truncateWords(s string, n int) string {
+	if n < 1 {
+		return ""
+	}
+
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+
+	cut := runes[:n-1]
+
+	if !unicode.IsSpace(runes[n-1]) {
+		for i := len(cut) - 1; i >= len(cut)/2; i-- {
+			if unicode.IsSpace(cut[i]) {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+
+	return strings.TrimSpace(string(cut)) + "…"
+}
+
+// Formats a number of bytes using the largest unit which it's at least one of, e.g. {{bytesHumanize 1536}} gives
+// "1.5 KB". Units are multiples of 1024, as operating systems show file sizes.
+func bytesHumanize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	unit := ""
+
+	for _, u := range []string{"KB", "MB", "GB", "TB"} {
+		value /= 1024
+		unit = u
+
+		if value < 1024 {
+			break
+		}
+	}
+
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + " " + unit
+}
+
+// Marks HTML as safe to include in a page without escaping, e.g. {{safeHTML .Body}}. It must only be used for HTML
+// which has already been sanitized, since anything in it is rendered as is.
+func safeHTML(s string) template.HTML {
+	return template.HTML(s)
+}
+
 // Map the names of template functions onto their implementations to be executed by a template.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":       humanDate,
+	"humanDateIn":     humanDateIn,
+	"timeAgo":         timeAgo,
+	"device":          describeDevice,
+	"avatarURL":       avatarURL,
+	"upper":           strings.ToUpper,
+	"t":               translate,
+	"truncate":        truncate,
+	"excerpt":         markdownExcerpt,
+	"pluralize":       pluralize,
+	"markdownExcerpt": markdownExcerpt,
+	"bytesHumanize":   bytesHumanize,
+	"urlFor":          urlFor,
+	"safeHTML":        safeHTML,
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// The directory which templates are parsed from in development mode, relative to the working directory, so the
+// application has to be started from the root of the repository.
+const devUIDir = "./ui"
+
+// Function used to parse the template set for each page from fsys, which holds the contents of the ui directory.
+// The templates embedded in the binary are parsed once at startup, and in development mode the templates on disk are
+// parsed again for every page which is rendered (see templates()). Pages link to static files through the
+// assetPath function, which gives their fingerprinted names from assets.
+func newTemplateCache(fsys fs.FS, assets *assetManifest) (map[string]*template.Template, error) {
 	// Initialize an empty cache.
 	// This cache will operate in memory to store the template sets for each HTML page we our serving.
 	// It maps the base element of each HTML page path to its template set.
 
 	cache := map[string]*template.Template{}
 
-	// Retrieve the name of all files in the ui.Files embedded filesystem matching the specified glob pattern
-	// as a slice of strings.
-	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl")
+	// Retrieve the name of all files in the filesystem matching the specified glob pattern as a slice of strings.
+	pages, err := fs.Glob(fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +334,8 @@ func newTemplateCache() (map[string]*template.Template, error) {
 			page,
 		}
 
-		// Use ParseFS() instead of ParseFiles() to parse the template files from the
-		// ui.Files embedded filesystem into a template set.
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		// Use ParseFS() instead of ParseFiles() to parse the template files from the filesystem into a template set.
+		ts, err := template.New(name).Funcs(functions).Funcs(template.FuncMap{"assetPath": assets.Path}).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -77,3 +347,13 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	// Return the template cache with no errors.
 	return cache, nil
 }
+
+// Function used to return the template set for each page. In development mode they are parsed from app.templateFS
+// every time, so that changes to the templates on disk show up without rebuilding the binary.
+func (app *application) templates() (map[string]*template.Template, error) {
+	if app.templateFS != nil {
+		return newTemplateCache(app.templateFS, app.assets)
+	}
+
+	return app.templateCache, nil
+}
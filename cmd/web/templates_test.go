@@ -1,10 +1,16 @@
 package main
 
 import (
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/ui"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -43,3 +49,271 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHumanDateIn(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := time.Date(2022, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		tm   time.Time
+		want string
+	}{
+		{"UTC", time.UTC, tm, "17 Mar 2022 at 10:15"},
+		{"Time zone", paris, tm, "17 Mar 2022 at 11:15"},
+		{"Daylight saving time", paris, tm.AddDate(0, 3, 0), "17 Jun 2022 at 12:15"},
+		{"No time zone", nil, tm, "17 Mar 2022 at 10:15"},
+		{"Empty", paris, time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, humanDateIn(tt.loc, tt.tm), tt.want)
+		})
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name   string
+		locale string
+		offset time.Duration
+		want   string
+	}{
+		{"Just now", "en", -10 * time.Second, "just now"},
+		{"One minute", "en", -90 * time.Second, "a minute ago"},
+		{"Hours", "en", -3*time.Hour - time.Minute, "3 hours ago"},
+		{"Days", "en", -50 * time.Hour, "2 days ago"},
+		{"Months", "en", -100 * 24 * time.Hour, "3 months ago"},
+		{"Years", "en", -800 * 24 * time.Hour, "2 years ago"},
+		{"Future", "en", 7*24*time.Hour + time.Minute, "in 7 days"},
+		{"Translated", "fr", -3*time.Hour - time.Minute, "il y a 3 heures"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := app.translations.Localizer(tt.locale)
+			assert.Equal(t, timeAgo(l, time.Now().Add(tt.offset)), tt.want)
+		})
+	}
+
+	assert.Equal(t, timeAgo(nil, time.Time{}), "")
+}
+
+func TestDescribeDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "Firefox on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want:      "Firefox on Windows",
+		},
+		{
+			name:      "Edge on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			want:      "Edge on Windows",
+		},
+		{
+			name:      "Chrome on Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			want:      "Chrome on Android",
+		},
+		{
+			name:      "Safari on iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iOS",
+		},
+		{
+			name:      "Empty",
+			userAgent: "",
+			want:      "Unknown browser on unknown device",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, describeDevice(tt.userAgent), tt.want)
+		})
+	}
+}
+
+func TestDevModeTemplates(t *testing.T) {
+	// Copy the templates into a filesystem which the test can change, standing in for the ui directory on disk.
+	fsys := fstest.MapFS{}
+
+	err := fs.WalkDir(ui.Files, "html", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(ui.Files, path)
+		if err != nil {
+			return err
+		}
+
+		fsys[path] = &fstest.MapFile{Data: b}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.templateFS = fsys
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Latest Snippets</h2>")
+
+	// Changes to the templates show up on the next render, without the template cache being rebuilt.
+	home := string(fsys["html/pages/home.tmpl"].Data)
+	fsys["html/pages/home.tmpl"].Data = []byte(strings.Replace(home, "Latest Snippets", "Newest Snippets", 1))
+
+	code, _, body = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Newest Snippets</h2>")
+
+	// A template which doesn't parse is reported as a server error.
+	fsys["html/pages/home.tmpl"].Data = []byte(`{{define "main"}}{{if}}{{end}}`)
+
+	code, _, _ = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusInternalServerError)
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"Short", "An old silent pond", 40, "An old silent pond"},
+		{"Long", "An old silent pond", 8, "An old…"},
+		{"Multi-byte characters", "古池や蛙飛び込む水の音", 5, "古池や蛙…"},
+		{"Emoji", "🐸🐸🐸🐸", 3, "🐸🐸…"},
+		{"Zero", "An old silent pond", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, truncate(tt.s, tt.n), tt.want)
+		})
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 snippets"},
+		{1, "1 snippet"},
+		{2, "2 snippets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, pluralize(tt.n, "snippet", "snippets"), tt.want)
+		})
+	}
+}
+
+func TestMarkdownExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"Plain text", "An old silent pond\nA frog jumps into the pond", 100, "An old silent pond A frog jumps into the pond"},
+		{"Headings and emphasis", "# Haiku\n\nAn **old** _silent_ `pond`", 100, "Haiku An old _silent_ pond"},
+		{"Links and images", "See [the pond](https://example.com) ![a frog](frog.png)", 100, "See the pond a frog"},
+		{"Lists and quotes", "- one\n* two\n1. three\n> four", 100, "one two three four"},
+		{"Code block", "Before\n```go\nfmt.Println(\"hi\")\n```\nAfter", 100, "Before After"},
+		{"Unclosed code block", "Before\n```\ncode", 100, "Before"},
+		{"Shortened", "# An old silent pond", 8, "An old…"},
+		{"Word boundary", "An old silent pond", 12, "An old…"},
+		{"Long word", "An extraordinarily silent pond", 12, "An extraord…"},
+		{"Multi-byte characters", "古池や蛙飛び込む水の音", 5, "古池や蛙…"},
+		{"Emoji", "A frog 🐸🐸 jumps in", 11, "A frog 🐸🐸…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, markdownExcerpt(tt.s, tt.n), tt.want)
+		})
+	}
+}
+
+func TestBytesHumanize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5 MB"},
+		{3 << 30, "3 GB"},
+		{2048 << 40, "2048 TB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, bytesHumanize(tt.n), tt.want)
+		})
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   string
+		params  []any
+		want    string
+		wantErr bool
+	}{
+		{"No parameters", "account.sessions", nil, "/account/sessions", false},
+		{"Parameter", "snippet.view", []any{1}, "/snippet/view/1", false},
+		{"Parameter in the middle", "account.webhooks.deliveries", []any{3}, "/account/webhooks/3/deliveries", false},
+		{"Escaped parameter", "snippet.preview", []any{"a/b c"}, "/preview/a%2Fb%20c", false},
+		{"Unknown route", "snippet.missing", nil, "", true},
+		{"Missing parameter", "snippet.view", nil, "", true},
+		{"Extra parameter", "home", []any{1}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := urlFor(tt.route, tt.params...)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
+
+func TestSafeHTML(t *testing.T) {
+	tmpl := template.Must(template.New("test").Funcs(functions).Parse(`{{.}} {{safeHTML .}}`))
+
+	var buf strings.Builder
+
+	err := tmpl.Execute(&buf, "<em>pond</em>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, buf.String(), "&lt;em&gt;pond&lt;/em&gt; <em>pond</em>")
+}
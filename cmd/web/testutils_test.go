@@ -2,26 +2,44 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"html"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	mailermocks "github.com/declanlin/snippetbox/internal/mailer/mocks"
+	"github.com/declanlin/snippetbox/internal/models"
 	"github.com/declanlin/snippetbox/internal/models/mocks"
+	"github.com/declanlin/snippetbox/ui"
 	"github.com/go-playground/form/v4"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func newTestApplication(t *testing.T) *application {
 
+	assets, err := newAssetManifest(ui.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Create an instance of the template cache.
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(ui.Files, assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	translations, err := newTranslations()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -32,16 +50,102 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager := scs.New()
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
+	sessionManager.Cookie.Persist = false
 
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
 		snippets:       &mocks.SnippetModel{},
 		users:          &mocks.UserModel{},
+		contacts:       &mocks.ContactModel{},
+		outbox:         &mocks.OutboxModel{},
+		blockedWords:   &mocks.BlockedWordModel{},
+		previews:       &mocks.PreviewModel{},
+		tokens:         &mocks.TokenModel{},
+		emailChanges:   &mocks.EmailChangeModel{},
+		apiTokens:      &mocks.APITokenModel{},
+		avatars:        &mocks.AvatarModel{},
+		invites:        &mocks.InviteModel{},
+		identities:     &mocks.UserIdentityModel{},
+		sessions:       &mocks.SessionModel{},
+		webhooks:       &mocks.WebhookModel{},
+		idempotency:    &mocks.IdempotencyModel{},
+		txRunner:       &mocks.TxRunner{},
+		hub:            newSnippetHub(),
 		templateCache:  templateCache,
+		assets:         assets,
+		translations:   translations,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		mailer:         &mailermocks.Mailer{},
+		config: &config{
+			sudoWindow:      10 * time.Minute,
+			defaultPageSize: 10,
+			maxPageSize:     100,
+			signupMode:      signupModeOpen,
+			baseURL:         "https://snippetbox.example.com",
+			siteName:        "Snippetbox",
+			authRateLimit:   0.2,
+			authRateBurst:   10,
+		},
+
+		sessionMigrations:  sessionMigrations,
+		shedder:            newLoadShedder(time.Second, 250*time.Millisecond),
+		corsTrustedOrigins: []string{"https://app.example.com"},
+		apiRateLimits:      map[string]int{apiTierAnonymous: 60, models.APITierStandard: 300, models.APITierElevated: 3000},
+		webhookClient:      &http.Client{Timeout: webhookTimeout},
+		db:                 &mocks.DB{},
+		started:            time.Now(),
+		tracer:             noop.NewTracerProvider().Tracer(tracerName),
+		oauthProviders:     newOAuthProviders("https://snippetbox.example.com", "github-id", "github-secret", "", ""),
+	}
+}
+
+// recordingOutboxModel wraps the mock outbox model to record the jobs passed to Enqueue(), e.g. to check the emails
+// which a handler sends. The jobs are recorded by the test server's goroutines, so they are guarded by a mutex.
+type recordingOutboxModel struct {
+	mocks.OutboxModel
+	mu   sync.Mutex
+	jobs []*models.Job
+}
+
+func (m *recordingOutboxModel) Enqueue(ctx context.Context, jobs ...*models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs = append(m.jobs, jobs...)
+	return nil
+}
+
+// Function used to return the data of the emails which have been queued with the given template.
+func (m *recordingOutboxModel) mails(t *testing.T, template string) []map[string]string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var mails []map[string]string
+
+	for _, job := range m.jobs {
+		if job.Kind != models.JobKindMail {
+			continue
+		}
+
+		var mail struct {
+			Template string            `json:"template"`
+			Data     map[string]string `json:"data"`
+		}
+
+		err := json.Unmarshal(job.Payload, &mail)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if mail.Template == template {
+			mails = append(mails, mail.Data)
+		}
 	}
+
+	return mails
 }
 
 type testServer struct {
@@ -120,3 +224,54 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Makes a POST request in the same way as postForm(), but with a forged Host header, e.g. to check that links in
+// emails don't point wherever the client says. The client's cookie jar looks cookies up by the Host header, so the
+// cookies for the test server are added by hand.
+func (ts *testServer) postFormWithHost(t *testing.T, host, urlPath string, form url.Values) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Host = host
+
+	for _, cookie := range ts.Client().Jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// Log in to the test server as the mock user alice@example.com. The session cookie is stored in the
+// client's cookie jar, so subsequent requests made with the same test server are authenticated.
+func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "alice@example.com")
+}
+
+// Log in to the test server as the mock user with the given email address, whose password is pa$$word.
+func (ts *testServer) loginAs(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Define a themeOption type to describe a theme which visitors can choose, for the theme picker.
+type themeOption struct {
+	Value string
+	Name  string
+}
+
+// The themes which visitors can choose between. The auto theme follows the browser's light or dark preference,
+// and is used until the visitor chooses another.
+var themes = []themeOption{
+	{Value: "auto", Name: "Automatic"},
+	{Value: "light", Name: "Light"},
+	{Value: "dark", Name: "Dark"},
+}
+
+const defaultTheme = "auto"
+
+// Function used to report whether visitors can choose the theme.
+func validTheme(theme string) bool {
+	return slices.ContainsFunc(themes, func(t themeOption) bool { return t.Value == theme })
+}
+
+// Function used to return the theme chosen by the visitor, which is kept in the session.
+func (app *application) theme(r *http.Request) string {
+	theme := app.sessionManager.GetString(r.Context(), "theme")
+	if !validTheme(theme) {
+		return defaultTheme
+	}
+
+	return theme
+}
+
+type themeForm struct {
+	Theme string `form:"theme"`
+}
+
+// Save the theme chosen with the theme picker in the session, and send the visitor back to the page which they
+// chose it on. The theme is applied by the class which the templates add to <body>, so that pages are styled
+// without any script or inline styles, which the Content-Security-Policy doesn't allow.
+func (app *application) accountThemePost(w http.ResponseWriter, r *http.Request) {
+	var form themeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validTheme(form.Theme) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "theme", form.Theme)
+
+	http.Redirect(w, r, refererRedirect(r), http.StatusSeeOther)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestAccountThemePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous visitors start with the auto theme, and can choose another.
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<body class='theme-auto'>")
+	assert.StringContains(t, body, "<meta name='color-scheme' content='light dark'>")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		theme        string
+		referer      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Back to the page", "light", ts.URL + "/snippet/view/1", http.StatusSeeOther, "/snippet/view/1"},
+		{"Other site", "light", "https://example.com/", http.StatusSeeOther, "/"},
+		{"Unknown theme", "purple", "", http.StatusBadRequest, ""},
+		{"Dark", "dark", "", http.StatusSeeOther, "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"theme": {tt.theme}, "csrf_token": {validCSRFToken}}
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/account/theme", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Referer", tt.referer)
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	// The chosen theme is kept in the session, and is added to every page.
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "<body class='theme-dark'>")
+	assert.StringContains(t, body, "<meta name='color-scheme' content='dark'>")
+	assert.StringContains(t, body, `<option value="dark" selected>`)
+}
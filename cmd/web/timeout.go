@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// The cause of the cancellation of a request's context when it runs out of time. Since model methods run their
+// queries with the request context, a query which is in progress is cancelled too, and the handler gets an error.
+var errRequestTimeout = errors.New("request timed out")
+
+// How much longer than a request's timeout the response may take to write, when the timeout is longer than the
+// server's write timeout (see extendTimeout).
+const timeoutWriteMargin = 5 * time.Second
+
+// A middleware which cancels the context of each request after the request timeout, so that slow queries are
+// abandoned rather than tying up database connections. The handler is left to respond, which it does with an HTTP
+// 503 Service Unavailable response when the cancellation causes it to fail (see serverError). Handlers which hold
+// the connection open stop the timer when they call markStreaming. It comes after recoverPanic, so that the timer
+// can be kept in the requestInfo.
+func (app *application) requestTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.requestTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+
+		timer := time.AfterFunc(app.config.requestTimeout, func() {
+			cancel(errRequestTimeout)
+		})
+		defer timer.Stop()
+
+		if info := requestInfoFromRequest(r); info != nil {
+			info.timeout = timer
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// A middleware which gives requests for a route a different timeout to the default, e.g. for routes which send
+// large responses. The server's write deadline is pushed back to match, since it would otherwise end the response
+// first.
+func (app *application) extendTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if info := requestInfoFromRequest(r); info != nil && info.timeout != nil && d > 0 {
+				info.timeout.Reset(d)
+
+				err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + timeoutWriteMargin))
+				if err != nil && !errors.Is(err, http.ErrNotSupported) {
+					app.serverError(w, r, err)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Function used to report whether a request failed because it ran out of time.
+func timedOut(r *http.Request) bool {
+	return errors.Is(context.Cause(r.Context()), errRequestTimeout)
+}
+
+// Function used to send the client an HTTP 503 Service Unavailable response when their request ran out of time,
+// with a friendly page for browsers. As with the 500 error page, it is rendered without the session data.
+func (app *application) requestTimedOut(w http.ResponseWriter, r *http.Request) {
+	app.logger.WarnContext(r.Context(), "request timed out", "method", r.Method, "uri", r.URL.RequestURI(),
+		"timeout", app.config.requestTimeout)
+
+	if wantsJSON(r) {
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "the request took too long to complete", nil)
+		return
+	}
+
+	data := app.newBareTemplateData()
+	data.RequestID = requestIDFromContext(r.Context())
+
+	app.render(w, r, http.StatusServiceUnavailable, "timeout.tmpl", data)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.requestTimeout = 20 * time.Millisecond
+
+	// A handler which waits for a slow query, as a model method would, and reports the error if it is cancelled.
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			app.serverError(w, r, r.Context().Err())
+		case <-time.After(200 * time.Millisecond):
+			w.Write([]byte("OK"))
+		}
+	}
+
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/slow", slow)
+	router.HandlerFunc(http.MethodGet, "/api/slow", slow)
+	router.Handler(http.MethodGet, "/download", app.extendTimeout(time.Second)(http.HandlerFunc(slow)))
+	router.HandlerFunc(http.MethodGet, "/stream", func(w http.ResponseWriter, r *http.Request) {
+		markStreaming(r)
+		slow(w, r)
+	})
+
+	ts := newTestServer(t, app.recoverPanic(app.requestTimeout(router)))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Page",
+			urlPath:  "/slow",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "That took too long",
+		},
+		{
+			name:     "API",
+			urlPath:  "/api/slow",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "the request took too long to complete",
+		},
+		{
+			name:     "Extended",
+			urlPath:  "/download",
+			wantCode: http.StatusOK,
+			wantBody: "OK",
+		},
+		{
+			name:     "Streaming",
+			urlPath:  "/stream",
+			wantCode: http.StatusOK,
+			wantBody: "OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	// Embed the time zone database, so that users' time zones can be loaded on hosts which don't have one
+	// installed (e.g. in minimal containers).
+	_ "time/tzdata"
+)
+
+// The name of the cookie in which main.js records the browser's time zone, which dates are shown in for visitors
+// who haven't chosen a time zone in their settings.
+const timezoneCookieName = "tz"
+
+// Function used to load a time zone from its IANA name, e.g. "Europe/London". Unlike time.LoadLocation(), the
+// server's own time zone can't be loaded as "Local", an empty name isn't taken to mean UTC, and the name has to fit
+// in the users table.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" || len(name) > 64 {
+		return nil, errors.New("invalid time zone")
+	}
+
+	return time.LoadLocation(name)
+}
+
+// Function used to return the time zone which the request's dates are shown in: the one chosen by the
+// authenticated user in their settings, or else the browser's time zone as recorded by main.js, or else UTC.
+func (app *application) timezone(r *http.Request) *time.Location {
+	name, _ := r.Context().Value(userTimezoneContextKey).(string)
+
+	if name == "" {
+		cookie, err := r.Cookie(timezoneCookieName)
+		if err == nil {
+			name = cookie.Value
+		}
+	}
+
+	loc, err := loadTimezone(name)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
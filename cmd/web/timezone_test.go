@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestTimezone(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		userTimezone string
+		cookie       string
+		want         string
+	}{
+		{"Default", "", "", "UTC"},
+		{"Browser", "", "Europe/Paris", "Europe/Paris"},
+		{"Invalid browser time zone", "", "Mars/Olympus_Mons", "UTC"},
+		{"Server time zone", "", "Local", "UTC"},
+		{"Chosen in settings", "Asia/Tokyo", "Europe/Paris", "Asia/Tokyo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: timezoneCookieName, Value: tt.cookie})
+			}
+			if tt.userTimezone != "" {
+				r = r.WithContext(context.WithValue(r.Context(), userTimezoneContextKey, tt.userTimezone))
+			}
+
+			assert.Equal(t, app.timezone(r).String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/declanlin/snippetbox/cmd/web"
+
+// Function used to create a tracer provider which sends spans to an OpenTelemetry collector at the given OTLP/HTTP
+// endpoint (e.g. "http://localhost:4318"), sampling the given fraction of traces which don't already have a
+// sampling decision from the caller. It is also installed as the global tracer provider, along with the W3C trace
+// context propagator, so that traces continue across services.
+func newTracerProvider(endpoint string, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("snippetbox"),
+		semconv.ServiceVersion(appVersion()),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp, nil
+}
+
+// A middleware which starts a span for every request, continuing the caller's trace if the request carries one.
+// The span is put in the request context, so that the database queries made while handling the request are
+// recorded as its children. It comes straight after recoverPanic, so that the user ID which authenticate records
+// in the requestInfo can be added to the span.
+func (app *application) traceRequests(router *httprouter.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			// Name the span after the route rather than the path, so that requests for different snippets are
+			// grouped together.
+			name := r.Method
+			if route := routePattern(router, r); route != "" {
+				name += " " + route
+			}
+
+			ctx, span := app.tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.URLPath(r.URL.Path),
+					semconv.UserAgentOriginal(r.UserAgent()),
+					semconv.ClientAddress(clientIP(r)),
+				),
+			)
+			defer span.End()
+
+			// Mark the span as failed if the request panics, before recoverPanic handles it.
+			defer func() {
+				if err := recover(); err != nil {
+					span.SetStatus(codes.Error, fmt.Sprint(err))
+					panic(err)
+				}
+			}()
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+
+			if sw.status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(sw.status))
+			}
+
+			if info := requestInfoFromRequest(r); info != nil && info.UserID != 0 {
+				span.SetAttributes(attribute.Int("enduser.id", info.UserID))
+			}
+		})
+	}
+}
+
+// Function used to return the pattern of the route which matches a request, e.g. "/snippet/view/:id", or an empty
+// string if no route matches.
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return ""
+	}
+
+	// httprouter doesn't record the pattern itself, so rebuild it by replacing each parameter's value in the path
+	// with its name. Parameters are matched in the order they appear in the path. A catch-all parameter (e.g.
+	// "/static/*filepath") can only come last, and matches the rest of the path.
+	path, suffix := r.URL.Path, ""
+
+	if n := len(params); n > 0 && strings.HasPrefix(params[n-1].Value, "/") {
+		path = strings.TrimSuffix(path, params[n-1].Value)
+		suffix = "/*" + params[n-1].Key
+		params = params[:n-1]
+	}
+
+	segments := strings.Split(path, "/")
+	next := 0
+
+	for i, segment := range segments {
+		if next < len(params) && segment == params[next].Value {
+			segments[i] = ":" + params[next].Key
+			next++
+		}
+	}
+
+	return strings.Join(segments, "/") + suffix
+}
+
+// Define a statusWriter type which records the status code and number of body bytes of a response. It passes
+// hijacking through, so that WebSocket handshakes still work, and can be unwrapped by http.ResponseController (e.g.
+// to flush event streams).
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that http.ResponseController can reach it.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequests(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		traceparent string
+		wantName    string
+		wantStatus  int
+		wantError   bool
+	}{
+		{
+			name:       "Route with a parameter",
+			urlPath:    "/snippet/view/1",
+			wantName:   "GET /snippet/view/:id",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Catch-all route",
+			urlPath:    "/static/css/main.css",
+			wantName:   "GET /static/*filepath",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "No route",
+			urlPath:    "/missing",
+			wantName:   "GET",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:        "Continued trace",
+			urlPath:     "/",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantName:    "GET /",
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+
+			app := newTestApplication(t)
+			app.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(tracerName)
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.traceparent != "" {
+				req.Header.Set("Traceparent", tt.traceparent)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			spans := recorder.Ended()
+			assert.Equal(t, len(spans), 1)
+
+			span := spans[0]
+			assert.Equal(t, span.Name(), tt.wantName)
+			assert.Equal(t, span.Status().Code == codes.Error, tt.wantError)
+
+			var status int64
+			for _, attr := range span.Attributes() {
+				if attr.Key == attribute.Key("http.response.status_code") {
+					status = attr.Value.AsInt64()
+				}
+			}
+			assert.Equal(t, status, int64(tt.wantStatus))
+
+			if tt.traceparent != "" {
+				assert.Equal(t, span.Parent().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+				assert.Equal(t, span.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+			}
+		})
+	}
+}
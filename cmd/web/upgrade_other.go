@@ -0,0 +1,42 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// Binary upgrades via socket handover are only supported on Unix-like systems, so no signal triggers one.
+var upgradeSignals []os.Signal
+
+// listen() returns a TCP listener for each of the given network addresses. Binary upgrades via socket handover are
+// only supported on Unix-like systems, so the listeners are always bound afresh.
+func listen(addrs ...string) ([]net.Listener, error) {
+	var lns []net.Listener
+
+	for _, addr := range addrs {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// startUpgradedProcess() always fails, since binary upgrades are not supported on this platform.
+func startUpgradedProcess(lns []net.Listener) (int, error) {
+	return 0, errors.New("binary upgrades are not supported on this platform")
+}
+
+// signalUpgradeReady() does nothing, since binary upgrades are not supported on this platform.
+func signalUpgradeReady() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// The name of the environment variable which tells a process started during a binary upgrade how many listening
+// sockets it has inherited from its parent. Files passed to a child process via ExtraFiles start at file
+// descriptor 3 (after stdin, stdout and stderr), in the order the listeners were passed. They are followed by the
+// write end of a pipe, which the child uses to tell the parent that it is ready (see signalUpgradeReady).
+const (
+	inheritedListenerEnv = "SNIPPETBOX_INHERITED_LISTENER"
+	inheritedListenerFD  = 3
+)
+
+// How long the parent waits for the upgraded process to become ready before giving up on the upgrade and carrying
+// on serving requests itself. It has to cover connecting to the database and running any migrations.
+const upgradeReadyTimeout = time.Minute
+
+// The signal which triggers a binary upgrade (see shutdownOnSignal).
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// listen() returns a TCP listener for each of the given network addresses. If the process was started by a parent
+// during a binary upgrade, the listening sockets inherited from the parent are reused instead of binding new ones,
+// so that no incoming connections are refused while the upgrade takes place. The parent was started with the same
+// arguments, so it passes the same listeners in the same order.
+func listen(addrs ...string) ([]net.Listener, error) {
+	inherited := os.Getenv(inheritedListenerEnv)
+
+	if inherited != "" && inherited != strconv.Itoa(len(addrs)) {
+		return nil, fmt.Errorf("inherited %s listeners, but %d are needed", inherited, len(addrs))
+	}
+
+	var lns []net.Listener
+
+	for i, addr := range addrs {
+		var ln net.Listener
+		var err error
+
+		if inherited == "" {
+			ln, err = net.Listen("tcp", addr)
+		} else {
+			// net.FileListener() duplicates the file descriptor, so it is safe to close f once the listener is
+			// created.
+			f := os.NewFile(uintptr(inheritedListenerFD+i), "listener")
+			ln, err = net.FileListener(f)
+			f.Close()
+		}
+
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// startUpgradedProcess() re-executes the application binary with the same arguments, passing the listening
+// sockets to the new process as extra files, and waits for it to become ready to serve requests. It returns the
+// process ID of the new process. If the new process exits or doesn't become ready within upgradeReadyTimeout, it
+// is killed and an error is returned, so that this process can carry on serving requests.
+func startUpgradedProcess(lns []net.Listener) (int, error) {
+	var files []*os.File
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, ln := range lns {
+		tcpListener, ok := ln.(*net.TCPListener)
+		if !ok {
+			return 0, fmt.Errorf("cannot hand over a listener of type %T", ln)
+		}
+
+		f, err := tcpListener.File()
+		if err != nil {
+			return 0, err
+		}
+
+		files = append(files, f)
+	}
+
+	// Look up the binary by the name it was started with rather than using os.Executable(), which on Linux
+	// resolves to the original (possibly deleted) file instead of the newly deployed one.
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return 0, err
+	}
+
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+
+	defer ready.Close()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), inheritedListenerEnv+"="+strconv.Itoa(len(files)))
+	cmd.ExtraFiles = append(files, readyWriter)
+
+	err = cmd.Start()
+
+	// Close this process's copy of the write end of the pipe, so that reading from it fails if the new process
+	// exits without signalling that it is ready.
+	readyWriter.Close()
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = waitUntilReady(ready, upgradeReadyTimeout)
+	if err != nil {
+		// Stop the new process, so that it doesn't start taking connections from the listeners which this process
+		// carries on serving.
+		cmd.Process.Kill()
+		cmd.Wait()
+
+		return 0, fmt.Errorf("upgraded process did not become ready: %w", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// waitUntilReady() waits for the upgraded process to write to the read end of its readiness pipe. It returns
+// io.EOF if the process exits first, or an error wrapping os.ErrDeadlineExceeded if it takes longer than timeout.
+func waitUntilReady(ready *os.File, timeout time.Duration) error {
+	err := ready.SetReadDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = ready.Read(make([]byte, 1))
+	return err
+}
+
+// signalUpgradeReady() tells the parent process which started this one during a binary upgrade that it is ready to
+// serve requests on the inherited listeners, so that the parent can shut down. It does nothing if the process
+// wasn't started by a binary upgrade.
+func signalUpgradeReady() error {
+	inherited := os.Getenv(inheritedListenerEnv)
+	if inherited == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(inherited)
+	if err != nil {
+		return err
+	}
+
+	f := os.NewFile(uintptr(inheritedListenerFD+n), "ready")
+	defer f.Close()
+
+	_, err = f.Write([]byte{1})
+	return err
+}
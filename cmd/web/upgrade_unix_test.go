@@ -0,0 +1,41 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+)
+
+func TestWaitUntilReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		signal  func(w *os.File)
+		wantErr error
+	}{
+		{"Ready", func(w *os.File) { w.Write([]byte{1}) }, nil},
+		{"Exited", func(w *os.File) { w.Close() }, io.EOF},
+		{"Timed out", func(w *os.File) {}, os.ErrDeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			defer w.Close()
+
+			tt.signal(w)
+
+			err = waitUntilReady(r, 50*time.Millisecond)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
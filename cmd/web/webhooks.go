@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// How long a webhook receiver has to respond to a delivery, the number of deliveries shown in a webhook's delivery
+// log, and the number of expired snippets announced on each run of the expiry notifier.
+const (
+	webhookTimeout     = 10 * time.Second
+	webhookLogSize     = 50
+	webhookExpiryBatch = 100
+)
+
+// Define a webhookJob type to hold the payload of a models.JobKindWebhook job. The body is built when the event
+// happens, so that retries deliver exactly the same body.
+type webhookJob struct {
+	WebhookID int             `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// Define the body which is delivered to webhooks for each event.
+type webhookEvent struct {
+	Event   string     `json:"event"`
+	Created time.Time  `json:"created"`
+	Snippet apiSnippet `json:"snippet"`
+}
+
+// Function used to create a delivery job for each of a user's webhooks, for an event which happened to one of
+// their snippets. The jobs are written to the outbox in the same transaction as the change to the snippet.
+func (app *application) newWebhookJobs(ctx context.Context, event string, snippet *models.Snippet) ([]*models.Job, error) {
+	webhooks, err := app.webhooks.ForUser(ctx, snippet.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhookJobs(webhooks, event, snippet)
+}
+
+// Function used to return a models.NewJobs function which creates the deliveries of an event to the given webhooks
+// for a snippet which is being inserted, once its ID is known. The webhooks have to be looked up beforehand, since
+// the function is called inside the transaction which inserts the snippet. It returns nil if there are no webhooks.
+func newSnippetJobs(webhooks []*models.Webhook, event string, snippet *models.Snippet) models.NewJobs {
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	return func(id int) ([]*models.Job, error) {
+		s := *snippet
+		s.ID = id
+
+		return webhookJobs(webhooks, event, &s)
+	}
+}
+
+// Function used to create a delivery job for each of the given webhooks, for an event which happened to a snippet.
+func webhookJobs(webhooks []*models.Webhook, event string, snippet *models.Snippet) ([]*models.Job, error) {
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(webhookEvent{
+		Event:   event,
+		Created: time.Now().UTC(),
+		Snippet: newAPISnippet(snippet),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*models.Job, 0, len(webhooks))
+
+	for _, wh := range webhooks {
+		job, err := models.NewJob(models.JobKindWebhook, webhookJob{WebhookID: wh.ID, Event: event, Body: body})
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Function used to let the live views subscribed to the hub and the fragment cache know that a snippet has been
+// created. The owner's webhooks are sent the event by the jobs which were written along with the snippet (see
+// newSnippetJobs()).
+func (app *application) snippetCreated(snippet *models.Snippet) {
+	app.hub.publish(snippet)
+	app.fragments.Purge()
+}
+
+// announceExpiredSnippets() queues deliveries of the expired event for a single batch of snippets which have
+// expired. As with expiry notices, the deliveries are written to the outbox in the same transaction which marks the
+// snippet, so each expiry is only announced once.
+func (app *application) announceExpiredSnippets(ctx context.Context) {
+	snippets, err := app.snippets.Expired(ctx, webhookExpiryBatch)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, snippet := range snippets {
+		jobs, err := app.newWebhookJobs(ctx, models.EventSnippetExpired, snippet)
+		if err == nil {
+			err = app.snippets.MarkExpiryAnnounced(ctx, snippet.ID, jobs...)
+		}
+
+		if err != nil {
+			app.logger.Error("expired webhooks failed", "snippet_id", snippet.ID, "error", err)
+		}
+	}
+}
+
+// Function used to sign a webhook body with the webhook's secret. Receivers can check a delivery came from us by
+// computing the HMAC-SHA256 of the body themselves and comparing it with the X-Snippetbox-Signature header.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook() sends a webhook job's body to the webhook and records the outcome in its delivery log. An error
+// is returned if the delivery failed, so that the outbox retries it later with backoff. Jobs for webhooks which
+// have since been deleted are dropped.
+func (app *application) deliverWebhook(ctx context.Context, job webhookJob) error {
+	wh, err := app.webhooks.Get(ctx, job.WebhookID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(job.Body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhook/1.0")
+	req.Header.Set("X-Snippetbox-Event", job.Event)
+	req.Header.Set("X-Snippetbox-Signature", signWebhook(wh.Secret, job.Body))
+
+	var statusCode int
+
+	rs, err := app.webhookClient.Do(req)
+	if err == nil {
+		rs.Body.Close()
+		statusCode = rs.StatusCode
+
+		if statusCode < 200 || statusCode > 299 {
+			err = fmt.Errorf("webhook responded with status %d", statusCode)
+		}
+	}
+
+	var deliveryErr string
+	if err != nil {
+		deliveryErr = err.Error()
+	}
+
+	if logErr := app.webhooks.RecordDelivery(ctx, wh.ID, job.Event, statusCode, deliveryErr); logErr != nil {
+		app.logger.Error(logErr.Error())
+	}
+
+	return err
+}
+
+// Function used to create the HTTP client which delivers webhooks. Webhook URLs are chosen by users, so the client
+// refuses to connect to loopback, private and other non-public addresses (checked after DNS resolution, so that a
+// public hostname can't point at an internal service), and doesn't follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("webhook address %s is not a public IP address", host)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type webhookForm struct {
+	URL                 string `form:"url"`
+	validator.Validator `form:"-"`
+}
+
+// Render and display the authenticated user's webhooks, along with the form for adding a new one.
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, http.StatusOK, webhookForm{})
+}
+
+func (app *application) accountWebhooksPost(w http.ResponseWriter, r *http.Request) {
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 500), "url", "This field cannot be more than 500 characters long")
+	form.CheckField(validator.WebURL(form.URL), "url", "This field must be an http or https URL")
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	_, err = app.webhooks.Insert(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"), form.URL)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Webhook added.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// Function used to render the webhooks page with the given form.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, form webhookForm) {
+	webhooks, err := app.webhooks.ForUser(r.Context(), app.sessionManager.GetInt(r.Context(), "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Webhooks = webhooks
+	app.render(w, r, status, "webhooks.tmpl", data)
+}
+
+// Function used to fetch the webhook with the ID given in the URL, making sure that it belongs to the authenticated
+// user. If it doesn't exist or belongs to someone else, an HTTP 404 Not Found response is sent and false is
+// returned, in which case the calling handler should return immediately.
+func (app *application) ownedWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	wh, err := app.webhooks.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if wh.UserID != app.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return wh, true
+}
+
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	wh, ok := app.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.webhooks.Delete(r.Context(), wh.ID, wh.UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.flash(r, flashSuccess, "Webhook deleted.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// Display the most recent delivery attempts for one of the authenticated user's webhooks.
+func (app *application) accountWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	wh, ok := app.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(r.Context(), wh.ID, webhookLogSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhook = wh
+	data.WebhookDeliveries = deliveries
+	app.render(w, r, http.StatusOK, "webhookdeliveries.tmpl", data)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/declanlin/snippetbox/internal/models/mocks"
+)
+
+func TestSignWebhook(t *testing.T) {
+	signature := signWebhook("key", []byte("The quick brown fox jumps over the lazy dog"))
+
+	assert.Equal(t, signature, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")
+}
+
+// deliveryWebhookModel wraps the mock webhook model so that the mock webhook points at a test server, and records
+// the deliveries made to it.
+type deliveryWebhookModel struct {
+	mocks.WebhookModel
+	url        string
+	statusCode int
+	err        string
+}
+
+func (m *deliveryWebhookModel) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	wh, err := m.WebhookModel.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Webhook{ID: wh.ID, UserID: wh.UserID, URL: m.url, Secret: wh.Secret}, nil
+}
+
+func (m *deliveryWebhookModel) RecordDelivery(ctx context.Context, webhookID int, event string, statusCode int, deliveryErr string) error {
+	m.statusCode = statusCode
+	m.err = deliveryErr
+	return nil
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	body := []byte(`{"event":"snippet.created"}`)
+
+	tests := []struct {
+		name       string
+		webhookID  int
+		status     int
+		wantErr    bool
+		wantStatus int
+	}{
+		{"Success", 1, http.StatusNoContent, false, http.StatusNoContent},
+		{"Server error", 1, http.StatusInternalServerError, true, http.StatusInternalServerError},
+		{"Deleted webhook", 2, http.StatusNoContent, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotEvent, gotSignature, gotBody string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotBody = string(b)
+				gotEvent = r.Header.Get("X-Snippetbox-Event")
+				gotSignature = r.Header.Get("X-Snippetbox-Signature")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			app := newTestApplication(t)
+
+			webhooks := &deliveryWebhookModel{url: server.URL}
+			app.webhooks = webhooks
+
+			err := app.deliverWebhook(context.Background(), webhookJob{WebhookID: tt.webhookID, Event: models.EventSnippetCreated, Body: body})
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, webhooks.statusCode, tt.wantStatus)
+
+			if tt.wantStatus != 0 {
+				assert.Equal(t, gotBody, string(body))
+				assert.Equal(t, gotEvent, models.EventSnippetCreated)
+				assert.Equal(t, gotSignature, signWebhook("WEBHOOKSECRETABCDEFGHIJKLM", body))
+			}
+		})
+	}
+}
+
+func TestWebhookClientRejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The test server listens on the loopback interface, which webhooks mustn't be able to reach.
+	_, err := newWebhookClient().Get(server.URL)
+	if err == nil {
+		t.Fatal("expected an error connecting to a loopback address")
+	}
+
+	assert.StringContains(t, err.Error(), "is not a public IP address")
+}
+
+// announcedSnippetModel wraps the mock snippet model to record the jobs passed to MarkExpiryAnnounced().
+type announcedSnippetModel struct {
+	mocks.SnippetModel
+	jobs map[int][]*models.Job
+}
+
+func (m *announcedSnippetModel) MarkExpiryAnnounced(ctx context.Context, id int, jobs ...*models.Job) error {
+	m.jobs[id] = append(m.jobs[id], jobs...)
+	return nil
+}
+
+func TestAnnounceExpiredSnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &announcedSnippetModel{jobs: map[int][]*models.Job{}}
+	app.snippets = snippets
+
+	app.announceExpiredSnippets(context.Background())
+
+	// The mock snippet belongs to alice, who has a single webhook.
+	assert.Equal(t, len(snippets.jobs[1]), 1)
+
+	job := snippets.jobs[1][0]
+	assert.Equal(t, job.Kind, models.JobKindWebhook)
+
+	var payload webhookJob
+
+	err := json.Unmarshal(job.Payload, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, payload.WebhookID, 1)
+	assert.Equal(t, payload.Event, models.EventSnippetExpired)
+	assert.StringContains(t, string(payload.Body), `"event":"snippet.expired"`)
+}
+
+func TestAccountWebhooks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/webhooks")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "https://hooks.example.com/snippetbox")
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	formTests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid URL", "https://example.org/hooks", http.StatusSeeOther, ""},
+		{"Empty URL", "", http.StatusUnprocessableEntity, "This field cannot be blank"},
+		{"Other scheme", "ftp://example.org/hooks", http.StatusUnprocessableEntity, "This field must be an http or https URL"},
+		{"Too long", "https://example.org/" + strings.Repeat("a", 500), http.StatusUnprocessableEntity, "This field cannot be more than 500 characters long"},
+	}
+
+	for _, tt := range formTests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("url", tt.url)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, body := ts.postForm(t, "/account/webhooks", form)
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Deliveries", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/webhooks/1/deliveries")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "connection refused")
+
+		code, _, _ = ts.get(t, "/account/webhooks/2/deliveries")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	deleteTests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Own webhook", "/account/webhooks/1/delete", http.StatusSeeOther},
+		{"Non-existent webhook", "/account/webhooks/2/delete", http.StatusNotFound},
+		{"String ID", "/account/webhooks/foo/delete", http.StatusNotFound},
+	}
+
+	for _, tt := range deleteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+// updatedSnippetModel wraps the mock snippet model to record the jobs passed to Update().
+type updatedSnippetModel struct {
+	mocks.SnippetModel
+	jobs []*models.Job
+}
+
+func (m *updatedSnippetModel) Update(ctx context.Context, id int, title string, content string, version int, jobs ...*models.Job) error {
+	err := m.SnippetModel.Update(ctx, id, title, content, version, jobs...)
+	if err == nil {
+		m.jobs = append(m.jobs, jobs...)
+	}
+
+	return err
+}
+
+func TestSnippetUpdatedWebhook(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &updatedSnippetModel{}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/edit/1")
+
+	form := url.Values{}
+	form.Add("title", "O snail")
+	form.Add("content", "Climb Mount Fuji")
+	form.Add("version", "1")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/snippet/edit/1", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The mock snippet belongs to alice, who has a single webhook.
+	assert.Equal(t, len(snippets.jobs), 1)
+
+	var payload webhookJob
+
+	err := json.Unmarshal(snippets.jobs[0].Payload, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, payload.Event, models.EventSnippetUpdated)
+	assert.StringContains(t, string(payload.Body), `"title":"O snail"`)
+}
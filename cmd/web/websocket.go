@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// How long each write to a WebSocket may take before the client is considered gone, how long the client has to
+// answer a ping, and how often pings are sent (which must be more often than the pong timeout).
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 50 * time.Second
+)
+
+// The upgrader's default origin check only accepts handshakes from pages on our own host, which stops other sites
+// from opening connections with a visitor's browser.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Define the message which is sent to the home page for each newly created public snippet. The created time is
+// formatted in the same way as the rest of the page, and the content is excerpted as it is there, so that the
+// script can add the snippet to the list as it is.
+type wsSnippetMessage struct {
+	Type    string `json:"type"`
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+	Created string `json:"created"`
+}
+
+// The length which the excerpts of snippets on the home page are cut to, by the template and in WebSocket messages.
+const listingExcerptLen = 100
+
+// Send newly created public snippets to the home page over a WebSocket, so that its list of the latest snippets
+// refreshes as they are created (see ui/static/js/live.js). The connection lasts until the client closes it or the
+// server shuts down.
+func (app *application) wsUpdates(w http.ResponseWriter, r *http.Request) {
+	// Subscribe before completing the handshake, so that no snippets are missed once the client is connected.
+	events, unsubscribe := app.hub.subscribe()
+	defer unsubscribe()
+
+	// Upgrade() sends an error response itself if the handshake is invalid.
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	markStreaming(r)
+
+	// The client doesn't send us anything, but the connection must still be read from for pongs and close messages
+	// to be handled. The read loop ends when the client closes the connection or stops answering pings.
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case snippet, ok := <-events:
+			if !ok {
+				msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+
+			err := conn.WriteJSON(wsSnippetMessage{
+				Type:    "snippet",
+				ID:      snippet.ID,
+				Title:   snippet.Title,
+				Excerpt: markdownExcerpt(snippet.Content, listingExcerptLen),
+				Created: humanDate(snippet.Created),
+			})
+			if err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/assert"
+	"github.com/declanlin/snippetbox/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+func TestWSUpdates(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	dialer := websocket.Dialer{
+		TLSClientConfig:  ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone(),
+		HandshakeTimeout: 5 * time.Second,
+	}
+	url := "wss" + strings.TrimPrefix(ts.URL, "https") + "/ws/updates"
+
+	t.Run("Same origin", func(t *testing.T) {
+		header := http.Header{"Origin": []string{ts.URL}}
+
+		conn, rs, err := dialer.Dial(url, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		assert.Equal(t, rs.StatusCode, http.StatusSwitchingProtocols)
+
+		created := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+		app.hub.publish(&models.Snippet{ID: 7, Title: "Private", Private: true})
+		app.hub.publish(&models.Snippet{ID: 8, Title: "O snail", Content: "# O snail\nClimb Mount Fuji,\nbut slowly, slowly!", Created: created})
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		var msg wsSnippetMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, msg, wsSnippetMessage{Type: "snippet", ID: 8, Title: "O snail", Excerpt: "O snail Climb Mount Fuji, but slowly, slowly!", Created: "17 Mar 2024 at 10:15"})
+	})
+
+	t.Run("Cross origin", func(t *testing.T) {
+		header := http.Header{"Origin": []string{"https://evil.example.com"}}
+
+		_, rs, err := dialer.Dial(url, header)
+		if err == nil {
+			t.Fatal("expected the handshake to fail")
+		}
+
+		assert.Equal(t, rs.StatusCode, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,207 @@
+// Package dbtrace wraps a database/sql driver so that each statement which is run with a context carrying an
+// OpenTelemetry span is recorded as a child span of it, named after the SQL operation (e.g. "SELECT") and holding
+// the statement text. Statements run without a traced context aren't recorded, so background work such as session
+// cleanup doesn't produce a stream of orphaned traces.
+package dbtrace
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/declanlin/snippetbox/internal/dbtrace"
+
+// Wrap returns a connector which opens connections with c and traces the statements run on them. The system is
+// recorded as the db.system attribute of each span, e.g. "mysql".
+func Wrap(c driver.Connector, system string) driver.Connector {
+	return &connector{Connector: c, system: system}
+}
+
+type connector struct {
+	driver.Connector
+	system string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{Conn: dc, system: c.system}, nil
+}
+
+// Function used to start a span for a statement, if the context is being traced. The returned function must be
+// called with the statement's error to end the span.
+func startSpan(ctx context.Context, system, query string) (context.Context, func(error)) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, func(error) {}
+	}
+
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+
+	ctx, span := parent.TracerProvider().Tracer(tracerName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", query),
+		),
+	)
+
+	return ctx, func(err error) {
+		if err != nil && err != driver.ErrSkip {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Define a conn type which wraps a driver connection. Optional interfaces which the wrapped connection doesn't
+// implement are reported to database/sql with driver.ErrSkip, so that it falls back as it would without the
+// wrapper.
+type conn struct {
+	driver.Conn
+	system string
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		ds  driver.Stmt
+		err error
+	)
+
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		ds, err = p.PrepareContext(ctx, query)
+	} else {
+		ds, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{Stmt: ds, conn: c, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, end := startSpan(ctx, c.system, query)
+	result, err := e.ExecContext(ctx, query, args)
+	end(err)
+
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, end := startSpan(ctx, c.system, query)
+	rows, err := q.QueryContext(ctx, query, args)
+	end(err)
+
+	return rows, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// Define a stmt type which wraps a prepared statement, so that each execution of it is traced. Unlike connections,
+// database/sql doesn't fall back when a statement returns driver.ErrSkip, so statements which don't implement the
+// context methods are run with the older ones instead.
+type stmt struct {
+	driver.Stmt
+	conn  *conn
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	ctx, end := startSpan(ctx, s.conn.system, s.query)
+	defer func() { end(err) }()
+
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+
+	return s.Stmt.Exec(values(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx, end := startSpan(ctx, s.conn.system, s.query)
+	defer func() { end(err) }()
+
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+
+	return s.Stmt.Query(values(args))
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return s.conn.CheckNamedValue(nv)
+}
+
+// Function used to convert named arguments to the positional arguments which the older driver methods take.
+func values(args []driver.NamedValue) []driver.Value {
+	v := make([]driver.Value, len(args))
+	for i, arg := range args {
+		v[i] = arg.Value
+	}
+
+	return v
+}
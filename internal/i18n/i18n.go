@@ -0,0 +1,185 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Define a catalog type to hold the translations for one locale, as read from its JSON file, e.g.
+//
+//	{"name": "Français", "messages": {"Home": "Accueil"}}
+//
+// Messages are keyed by their English text, so anything which hasn't been translated yet is shown in English.
+type catalog struct {
+	Name     string            `json:"name"`
+	Messages map[string]string `json:"messages"`
+}
+
+// Define a Bundle type to hold the catalog for each supported locale, along with the locale which is used when
+// none of the supported locales is wanted.
+type Bundle struct {
+	catalogs      map[string]catalog
+	defaultLocale string
+}
+
+// Load reads a catalog from each of the JSON files in the top level of fsys, named after their locales (e.g.
+// fr.json). There must be a catalog for the default locale.
+func Load(fsys fs.FS, defaultLocale string) (*Bundle, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{catalogs: map[string]catalog{}, defaultLocale: defaultLocale}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var c catalog
+
+		err = json.Unmarshal(data, &c)
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", file, err)
+		}
+
+		b.catalogs[strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))] = c
+	}
+
+	if _, ok := b.catalogs[defaultLocale]; !ok {
+		return nil, fmt.Errorf("i18n: no catalog for the default locale %q", defaultLocale)
+	}
+
+	return b, nil
+}
+
+// Define a Language type to describe a supported locale, e.g. for a language picker.
+type Language struct {
+	Locale string
+	Name   string
+}
+
+// Languages returns the supported locales, in order.
+func (b *Bundle) Languages() []Language {
+	languages := make([]Language, 0, len(b.catalogs))
+	for locale, c := range b.catalogs {
+		languages = append(languages, Language{Locale: locale, Name: c.Name})
+	}
+
+	slices.SortFunc(languages, func(a, b Language) int { return strings.Compare(a.Locale, b.Locale) })
+
+	return languages
+}
+
+// Supported reports whether there is a catalog for the locale.
+func (b *Bundle) Supported(locale string) bool {
+	_, ok := b.catalogs[locale]
+	return ok
+}
+
+// Match returns the supported locale which best matches an Accept-Language header, e.g. "fr-CA,fr;q=0.9,en;q=0.8".
+// The languages are tried in order of preference, first as given and then without their region, so "fr-CA" matches
+// the "fr" catalog. The default locale is returned if none of them match.
+func (b *Bundle) Match(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{tag: strings.ToLower(tag), q: q})
+	}
+
+	// Languages with the same quality keep the order they were given in.
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for _, c := range candidates {
+		if b.Supported(c.tag) {
+			return c.tag
+		}
+
+		if base, _, ok := strings.Cut(c.tag, "-"); ok && b.Supported(base) {
+			return base
+		}
+	}
+
+	return b.defaultLocale
+}
+
+// Localizer returns a Localizer which translates into the locale, or into the default locale if it isn't supported.
+func (b *Bundle) Localizer(locale string) *Localizer {
+	if !b.Supported(locale) {
+		locale = b.defaultLocale
+	}
+
+	return &Localizer{bundle: b, locale: locale}
+}
+
+// Define a Localizer type which translates messages into one locale.
+type Localizer struct {
+	bundle *Bundle
+	locale string
+}
+
+// Locale returns the locale which l translates into. A nil Localizer translates into English.
+func (l *Localizer) Locale() string {
+	if l == nil {
+		return "en"
+	}
+
+	return l.locale
+}
+
+// T translates a message, falling back to the default locale and then to the message itself. If there are any
+// arguments, the translation is used as a format string for them, as with fmt.Sprintf(). A nil Localizer returns the
+// message untranslated, so that pages rendered without one still work.
+func (l *Localizer) T(message string, args ...any) string {
+	translation := message
+
+	if l != nil {
+		if s, ok := l.bundle.catalogs[l.locale].Messages[message]; ok {
+			translation = s
+		} else if s, ok := l.bundle.catalogs[l.bundle.defaultLocale].Messages[message]; ok {
+			translation = s
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(translation, args...)
+	}
+
+	return translation
+}
@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// Embed the email templates so that they are compiled into the binary alongside the rest of the application.
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// Define a Mailer type which holds a mail.Dialer instance (used to connect to an SMTP server) and the sender
+// information for outgoing emails (e.g. "Snippetbox <no-reply@snippetbox.example.com>").
+type Mailer struct {
+	dialer *mail.Dialer
+	sender string
+}
+
+type MailerInterface interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// Initialize a new Mailer instance with the given SMTP server settings.
+func New(host string, port int, username, password, sender string) *Mailer {
+	// Configure the dialer with a 5-second timeout whenever we send an email.
+	dialer := mail.NewDialer(host, port, username, password)
+	dialer.Timeout = 5 * time.Second
+
+	return &Mailer{
+		dialer: dialer,
+		sender: sender,
+	}
+}
+
+// Send() executes the "subject", "plainBody" and "htmlBody" templates in the specified template file using the
+// provided dynamic data, and sends the resulting email to the recipient.
+func (m *Mailer) Send(recipient, templateFile string, data any) error {
+	// Parse the template file from the embedded filesystem.
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+
+	// Execute each of the named templates into their own byte buffers.
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+
+	// Build the message, setting the plain-text body first and the HTML body as an alternative.
+	msg := mail.NewMessage()
+	msg.SetHeader("To", recipient)
+	msg.SetHeader("From", m.sender)
+	msg.SetHeader("Subject", subject.String())
+	msg.SetBody("text/plain", plainBody.String())
+	msg.AddAlternative("text/html", htmlBody.String())
+
+	// Open a connection to the SMTP server, send the message, and close the connection.
+	return m.dialer.DialAndSend(msg)
+}
@@ -0,0 +1,7 @@
+package mocks
+
+type Mailer struct{}
+
+func (m *Mailer) Send(recipient, templateFile string, data any) error {
+	return nil
+}
@@ -0,0 +1,446 @@
+// Package migrations holds the versioned SQL migrations which create and evolve the database schema, embedded in
+// the binary, and a Migrator which applies them. Each database system has its own directory of migrations (e.g.
+// sql/mysql), holding a pair of files for each migration named "<version>_<name>.up.sql" and
+// "<version>_<name>.down.sql", where the versions are numbered from 1 without gaps. Every directory has the same
+// migrations, so that a version means the same schema whichever database is used. The version of the schema is
+// recorded in the schema_migrations table.
+//
+// MySQL can't roll back schema changes, so each migration is recorded as dirty while it is being applied. If it
+// fails partway through, the database has to be repaired by hand and its version set with Force before any more
+// migrations can be applied. Migrations are applied the same way on PostgreSQL. On SQLite, each run of migrations
+// is a single transaction, which also stops two processes from migrating at once.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+//go:embed sql/mysql/*.sql sql/postgres/*.sql sql/sqlite/*.sql
+var files embed.FS
+
+// The name of the lock which stops two processes from migrating the same database at once, and how long to wait
+// for it in seconds.
+const (
+	lockName    = "snippetbox_migrations"
+	lockTimeout = 30
+)
+
+// Custom error for when the database has a migration which failed partway through.
+var ErrDirty = errors.New("migrations: the database is dirty after a failed migration")
+
+// Custom error for when the database already has tables, but no record of which migrations have been applied,
+// e.g. because it was created before migrations were introduced.
+var ErrUnmanaged = errors.New("migrations: the database has tables which weren't created by migrations")
+
+// Define a dialect type to hold the SQL which differs between the database systems that migrations can be applied
+// to.
+type dialect struct {
+	// Function used to take the migration lock on conn, waiting up to lockTimeout seconds for it, and a statement
+	// which releases it.
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock string
+
+	// A query which counts the tables in the database other than schema_migrations.
+	countTables string
+
+	// A statement which records the version of the schema and whether it is dirty.
+	insertVersion string
+
+	// Function used to report whether err means that a table doesn't exist.
+	missingTable func(err error) bool
+}
+
+var dialects = map[string]*dialect{
+	"mysql": {
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			var locked sql.NullInt64
+
+			err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, lockTimeout).Scan(&locked)
+			if err != nil {
+				return err
+			}
+			if locked.Int64 != 1 {
+				return errLockTimeout
+			}
+
+			return nil
+		},
+		unlock: `SELECT RELEASE_LOCK('` + lockName + `')`,
+		countTables: `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name <> 'schema_migrations'`,
+		insertVersion: `INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)`,
+		missingTable: func(err error) bool {
+			var mySQLError *mysql.MySQLError
+			return errors.As(err, &mySQLError) && mySQLError.Number == 1146
+		},
+	},
+	"postgres": {
+		// PostgreSQL's advisory locks can't be waited for with a timeout, so the lock is tried once a second.
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			for i := 0; i < lockTimeout; i++ {
+				var locked bool
+
+				err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockName).Scan(&locked)
+				if err != nil {
+					return err
+				}
+				if locked {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+				}
+			}
+
+			return errLockTimeout
+		},
+		unlock: `SELECT pg_advisory_unlock(hashtext('` + lockName + `'))`,
+		countTables: `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`,
+		insertVersion: `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`,
+		missingTable: func(err error) bool {
+			var pgError *pgconn.PgError
+			return errors.As(err, &pgError) && pgError.Code == "42P01"
+		},
+	},
+	"sqlite": {
+		// SQLite locks the whole database for writing when an immediate transaction begins, waiting for the busy
+		// timeout given in the DSN if another connection holds the lock. The migrations run inside the transaction.
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`)
+			return err
+		},
+		unlock: `COMMIT`,
+		countTables: `SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name <> 'schema_migrations' AND name NOT LIKE 'sqlite_%'`,
+		insertVersion: `INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)`,
+		missingTable: func(err error) bool {
+			var sqliteError sqlite3.Error
+			return errors.As(err, &sqliteError) && strings.HasPrefix(sqliteError.Error(), "no such table")
+		},
+	},
+}
+
+var errLockTimeout = errors.New("migrations: timed out waiting for another process to finish migrating")
+
+// Define a Migration type to hold one of the embedded migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load returns the embedded migrations for the given database driver ("mysql", "postgres" or "sqlite") in
+// order of version.
+func Load(driver string) ([]Migration, error) {
+	if _, ok := dialects[driver]; !ok {
+		return nil, fmt.Errorf("migrations: unsupported database driver %q", driver)
+	}
+
+	names, err := fs.Glob(files, path.Join("sql", driver, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, name := range names {
+		base := path.Base(name)
+
+		prefix, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrations: invalid file name %q", base)
+		}
+
+		number, title, ok := strings.Cut(prefix, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: invalid file name %q", base)
+		}
+
+		b, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, found := byVersion[version]
+		if !found {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for version := 1; version <= len(byVersion); version++ {
+		m, found := byVersion[version]
+		if !found {
+			return nil, fmt.Errorf("migrations: version %d is missing", version)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down file", version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	return migrations, nil
+}
+
+// Define a Migrator type which applies the embedded migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	dialect    *dialect
+	migrations []Migration
+}
+
+// New returns a Migrator for the given database, which is accessed with the given driver ("mysql", "postgres" or
+// "sqlite").
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, dialect: dialects[driver], migrations: migrations}, nil
+}
+
+// Latest returns the version which the migrations bring the schema up to.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the current version of the schema (0 if no migrations have been applied), and whether the last
+// migration failed partway through.
+func (m *Migrator) Version(ctx context.Context) (int, bool, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	return m.version(ctx, conn)
+}
+
+// Up applies every migration which hasn't been applied yet, returning the migrations which were applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn, current int) error {
+		if current == 0 {
+			// A database without any migrations should be empty, since the first migration creates the tables.
+			var tables int
+
+			err := conn.QueryRowContext(ctx, m.dialect.countTables).Scan(&tables)
+			if err != nil {
+				return err
+			}
+			if tables > 0 {
+				return fmt.Errorf("%w (if the schema is up to date, force version %d)", ErrUnmanaged, m.Latest())
+			}
+		}
+
+		for _, migration := range m.migrations[current:] {
+			err := m.apply(ctx, conn, migration.Version, migration.Up, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrations: applying %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the given number of migrations, most recent first, returning the migrations which were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn, current int) error {
+		for i := 0; i < steps && current > 0; i++ {
+			migration := m.migrations[current-1]
+
+			err := m.apply(ctx, conn, migration.Version, migration.Down, migration.Version-1)
+			if err != nil {
+				return fmt.Errorf("migrations: reverting %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+			current--
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Force records the given version as the current version of the schema without running any migrations, and
+// clears the dirty flag. It is used once a failed migration has been repaired by hand, or to start managing a
+// database which was created before migrations were introduced.
+func (m *Migrator) Force(ctx context.Context, v int) error {
+	if v < 0 || v > m.Latest() {
+		return fmt.Errorf("migrations: version %d doesn't exist", v)
+	}
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = createVersionTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return m.setVersion(ctx, conn, v, false)
+}
+
+// Function used to run fn with the migration lock held, on the connection which holds it, along with the current
+// version of the schema. It fails with ErrDirty if a migration failed partway through.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, current int) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = m.dialect.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), m.dialect.unlock)
+
+	err = createVersionTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	current, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w (at version %d)", ErrDirty, current)
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("migrations: the database is at version %d, which is newer than the latest migration (%d)", current, m.Latest())
+	}
+
+	return fn(conn, current)
+}
+
+// Function used to run the statements of a migration, recording the schema as dirty at the given version while
+// they run and then as clean at the target version.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, dirtyVersion int, script string, target int) error {
+	err := m.setVersion(ctx, conn, dirtyVersion, true)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range statements(script) {
+		_, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.setVersion(ctx, conn, target, false)
+}
+
+// Function used to split a migration script into its statements, which end with a semicolon at the end of a line.
+// The MySQL driver only runs one statement at a time unless multiStatements is enabled in the DSN.
+func statements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if s := strings.TrimSpace(current.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+
+	return stmts
+}
+
+func createVersionTable(ctx context.Context, conn *sql.Conn) error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	dirty BOOLEAN NOT NULL
+	)`
+
+	_, err := conn.ExecContext(ctx, stmt)
+	return err
+}
+
+// Function used to read the version of the schema. The schema_migrations table holds at most one row.
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int, bool, error) {
+	var v int
+	var dirty bool
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&v, &dirty)
+	if err != nil {
+		// The table doesn't exist until a migration has been applied.
+		if errors.Is(err, sql.ErrNoRows) || m.dialect.missingTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return v, dirty, nil
+}
+
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, v int, dirty bool) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+
+	if v == 0 && !dirty {
+		return nil
+	}
+
+	_, err = conn.ExecContext(ctx, m.dialect.insertVersion, v, dirty)
+	return err
+}
@@ -0,0 +1,7 @@
+-- Create a `blocked_words` table to store words which are not allowed in snippet titles.
+CREATE TABLE blocked_words (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    word VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL
+);
+ALTER TABLE blocked_words ADD CONSTRAINT blocked_words_uc_word UNIQUE (word);
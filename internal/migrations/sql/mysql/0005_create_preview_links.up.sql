@@ -0,0 +1,11 @@
+-- Create a `preview_links` table to store the hashed tokens of preview links for private snippets.
+CREATE TABLE preview_links (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    hash BINARY(32) NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+ALTER TABLE preview_links ADD CONSTRAINT preview_links_uc_hash UNIQUE (hash);
+ALTER TABLE preview_links ADD CONSTRAINT preview_links_fk_snippet FOREIGN KEY (snippet_id)
+REFERENCES snippets(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Add a column to the `users` table which records when a locked account can next be logged in to.
+ALTER TABLE users ADD COLUMN locked_until DATETIME;
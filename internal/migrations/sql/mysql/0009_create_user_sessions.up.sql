@@ -0,0 +1,13 @@
+-- Create a `user_sessions` table to record which logged in sessions belong to which user.
+CREATE TABLE user_sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    token CHAR(43) NOT NULL,
+    user_id INTEGER NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    last_activity DATETIME NOT NULL
+);
+ALTER TABLE user_sessions ADD CONSTRAINT user_sessions_uc_token UNIQUE (token);
+ALTER TABLE user_sessions ADD CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id)
+REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Add a `role` column to the `users` table. Roles are 'user', 'moderator' or 'admin'.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
@@ -0,0 +1,2 @@
+-- Add an `active` column to the `users` table. Deactivated users can't log in.
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
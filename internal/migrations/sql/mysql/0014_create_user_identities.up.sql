@@ -0,0 +1,11 @@
+-- Create a `user_identities` table to link users to their accounts with OAuth providers (e.g. GitHub).
+CREATE TABLE user_identities (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_uc_provider_subject UNIQUE (provider, subject);
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_fk_user FOREIGN KEY (user_id)
+REFERENCES users(id) ON DELETE CASCADE;
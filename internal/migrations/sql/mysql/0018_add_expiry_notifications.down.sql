@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_expires ON snippets;
+ALTER TABLE snippets DROP COLUMN expiry_notified;
+ALTER TABLE users DROP COLUMN notify_expiry;
@@ -0,0 +1,5 @@
+-- Add columns to record whether users want to be emailed before their snippets expire, and which snippets
+-- they have already been emailed about.
+ALTER TABLE users ADD COLUMN notify_expiry BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE snippets ADD COLUMN expiry_notified BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX idx_snippets_expires ON snippets(expires);
@@ -0,0 +1,2 @@
+-- Add a column to record the rate limit tier of each API token.
+ALTER TABLE api_tokens ADD COLUMN tier VARCHAR(20) NOT NULL DEFAULT 'standard';
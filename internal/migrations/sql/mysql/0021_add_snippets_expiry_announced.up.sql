@@ -0,0 +1,4 @@
+-- Add a column to record which expired snippets have been announced to webhooks. Snippets which had already
+-- expired when the column was added are marked as announced.
+ALTER TABLE snippets ADD COLUMN expiry_announced BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE snippets ALTER COLUMN expiry_announced SET DEFAULT FALSE;
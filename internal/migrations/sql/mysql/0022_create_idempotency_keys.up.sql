@@ -0,0 +1,16 @@
+-- Create an `idempotency_keys` table to store the responses to API requests with an Idempotency-Key header,
+-- so that retries of the requests can be sent the same response.
+CREATE TABLE idempotency_keys (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash BINARY(32) NOT NULL,
+    status INTEGER NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    body MEDIUMBLOB NOT NULL,
+    expires DATETIME NOT NULL
+);
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_uc_user_key UNIQUE (user_id, idempotency_key);
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_fk_user FOREIGN KEY (user_id)
+REFERENCES users(id) ON DELETE CASCADE;
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires);
@@ -0,0 +1,8 @@
+-- Create a `contact_messages` table to store messages submitted through the contact form.
+CREATE TABLE contact_messages (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    created TIMESTAMP NOT NULL
+);
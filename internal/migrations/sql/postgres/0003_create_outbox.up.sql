@@ -0,0 +1,14 @@
+-- Create an `outbox` table to store background jobs (e.g. emails) until they have been run successfully.
+CREATE TABLE outbox (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    run_after TIMESTAMP NOT NULL,
+    created TIMESTAMP NOT NULL,
+    completed TIMESTAMP
+);
+
+-- Add an index which lets the dispatcher find pending jobs quickly.
+CREATE INDEX idx_outbox_pending ON outbox(completed, run_after);
@@ -0,0 +1 @@
+DROP TABLE preview_links;
@@ -0,0 +1,3 @@
+-- Add a `verified` column to the `users` table. Users are considered verified until they change their email
+-- address, at which point they need to confirm the new address.
+ALTER TABLE users ADD COLUMN verified BOOLEAN NOT NULL DEFAULT TRUE;
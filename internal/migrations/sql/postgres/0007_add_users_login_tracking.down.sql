@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN failed_logins;
+ALTER TABLE users DROP COLUMN last_login_ip;
+ALTER TABLE users DROP COLUMN last_login;
@@ -0,0 +1,5 @@
+-- Add columns to the `users` table which record each user's last successful login, and the number of failed
+-- login attempts since then.
+ALTER TABLE users ADD COLUMN last_login TIMESTAMP;
+ALTER TABLE users ADD COLUMN last_login_ip VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
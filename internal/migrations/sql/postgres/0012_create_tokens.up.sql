@@ -0,0 +1,8 @@
+-- Create a `tokens` table to store the hashed single-use tokens which are emailed to users.
+CREATE TABLE tokens (
+    hash BYTEA NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry TIMESTAMP NOT NULL,
+    scope VARCHAR(50) NOT NULL
+);
+ALTER TABLE tokens ADD CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
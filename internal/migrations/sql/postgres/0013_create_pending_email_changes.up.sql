@@ -0,0 +1,8 @@
+-- Create a `pending_email_changes` table to hold new email addresses until they have been confirmed.
+CREATE TABLE pending_email_changes (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+ALTER TABLE pending_email_changes ADD CONSTRAINT pending_email_changes_fk_user FOREIGN KEY (user_id)
+REFERENCES users(id) ON DELETE CASCADE;
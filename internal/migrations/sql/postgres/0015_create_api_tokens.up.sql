@@ -0,0 +1,12 @@
+-- Create an `api_tokens` table to store the hashed personal API tokens which users create for the JSON API.
+CREATE TABLE api_tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    hash BYTEA NOT NULL,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    last_used TIMESTAMP
+);
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_hash UNIQUE (hash);
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id)
+REFERENCES users(id) ON DELETE CASCADE;
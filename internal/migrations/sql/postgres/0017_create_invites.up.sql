@@ -0,0 +1,12 @@
+-- Create an `invites` table to store the hashed invite codes which admins create when signups are invite-only.
+CREATE TABLE invites (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    hash BYTEA NOT NULL,
+    created_by INTEGER NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    used TIMESTAMP
+);
+ALTER TABLE invites ADD CONSTRAINT invites_uc_hash UNIQUE (hash);
+ALTER TABLE invites ADD CONSTRAINT invites_fk_user FOREIGN KEY (created_by)
+REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+-- Add a column holding the version of each snippet, which is incremented by every edit. Edits are only applied to
+-- the version which the editor loaded, so that two people editing a snippet at once can't overwrite each other.
+ALTER TABLE snippets ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Add the version column to the snippets_archive table, so that archived snippets keep their version when they are
+-- restored. Snippets which were archived before this migration are restored as version 1.
+ALTER TABLE snippets_archive ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
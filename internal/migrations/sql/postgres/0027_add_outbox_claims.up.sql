@@ -0,0 +1,5 @@
+-- Add columns recording which dispatcher has claimed each job in the outbox, and until when. A job is only run by
+-- the dispatcher which claimed it, so that two processes sharing the database don't run it twice. Jobs whose claim
+-- has run out (e.g. because their dispatcher crashed) can be claimed again.
+ALTER TABLE outbox ADD COLUMN claimed_by VARCHAR(100);
+ALTER TABLE outbox ADD COLUMN claimed_until TIMESTAMP;
@@ -0,0 +1,35 @@
+-- Create a `snippets` table.
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NOT NULL,
+    private BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Add an index on the created column.
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+-- Add an index on the user_id column, which is used to list the snippets on a user's profile page.
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+
+-- Create a `users` table. Email addresses are unique, which the users model relies on to detect duplicate signups.
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT users_uc_email UNIQUE (email)
+);
+
+-- Create a `sessions` table for the session store used by scs (see internal/sqlite3store). Expiry times are
+-- stored as Julian day numbers.
+CREATE TABLE sessions (
+    token TEXT PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
@@ -0,0 +1,10 @@
+-- Create a `preview_links` table to store the hashed tokens of preview links for private snippets.
+CREATE TABLE preview_links (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    hash BLOB NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT preview_links_uc_hash UNIQUE (hash),
+    CONSTRAINT preview_links_fk_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
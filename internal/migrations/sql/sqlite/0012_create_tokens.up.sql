@@ -0,0 +1,8 @@
+-- Create a `tokens` table to store the hashed single-use tokens which are emailed to users.
+CREATE TABLE tokens (
+    hash BLOB NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expiry DATETIME NOT NULL,
+    scope VARCHAR(50) NOT NULL,
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- Create an `api_tokens` table to store the hashed personal API tokens which users create for the JSON API.
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    hash BLOB NOT NULL,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME,
+    CONSTRAINT api_tokens_uc_hash UNIQUE (hash),
+    CONSTRAINT api_tokens_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+-- Create an `avatars` table to store each user's avatar, resized to each of the sizes it is served at.
+CREATE TABLE avatars (
+    user_id INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    image BLOB NOT NULL,
+    updated DATETIME NOT NULL,
+    PRIMARY KEY (user_id, size),
+    CONSTRAINT avatars_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
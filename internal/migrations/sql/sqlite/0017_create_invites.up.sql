@@ -0,0 +1,11 @@
+-- Create an `invites` table to store the hashed invite codes which admins create when signups are invite-only.
+CREATE TABLE invites (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    hash BLOB NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    used DATETIME,
+    CONSTRAINT invites_uc_hash UNIQUE (hash),
+    CONSTRAINT invites_fk_user FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+);
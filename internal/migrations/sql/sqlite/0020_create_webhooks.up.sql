@@ -0,0 +1,19 @@
+-- Create a `webhooks` table to store the URLs which users want their snippets' lifecycle events sent to, and
+-- a `webhook_deliveries` table to log each attempt to deliver an event.
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    secret CHAR(26) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT webhooks_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT webhook_deliveries_fk_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
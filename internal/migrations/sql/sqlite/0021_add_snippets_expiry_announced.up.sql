@@ -0,0 +1,5 @@
+-- Add a column to record which expired snippets have been announced to webhooks. Snippets which had already
+-- expired when the column was added are marked as announced. SQLite can't change the default of a column, so
+-- they are marked after the column has been added.
+ALTER TABLE snippets ADD COLUMN expiry_announced BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE snippets SET expiry_announced = TRUE;
@@ -0,0 +1,15 @@
+-- Create an `idempotency_keys` table to store the responses to API requests with an Idempotency-Key header,
+-- so that retries of the requests can be sent the same response.
+CREATE TABLE idempotency_keys (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash BLOB NOT NULL,
+    status INTEGER NOT NULL,
+    location VARCHAR(255) NOT NULL,
+    body BLOB NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT idempotency_keys_uc_user_key UNIQUE (user_id, idempotency_key),
+    CONSTRAINT idempotency_keys_fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires);
@@ -0,0 +1,13 @@
+-- Create a `snippets_archive` table to hold snippets which expired long ago, moved out of the snippets table by
+-- the archiver so that it stays small. The snippets keep their IDs, so that they can be restored.
+CREATE TABLE snippets_archive (
+    id INTEGER NOT NULL PRIMARY KEY,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    user_id INTEGER NOT NULL,
+    private BOOLEAN NOT NULL DEFAULT FALSE,
+    archived DATETIME NOT NULL
+);
+CREATE INDEX idx_snippets_archive_archived ON snippets_archive(archived);
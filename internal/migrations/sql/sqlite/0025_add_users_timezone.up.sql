@@ -0,0 +1,3 @@
+-- Add a column holding the IANA name of the time zone which each user has chosen to see dates in, e.g.
+-- "Europe/London". It is empty for users who haven't chosen one, whose browser's time zone is used instead.
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE snippets_archive DROP COLUMN version;
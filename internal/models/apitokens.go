@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The rate limit tiers which API tokens can be in. Tokens are in the standard tier when they are created; the tier
+// can only be changed in the database.
+const (
+	APITierStandard = "standard"
+	APITierElevated = "elevated"
+)
+
+// Define an APIToken type to hold the details of a personal API token. The plaintext token is only known when the
+// token is created, since only its hash is stored. The tier determines the token's rate limit.
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Tier     string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+// Define an APITokenModel type which wraps an sql.DB connection pool. API tokens let users access the JSON API
+// without a session cookie, by sending the token in an "Authorization: Bearer" header. Unlike the tokens in
+// TokenModel, they don't expire and can be used any number of times until they are revoked.
+type APITokenModel struct {
+	DB *DB
+}
+
+type APITokenModelInterface interface {
+	New(ctx context.Context, userID int, name string) (string, error)
+	Authenticate(ctx context.Context, token string) (*APIToken, error)
+	ForUser(ctx context.Context, userID int) ([]*APIToken, error)
+	Revoke(ctx context.Context, id, userID int) error
+}
+
+// Function to create a new API token with the given name for a user, returning the plaintext token.
+func (m *APITokenModel) New(ctx context.Context, userID int, name string) (string, error) {
+	token, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO api_tokens (hash, user_id, name, created) VALUES(?, ?, ?, %s)`, m.DB.Dialect.now())
+
+	_, err = m.DB.ExecContext(ctx, stmt, hash, userID, name)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Function to return the API token matching a plaintext token, recording that it has been used. An ErrNoRecord
+// error is returned if there is no matching token. To avoid a database write on every request, the last used time is
+// only updated if it is more than a minute old.
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (*APIToken, error) {
+	t := &APIToken{}
+
+	var lastUsed sql.NullTime
+
+	hash := hashToken(token)
+
+	stmt := `SELECT id, user_id, name, tier, created, last_used FROM api_tokens WHERE hash = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, hash).Scan(&t.ID, &t.UserID, &t.Name, &t.Tier, &t.Created, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	t.LastUsed = lastUsed.Time
+
+	stmt = fmt.Sprintf(`UPDATE api_tokens SET last_used = %[1]s
+	WHERE id = ? AND (last_used IS NULL OR last_used < %[2]s)`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("-1", "MINUTE"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Function to return a user's API tokens, most recently created first.
+func (m *APITokenModel) ForUser(ctx context.Context, userID int) ([]*APIToken, error) {
+	stmt := `SELECT id, user_id, name, tier, created, last_used FROM api_tokens WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+
+	for rows.Next() {
+		t := &APIToken{}
+
+		var lastUsed sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Tier, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		t.LastUsed = lastUsed.Time
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Function to revoke one of a user's API tokens. An ErrNoRecord error is returned if the token doesn't exist or
+// belongs to another user.
+func (m *APITokenModel) Revoke(ctx context.Context, id, userID int) error {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The columns which are copied between the snippets table and the snippets_archive table.
+const archivedColumns = "id, title, content, created, expires, user_id, private, version"
+
+// Define a function that will move up to limit snippets which expired more than retention ago from the snippets
+// table to the snippets_archive table, oldest first, returning how many were moved. Each batch is moved in a
+// single transaction, so a snippet is never in both tables or in neither. The snippets' preview links are deleted
+// along with them.
+func (m *SnippetModel) Archive(ctx context.Context, retention time.Duration, limit int) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	stmt := fmt.Sprintf(`SELECT id FROM snippets WHERE expires < %s ORDER BY expires LIMIT ? %s`,
+		tx.Dialect.fromNow("?", "SECOND"), tx.Dialect.forUpdate())
+
+	rows, err := tx.QueryContext(ctx, stmt, -int(retention.Seconds()), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	var ids []any
+
+	for rows.Next() {
+		var id int
+
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	stmt = fmt.Sprintf(`INSERT INTO snippets_archive (%[1]s, archived) SELECT %[1]s, %[2]s FROM snippets
+	WHERE id IN (%[3]s)`, archivedColumns, tx.Dialect.now(), in)
+
+	_, err = tx.ExecContext(ctx, stmt, ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM snippets WHERE id IN (%s)`, in), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// Define a function that will move an archived snippet back to the snippets table, so that it can be viewed again
+// until it expires the given number of days from now. If there is no archived snippet with the ID, ErrNoRecord is
+// returned.
+func (m *SnippetModel) Restore(ctx context.Context, id int, expires int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt := fmt.Sprintf(`INSERT INTO snippets (%s) SELECT id, title, content, created, %s, user_id, private, version
+	FROM snippets_archive WHERE id = ?`, archivedColumns, tx.Dialect.fromNow("?", "DAY"))
+
+	result, err := tx.ExecContext(ctx, stmt, expires, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM snippets_archive WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Define a function that will return up to limit of the most recently archived snippets.
+func (m *SnippetModel) Archived(ctx context.Context, limit int) ([]*Snippet, error) {
+	stmt := fmt.Sprintf(`SELECT %s FROM snippets_archive ORDER BY archived DESC, id DESC LIMIT ?`, archivedColumns)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private, &s.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Define an Avatar type to hold a user's avatar image at one of the sizes it is stored at. Image holds the encoded
+// PNG image.
+type Avatar struct {
+	UserID  int
+	Size    int
+	Image   []byte
+	Updated time.Time
+}
+
+// Define an AvatarModel type which wraps an sql.DB connection pool. Each avatar is stored once for every size it
+// is served at, so that it doesn't need to be resized on every request.
+type AvatarModel struct {
+	DB *DB
+}
+
+type AvatarModelInterface interface {
+	Set(ctx context.Context, userID int, images map[int][]byte) error
+	Get(ctx context.Context, userID, size int) (*Avatar, error)
+	Updated(ctx context.Context, userID int) (time.Time, error)
+	Delete(ctx context.Context, userID int) error
+}
+
+// Function to replace a user's avatar with the given images, keyed by their size.
+func (m *AvatarModel) Set(ctx context.Context, userID int, images map[int][]byte) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM avatars WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	for size, image := range images {
+		stmt := fmt.Sprintf(`INSERT INTO avatars (user_id, size, image, updated) VALUES(?, ?, ?, %s)`, tx.Dialect.now())
+
+		_, err = tx.ExecContext(ctx, stmt, userID, size, image)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Function to return a user's avatar at the given size. An ErrNoRecord error is returned if the user has no
+// avatar.
+func (m *AvatarModel) Get(ctx context.Context, userID, size int) (*Avatar, error) {
+	a := &Avatar{}
+
+	stmt := `SELECT user_id, size, image, updated FROM avatars WHERE user_id = ? AND size = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, size).Scan(&a.UserID, &a.Size, &a.Image, &a.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// Function to return the time a user's avatar was last changed, without fetching the images. An ErrNoRecord error
+// is returned if the user has no avatar.
+func (m *AvatarModel) Updated(ctx context.Context, userID int) (time.Time, error) {
+	var updated sql.NullTime
+
+	err := m.DB.QueryRowContext(ctx, `SELECT MAX(updated) FROM avatars WHERE user_id = ?`, userID).Scan(&updated)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !updated.Valid {
+		return time.Time{}, ErrNoRecord
+	}
+
+	return updated.Time, nil
+}
+
+// Function to remove a user's avatar.
+func (m *AvatarModel) Delete(ctx context.Context, userID int) error {
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM avatars WHERE user_id = ?`, userID)
+	return err
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a BlockedWord type to hold data for an individual entry in the denylist of words which are not allowed
+// to appear in public listings (e.g. snippet titles).
+type BlockedWord struct {
+	ID      int
+	Word    string
+	Created time.Time
+}
+
+// The length of time for which the denylist is cached in memory before being re-read from the database.
+const blockedWordsCacheTTL = 5 * time.Minute
+
+// Define a BlockedWordModel type which wraps an sql.DB connection pool. The denylist is checked on every snippet
+// submission, so the words are cached in memory and the cache is invalidated whenever the list changes.
+type BlockedWordModel struct {
+	DB *DB
+
+	mu       sync.Mutex
+	words    []string
+	cachedAt time.Time
+}
+
+type BlockedWordModelInterface interface {
+	Words(ctx context.Context) ([]string, error)
+	All(ctx context.Context) ([]*BlockedWord, error)
+	Insert(ctx context.Context, word string) error
+	Delete(ctx context.Context, id int) error
+}
+
+// Define a function that will return the blocked words as a slice of lowercase strings, using the in-memory cache
+// if it has not yet expired.
+func (m *BlockedWordModel) Words(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.words != nil && time.Since(m.cachedAt) < blockedWordsCacheTTL {
+		return m.words, nil
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT word FROM blocked_words`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	words := []string{}
+
+	for rows.Next() {
+		var word string
+
+		err = rows.Scan(&word)
+		if err != nil {
+			return nil, err
+		}
+
+		words = append(words, word)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	m.words = words
+	m.cachedAt = time.Now()
+
+	return words, nil
+}
+
+// Define a function that will return every entry in the denylist, in alphabetical order.
+func (m *BlockedWordModel) All(ctx context.Context) ([]*BlockedWord, error) {
+	rows, err := m.DB.QueryContext(ctx, `SELECT id, word, created FROM blocked_words ORDER BY word`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	blockedWords := []*BlockedWord{}
+
+	for rows.Next() {
+		b := &BlockedWord{}
+
+		err = rows.Scan(&b.ID, &b.Word, &b.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		blockedWords = append(blockedWords, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blockedWords, nil
+}
+
+// Define a function that will add a word to the denylist. Words are stored in lowercase so that matching is
+// case-insensitive. Adding a word which is already blocked is a no-op.
+func (m *BlockedWordModel) Insert(ctx context.Context, word string) error {
+	stmt := fmt.Sprintf(`INSERT INTO blocked_words (word, created) VALUES(?, %s)`, m.DB.Dialect.now())
+
+	_, err := m.DB.ExecContext(ctx, stmt, strings.ToLower(strings.TrimSpace(word)))
+	if err != nil && !m.DB.Dialect.isDuplicate(err, "blocked_words_uc_word") {
+		return err
+	}
+
+	m.invalidate()
+
+	return nil
+}
+
+// Define a function that will remove the entry with a specific ID from the denylist.
+func (m *BlockedWordModel) Delete(ctx context.Context, id int) error {
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM blocked_words WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	m.invalidate()
+
+	return nil
+}
+
+// Function used to clear the cached denylist so that the next call to Words() reads it from the database.
+func (m *BlockedWordModel) invalidate() {
+	m.mu.Lock()
+	m.words = nil
+	m.mu.Unlock()
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Define a ContactMessage type to hold data for an individual message submitted through the contact form.
+type ContactMessage struct {
+	ID      int
+	Name    string
+	Email   string
+	Message string
+	Created time.Time
+}
+
+// Define a ContactModel type which wraps an sql.DB connection pool.
+type ContactModel struct {
+	DB *DB
+}
+
+type ContactModelInterface interface {
+	Insert(ctx context.Context, name, email, message string, jobs ...*Job) (int, error)
+	List(ctx context.Context, filters Filters) ([]*ContactMessage, Metadata, error)
+}
+
+// Define a function that will store a new contact message in the MYSQL database. Any jobs passed in (e.g. emails
+// notifying the site operators) are written to the outbox in the same transaction as the message.
+func (m *ContactModel) Insert(ctx context.Context, name, email, message string, jobs ...*Job) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Roll back the transaction if we return before committing it. Calling Rollback() after Commit() is a no-op.
+	defer tx.Rollback()
+
+	// Generate an SQL statement for inserting a new contact message into the database.
+	stmt := fmt.Sprintf(`INSERT INTO contact_messages (name, email, message, created)
+	VALUES(?, ?, ?, %s)`, tx.Dialect.now())
+
+	id, err := tx.Dialect.insert(ctx, tx, stmt, name, email, message)
+	if err != nil {
+		return 0, err
+	}
+
+	err = enqueueJobs(ctx, tx, jobs...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Define a function that will return a page of contact messages, newest first, optionally filtered by a search term
+// matching the sender's name or email address.
+func (m *ContactModel) List(ctx context.Context, filters Filters) ([]*ContactMessage, Metadata, error) {
+	// COUNT(*) OVER() adds the total number of matching rows (ignoring LIMIT and OFFSET) to each row.
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, name, email, message, created FROM contact_messages
+	WHERE (? = '' OR %s OR %s) ORDER BY created DESC, id DESC LIMIT ? OFFSET ?`, m.DB.Dialect.like("name"), m.DB.Dialect.like("email"))
+
+	pattern := filters.likePattern()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, filters.Search, pattern, pattern, filters.PageSize, filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	messages := []*ContactMessage{}
+
+	for rows.Next() {
+		c := &ContactMessage{}
+
+		err = rows.Scan(&totalRecords, &c.ID, &c.Name, &c.Email, &c.Message, &c.Created)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		messages = append(messages, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return messages, calculateMetadata(totalRecords, filters), nil
+}
@@ -0,0 +1,387 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Define a Dialect type to describe how the SQL used by the models differs between the database systems which they
+// can be used with. Statements are written with ? placeholders, which DB and Tx convert to the dialect's own, and
+// the few parts of them which can't be written portably (e.g. the current time) come from the dialect's methods.
+type Dialect struct {
+	// The name of the database system, e.g. "mysql".
+	Name string
+
+	// Whether placeholders are numbered ($1, $2 and so on) rather than written as ?.
+	numberedPlaceholders bool
+
+	// The SQL for the current UTC time.
+	timestamp string
+
+	// Function used to return the SQL for the current UTC time plus n of the given unit.
+	offset func(n, unit string) string
+
+	// The SQL which follows a column to match it against a LIKE pattern in a placeholder without regard to case.
+	likeClause string
+
+	// Whether an upsert is written with ON CONFLICT, rather than MYSQL's ON DUPLICATE KEY UPDATE.
+	onConflict bool
+
+	// The clause which locks the rows read by a SELECT statement until the end of the transaction, if any.
+	lockClause string
+
+	// Whether the ID of an inserted row has to be returned by the INSERT statement, because the driver doesn't
+	// support sql.Result.LastInsertId.
+	returningID bool
+
+	// Function used to report whether err is a violation of the given unique constraint (or of any unique
+	// constraint if it is empty).
+	uniqueViolation func(err error, constraint string) bool
+
+	// Function used to report whether err is a violation of a foreign key constraint.
+	foreignKeyViolation func(err error) bool
+}
+
+// The dialects of the supported database systems.
+var (
+	MySQL = &Dialect{
+		Name:      "mysql",
+		timestamp: "UTC_TIMESTAMP()",
+		offset: func(n, unit string) string {
+			return fmt.Sprintf("UTC_TIMESTAMP() + INTERVAL %s %s", n, unit)
+		},
+		likeClause: "LIKE ?",
+		lockClause: "FOR UPDATE",
+		uniqueViolation: func(err error, constraint string) bool {
+			// MySQL reports duplicate entries with error 1062 (ER_DUP_ENTRY), naming the constraint in the message.
+			var mySQLError *mysql.MySQLError
+			return errors.As(err, &mySQLError) && mySQLError.Number == 1062 &&
+				strings.Contains(mySQLError.Message, constraint)
+		},
+		foreignKeyViolation: func(err error) bool {
+			// MySQL reports deleting a referenced row with error 1451 (ER_ROW_IS_REFERENCED_2), and referring to a
+			// missing one with error 1452 (ER_NO_REFERENCED_ROW_2).
+			var mySQLError *mysql.MySQLError
+			return errors.As(err, &mySQLError) && (mySQLError.Number == 1451 || mySQLError.Number == 1452)
+		},
+	}
+
+	Postgres = &Dialect{
+		Name:                 "postgres",
+		numberedPlaceholders: true,
+		timestamp:            "(NOW() AT TIME ZONE 'UTC')",
+		offset: func(n, unit string) string {
+			return fmt.Sprintf("(NOW() AT TIME ZONE 'UTC') + CAST(%s AS BIGINT) * INTERVAL '1 %s'", n, unit)
+		},
+		likeClause:  "ILIKE ?",
+		onConflict:  true,
+		lockClause:  "FOR UPDATE",
+		returningID: true,
+		uniqueViolation: func(err error, constraint string) bool {
+			var pgError *pgconn.PgError
+			return errors.As(err, &pgError) && pgError.Code == "23505" &&
+				(constraint == "" || pgError.ConstraintName == constraint)
+		},
+		foreignKeyViolation: func(err error) bool {
+			var pgError *pgconn.PgError
+			return errors.As(err, &pgError) && pgError.Code == "23503"
+		},
+	}
+
+	// SQLite stores times as text, in a format which sorts in time order and which the driver parses back into
+	// time.Time values for DATETIME columns. It has no row locks (the whole database is locked by a transaction
+	// instead), and doesn't escape LIKE patterns unless asked to.
+	SQLite = &Dialect{
+		Name:      "sqlite",
+		timestamp: "datetime('now')",
+		offset: func(n, unit string) string {
+			return fmt.Sprintf("datetime('now', %s || ' %sS')", n, unit)
+		},
+		likeClause: `LIKE ? ESCAPE '\'`,
+		onConflict: true,
+		uniqueViolation: func(err error, constraint string) bool {
+			// SQLite names the table and columns in the message rather than the constraint, so the constraint is
+			// matched by the table name it starts with (e.g. "users" for users_uc_email).
+			var sqliteError sqlite3.Error
+			table, _, _ := strings.Cut(constraint, "_uc_")
+			return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique &&
+				(constraint == "" || strings.Contains(sqliteError.Error(), "failed: "+table+"."))
+		},
+		foreignKeyViolation: func(err error) bool {
+			var sqliteError sqlite3.Error
+			return errors.As(err, &sqliteError) && sqliteError.ExtendedCode == sqlite3.ErrConstraintForeignKey
+		},
+	}
+)
+
+// DialectFor returns the dialect of the given database system, i.e. "mysql", "postgres" or "sqlite".
+func DialectFor(name string) (*Dialect, error) {
+	for _, d := range []*Dialect{MySQL, Postgres, SQLite} {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("models: unsupported database %q", name)
+}
+
+// Function used to convert the ? placeholders in a statement into the dialect's own. Question marks inside string
+// literals are left alone.
+func (d *Dialect) rebind(query string) string {
+	if !d.numberedPlaceholders || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	inString := false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'':
+			inString = !inString
+		case c == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// Function used to return the SQL for the current UTC time.
+func (d *Dialect) now() string {
+	return d.timestamp
+}
+
+// Function used to return the SQL for the current UTC time plus n of the given unit (SECOND, MINUTE or DAY). n is
+// either a number, which can be negative, or a ? placeholder.
+func (d *Dialect) fromNow(n, unit string) string {
+	return d.offset(n, unit)
+}
+
+// Function used to return the SQL which matches a column against a LIKE pattern (given in a ? placeholder) without
+// regard to case. MYSQL's LIKE ignores case with the default collation, but PostgreSQL's doesn't.
+func (d *Dialect) like(column string) string {
+	return column + " " + d.likeClause
+}
+
+// Function used to return the clause which locks the rows read by a SELECT statement until the end of the
+// transaction. It is empty for SQLite, where a transaction locks the whole database.
+func (d *Dialect) forUpdate() string {
+	return d.lockClause
+}
+
+// Function used to return the clause which locks the rows read by a SELECT statement until the end of the
+// transaction like forUpdate(), but skips rows which another transaction has already locked instead of waiting for
+// them. It is empty for SQLite, where transactions which write (see sqliteParams in cmd/web) take it in turns.
+func (d *Dialect) forUpdateSkipLocked() string {
+	if d.lockClause == "" {
+		return ""
+	}
+
+	return d.lockClause + " SKIP LOCKED"
+}
+
+// Function used to return the clause of an INSERT statement which updates the given columns with the inserted
+// values instead if a row with the same key already exists.
+func (d *Dialect) upsert(key string, columns ...string) string {
+	sets := make([]string, len(columns))
+
+	for i, column := range columns {
+		if d.onConflict {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+		} else {
+			sets[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+		}
+	}
+
+	if d.onConflict {
+		return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(sets, ", "))
+	}
+
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// Function used to report whether err is a violation of the given unique constraint, or of any unique constraint
+// if it is empty.
+func (d *Dialect) isDuplicate(err error, constraint string) bool {
+	return err != nil && d.uniqueViolation(err, constraint)
+}
+
+// Function used to wrap the error returned by the driver for a failed statement in a QueryError, classifying it as
+// ErrConflict or ErrForeignKey if it is a constraint violation. The statement's whitespace is collapsed so that it
+// fits on one line of a log. Errors which are already wrapped are returned as they are.
+func (d *Dialect) queryError(query string, err error) error {
+	var queryError *QueryError
+	if err == nil || errors.As(err, &queryError) {
+		return err
+	}
+
+	queryError = &QueryError{Query: strings.Join(strings.Fields(query), " "), Err: err}
+
+	switch {
+	case d.uniqueViolation(err, ""):
+		queryError.kind = ErrConflict
+	case d.foreignKeyViolation(err):
+		queryError.kind = ErrForeignKey
+	}
+
+	return queryError
+}
+
+// Define an execQueryer interface, which is satisfied by both *DB and *Tx.
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Function used to return an INSERT statement into a table with an id column as it is run by insert(), which
+// returns the ID of the new row from the statement itself if the dialect needs it to.
+func (d *Dialect) returning(stmt string) string {
+	if d.returningID {
+		return stmt + " RETURNING id"
+	}
+
+	return stmt
+}
+
+// Function used to run an INSERT statement into a table with an id column, returning the ID of the new row.
+func (d *Dialect) insert(ctx context.Context, q execQueryer, stmt string, args ...any) (int, error) {
+	if d.returningID {
+		var id int
+
+		err := q.QueryRowContext(ctx, d.returning(stmt), args...).Scan(&id)
+		if err != nil {
+			return 0, d.queryError(stmt, err)
+		}
+
+		return id, nil
+	}
+
+	result, err := q.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// LastInsertId() returns the integer generated by the database for the AUTO_INCREMENT column of the new row.
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Define a DB type which wraps an sql.DB connection pool along with the dialect of the database it connects to,
+// and an optional read-only replica of the database (see replica.go). Its methods convert the placeholders in
+// statements before running them, run them in the transaction started by WithTx if the context carries one (see
+// tx.go), and wrap the errors of failed statements in QueryErrors.
+type DB struct {
+	*sql.DB
+	Dialect *Dialect
+	Replica *Replica
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if tx := db.txFrom(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+
+	result, err := db.DB.ExecContext(ctx, db.Dialect.rebind(query), args...)
+	return result, db.Dialect.queryError(query, err)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if tx := db.txFrom(ctx); tx != nil {
+		return tx.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := db.DB.QueryContext(ctx, db.Dialect.rebind(query), args...)
+	return rows, db.Dialect.queryError(query, err)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if tx := db.txFrom(ctx); tx != nil {
+		return tx.QueryRowContext(ctx, query, args...)
+	}
+
+	return db.DB.QueryRowContext(ctx, db.Dialect.rebind(query), args...)
+}
+
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.DB.PrepareContext(ctx, db.Dialect.rebind(query))
+}
+
+// BeginTx starts a transaction. If the context carries a transaction started by WithTx, the returned transaction
+// is part of it, and committing or rolling it back does nothing.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	if outer := db.txFrom(ctx); outer != nil {
+		return &Tx{Tx: outer.Tx, Dialect: db.Dialect, pool: db.DB, nested: true}, nil
+	}
+
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{Tx: tx, Dialect: db.Dialect, pool: db.DB}, nil
+}
+
+// Define a Tx type which wraps an sql.Tx transaction in the same way as DB, along with the connection pool which
+// it was started on.
+type Tx struct {
+	*sql.Tx
+	Dialect *Dialect
+	pool    *sql.DB
+	nested  bool
+}
+
+func (tx *Tx) Commit() error {
+	if tx.nested {
+		return nil
+	}
+
+	return tx.Tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.nested {
+		return nil
+	}
+
+	return tx.Tx.Rollback()
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	result, err := tx.Tx.ExecContext(ctx, tx.Dialect.rebind(query), args...)
+	return result, tx.Dialect.queryError(query, err)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := tx.Tx.QueryContext(ctx, tx.Dialect.rebind(query), args...)
+	return rows, tx.Dialect.queryError(query, err)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.Dialect.rebind(query), args...)
+}
+
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.Tx.PrepareContext(ctx, tx.Dialect.rebind(query))
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Define an EmailChangeModel type which wraps an sql.DB connection pool. When a user changes their email address,
+// the new address is held as a pending change until the user confirms it using a link sent to the new address.
+// Each user has at most one pending change.
+type EmailChangeModel struct {
+	DB *DB
+}
+
+type EmailChangeModelInterface interface {
+	Insert(ctx context.Context, userID int, email string) error
+	Get(ctx context.Context, userID int) (string, error)
+	Confirm(ctx context.Context, userID int) error
+}
+
+// Function to record a pending change of a user's email address, replacing any previous pending change. An
+// ErrDuplicateEmail error is returned if the new address already belongs to a user.
+func (m *EmailChangeModel) Insert(ctx context.Context, userID int, email string) error {
+	var exists bool
+
+	err := m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT true FROM users WHERE email = ?)`, email).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrDuplicateEmail
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO pending_email_changes (user_id, email, created) VALUES(?, ?, %s) %s`,
+		m.DB.Dialect.now(), m.DB.Dialect.upsert("user_id", "email", "created"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, email)
+	return err
+}
+
+// Function to return the new email address in a user's pending change. An ErrNoRecord error is returned if the
+// user has no pending change.
+func (m *EmailChangeModel) Get(ctx context.Context, userID int) (string, error) {
+	var email string
+
+	err := m.DB.QueryRowContext(ctx, `SELECT email FROM pending_email_changes WHERE user_id = ?`, userID).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	return email, nil
+}
+
+// Function to apply a user's pending change. The new address is marked as
+// verified, since confirming the change proves that the user controls it. An ErrNoRecord error is returned if
+// there is no pending change, and an ErrDuplicateEmail error if another user has taken the address since the
+// change was requested.
+func (m *EmailChangeModel) Confirm(ctx context.Context, userID int) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var email string
+
+	err = tx.QueryRowContext(ctx, `SELECT email FROM pending_email_changes WHERE user_id = ? `+tx.Dialect.forUpdate(), userID).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET email = ?, verified = TRUE WHERE id = ?`, email, userID)
+	if err != nil {
+		if tx.Dialect.isDuplicate(err, "users_uc_email") {
+			return ErrDuplicateEmail
+		}
+
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM pending_email_changes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
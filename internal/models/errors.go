@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Custom error for when an sql row query returns no matching records.
 var ErrNoRecord = errors.New("models: no matching record found")
@@ -10,3 +13,52 @@ var ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 // Custom error for when a user attempts to sign up with an email address that is already being used.
 var ErrDuplicateEmail = errors.New("models: duplicate email")
+
+// Custom error for when a user attempts to log in to an account which has been locked after too many failed
+// login attempts.
+var ErrAccountLocked = errors.New("models: account locked")
+
+// Custom error for when a user attempts to log in to an account which has been deactivated by an administrator.
+var ErrAccountDeactivated = errors.New("models: account deactivated")
+
+// Custom error for when a user logs in through an OAuth provider with the email address of an existing account which
+// hasn't been verified. Whoever created the account may not own the address, so the identity isn't linked to it.
+var ErrAccountUnverified = errors.New("models: account unverified")
+
+// Custom error for when a user attempts to link an identity with an OAuth provider which is already linked to
+// another user.
+var ErrDuplicateIdentity = errors.New("models: duplicate identity")
+
+// Custom error for when a user attempts to save an edit to a record which has been changed by someone else since
+// they loaded it.
+var ErrEditConflict = errors.New("models: edit conflict")
+
+// Custom error for when a statement fails because it would create a record which conflicts with an existing one,
+// i.e. because it violates a unique constraint.
+var ErrConflict = errors.New("models: conflicting record")
+
+// Custom error for when a statement fails because it refers to a record which doesn't exist, or deletes a record
+// which others still refer to, i.e. because it violates a foreign key constraint.
+var ErrForeignKey = errors.New("models: foreign key violation")
+
+// Define a QueryError type to wrap the error returned by the database driver for a failed statement, along with
+// the statement, so that the error says which statement failed. If the failure is a constraint violation, it is
+// also classified as ErrConflict or ErrForeignKey, which errors.Is() finds along with the driver's error.
+type QueryError struct {
+	Query string
+	Err   error
+
+	kind error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("models: query %q: %v", e.Query, e.Err)
+}
+
+func (e *QueryError) Unwrap() []error {
+	if e.kind != nil {
+		return []error{e.kind, e.Err}
+	}
+
+	return []error{e.Err}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Define a Filters type to hold the search term, page, sort order and other filters requested for a paginated
+// listing. Listings which don't support a filter ignore it. Sort is a column name, prefixed with "-" for a
+// descending sort, and must be one of the values in SortSafelist.
+type Filters struct {
+	Search       string
+	Page         int
+	PageSize     int
+	Sort         string
+	SortSafelist []string
+	Author       int
+	CreatedAfter time.Time
+}
+
+// Function used to return the number of records to skip to reach the requested page.
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// Function used to return the column to sort by. The sort value is checked against the safelist before it is used
+// in a query, since it can't be passed as a placeholder parameter; the handlers validate it first, so a value which
+// isn't on the safelist is a bug and causes a panic.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// Function used to return the direction to sort in ("ASC" or "DESC").
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// Function used to return a pattern for matching the search term with LIKE. Wildcard characters in the search term
+// are escaped, so that they match literally.
+func (f Filters) likePattern() string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(f.Search) + "%"
+}
+
+// Define a Metadata type to hold the pagination details of a listing.
+type Metadata struct {
+	CurrentPage  int
+	PageSize     int
+	LastPage     int
+	TotalRecords int
+}
+
+// Function used to calculate the pagination metadata for a listing from the total number of matching records.
+func calculateMetadata(totalRecords int, f Filters) Metadata {
+	lastPage := (totalRecords + f.PageSize - 1) / f.PageSize
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	return Metadata{
+		CurrentPage:  f.Page,
+		PageSize:     f.PageSize,
+		LastPage:     lastPage,
+		TotalRecords: totalRecords,
+	}
+}
+
+// Function used to check whether there is a page before the current one.
+func (m Metadata) HasPrevious() bool {
+	return m.CurrentPage > 1
+}
+
+// Function used to check whether there is a page after the current one.
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
+
+// Function used to return the number of the page before the current one.
+func (m Metadata) PreviousPage() int {
+	return m.CurrentPage - 1
+}
+
+// Function used to return the number of the page after the current one.
+func (m Metadata) NextPage() int {
+	return m.CurrentPage + 1
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Define an IdempotentResponse type to hold the response which was sent to an API request with an Idempotency-Key
+// header, so that it can be sent again if the request is retried with the same key. RequestHash identifies the
+// request, so that a key can't be reused for a different request.
+type IdempotentResponse struct {
+	RequestHash []byte
+	Status      int
+	Location    string
+	Body        []byte
+}
+
+// Define an IdempotencyModel type which wraps an sql.DB connection pool.
+type IdempotencyModel struct {
+	DB *DB
+}
+
+type IdempotencyModelInterface interface {
+	Get(ctx context.Context, userID int, key string) (*IdempotentResponse, error)
+	Insert(ctx context.Context, userID int, key string, response *IdempotentResponse, ttl time.Duration) error
+}
+
+// Function to return the response stored for one of a user's idempotency keys. An ErrNoRecord error is returned if
+// the key hasn't been used, or its response has expired.
+func (m *IdempotencyModel) Get(ctx context.Context, userID int, key string) (*IdempotentResponse, error) {
+	stmt := fmt.Sprintf(`SELECT request_hash, status, location, body FROM idempotency_keys
+	WHERE user_id = ? AND idempotency_key = ? AND expires > %s`, m.DB.Dialect.now())
+
+	res := &IdempotentResponse{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, key).Scan(&res.RequestHash, &res.Status, &res.Location, &res.Body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Function to store the response to a request with an idempotency key for the given duration. Expired responses
+// are deleted at the same time. If a response has already been stored for the key (e.g. by a concurrent retry of
+// the same request), the existing response is kept.
+func (m *IdempotencyModel) Insert(ctx context.Context, userID int, key string, response *IdempotentResponse, ttl time.Duration) error {
+	_, err := m.DB.ExecContext(ctx, fmt.Sprintf(`DELETE FROM idempotency_keys WHERE expires <= %s`, m.DB.Dialect.now()))
+	if err != nil {
+		return err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, status, location, body, expires)
+	VALUES(?, ?, ?, ?, ?, ?, %s)`, m.DB.Dialect.fromNow("?", "SECOND"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, key, response.RequestHash, response.Status, response.Location, response.Body, int(ttl.Seconds()))
+	if err != nil {
+		if m.DB.Dialect.isDuplicate(err, "idempotency_keys_uc_user_key") {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
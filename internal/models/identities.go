@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a UserIdentityModel type which wraps an sql.DB connection pool. A user identity links a user account to
+// an account with an external OAuth provider (e.g. GitHub), identified by the provider's subject ID for the user.
+type UserIdentityModel struct {
+	DB *DB
+}
+
+type UserIdentityModelInterface interface {
+	Authenticate(ctx context.Context, provider, subject, name, email string) (int, error)
+	Link(ctx context.Context, userID int, provider, subject string) error
+}
+
+// Function to find or create the user for an identity with an OAuth provider, returning the user's ID. The email
+// address must already have been verified by the provider.
+//
+//   - If the identity has been seen before, the user it is linked to is returned.
+//   - Otherwise, if a verified user with the same email address exists, the identity is linked to that user. If the
+//     user hasn't verified their email address, an ErrAccountUnverified error is returned instead, since the account
+//     may have been created by someone else in order to take over the identity's owner's account once it is linked.
+//   - Otherwise, a new user is created (with an unusable random password) and the identity is linked to them.
+func (m *UserIdentityModel) Authenticate(ctx context.Context, provider, subject, name, email string) (int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var id int
+
+	stmt := `SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`
+
+	err = tx.QueryRowContext(ctx, stmt, provider, subject).Scan(&id)
+	if err == nil {
+		return id, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var verified bool
+
+	stmt = `SELECT id, verified FROM users WHERE email = ?`
+
+	err = tx.QueryRowContext(ctx, stmt, email).Scan(&id, &verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, err = insertPasswordlessUser(ctx, tx, name, email)
+		verified = true
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !verified {
+		return 0, ErrAccountUnverified
+	}
+
+	err = insertIdentity(ctx, tx, id, provider, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Function to link an identity with an OAuth provider to the given user, who has proved that they own it by logging
+// in to the provider. Linking an identity which is already linked to the user does nothing, and an
+// ErrDuplicateIdentity error is returned if it is linked to another user.
+func (m *UserIdentityModel) Link(ctx context.Context, userID int, provider, subject string) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var id int
+
+	stmt := `SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`
+
+	err = tx.QueryRowContext(ctx, stmt, provider, subject).Scan(&id)
+	switch {
+	case err == nil && id == userID:
+		return nil
+	case err == nil:
+		return ErrDuplicateIdentity
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	err = insertIdentity(ctx, tx, userID, provider, subject)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function used to link an identity with an OAuth provider to a user as part of an existing transaction.
+func insertIdentity(ctx context.Context, tx *Tx, userID int, provider, subject string) error {
+	stmt := fmt.Sprintf(`INSERT INTO user_identities (user_id, provider, subject, created)
+	VALUES(?, ?, ?, %s)`, tx.Dialect.now())
+
+	_, err := tx.ExecContext(ctx, stmt, userID, provider, subject)
+	if tx.Dialect.isDuplicate(err, "user_identities_uc_provider_subject") {
+		return ErrDuplicateIdentity
+	}
+
+	return err
+}
+
+// Function used to create a user who signs in through an OAuth provider. The user is given a random password
+// which is never disclosed, so they can only log in with a password after changing it. Their email address has been
+// verified by the provider, so they are created as verified.
+func insertPasswordlessUser(ctx context.Context, tx *Tx, name, email string) (int, error) {
+	password := make([]byte, 32)
+
+	_, err := rand.Read(password)
+	if err != nil {
+		return 0, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword(password, 12)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO users (name, email, hashed_password, created, verified)
+	VALUES (?, ?, ?, %s, TRUE)`, tx.Dialect.now())
+
+	return tx.Dialect.insert(ctx, tx, stmt, name, email, string(hashedPassword))
+}
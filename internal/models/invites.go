@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Define an Invite type to hold the details of an invite code which allows someone to sign up when signups are
+// invite-only. Like other tokens, only the hash of the code is stored.
+type Invite struct {
+	ID        int
+	CreatedBy int
+	Created   time.Time
+	Expires   time.Time
+	Used      time.Time
+}
+
+// Define an InviteModel type which wraps an sql.DB connection pool.
+type InviteModel struct {
+	DB *DB
+}
+
+type InviteModelInterface interface {
+	New(ctx context.Context, createdBy int, ttl time.Duration) (string, error)
+	Redeem(ctx context.Context, code string) error
+	List(ctx context.Context) ([]*Invite, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// Function to create a new invite which is valid for the given duration, returning the plaintext invite code.
+func (m *InviteModel) New(ctx context.Context, createdBy int, ttl time.Duration) (string, error) {
+	code, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO invites (hash, created_by, created, expires)
+	VALUES(?, ?, %[1]s, %[2]s)`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("?", "SECOND"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, hash, createdBy, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Function to mark an unused, unexpired invite as used. The check and the update are a single statement, so an
+// invite can't be redeemed twice by concurrent signups. An ErrNoRecord error is returned if there is no such invite.
+func (m *InviteModel) Redeem(ctx context.Context, code string) error {
+	stmt := fmt.Sprintf(`UPDATE invites SET used = %[1]s
+	WHERE hash = ? AND used IS NULL AND expires > %[1]s`, m.DB.Dialect.now())
+
+	result, err := m.DB.ExecContext(ctx, stmt, hashToken(code))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Function to return all invites, most recently created first.
+func (m *InviteModel) List(ctx context.Context) ([]*Invite, error) {
+	stmt := `SELECT id, created_by, created, expires, used FROM invites ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invites := []*Invite{}
+
+	for rows.Next() {
+		i := &Invite{}
+
+		var used sql.NullTime
+
+		err = rows.Scan(&i.ID, &i.CreatedBy, &i.Created, &i.Expires, &used)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Used = used.Time
+
+		invites = append(invites, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// Function to delete an invite, so that it can no longer be used. An ErrNoRecord error is returned if there is no
+// matching invite.
+func (m *InviteModel) Delete(ctx context.Context, id int) error {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM invites WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
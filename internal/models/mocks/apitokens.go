@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockAPIToken = &models.APIToken{
+	ID:      1,
+	UserID:  1,
+	Name:    "Deploy script",
+	Tier:    models.APITierStandard,
+	Created: time.Now(),
+}
+
+var mockElevatedAPIToken = &models.APIToken{
+	ID:      2,
+	UserID:  1,
+	Name:    "Sync",
+	Tier:    models.APITierElevated,
+	Created: time.Now(),
+}
+
+type APITokenModel struct{}
+
+func (m *APITokenModel) New(ctx context.Context, userID int, name string) (string, error) {
+	return "NEWAPITOKENABCDEFGHIJKLMNO", nil
+}
+
+func (m *APITokenModel) Authenticate(ctx context.Context, token string) (*models.APIToken, error) {
+	switch token {
+	case "ALICEAPITOKENABCDEFGHIJKLM":
+		return mockAPIToken, nil
+	case "ALICEELEVATEDTOKENABCDEFGH":
+		return mockElevatedAPIToken, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *APITokenModel) ForUser(ctx context.Context, userID int) ([]*models.APIToken, error) {
+	switch userID {
+	case 1:
+		return []*models.APIToken{mockAPIToken}, nil
+	default:
+		return []*models.APIToken{}, nil
+	}
+}
+
+func (m *APITokenModel) Revoke(ctx context.Context, id, userID int) error {
+	if id == mockAPIToken.ID && userID == mockAPIToken.UserID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
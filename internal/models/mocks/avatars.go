@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockAvatarUpdated = time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+type AvatarModel struct{}
+
+func (m *AvatarModel) Set(ctx context.Context, userID int, images map[int][]byte) error {
+	return nil
+}
+
+func (m *AvatarModel) Get(ctx context.Context, userID, size int) (*models.Avatar, error) {
+	if userID == 1 {
+		return &models.Avatar{
+			UserID:  1,
+			Size:    size,
+			Image:   []byte("\x89PNG\r\n\x1a\n"),
+			Updated: mockAvatarUpdated,
+		}, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *AvatarModel) Updated(ctx context.Context, userID int) (time.Time, error) {
+	if userID == 1 {
+		return mockAvatarUpdated, nil
+	}
+
+	return time.Time{}, models.ErrNoRecord
+}
+
+func (m *AvatarModel) Delete(ctx context.Context, userID int) error {
+	return nil
+}
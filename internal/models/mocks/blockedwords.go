@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockBlockedWord = &models.BlockedWord{
+	ID:      1,
+	Word:    "spam",
+	Created: time.Now(),
+}
+
+type BlockedWordModel struct{}
+
+func (m *BlockedWordModel) Words(ctx context.Context) ([]string, error) {
+	return []string{mockBlockedWord.Word}, nil
+}
+
+func (m *BlockedWordModel) All(ctx context.Context) ([]*models.BlockedWord, error) {
+	return []*models.BlockedWord{mockBlockedWord}, nil
+}
+
+func (m *BlockedWordModel) Insert(ctx context.Context, word string) error {
+	return nil
+}
+
+func (m *BlockedWordModel) Delete(ctx context.Context, id int) error {
+	return nil
+}
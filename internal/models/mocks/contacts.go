@@ -0,0 +1,27 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type ContactModel struct{}
+
+func (m *ContactModel) Insert(ctx context.Context, name, email, message string, jobs ...*models.Job) (int, error) {
+	return 1, nil
+}
+
+func (m *ContactModel) List(ctx context.Context, filters models.Filters) ([]*models.ContactMessage, models.Metadata, error) {
+	message := &models.ContactMessage{
+		ID:      1,
+		Name:    "Bob",
+		Email:   "bob@example.com",
+		Message: "Hello! I love the site.",
+		Created: time.Now(),
+	}
+
+	metadata := models.Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, LastPage: 1, TotalRecords: 1}
+	return []*models.ContactMessage{message}, metadata, nil
+}
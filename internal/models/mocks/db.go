@@ -0,0 +1,13 @@
+package mocks
+
+import "context"
+
+// Define a DB type which stands in for the database connection pool in health checks. Set Err to make the database
+// appear unreachable.
+type DB struct {
+	Err error
+}
+
+func (m *DB) PingContext(ctx context.Context) error {
+	return m.Err
+}
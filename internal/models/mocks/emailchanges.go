@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"context"
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type EmailChangeModel struct{}
+
+func (m *EmailChangeModel) Insert(ctx context.Context, userID int, email string) error {
+	switch email {
+	case "dupe@example.com":
+		return models.ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+func (m *EmailChangeModel) Get(ctx context.Context, userID int) (string, error) {
+	return "", models.ErrNoRecord
+}
+
+func (m *EmailChangeModel) Confirm(ctx context.Context, userID int) error {
+	if userID == 1 {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type IdempotencyModel struct{}
+
+func (m *IdempotencyModel) Get(ctx context.Context, userID int, key string) (*models.IdempotentResponse, error) {
+	return nil, models.ErrNoRecord
+}
+
+func (m *IdempotencyModel) Insert(ctx context.Context, userID int, key string, response *models.IdempotentResponse, ttl time.Duration) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type UserIdentityModel struct{}
+
+func (m *UserIdentityModel) Authenticate(ctx context.Context, provider, subject, name, email string) (int, error) {
+	if email == "unverified@example.com" {
+		return 0, models.ErrAccountUnverified
+	}
+
+	return 1, nil
+}
+
+func (m *UserIdentityModel) Link(ctx context.Context, userID int, provider, subject string) error {
+	if subject == "linked-elsewhere" {
+		return models.ErrDuplicateIdentity
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockInvite = &models.Invite{
+	ID:        1,
+	CreatedBy: 3,
+	Created:   time.Now(),
+	Expires:   time.Now().Add(7 * 24 * time.Hour),
+}
+
+type InviteModel struct{}
+
+func (m *InviteModel) New(ctx context.Context, createdBy int, ttl time.Duration) (string, error) {
+	return "NEWINVITECODEABCDEFGHIJKLM", nil
+}
+
+func (m *InviteModel) Redeem(ctx context.Context, code string) error {
+	switch code {
+	case "VALIDINVITECODEABCDEFGHIJK":
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *InviteModel) List(ctx context.Context) ([]*models.Invite, error) {
+	return []*models.Invite{mockInvite}, nil
+}
+
+func (m *InviteModel) Delete(ctx context.Context, id int) error {
+	if id == mockInvite.ID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
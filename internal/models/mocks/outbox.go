@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type OutboxModel struct{}
+
+func (m *OutboxModel) Enqueue(ctx context.Context, jobs ...*models.Job) error {
+	return nil
+}
+
+func (m *OutboxModel) Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]*models.Job, error) {
+	return []*models.Job{}, nil
+}
+
+func (m *OutboxModel) Complete(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *OutboxModel) Fail(ctx context.Context, id int, jobErr error, retryIn time.Duration) error {
+	return nil
+}
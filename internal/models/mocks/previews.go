@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type PreviewModel struct{}
+
+func (m *PreviewModel) Insert(ctx context.Context, snippetID int, ttl time.Duration) (string, error) {
+	return "ABCDEFGHIJKLMNOPQRSTUVWXYZ", nil
+}
+
+func (m *PreviewModel) Get(ctx context.Context, token string) (*models.Snippet, error) {
+	switch token {
+	case "ABCDEFGHIJKLMNOPQRSTUVWXYZ":
+		return mockSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *PreviewModel) ForSnippet(ctx context.Context, snippetID int) ([]*models.PreviewLink, error) {
+	return []*models.PreviewLink{}, nil
+}
+
+func (m *PreviewModel) Revoke(ctx context.Context, id, snippetID int) error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockSession = &models.Session{
+	ID:           1,
+	Token:        "other-device-token",
+	UserID:       1,
+	IP:           "198.51.100.7",
+	UserAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+	Created:      time.Now(),
+	LastActivity: time.Now(),
+}
+
+type SessionModel struct{}
+
+func (m *SessionModel) Insert(ctx context.Context, token string, userID int, ip, userAgent string) error {
+	return nil
+}
+
+func (m *SessionModel) Touch(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *SessionModel) ForUser(ctx context.Context, userID int) ([]*models.Session, error) {
+	switch userID {
+	case 1:
+		return []*models.Session{mockSession}, nil
+	default:
+		return []*models.Session{}, nil
+	}
+}
+
+func (m *SessionModel) Revoke(ctx context.Context, id, userID int) (string, error) {
+	if id == mockSession.ID && userID == mockSession.UserID {
+		return mockSession.Token, nil
+	}
+
+	return "", models.ErrNoRecord
+}
+
+func (m *SessionModel) Delete(ctx context.Context, token string) error {
+	return nil
+}
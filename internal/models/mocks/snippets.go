@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"time"
 
 	"github.com/declanlin/snippetbox/internal/models"
@@ -12,15 +13,26 @@ var mockSnippet = &models.Snippet{
 	Content: "An old silent pond...",
 	Created: time.Now(),
 	Expires: time.Now(),
+	UserID:  1,
+	Version: 1,
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, expires int, private bool, newJobs models.NewJobs) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) InsertBatch(ctx context.Context, userID int, snippets []models.NewSnippet) ([]int, error) {
+	ids := make([]int, len(snippets))
+	for i := range snippets {
+		ids[i] = i + 2
+	}
+
+	return ids, nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
@@ -29,6 +41,102 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context, after int, limit int) ([]*models.Snippet, error) {
+	if after != 0 && after <= mockSnippet.ID {
+		return []*models.Snippet{}, nil
+	}
+
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, includePrivate bool, limit int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) ListAll(ctx context.Context, filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	metadata := models.Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, LastPage: 1, TotalRecords: 1}
+	return []*models.Snippet{mockSnippet}, metadata, nil
+}
+
+func (m *SnippetModel) ListPublic(ctx context.Context, filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	if filters.Author != 0 && filters.Author != mockSnippet.UserID {
+		return []*models.Snippet{}, models.Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, LastPage: 1}, nil
+	}
+
+	metadata := models.Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, LastPage: 1, TotalRecords: 1}
+	return []*models.Snippet{mockSnippet}, metadata, nil
+}
+
+func (m *SnippetModel) CountPublic(ctx context.Context) (int, error) {
+	return 1, nil
+}
+
+func (m *SnippetModel) PublicIndex(ctx context.Context, offset, limit int) ([]*models.Snippet, error) {
+	if offset > 0 {
+		return []*models.Snippet{}, nil
+	}
+
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, version int, jobs ...*models.Job) error {
+	switch {
+	case id != 1:
+		return models.ErrNoRecord
+	case version != mockSnippet.Version:
+		return models.ErrEditConflict
+	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int, jobs ...*models.Job) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) ExpiringSoon(ctx context.Context, within time.Duration, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) MarkExpiryNotified(ctx context.Context, id int, jobs ...*models.Job) error {
+	return nil
+}
+
+func (m *SnippetModel) Expired(ctx context.Context, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) MarkExpiryAnnounced(ctx context.Context, id int, jobs ...*models.Job) error {
+	return nil
+}
+
+func (m *SnippetModel) Archive(ctx context.Context, retention time.Duration, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) Restore(ctx context.Context, id int, expires int) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Archived(ctx context.Context, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+
+func (m *SnippetModel) Stats(ctx context.Context) (*models.SnippetStats, error) {
+	return &models.SnippetStats{Active: 1, Expired: 2, Archived: 3, CreatedToday: 1, CreatedThisWeek: 1}, nil
+}
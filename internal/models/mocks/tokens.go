@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+type TokenModel struct{}
+
+func (m *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error) {
+	return "ABCDEFGHIJKLMNOPQRSTUVWXYZ", nil
+}
+
+func (m *TokenModel) UserID(ctx context.Context, scope, token string) (int, error) {
+	if token == "ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		return 1, nil
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int) error {
+	return nil
+}
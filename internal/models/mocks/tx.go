@@ -0,0 +1,11 @@
+package mocks
+
+import "context"
+
+// Define a TxRunner type which stands in for the database in handlers which run several model operations in a
+// transaction. The mock models don't share any state, so the function is simply run.
+type TxRunner struct{}
+
+func (m *TxRunner) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
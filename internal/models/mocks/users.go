@@ -1,30 +1,180 @@
 package mocks
 
-import "github.com/declanlin/snippetbox/internal/models"
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+// The mock administrator, who can be logged in as with admin@example.com and pa$$word.
+var mockAdmin = &models.User{
+	ID:       3,
+	Name:     "Carol",
+	Email:    "admin@example.com",
+	Created:  time.Now(),
+	Verified: true,
+	Role:     models.RoleAdmin,
+	Active:   true,
+}
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 4, nil
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password, ip string) (*models.User, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
-		return 1, nil
+		u := &models.User{
+			ID:           1,
+			Name:         "Alice",
+			Email:        "alice@example.com",
+			Created:      time.Now(),
+			Verified:     true,
+			LastLogin:    time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			LastLoginIP:  "192.0.2.1",
+			FailedLogins: 2,
+			Role:         models.RoleUser,
+			Active:       true,
+		}
+
+		return u, nil
+	}
+	if email == "admin@example.com" && password == "pa$$word" {
+		return mockAdmin, nil
+	}
+	if email == "deactivated@example.com" {
+		return nil, models.ErrAccountDeactivated
 	}
-	return 0, models.ErrInvalidCredentials
+	if email == "locked@example.com" {
+		return nil, models.ErrAccountLocked
+	}
+	return nil, models.ErrInvalidCredentials
 }
 
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 3:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
+
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	if id == 1 {
+		u := &models.User{
+			ID:          1,
+			Name:        "Alice",
+			Email:       "alice@example.com",
+			Created:     time.Now(),
+			Verified:    true,
+			LastLogin:   time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			LastLoginIP: "192.0.2.1",
+			Role:        models.RoleUser,
+			Active:      true,
+		}
+
+		return u, nil
+	}
+
+	if id == mockAdmin.ID {
+		return mockAdmin, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return m.Get(ctx, 1)
+	case mockAdmin.Email:
+		return mockAdmin, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	if id == 1 {
+		if currentPassword != "pa$$word" {
+			return models.ErrInvalidCredentials
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) Delete(ctx context.Context, id int, password string, sessionTokens []string) error {
+	if id == 1 {
+		if password != "pa$$word" {
+			return models.ErrInvalidCredentials
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) Update(ctx context.Context, id int, name, email string) error {
+	switch email {
+	case "dupe@example.com":
+		return models.ErrDuplicateEmail
+	default:
+		return nil
+	}
+}
+
+func (m *UserModel) Verify(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *UserModel) List(ctx context.Context, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	user, _ := m.Get(ctx, 1)
+
+	metadata := models.Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, LastPage: 1, TotalRecords: 2}
+	return []*models.User{user, mockAdmin}, metadata, nil
+}
+
+func (m *UserModel) SetActive(ctx context.Context, id int, active bool) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) CheckPassword(ctx context.Context, id int, password string) error {
+	if id == 1 || id == mockAdmin.ID {
+		if password != "pa$$word" {
+			return models.ErrInvalidCredentials
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) SetNotifyExpiry(ctx context.Context, id int, notify bool) error {
+	return nil
+}
+
+func (m *UserModel) SetTimezone(ctx context.Context, id int, timezone string) error {
+	return nil
+}
+
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	return 3, nil
+}
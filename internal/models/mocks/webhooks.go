@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/models"
+)
+
+var mockWebhook = &models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://hooks.example.com/snippetbox",
+	Secret:  "WEBHOOKSECRETABCDEFGHIJKLM",
+	Created: time.Now(),
+}
+
+type WebhookModel struct{}
+
+func (m *WebhookModel) Insert(ctx context.Context, userID int, url string) (int, error) {
+	return 2, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	if id == mockWebhook.ID {
+		return mockWebhook, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *WebhookModel) ForUser(ctx context.Context, userID int) ([]*models.Webhook, error) {
+	switch userID {
+	case 1:
+		return []*models.Webhook{mockWebhook}, nil
+	default:
+		return []*models.Webhook{}, nil
+	}
+}
+
+func (m *WebhookModel) Delete(ctx context.Context, id, userID int) error {
+	if id == mockWebhook.ID && userID == mockWebhook.UserID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) RecordDelivery(ctx context.Context, webhookID int, event string, statusCode int, deliveryErr string) error {
+	return nil
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*models.WebhookDelivery, error) {
+	if webhookID != mockWebhook.ID {
+		return []*models.WebhookDelivery{}, nil
+	}
+
+	return []*models.WebhookDelivery{
+		{ID: 2, WebhookID: 1, Event: models.EventSnippetCreated, Error: "connection refused", Created: time.Now()},
+		{ID: 1, WebhookID: 1, Event: models.EventSnippetCreated, StatusCode: 200, Created: time.Now()},
+	}, nil
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The kinds of background job which can be stored in the outbox.
+const (
+	JobKindMail    = "mail"
+	JobKindWebhook = "webhook"
+)
+
+// Define a Job type to hold data for a background job (e.g. sending an email) waiting in the outbox. Jobs are
+// written to the outbox in the same transaction as the change which triggers them, so that they survive a
+// process restart and are never lost if the change is committed.
+type Job struct {
+	ID        int
+	Kind      string
+	Payload   []byte
+	Attempts  int
+	LastError string
+	RunAfter  time.Time
+	Created   time.Time
+}
+
+// NewJob() returns a new Job of the given kind with the payload encoded as JSON.
+func NewJob(kind string, payload any) (*Job, error) {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{Kind: kind, Payload: js}, nil
+}
+
+// Define an OutboxModel type which wraps an sql.DB connection pool.
+type OutboxModel struct {
+	DB *DB
+}
+
+type OutboxModelInterface interface {
+	Enqueue(ctx context.Context, jobs ...*Job) error
+	Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]*Job, error)
+	Complete(ctx context.Context, id int) error
+	Fail(ctx context.Context, id int, jobErr error, retryIn time.Duration) error
+}
+
+// The number of attempts after which a failing job is no longer retried. Such jobs are left in the outbox
+// (with their last error) so that they can be inspected by an operator.
+const maxJobAttempts = 10
+
+// Define a NewJobs type for a function which creates the jobs to write to the outbox along with a record which is
+// being inserted, once the record's ID is known (e.g. webhook deliveries which include the ID). It is called inside
+// the transaction which inserts the record, so it mustn't use the database itself.
+type NewJobs func(id int) ([]*Job, error)
+
+// Function used to write jobs to the outbox as part of an existing transaction.
+func enqueueJobs(ctx context.Context, tx *Tx, jobs ...*Job) error {
+	stmt := fmt.Sprintf(`INSERT INTO outbox (kind, payload, attempts, last_error, run_after, created)
+	VALUES(?, ?, 0, '', %[1]s, %[1]s)`, tx.Dialect.now())
+
+	for _, job := range jobs {
+		_, err := tx.ExecContext(ctx, stmt, job.Kind, job.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Define a function that will write jobs to the outbox on their own, for changes which do not need to be written
+// in the same transaction as the jobs.
+func (m *OutboxModel) Enqueue(ctx context.Context, jobs ...*Job) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = enqueueJobs(ctx, tx, jobs...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Define a function that will claim up to limit jobs which are due to be run for the given worker, oldest first,
+// and return them. Claimed jobs aren't returned to other workers until the lease has passed, so each job is only run
+// by one process at a time even if several share the database. The lease should be long enough to run all of the
+// jobs, since a job whose lease runs out before it is completed or failed may be run again.
+func (m *OutboxModel) Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]*Job, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// Lock the due jobs which aren't already claimed, skipping any which another worker is claiming at the same
+	// time, so that the workers claim different jobs rather than waiting for each other.
+	stmt := fmt.Sprintf(`SELECT id FROM outbox
+	WHERE completed IS NULL AND run_after <= %[1]s AND attempts < ? AND (claimed_until IS NULL OR claimed_until <= %[1]s)
+	ORDER BY id LIMIT ? %[2]s`, tx.Dialect.now(), tx.Dialect.forUpdateSkipLocked())
+
+	rows, err := tx.QueryContext(ctx, stmt, maxJobAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []any
+
+	for rows.Next() {
+		var id int
+
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return []*Job{}, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	stmt = fmt.Sprintf(`UPDATE outbox SET claimed_by = ?, claimed_until = %s WHERE id IN (%s)`,
+		tx.Dialect.fromNow("?", "SECOND"), in)
+
+	_, err = tx.ExecContext(ctx, stmt, append([]any{worker, int(lease.Seconds())}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = fmt.Sprintf(`SELECT id, kind, payload, attempts, last_error, run_after, created FROM outbox
+	WHERE id IN (%s) ORDER BY id`, in)
+
+	rows, err = tx.QueryContext(ctx, stmt, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*Job{}
+
+	for rows.Next() {
+		j := &Job{}
+
+		err = rows.Scan(&j.ID, &j.Kind, &j.Payload, &j.Attempts, &j.LastError, &j.RunAfter, &j.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Define a function that will mark a job as successfully completed.
+func (m *OutboxModel) Complete(ctx context.Context, id int) error {
+	stmt := fmt.Sprintf(`UPDATE outbox SET completed = %s, claimed_by = NULL, claimed_until = NULL WHERE id = ?`,
+		m.DB.Dialect.now())
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// Define a function that will record a failed attempt at running a job, and schedule it to be retried once the
+// retryIn duration has passed by whichever worker claims it then.
+func (m *OutboxModel) Fail(ctx context.Context, id int, jobErr error, retryIn time.Duration) error {
+	stmt := fmt.Sprintf(`UPDATE outbox SET attempts = attempts + 1, last_error = ?,
+	run_after = %s, claimed_by = NULL, claimed_until = NULL WHERE id = ?`, m.DB.Dialect.fromNow("?", "SECOND"))
+
+	_, err := m.DB.ExecContext(ctx, stmt, jobErr.Error(), int(retryIn.Seconds()), id)
+	return err
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Define a preparedStmts type to hold the statements which a model runs most often, prepared once when the model
+// is created so that the database doesn't have to parse them again for every request. The statements are keyed by
+// their SQL, as written with ? placeholders.
+type preparedStmts map[string]*sql.Stmt
+
+// Function used to prepare the given statements on a connection pool. If any of them can't be prepared, the ones
+// which have been are closed again.
+func prepare(ctx context.Context, db *DB, queries ...string) (preparedStmts, error) {
+	p := preparedStmts{}
+
+	for _, query := range queries {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+
+		p[query] = stmt
+	}
+
+	return p, nil
+}
+
+// Function used to close all of the prepared statements, e.g. when the application shuts down.
+func (p preparedStmts) close() error {
+	var errs []error
+
+	for query, stmt := range p {
+		errs = append(errs, stmt.Close())
+		delete(p, query)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Function used to return an execQueryer which runs the prepared statements on q (a *DB or a *Tx), and any other
+// statements on q as they are. Models created without their constructor have no prepared statements, so all of
+// their statements are run as they are.
+func (p preparedStmts) on(q execQueryer) execQueryer {
+	return stmtRunner{p: p, q: q}
+}
+
+// Define a stmtRunner type which is returned by preparedStmts.on().
+type stmtRunner struct {
+	p preparedStmts
+	q execQueryer
+}
+
+// Function used to return the prepared statement for query, bound to the transaction if q is one (or is a *DB
+// and the context carries a transaction started by WithTx), or nil if it hasn't been prepared.
+func (r stmtRunner) stmt(ctx context.Context, query string) *sql.Stmt {
+	stmt, ok := r.p[query]
+	if !ok {
+		return nil
+	}
+
+	switch q := r.q.(type) {
+	case *Tx:
+		return q.StmtContext(ctx, stmt)
+	case *DB:
+		if tx := q.txFrom(ctx); tx != nil {
+			return tx.StmtContext(ctx, stmt)
+		}
+	}
+
+	return stmt
+}
+
+// Function used to wrap the error of a failed prepared statement in the same way as DB and Tx do.
+func (r stmtRunner) queryError(query string, err error) error {
+	switch q := r.q.(type) {
+	case *DB:
+		return q.Dialect.queryError(query, err)
+	case *Tx:
+		return q.Dialect.queryError(query, err)
+	}
+
+	return err
+}
+
+func (r stmtRunner) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if stmt := r.stmt(ctx, query); stmt != nil {
+		result, err := stmt.ExecContext(ctx, args...)
+		return result, r.queryError(query, err)
+	}
+
+	return r.q.ExecContext(ctx, query, args...)
+}
+
+func (r stmtRunner) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if stmt := r.stmt(ctx, query); stmt != nil {
+		rows, err := stmt.QueryContext(ctx, args...)
+		return rows, r.queryError(query, err)
+	}
+
+	return r.q.QueryContext(ctx, query, args...)
+}
+
+func (r stmtRunner) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if stmt := r.stmt(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+
+	return r.q.QueryRowContext(ctx, query, args...)
+}
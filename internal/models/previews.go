@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Define a PreviewLink type to hold data for a time-boxed link which lets anyone holding it view a private
+// snippet without logging in. The plaintext token is only ever known when the link is created.
+type PreviewLink struct {
+	ID        int
+	SnippetID int
+	Created   time.Time
+	Expires   time.Time
+}
+
+// Define a PreviewModel type which wraps an sql.DB connection pool.
+type PreviewModel struct {
+	DB *DB
+}
+
+type PreviewModelInterface interface {
+	Insert(ctx context.Context, snippetID int, ttl time.Duration) (string, error)
+	Get(ctx context.Context, token string) (*Snippet, error)
+	ForSnippet(ctx context.Context, snippetID int) ([]*PreviewLink, error)
+	Revoke(ctx context.Context, id, snippetID int) error
+}
+
+// Define a function that will create a new preview link for a snippet which is valid for the given duration,
+// returning the plaintext token for the link.
+func (m *PreviewModel) Insert(ctx context.Context, snippetID int, ttl time.Duration) (string, error) {
+	token, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO preview_links (hash, snippet_id, created, expires)
+	VALUES(?, ?, %[1]s, %[2]s)`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("?", "SECOND"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, hash, snippetID, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Define a function that will return the snippet which a preview link token points to. An ErrNoRecord error is
+// returned if the token does not exist, the link has expired or been revoked, or the snippet itself has expired.
+func (m *PreviewModel) Get(ctx context.Context, token string) (*Snippet, error) {
+	stmt := fmt.Sprintf(`SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, s.private
+	FROM snippets s INNER JOIN preview_links p ON p.snippet_id = s.id
+	WHERE p.hash = ? AND p.expires > %[1]s AND s.expires > %[1]s`, m.DB.Dialect.now())
+
+	s := &Snippet{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, hashToken(token)).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Define a function that will return the unexpired preview links for a snippet, newest first.
+func (m *PreviewModel) ForSnippet(ctx context.Context, snippetID int) ([]*PreviewLink, error) {
+	stmt := fmt.Sprintf(`SELECT id, snippet_id, created, expires FROM preview_links
+	WHERE snippet_id = ? AND expires > %s ORDER BY id DESC`, m.DB.Dialect.now())
+
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	links := []*PreviewLink{}
+
+	for rows.Next() {
+		l := &PreviewLink{}
+
+		err = rows.Scan(&l.ID, &l.SnippetID, &l.Created, &l.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// Define a function that will revoke a preview link by deleting it. The snippet ID is checked as well, so that a
+// link can only be revoked through the snippet it belongs to.
+func (m *PreviewModel) Revoke(ctx context.Context, id, snippetID int) error {
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM preview_links WHERE id = ? AND snippet_id = ?`, id, snippetID)
+	return err
+}
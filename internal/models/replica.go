@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// How long reads are sent to the primary database after the replica couldn't be reached, before the replica is
+// tried again.
+const replicaRetryInterval = 30 * time.Second
+
+// Define a Replica type which wraps the connection pool of a read-only replica of the database. Reads which can
+// tolerate replication lag are sent to it by DB.read, which falls back to the primary while it is unreachable.
+type Replica struct {
+	*DB
+
+	// The time (in Unix nanoseconds) until which the replica is skipped after it couldn't be reached.
+	downUntil atomic.Int64
+}
+
+// The type of the context key which marks reads which must go to the primary database.
+type primaryContextKey struct{}
+
+// WithPrimary returns a copy of ctx in which reads go to the primary database rather than the replica. It is used
+// for reads which must see a write made just before, e.g. the page a user is redirected to after editing a snippet,
+// which the replica may not have caught up with yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// Function used to report whether reads made with ctx must go to the primary database (see WithPrimary).
+func readsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
+// Function used to run a read on the replica if there is one and it is reachable, and on the primary otherwise.
+// fn is called with the database to read from, and is called again with the primary if the replica can't be
+// reached, so it must not keep any results of a failed call. Reads made in a transaction started by WithTx always
+// use the primary, so that they see the transaction's writes, as do reads made with a context from WithPrimary.
+func (db *DB) read(ctx context.Context, fn func(q *DB) error) error {
+	r := db.Replica
+	if r == nil || db.txFrom(ctx) != nil || readsPrimary(ctx) || time.Now().UnixNano() < r.downUntil.Load() {
+		return fn(db)
+	}
+
+	err := fn(r.DB)
+	if unreachable(err) {
+		r.downUntil.Store(time.Now().Add(replicaRetryInterval).UnixNano())
+		return fn(db)
+	}
+
+	return err
+}
+
+// Function used to report whether err means that the database couldn't be reached, rather than that the
+// statement failed.
+func unreachable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
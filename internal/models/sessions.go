@@ -0,0 +1,127 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Define a Session type to hold the details of a logged in session belonging to a user. Token is the session
+// token used by the session manager, and must never be shown to users.
+type Session struct {
+	ID           int
+	Token        string
+	UserID       int
+	IP           string
+	UserAgent    string
+	Created      time.Time
+	LastActivity time.Time
+}
+
+// Define a SessionModel type which wraps an sql.DB connection pool. It records which sessions belong to which
+// user, so that users can see and revoke their sessions without the whole session store being searched.
+type SessionModel struct {
+	DB *DB
+}
+
+type SessionModelInterface interface {
+	Insert(ctx context.Context, token string, userID int, ip, userAgent string) error
+	Touch(ctx context.Context, token string) error
+	ForUser(ctx context.Context, userID int) ([]*Session, error)
+	Revoke(ctx context.Context, id, userID int) (string, error)
+	Delete(ctx context.Context, token string) error
+}
+
+// Function to record that the session with the given token belongs to a user who has just logged in. Long user
+// agent strings are truncated to fit the user_agent column.
+func (m *SessionModel) Insert(ctx context.Context, token string, userID int, ip, userAgent string) error {
+	if len(userAgent) > 255 {
+		userAgent = strings.ToValidUTF8(userAgent[:255], "")
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO user_sessions (token, user_id, ip, user_agent, created, last_activity)
+	VALUES(?, ?, ?, ?, %[1]s, %[1]s)`, m.DB.Dialect.now())
+
+	_, err := m.DB.ExecContext(ctx, stmt, token, userID, ip, userAgent)
+	return err
+}
+
+// Function to update the last activity time of the session with the given token. To avoid a database write on
+// every request, the time is only updated if it is more than a minute old.
+func (m *SessionModel) Touch(ctx context.Context, token string) error {
+	stmt := fmt.Sprintf(`UPDATE user_sessions SET last_activity = %[1]s
+	WHERE token = ? AND last_activity < %[2]s`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("-1", "MINUTE"))
+
+	_, err := m.DB.ExecContext(ctx, stmt, token)
+	return err
+}
+
+// Function to return the sessions recorded for a user, most recently active first.
+func (m *SessionModel) ForUser(ctx context.Context, userID int) ([]*Session, error) {
+	stmt := `SELECT id, token, user_id, ip, user_agent, created, last_activity FROM user_sessions
+	WHERE user_id = ? ORDER BY last_activity DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		s := &Session{}
+
+		err = rows.Scan(&s.ID, &s.Token, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastActivity)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Function to remove the record of the session with a specific ID, returning its token so that the session can
+// be removed from the session store. An ErrNoRecord error is returned if the session doesn't belong to the user.
+func (m *SessionModel) Revoke(ctx context.Context, id, userID int) (string, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	var token string
+
+	err = tx.QueryRowContext(ctx, `SELECT token FROM user_sessions WHERE id = ? AND user_id = ? `+tx.Dialect.forUpdate(), id, userID).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_sessions WHERE id = ?`, id)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// Function to remove the record of the session with the given token, e.g. when the user logs out.
+func (m *SessionModel) Delete(ctx context.Context, token string) error {
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM user_sessions WHERE token = ?`, token)
+	return err
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/declanlin/snippetbox/internal/cache"
+)
+
+// Define a CachedSnippetModel type which wraps a snippet model with an in-memory cache of the snippets returned by
+// Get() and Latest(), which are read on every snippet view and home page view. A snippet is cached until it expires
+// at the latest, and the cache is invalidated whenever snippets are inserted or deleted through the model. Other
+// instances of the application don't see the invalidations, so they can serve a stale snippet for up to the
+// cache's time to live.
+type CachedSnippetModel struct {
+	SnippetModelInterface
+
+	snippets *cache.LRU[int, *Snippet]
+	latest   *cache.LRU[int, []*Snippet]
+}
+
+// NewCachedSnippetModel returns a CachedSnippetModel which caches up to size snippets from m, each for up to ttl.
+func NewCachedSnippetModel(m SnippetModelInterface, size int, ttl time.Duration) *CachedSnippetModel {
+	return &CachedSnippetModel{
+		SnippetModelInterface: m,
+		snippets:              cache.New[int, *Snippet](size, ttl),
+		// The home page only ever asks for one limit, so there's no need to keep many of these.
+		latest: cache.New[int, []*Snippet](4, ttl),
+	}
+}
+
+// Define a function that will return a specified snippet, from the cache if it is there. Only snippets which exist
+// are cached. Reads which must go to the primary database (see WithPrimary) skip the cache, which may hold a
+// snippet read from a replica which hadn't caught up, and refresh it instead.
+func (m *CachedSnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	if s, ok := m.snippets.Get(id); ok && !readsPrimary(ctx) {
+		return copySnippet(s), nil
+	}
+
+	s, err := m.SnippetModelInterface.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.snippets.Set(id, copySnippet(s), s.Expires)
+
+	return s, nil
+}
+
+// Define a function that will return up to limit of the most recently created public snippets, from the cache if
+// they are there. They are cached until the first of them expires. Only the first page is cached, since the later
+// pages are read far less often. As with Get(), reads which must go to the primary database skip the cache.
+func (m *CachedSnippetModel) Latest(ctx context.Context, after int, limit int) ([]*Snippet, error) {
+	if after != 0 {
+		return m.SnippetModelInterface.Latest(ctx, after, limit)
+	}
+
+	if snippets, ok := m.latest.Get(limit); ok && !readsPrimary(ctx) {
+		return copySnippets(snippets), nil
+	}
+
+	snippets, err := m.SnippetModelInterface.Latest(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var expires time.Time
+	for _, s := range snippets {
+		if expires.IsZero() || s.Expires.Before(expires) {
+			expires = s.Expires
+		}
+	}
+
+	m.latest.Set(limit, copySnippets(snippets), expires)
+
+	return snippets, nil
+}
+
+func (m *CachedSnippetModel) Insert(ctx context.Context, userID int, title string, content string, expires int, private bool, newJobs NewJobs) (int, error) {
+	id, err := m.SnippetModelInterface.Insert(ctx, userID, title, content, expires, private, newJobs)
+	if err != nil {
+		return 0, err
+	}
+
+	m.latest.Purge()
+
+	return id, nil
+}
+
+func (m *CachedSnippetModel) InsertBatch(ctx context.Context, userID int, snippets []NewSnippet) ([]int, error) {
+	ids, err := m.SnippetModelInterface.InsertBatch(ctx, userID, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	m.latest.Purge()
+
+	return ids, nil
+}
+
+func (m *CachedSnippetModel) Restore(ctx context.Context, id int, expires int) error {
+	err := m.SnippetModelInterface.Restore(ctx, id, expires)
+	if err != nil {
+		return err
+	}
+
+	m.latest.Purge()
+
+	return nil
+}
+
+// Define a function that will edit a specified snippet and remove it from the cache, so that the next Get() fetches
+// the new version.
+func (m *CachedSnippetModel) Update(ctx context.Context, id int, title string, content string, version int, jobs ...*Job) error {
+	defer m.latest.Purge()
+	defer m.snippets.Delete(id)
+
+	return m.SnippetModelInterface.Update(ctx, id, title, content, version, jobs...)
+}
+
+// Define a function that will delete a specified snippet and remove it from the cache. It is removed even if the
+// delete fails, in case it failed after the snippet was deleted (e.g. while committing).
+func (m *CachedSnippetModel) Delete(ctx context.Context, id int, jobs ...*Job) error {
+	defer m.latest.Purge()
+	defer m.snippets.Delete(id)
+
+	return m.SnippetModelInterface.Delete(ctx, id, jobs...)
+}
+
+// Purge empties the cache, e.g. after snippets have been deleted other than through the model. It does nothing if
+// m is nil, so that it can be called whether or not caching is enabled.
+func (m *CachedSnippetModel) Purge() {
+	if m == nil {
+		return
+	}
+
+	m.snippets.Purge()
+	m.latest.Purge()
+}
+
+// Functions used to copy snippets going into and out of the cache, so that callers can't change the cached ones.
+func copySnippet(s *Snippet) *Snippet {
+	c := *s
+	return &c
+}
+
+func copySnippets(snippets []*Snippet) []*Snippet {
+	c := make([]*Snippet, len(snippets))
+	for i, s := range snippets {
+		c[i] = copySnippet(s)
+	}
+
+	return c
+}
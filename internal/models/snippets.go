@@ -1,63 +1,195 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
-// Define a Snippet type to hold data for an individual Snippet.
+// Define a Snippet type to hold data for an individual Snippet. Version is incremented each time the snippet is
+// edited, and is only fetched by Get().
 type Snippet struct {
 	ID      int
 	Title   string
 	Content string
 	Created time.Time
 	Expires time.Time
+	UserID  int
+	Private bool
+	Version int
 }
 
-// Define a SnippetModel type which wraps an sql.DB connection pool.
+// Define a NewSnippet type to hold the fields of a snippet which is yet to be inserted. Expires is the number of days
+// until the snippet expires, and Jobs (if set) creates the jobs to write to the outbox along with the snippet.
+type NewSnippet struct {
+	Title   string
+	Content string
+	Expires int
+	Private bool
+	Jobs    NewJobs
+}
+
+// Define a SnippetStats type to hold the numbers of snippets in each state, and of snippets created recently.
+type SnippetStats struct {
+	Active          int
+	Expired         int
+	Archived        int
+	CreatedToday    int
+	CreatedThisWeek int
+}
+
+// Define a SnippetModel type which wraps an sql.DB connection pool, along with the statements which are run on
+// every page view, prepared by NewSnippetModel. Get(), Latest() and the listings read from the database's replica
+// if it has one.
 type SnippetModel struct {
-	DB *sql.DB
+	DB           *DB
+	stmts        preparedStmts
+	replicaStmts preparedStmts
 }
 
-// Define a function that will insert a new snippet into the MYSQL database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	// Generate an SQL statement for inserting a new snippet into the database.
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// NewSnippetModel returns a SnippetModel with the statements run by Insert(), Get() and Latest() prepared. They
+// are closed by Close(). The reads are prepared on the replica too if it can be reached, and are run unprepared on
+// it otherwise.
+func NewSnippetModel(db *DB) (*SnippetModel, error) {
+	m := &SnippetModel{DB: db}
 
-	// Use the Exec() method on the embedded connection pool to execute the SQL statement.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	stmts, err := prepare(context.Background(), db, db.Dialect.returning(m.insertStmt()), m.getStmt(), m.latestStmt())
 	if err != nil {
-		return 0, nil
+		return nil, err
+	}
+
+	m.stmts = stmts
+
+	if db.Replica != nil {
+		m.replicaStmts, _ = prepare(context.Background(), db.Replica.DB, m.getStmt(), m.latestStmt())
 	}
 
-	// Use the LastInsertID() from the Result interface on the result returned by Exec(), which returns
-	// the integer generated by the database in response to executing a command, typically the
-	// AUTO_INCREMENT command when inserting a new row.
-	id, err := result.LastInsertId()
+	return m, nil
+}
+
+// Function used to close the model's prepared statements, which must be done before the connection pool is
+// closed.
+func (m *SnippetModel) Close() error {
+	return errors.Join(m.stmts.close(), m.replicaStmts.close())
+}
+
+// Function used to run a read with the prepared statements of the database which it is run on (see DB.read).
+func (m *SnippetModel) read(ctx context.Context, fn func(q execQueryer) error) error {
+	return m.DB.read(ctx, func(db *DB) error {
+		if db == m.DB {
+			return fn(m.stmts.on(db))
+		}
+
+		return fn(m.replicaStmts.on(db))
+	})
+}
+
+// Function used to run a query as a read (see read()), returning its rows.
+func (m *SnippetModel) readRows(ctx context.Context, stmt string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+
+	err := m.read(ctx, func(q execQueryer) error {
+		var err error
+		rows, err = q.QueryContext(ctx, stmt, args...)
+		return err
+	})
+
+	return rows, err
+}
+
+// Functions used to return the statements which NewSnippetModel prepares.
+func (m *SnippetModel) insertStmt() string {
+	return fmt.Sprintf(`INSERT INTO snippets (title, content, created, expires, user_id, private)
+	VALUES(?, ?, %s, %s, ?, ?)`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("?", "DAY"))
+}
+
+func (m *SnippetModel) getStmt() string {
+	return fmt.Sprintf(`SELECT id, title, content, created, expires, user_id, private, version FROM snippets
+	WHERE expires > %s AND id = ?`, m.DB.Dialect.now())
+}
+
+func (m *SnippetModel) latestStmt() string {
+	return fmt.Sprintf(`SELECT id, title, content, created, expires, user_id, private FROM snippets
+	WHERE expires > %s AND private = FALSE AND (? = 0 OR id < ?) ORDER BY id DESC LIMIT ?`, m.DB.Dialect.now())
+}
+
+// Define a function that will insert a new snippet owned by the specified user into the MYSQL database.
+// Private snippets are left out of public listings and can only be viewed by their owner or through a preview link.
+// If newJobs isn't nil, the jobs which it creates (e.g. the deliveries to the owner's webhooks) are written to the
+// outbox in the same transaction as the snippet.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, expires int, private bool, newJobs NewJobs) (int, error) {
+	ids, err := m.InsertBatch(ctx, userID, []NewSnippet{{Title: title, Content: content, Expires: expires, Private: private, Jobs: newJobs}})
 	if err != nil {
 		return 0, err
 	}
 
-	// Return the ID of the snippet (converted from int64 to int) along with no errors.
-	return int(id), nil
+	// Return the ID of the snippet along with no errors.
+	return ids[0], nil
+}
+
+// Function to insert several new snippets owned by the specified user in a single transaction, returning their IDs
+// in the same order, along with the outbox jobs of each snippet. Either all of the snippets are inserted, or none of
+// them are.
+func (m *SnippetModel) InsertBatch(ctx context.Context, userID int, snippets []NewSnippet) ([]int, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	stmt := m.insertStmt()
+	q := m.stmts.on(tx)
+
+	ids := make([]int, 0, len(snippets))
+
+	for _, s := range snippets {
+		// Execute the prepared SQL statement in the transaction. The dialect returns the integer generated by the
+		// database for the new row's id column, e.g. by its AUTO_INCREMENT attribute with MYSQL.
+		id, err := tx.Dialect.insert(ctx, q, stmt, s.Title, s.Content, s.Expires, userID, s.Private)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.Jobs != nil {
+			jobs, err := s.Jobs(id)
+			if err != nil {
+				return nil, err
+			}
+
+			err = enqueueJobs(ctx, tx, jobs...)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
 }
 
 // Define a function that will read and return a specified snippet based on its unique ID.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
 	// Generate an SQL statement for selecting a snippet from the database according to a given ID.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
-
-	// Query a single row by calling QueryRow() on our connection pool.
-	row := m.DB.QueryRow(stmt, id)
+	stmt := m.getStmt()
 
 	// Initialize a pointer to a zeroed Snippet struct.
 	s := &Snippet{}
 
-	// Use row.Scan() to copy in columns from the queried row to the corresponding fields in the Snippet struct s.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Query a single row by calling QueryRow() on our connection pool (or the replica), with the prepared statement,
+	// and use row.Scan() to copy in columns from the queried row to the corresponding fields in the Snippet struct s.
+	err := m.read(ctx, func(q execQueryer) error {
+		row := q.QueryRowContext(ctx, stmt, id)
+		return row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private, &s.Version)
+	})
 
 	if err != nil {
 		// Check if the query returns no rows using the errors.Is() function.
@@ -73,15 +205,17 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-// Define a function that will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	// Generate an SQL statement for selecting the 10 most recently created snippets.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// Define a function that will return up to limit of the most recently created public snippets. If after isn't 0,
+// only the snippets which come after the snippet with that ID are returned, i.e. those with lower IDs. This lets
+// the snippets be paged through by passing the ID of the last snippet on each page, which unlike an offset stays
+// fast however far through the snippets the page is, and doesn't skip or repeat snippets as new ones are created.
+func (m *SnippetModel) Latest(ctx context.Context, after int, limit int) ([]*Snippet, error) {
+	// Generate an SQL statement for selecting the most recently created snippets.
+	stmt := m.latestStmt()
 
-	// Query multiple rows by calling Query() on our connection pool.
+	// Query multiple rows by calling Query() on our connection pool (or the replica), with the prepared statement.
 	// Query() returns an sql.Rows resultset containing the result of our query.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.readRows(ctx, stmt, after, after, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +233,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		s := &Snippet{}
 
 		// Use row.Scan() to copy in columns from the queried row to the corresponding fields in the Snippet struct s.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
 		if err != nil {
 			return nil, err
 		}
@@ -117,8 +251,403 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	return snippets, nil
 }
 
+// Define a function that will return up to limit of the unexpired snippets created by the specified user. Private
+// snippets are only included if includePrivate is true.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, includePrivate bool, limit int) ([]*Snippet, error) {
+	// Generate an SQL statement for selecting the unexpired snippets belonging to a user, newest first.
+	stmt := fmt.Sprintf(`SELECT id, title, content, created, expires, user_id, private FROM snippets
+	WHERE expires > %s AND user_id = ? AND (private = FALSE OR ?) ORDER BY id DESC LIMIT ?`, m.DB.Dialect.now())
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, includePrivate, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Define a function that will return a page of every snippet, including private and expired snippets, newest
+// first. If filters.Search is set, only snippets whose titles contain it are returned.
+func (m *SnippetModel) ListAll(ctx context.Context, filters Filters) ([]*Snippet, Metadata, error) {
+	// COUNT(*) OVER() adds the total number of matching rows (ignoring LIMIT and OFFSET) to each row.
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, title, content, created, expires, user_id, private FROM snippets
+	WHERE (? = '' OR %s) ORDER BY id DESC LIMIT ? OFFSET ?`, m.DB.Dialect.like("title"))
+
+	rows, err := m.readRows(ctx, stmt, filters.Search, filters.likePattern(), filters.PageSize, filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&totalRecords, &s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, calculateMetadata(totalRecords, filters), nil
+}
+
+// Define a function that will return a page of public, unexpired snippets in the order given by filters.Sort. If
+// filters.Author is set, only that user's snippets are returned, and if filters.CreatedAfter is set, only snippets
+// created after that time are returned.
+func (m *SnippetModel) ListPublic(ctx context.Context, filters Filters) ([]*Snippet, Metadata, error) {
+	// The sort column and direction are checked against the safelist, so they can be interpolated into the query.
+	// Sorting by ID as well makes the order of snippets with equal sort values stable between pages.
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, title, content, created, expires, user_id, private FROM snippets
+	WHERE expires > %s AND private = FALSE AND (? = 0 OR user_id = ?) AND (? OR created > ?)
+	ORDER BY %s %s, id ASC LIMIT ? OFFSET ?`, m.DB.Dialect.now(), filters.sortColumn(), filters.sortDirection())
+
+	args := []any{filters.Author, filters.Author, filters.CreatedAfter.IsZero(), filters.CreatedAfter.UTC(),
+		filters.PageSize, filters.offset()}
+
+	rows, err := m.readRows(ctx, stmt, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&totalRecords, &s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, calculateMetadata(totalRecords, filters), nil
+}
+
+// Function to return the number of public, unexpired snippets.
+func (m *SnippetModel) CountPublic(ctx context.Context) (int, error) {
+	var count int
+
+	stmt := fmt.Sprintf(`SELECT COUNT(*) FROM snippets WHERE expires > %s AND private = FALSE`, m.DB.Dialect.now())
+
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Define a function that will count the snippets which haven't expired, those which have (but haven't been archived
+// yet), those which have been archived, and those created in the last day and week. Snippets created recently
+// which have since been archived aren't counted as created.
+func (m *SnippetModel) Stats(ctx context.Context) (*SnippetStats, error) {
+	stmt := fmt.Sprintf(`SELECT
+		COALESCE(SUM(CASE WHEN expires > %[1]s THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN expires <= %[1]s THEN 1 ELSE 0 END), 0),
+		(SELECT COUNT(*) FROM snippets_archive),
+		COALESCE(SUM(CASE WHEN created > %[2]s THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN created > %[3]s THEN 1 ELSE 0 END), 0)
+	FROM snippets`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("-1", "DAY"), m.DB.Dialect.fromNow("-7", "DAY"))
+
+	s := &SnippetStats{}
+
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&s.Active, &s.Expired, &s.Archived, &s.CreatedToday, &s.CreatedThisWeek)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Function to return up to limit public, unexpired snippets in the order they were created, skipping the first
+// offset of them. Only the ID and creation time of each snippet are fetched, since this is used to list every
+// snippet in the sitemap.
+func (m *SnippetModel) PublicIndex(ctx context.Context, offset, limit int) ([]*Snippet, error) {
+	stmt := fmt.Sprintf(`SELECT id, created FROM snippets WHERE expires > %s AND private = FALSE
+	ORDER BY id ASC LIMIT ? OFFSET ?`, m.DB.Dialect.now())
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Define a function that will permanently delete the snippet with a specific ID. Any jobs passed in (i.e. the
+// deliveries to the owner's webhooks) are written to the outbox in the same transaction, and only if the snippet
+// was deleted.
+func (m *SnippetModel) Delete(ctx context.Context, id int, jobs ...*Job) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	err = enqueueJobs(ctx, tx, jobs...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Define a function that will change the title and content of an unexpired snippet, provided that it is still at
+// the given version, i.e. that nobody else has edited it since the version was fetched. ErrEditConflict is returned
+// if it has been, and ErrNoRecord if the snippet doesn't exist or has expired. Any jobs passed in (i.e. the
+// deliveries to the owner's webhooks) are written to the outbox in the same transaction, and only if the snippet
+// was updated.
+func (m *SnippetModel) Update(ctx context.Context, id int, title string, content string, version int, jobs ...*Job) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt := fmt.Sprintf(`UPDATE snippets SET title = ?, content = ?, version = version + 1
+	WHERE id = ? AND version = ? AND expires > %s`, tx.Dialect.now())
+
+	result, err := tx.ExecContext(ctx, stmt, title, content, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		err = enqueueJobs(ctx, tx, jobs...)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	// Nothing was updated, so find out whether that's because the snippet was edited in the meantime.
+	var exists bool
+
+	stmt = fmt.Sprintf(`SELECT EXISTS(SELECT true FROM snippets WHERE id = ? AND expires > %s)`, tx.Dialect.now())
+
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return ErrEditConflict
+}
+
+// Define a function that will return up to limit unexpired snippets which expire within the given duration, whose
+// owners have asked to be notified before their snippets expire and haven't been yet.
+func (m *SnippetModel) ExpiringSoon(ctx context.Context, within time.Duration, limit int) ([]*Snippet, error) {
+	stmt := fmt.Sprintf(`SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, s.private
+	FROM snippets AS s INNER JOIN users AS u ON u.id = s.user_id
+	WHERE u.notify_expiry AND NOT s.expiry_notified
+	AND s.expires > %s AND s.expires <= %s
+	ORDER BY s.expires LIMIT ?`, m.DB.Dialect.now(), m.DB.Dialect.fromNow("?", "SECOND"))
+
+	rows, err := m.DB.QueryContext(ctx, stmt, int(within.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Define a function that will record that the owner of a snippet has been notified that it is about to expire.
+// Any jobs passed in (i.e. the notification email) are written to the outbox in the same transaction, and only if
+// the snippet hadn't already been marked, so that owners are never notified twice.
+func (m *SnippetModel) MarkExpiryNotified(ctx context.Context, id int, jobs ...*Job) error {
+	return m.markAndEnqueue(ctx, `UPDATE snippets SET expiry_notified = TRUE WHERE id = ? AND NOT expiry_notified`, id, jobs)
+}
+
+// Define a function that will return up to limit expired snippets whose expiry hasn't been announced to their
+// owners' webhooks yet, oldest first.
+func (m *SnippetModel) Expired(ctx context.Context, limit int) ([]*Snippet, error) {
+	stmt := fmt.Sprintf(`SELECT id, title, content, created, expires, user_id, private FROM snippets
+	WHERE NOT expiry_announced AND expires <= %s ORDER BY expires LIMIT ?`, m.DB.Dialect.now())
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.UserID, &s.Private)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Define a function that will record that a snippet's expiry has been announced to its owner's webhooks, writing
+// any jobs passed in (i.e. the webhook deliveries) to the outbox in the same transaction, as MarkExpiryNotified does.
+func (m *SnippetModel) MarkExpiryAnnounced(ctx context.Context, id int, jobs ...*Job) error {
+	return m.markAndEnqueue(ctx, `UPDATE snippets SET expiry_announced = TRUE WHERE id = ? AND NOT expiry_announced`, id, jobs)
+}
+
+// Function used to run an UPDATE statement which sets a flag on a snippet, and write jobs to the outbox in the same
+// transaction if (and only if) the flag wasn't already set.
+func (m *SnippetModel) markAndEnqueue(ctx context.Context, stmt string, id int, jobs []*Job) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return nil
+	}
+
+	err = enqueueJobs(ctx, tx, jobs...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(ctx context.Context, userID int, title string, content string, expires int, private bool, newJobs NewJobs) (int, error)
+	InsertBatch(ctx context.Context, userID int, snippets []NewSnippet) ([]int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context, after int, limit int) ([]*Snippet, error)
+	ByUser(ctx context.Context, userID int, includePrivate bool, limit int) ([]*Snippet, error)
+	ListAll(ctx context.Context, filters Filters) ([]*Snippet, Metadata, error)
+	ListPublic(ctx context.Context, filters Filters) ([]*Snippet, Metadata, error)
+	CountPublic(ctx context.Context) (int, error)
+	PublicIndex(ctx context.Context, offset, limit int) ([]*Snippet, error)
+	Update(ctx context.Context, id int, title string, content string, version int, jobs ...*Job) error
+	Delete(ctx context.Context, id int, jobs ...*Job) error
+	ExpiringSoon(ctx context.Context, within time.Duration, limit int) ([]*Snippet, error)
+	MarkExpiryNotified(ctx context.Context, id int, jobs ...*Job) error
+	Expired(ctx context.Context, limit int) ([]*Snippet, error)
+	MarkExpiryAnnounced(ctx context.Context, id int, jobs ...*Job) error
+	Archive(ctx context.Context, retention time.Duration, limit int) (int, error)
+	Restore(ctx context.Context, id int, expires int) error
+	Archived(ctx context.Context, limit int) ([]*Snippet, error)
+	Stats(ctx context.Context) (*SnippetStats, error)
 }
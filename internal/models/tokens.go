@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The scopes which a token can be issued for. A token can only be used for the purpose it was issued for.
+const (
+	ScopeVerification = "verification"
+	ScopeEmailChange  = "email-change"
+	ScopeMagicLink    = "magic-link"
+	ScopeUnsubscribe  = "unsubscribe"
+)
+
+// Define a TokenModel type which wraps an sql.DB connection pool. Tokens are single-use secrets issued to a user
+// for a specific purpose (e.g. verifying their email address) and are typically sent to the user by email.
+type TokenModel struct {
+	DB *DB
+}
+
+type TokenModelInterface interface {
+	New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error)
+	UserID(ctx context.Context, scope, token string) (int, error)
+	DeleteAllForUser(ctx context.Context, scope string, userID int) error
+}
+
+// Function used to generate a new random token. It returns the plaintext token, which is given to the client
+// (e.g. as part of a URL), and the SHA-256 hash of the token, which is what gets stored in the database. Storing
+// only the hash means that a leaked database does not reveal any usable tokens.
+func generateToken() (string, []byte, error) {
+	// Fill a byte slice with 16 bytes (128 bits) of entropy from the operating system's CSPRNG.
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Encode the random bytes as a base-32 string without padding, which is safe to use in URLs. The result
+	// is always 26 characters long.
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	return plaintext, hashToken(plaintext), nil
+}
+
+// Function used to hash a plaintext token so that it can be looked up in the database.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Define a function that will issue a new token for a user which is valid for the given duration, returning the
+// plaintext token.
+func (m *TokenModel) New(ctx context.Context, userID int, ttl time.Duration, scope string) (string, error) {
+	token, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO tokens (hash, user_id, expiry, scope)
+	VALUES(?, ?, %s, ?)`, m.DB.Dialect.fromNow("?", "SECOND"))
+
+	_, err = m.DB.ExecContext(ctx, stmt, hash, userID, int(ttl.Seconds()), scope)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Define a function that will return the ID of the user which an unexpired token was issued to. An ErrNoRecord
+// error is returned if there is no matching token for the scope.
+func (m *TokenModel) UserID(ctx context.Context, scope, token string) (int, error) {
+	var userID int
+
+	stmt := fmt.Sprintf(`SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > %s`, m.DB.Dialect.now())
+
+	err := m.DB.QueryRowContext(ctx, stmt, hashToken(token), scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// Define a function that will delete all of a user's tokens for a scope, e.g. once one of them has been used.
+func (m *TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int) error {
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM tokens WHERE scope = ? AND user_id = ?`, scope, userID)
+	return err
+}
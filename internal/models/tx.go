@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+)
+
+// Define a TxRunner interface, which is satisfied by *DB, for running several model operations atomically. The
+// handlers use it (rather than *DB) so that they can be tested with a mock.
+type TxRunner interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// The type of the context key which the transaction started by WithTx is stored under.
+type txContextKey struct{}
+
+// WithTx runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise. The methods of
+// every model which uses db run their statements in the transaction when they are called with the context passed
+// to fn, including the ones which start transactions of their own. If ctx already carries a transaction, fn joins
+// it, so that the outermost call decides whether it is committed.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if db.txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = fn(context.WithValue(ctx, txContextKey{}, tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function used to return the transaction started by WithTx on db which ctx carries, or nil if there isn't one.
+func (db *DB) txFrom(ctx context.Context) *Tx {
+	tx, ok := ctx.Value(txContextKey{}).(*Tx)
+	if !ok || tx.pool != db.DB {
+		return nil
+	}
+
+	return tx
+}
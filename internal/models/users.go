@@ -1,15 +1,36 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
+	"fmt"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// The roles which users can have. Each role has all of the privileges of the roles before it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// The rank of each role, used to compare the privileges of roles.
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Function to check whether a user with the given role has at least the privileges of the required role. Unknown
+// roles have no privileges.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
 // Define a User type to hold data for an individual User.
 type User struct {
 	ID             int
@@ -17,96 +38,493 @@ type User struct {
 	Email          string
 	HashedPassword string
 	Created        time.Time
+	Verified       bool
+	LastLogin      time.Time
+	LastLoginIP    string
+	FailedLogins   int
+	Role           string
+	Active         bool
+	NotifyExpiry   bool
+	Timezone       string
 }
 
-// Define a UserModel type which wraps an sql.DB connection pool.
+// Define a UserModel type which wraps an sql.DB connection pool, along with the statements which are run on every
+// login and authenticated request, prepared by NewUserModel. After MaxFailedLogins consecutive failed login
+// attempts, an account is locked for LockoutDuration. A MaxFailedLogins of zero disables the lockout.
 type UserModel struct {
-	DB *sql.DB
+	DB              *DB
+	MaxFailedLogins int
+	LockoutDuration time.Duration
+	stmts           preparedStmts
+}
+
+// NewUserModel returns a UserModel with the statements run by Authenticate() and Exists() prepared. They are
+// closed by Close().
+func NewUserModel(db *DB, maxFailedLogins int, lockoutDuration time.Duration) (*UserModel, error) {
+	m := &UserModel{DB: db, MaxFailedLogins: maxFailedLogins, LockoutDuration: lockoutDuration}
+
+	stmts, err := prepare(context.Background(), db, m.authenticateStmt(), m.failedLoginStmt(), m.loginStmt(), existsStmt)
+	if err != nil {
+		return nil, err
+	}
+
+	m.stmts = stmts
+
+	return m, nil
 }
 
+// Function used to close the model's prepared statements, which must be done before the connection pool is
+// closed.
+func (m *UserModel) Close() error {
+	return m.stmts.close()
+}
+
+// Functions used to return the statements which NewUserModel prepares.
+func (m *UserModel) authenticateStmt() string {
+	return fmt.Sprintf(`SELECT id, name, email, hashed_password, created, verified, last_login, last_login_ip, failed_logins,
+	role, active, COALESCE(locked_until > %s, FALSE) FROM users WHERE email = ? %s`, m.DB.Dialect.now(), m.DB.Dialect.forUpdate())
+}
+
+// MYSQL evaluates the assignments in an UPDATE statement from left to right, whereas PostgreSQL evaluates them all
+// against the row as it was, so the lockout is set before the counter is incremented, which works the same way
+// with both.
+func (m *UserModel) failedLoginStmt() string {
+	return fmt.Sprintf(`UPDATE users
+	SET locked_until = CASE WHEN ? > 0 AND failed_logins + 1 >= ? THEN %s ELSE locked_until END,
+	failed_logins = failed_logins + 1
+	WHERE id = ?`, m.DB.Dialect.fromNow("?", "SECOND"))
+}
+
+func (m *UserModel) loginStmt() string {
+	return fmt.Sprintf(`UPDATE users SET last_login = %s, last_login_ip = ?, failed_logins = 0, locked_until = NULL
+	WHERE id = ?`, m.DB.Dialect.now())
+}
+
+const existsStmt = `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
+
 type UserModelInterface interface {
-	Insert(name, email, password string) error
-	Authenticate(email, password string) (int, error)
-	Exists(id int) (bool, error)
+	Insert(ctx context.Context, name, email, password string) (int, error)
+	Authenticate(ctx context.Context, email, password, ip string) (*User, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error
+	Delete(ctx context.Context, id int, password string, sessionTokens []string) error
+	Update(ctx context.Context, id int, name, email string) error
+	Verify(ctx context.Context, id int) error
+	List(ctx context.Context, filters Filters) ([]*User, Metadata, error)
+	SetActive(ctx context.Context, id int, active bool) error
+	CheckPassword(ctx context.Context, id int, password string) error
+	SetNotifyExpiry(ctx context.Context, id int, notify bool) error
+	SetTimezone(ctx context.Context, id int, timezone string) error
+	Count(ctx context.Context) (int, error)
 }
 
-// Define a function that will insert a new user into the MYSQL database.
-func (m *UserModel) Insert(name, email, password string) error {
+// Define a function that will insert a new user into the MYSQL database, returning the ID of the new user.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
 	// Hash the password that the user wants to sign up with a cost of 12.
 	// The cost of 12 entails (2^12=4096) bcrypt iterations to generate the hash.
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Generate an SQL statement to insert a new user into our users table.
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	// Generate an SQL statement to insert a new user into our users table. The user isn't verified until they
+	// follow the link sent to their email address, since anyone can sign up with an address which isn't theirs.
+	stmt := fmt.Sprintf(`INSERT INTO users (name, email, hashed_password, created, verified)
+	VALUES (?, ?, ?, %s, FALSE)`, m.DB.Dialect.now())
 
 	// Execute the SQL statement to insert a new user into the users table.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	id, err := m.DB.Dialect.insert(ctx, m.DB, stmt, name, email, string(hashedPassword))
 
-	// If an error occurs executing the SQL statement, check if the error has the type *mysql.MySQLError.
-	// If it does, the error will be assigned to the mySQLError variable.
-	// Check whether or not the error relates to the users_uc_email key by checking if the SQL error code equals
-	// 1062 (ERR_DUP_ENTRY) and the contents of the error message string.
-	// If it does, return an ErrDuplicateEmail error (see internal/models/errors.go).
+	// If an error occurs executing the SQL statement, check whether it is a violation of the users_uc_email
+	// constraint, which the database's dialect recognises from its error code (e.g. 1062 (ERR_DUP_ENTRY) for MYSQL).
+	// If it is, return an ErrDuplicateEmail error (see internal/models/errors.go).
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if m.DB.Dialect.isDuplicate(err, "users_uc_email") {
+			return 0, ErrDuplicateEmail
 		}
 
 		// Return all other types of errors as is.
-		return err
+		return 0, err
 	}
 
-	// Return without errors once the user has been created successfully in the database.
-	return nil
+	// Return the ID of the user once they have been created successfully in the database.
+	return id, nil
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
-	// Retrieve the ID and hashed password associated with the given email.
-	var id int
-	var hashedPassword []byte
+// Function to check a user's email and password, recording the login attempt against their account. On success, the
+// user is returned as they were before this login, so LastLogin and LastLoginIP describe their previous login and
+// FailedLogins counts the failed attempts since then. The login time and client IP address ip are then stored as the
+// new last login, and the failed attempt counter is reset. A failed attempt increments the counter instead, and
+// locks the account once the counter reaches MaxFailedLogins. Attempts made while the account is locked return an
+// ErrAccountLocked error without the password being checked, and logins to deactivated accounts return an
+// ErrAccountDeactivated error.
+func (m *UserModel) Authenticate(ctx context.Context, email, password, ip string) (*User, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
 
-	// Generate an SQL statement for selecting user information for a matching email record.
-	stmt := `SELECT id, hashed_password FROM users WHERE email = ?`
+	// Run the prepared statements within the transaction.
+	q := m.stmts.on(tx)
+
+	u := &User{}
+
+	var lastLogin sql.NullTime
+	var locked bool
+
+	// Generate an SQL statement for selecting user information for a matching email record, locking the row so
+	// that concurrent attempts for the same user are counted correctly.
+	stmt := m.authenticateStmt()
 
 	// Execute the SQL statment.
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err = q.QueryRowContext(ctx, stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Verified,
+		&lastLogin, &u.LastLoginIP, &u.FailedLogins, &u.Role, &u.Active, &locked)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, ErrInvalidCredentials
+			return nil, ErrInvalidCredentials
 		} else {
-			return 0, err
+			return nil, err
 		}
 	}
 
+	if locked {
+		return nil, ErrAccountLocked
+	}
+
+	u.LastLogin = lastLogin.Time
+
 	// Check whether the hashed password and plaintext password match.
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	err = bcrypt.CompareHashAndPassword([]byte(u.HashedPassword), []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
+			// Count the failed attempt, locking the account if it was one too many.
+			_, err = q.ExecContext(ctx, m.failedLoginStmt(), m.MaxFailedLogins, m.MaxFailedLogins, int(m.LockoutDuration.Seconds()), u.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, ErrInvalidCredentials
 		} else {
-			return 0, err
+			return nil, err
 		}
 	}
 
-	// If the user's email and password are authenticated successfully, return the user's ID with no errors.
-	return id, nil
+	// Only tell the user that their account has been deactivated once they have proved who they are.
+	if !u.Active {
+		return nil, ErrAccountDeactivated
+	}
+
+	_, err = q.ExecContext(ctx, m.loginStmt(), ip, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	// If the user's email and password are authenticated successfully, return the user with no errors.
+	return u, nil
 }
 
 // Function to check if a user with a specific ID exists in our database.
-func (m *UserModel) Exists(id int) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
 	var exists bool
 
-	stmt := `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
-
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.stmts.on(m.DB).QueryRowContext(ctx, existsStmt, id).Scan(&exists)
 
 	return exists, err
 }
+
+// Define a function that will return the number of users, including deactivated ones.
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Define a function that will return the details of a user with a specific ID. The hashed password is deliberately
+// left out of the query, since it is never needed for display purposes.
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	return m.get(ctx, `WHERE id = ?`, id)
+}
+
+// Function to return the user with the given email address. An ErrNoRecord error is returned if there is no
+// such user.
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return m.get(ctx, `WHERE email = ?`, email)
+}
+
+// Function to return the user matching the given WHERE clause, which is shared by Get() and GetByEmail().
+func (m *UserModel) get(ctx context.Context, where string, arg any) (*User, error) {
+	u := &User{}
+
+	var lastLogin sql.NullTime
+
+	stmt := `SELECT id, name, email, created, verified, last_login, last_login_ip, failed_logins, role, active,
+	notify_expiry, timezone FROM users ` + where
+
+	err := m.DB.QueryRowContext(ctx, stmt, arg).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Verified, &lastLogin, &u.LastLoginIP,
+		&u.FailedLogins, &u.Role, &u.Active, &u.NotifyExpiry, &u.Timezone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	// The last_login column is NULL for users who have never logged in with a password.
+	u.LastLogin = lastLogin.Time
+
+	return u, nil
+}
+
+// Function to change the password of the user with a specific ID. The current password must match the stored
+// bcrypt hash, otherwise an ErrInvalidCredentials error is returned and the password is left unchanged.
+func (m *UserModel) PasswordUpdate(ctx context.Context, id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&currentHashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// Check whether the hashed password and the plaintext current password match.
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	// Hash the new password with the same cost that is used when a user signs up.
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
+	return err
+}
+
+// Function to permanently delete the user with a specific ID, along with all of their snippets (including archived
+// ones) and the session store records for the given session tokens. The password must match the stored bcrypt
+// hash, otherwise an ErrInvalidCredentials error is returned. All of the deletions happen inside a single transaction, so either
+// everything belonging to the user is removed or nothing is.
+func (m *UserModel) Delete(ctx context.Context, id int, password string, sessionTokens []string) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Roll back the transaction if we return before committing it. Calling Rollback() after Commit() is a no-op.
+	defer tx.Rollback()
+
+	// Lock the user's row for the duration of the transaction while we check their password.
+	var hashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = ? ` + tx.Dialect.forUpdate()
+
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM snippets WHERE user_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// The user's archived snippets are deleted too, so that they can't be restored without an owner.
+	_, err = tx.ExecContext(ctx, `DELETE FROM snippets_archive WHERE user_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	// Remove the user's sessions from the session store table used by scs, so that they are logged out on
+	// every device.
+	for _, token := range sessionTokens {
+		_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE token = ?`, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Function to change the name and email address of the user with a specific ID. If the email address changes, the
+// user is marked as unverified until they confirm the new address. An ErrDuplicateEmail error is returned if the
+// new email address already belongs to another user.
+func (m *UserModel) Update(ctx context.Context, id int, name, email string) error {
+	// MYSQL evaluates the assignments in an UPDATE statement from left to right, so the verified column must be
+	// set before the email column is changed. (PostgreSQL evaluates them all against the row as it was.)
+	stmt := `UPDATE users SET verified = (verified AND email = ?), name = ?, email = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, email, name, email, id)
+	if err != nil {
+		if m.DB.Dialect.isDuplicate(err, "users_uc_email") {
+			return ErrDuplicateEmail
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Function to mark the email address of the user with a specific ID as verified.
+func (m *UserModel) Verify(ctx context.Context, id int) error {
+	_, err := m.DB.ExecContext(ctx, `UPDATE users SET verified = TRUE WHERE id = ?`, id)
+	return err
+}
+
+// Function to return a page of users, in the order they signed up. If filters.Search is set, only users whose name
+// or email address contains it are returned.
+func (m *UserModel) List(ctx context.Context, filters Filters) ([]*User, Metadata, error) {
+	// COUNT(*) OVER() adds the total number of matching rows (ignoring LIMIT and OFFSET) to each row.
+	stmt := fmt.Sprintf(`SELECT COUNT(*) OVER(), id, name, email, created, verified, last_login, last_login_ip, failed_logins,
+	role, active FROM users WHERE (? = '' OR %s OR %s) ORDER BY id LIMIT ? OFFSET ?`, m.DB.Dialect.like("name"), m.DB.Dialect.like("email"))
+
+	pattern := filters.likePattern()
+
+	rows, err := m.DB.QueryContext(ctx, stmt, filters.Search, pattern, pattern, filters.PageSize, filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		u := &User{}
+
+		var lastLogin sql.NullTime
+
+		err = rows.Scan(&totalRecords, &u.ID, &u.Name, &u.Email, &u.Created, &u.Verified, &lastLogin, &u.LastLoginIP,
+			&u.FailedLogins, &u.Role, &u.Active)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		u.LastLogin = lastLogin.Time
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, calculateMetadata(totalRecords, filters), nil
+}
+
+// Function to deactivate or reactivate the user with a specific ID. Deactivated users can't log in, and are
+// logged out of their existing sessions.
+func (m *UserModel) SetActive(ctx context.Context, id int, active bool) error {
+	result, err := m.DB.ExecContext(ctx, `UPDATE users SET active = ? WHERE id = ?`, active, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MYSQL reports the number of rows actually changed (rather than matched), so check whether the user exists
+	// when none were.
+	if rowsAffected == 0 {
+		exists, err := m.Exists(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// Function to check the password of the user with a specific ID, e.g. when a logged in user re-enters it before a
+// sensitive action. An ErrInvalidCredentials error is returned if the password doesn't match.
+func (m *UserModel) CheckPassword(ctx context.Context, id int, password string) error {
+	var hashedPassword []byte
+
+	err := m.DB.QueryRowContext(ctx, `SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Function to set whether the user with a specific ID wants to be emailed before their snippets expire.
+func (m *UserModel) SetNotifyExpiry(ctx context.Context, id int, notify bool) error {
+	_, err := m.DB.ExecContext(ctx, `UPDATE users SET notify_expiry = ? WHERE id = ?`, notify, id)
+	return err
+}
+
+// Function to set the time zone which the user with a specific ID sees dates in. An empty timezone means that the
+// browser's time zone is used instead.
+func (m *UserModel) SetTimezone(ctx context.Context, id int, timezone string) error {
+	_, err := m.DB.ExecContext(ctx, `UPDATE users SET timezone = ? WHERE id = ?`, timezone, id)
+	return err
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The snippet lifecycle events which are delivered to webhooks.
+const (
+	EventSnippetCreated = "snippet.created"
+	EventSnippetUpdated = "snippet.updated"
+	EventSnippetDeleted = "snippet.deleted"
+	EventSnippetExpired = "snippet.expired"
+)
+
+// Define a Webhook type to hold the details of a URL which a user has registered to be sent their snippets'
+// lifecycle events. Each delivery is signed with the secret, so that the receiver can check it came from us.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Created time.Time
+}
+
+// Define a WebhookDelivery type to hold the outcome of an attempt to deliver an event to a webhook. StatusCode is
+// zero if no response was received, in which case Error says why.
+type WebhookDelivery struct {
+	ID         int
+	WebhookID  int
+	Event      string
+	StatusCode int
+	Error      string
+	Created    time.Time
+}
+
+// Define a WebhookModel type which wraps an sql.DB connection pool.
+type WebhookModel struct {
+	DB *DB
+}
+
+type WebhookModelInterface interface {
+	Insert(ctx context.Context, userID int, url string) (int, error)
+	Get(ctx context.Context, id int) (*Webhook, error)
+	ForUser(ctx context.Context, userID int) ([]*Webhook, error)
+	Delete(ctx context.Context, id, userID int) error
+	RecordDelivery(ctx context.Context, webhookID int, event string, statusCode int, deliveryErr string) error
+	Deliveries(ctx context.Context, webhookID, limit int) ([]*WebhookDelivery, error)
+}
+
+// Function to register a webhook URL for a user, returning the ID of the new webhook. A random secret for signing
+// deliveries is generated for it.
+func (m *WebhookModel) Insert(ctx context.Context, userID int, url string) (int, error) {
+	secret, _, err := generateToken()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO webhooks (user_id, url, secret, created) VALUES(?, ?, ?, %s)`, m.DB.Dialect.now())
+
+	return m.DB.Dialect.insert(ctx, m.DB, stmt, userID, url, secret)
+}
+
+// Function to return the webhook with a specific ID.
+func (m *WebhookModel) Get(ctx context.Context, id int) (*Webhook, error) {
+	wh := &Webhook{}
+
+	stmt := `SELECT id, user_id, url, secret, created FROM webhooks WHERE id = ?`
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&wh.ID, &wh.UserID, &wh.URL, &wh.Secret, &wh.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return wh, nil
+}
+
+// Function to return a user's webhooks, most recently created first.
+func (m *WebhookModel) ForUser(ctx context.Context, userID int) ([]*Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, created FROM webhooks WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		wh := &Webhook{}
+
+		err = rows.Scan(&wh.ID, &wh.UserID, &wh.URL, &wh.Secret, &wh.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, wh)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Function to delete one of a user's webhooks, along with its delivery log. An ErrNoRecord error is returned if the
+// webhook doesn't exist or belongs to another user.
+func (m *WebhookModel) Delete(ctx context.Context, id, userID int) error {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Function to record the outcome of an attempt to deliver an event to a webhook in its delivery log.
+func (m *WebhookModel) RecordDelivery(ctx context.Context, webhookID int, event string, statusCode int, deliveryErr string) error {
+	stmt := fmt.Sprintf(`INSERT INTO webhook_deliveries (webhook_id, event, status_code, error, created)
+	VALUES(?, ?, ?, ?, %s)`, m.DB.Dialect.now())
+
+	_, err := m.DB.ExecContext(ctx, stmt, webhookID, event, statusCode, deliveryErr)
+	return err
+}
+
+// Function to return up to limit of the most recent delivery attempts for a webhook, newest first.
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID, limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT id, webhook_id, event, status_code, error, created FROM webhook_deliveries
+	WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		d := &WebhookDelivery{}
+
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.StatusCode, &d.Error, &d.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,129 @@
+// Package pgxstore is a session store for scs which keeps sessions in the sessions table of a PostgreSQL database,
+// accessed through a database/sql connection pool opened with the pgx driver (see github.com/jackc/pgx/v5/stdlib).
+// It is the PostgreSQL counterpart of github.com/alexedwards/scs/mysqlstore, and satisfies scs.IterableStore.
+package pgxstore
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// PostgresStore represents the session store.
+type PostgresStore struct {
+	DB          *sql.DB
+	stopCleanup chan bool
+}
+
+// New returns a new PostgresStore instance, with a background cleanup goroutine that runs every 5 minutes to remove
+// expired session data.
+func New(db *sql.DB) *PostgresStore {
+	return NewWithCleanupInterval(db, 5*time.Minute)
+}
+
+// NewWithCleanupInterval returns a new PostgresStore instance. The cleanupInterval parameter controls how
+// frequently expired session data is removed by the background cleanup goroutine. Setting it to 0 prevents the
+// cleanup goroutine from running (i.e. expired sessions will not be removed).
+func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *PostgresStore {
+	p := &PostgresStore{DB: db}
+
+	if cleanupInterval > 0 {
+		p.stopCleanup = make(chan bool)
+		go p.startCleanup(cleanupInterval)
+	}
+
+	return p
+}
+
+// Find returns the data for a given session token. If the session token is not found or is expired, the returned
+// exists flag will be set to false.
+func (p *PostgresStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := p.DB.QueryRow(`SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry`, token).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds a session token and data to the store with the given expiry time. If the session token already
+// exists, then the data and expiry time are updated.
+func (p *PostgresStore) Commit(token string, b []byte, expiry time.Time) error {
+	stmt := `INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3)
+	ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry`
+
+	_, err := p.DB.Exec(stmt, token, b, expiry)
+	return err
+}
+
+// Delete removes a session token and corresponding data from the store.
+func (p *PostgresStore) Delete(token string) error {
+	_, err := p.DB.Exec(`DELETE FROM sessions WHERE token = $1`, token)
+	return err
+}
+
+// All returns a map containing the token and data for all active (i.e. not expired) sessions in the store.
+func (p *PostgresStore) All() (map[string][]byte, error) {
+	rows, err := p.DB.Query(`SELECT token, data FROM sessions WHERE current_timestamp < expiry`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+
+	for rows.Next() {
+		var (
+			token string
+			data  []byte
+		)
+
+		err = rows.Scan(&token, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[token] = data
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (p *PostgresStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := p.deleteExpired()
+			if err != nil {
+				log.Println(err)
+			}
+		case <-p.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup terminates the background cleanup goroutine, e.g. when the application shuts down.
+func (p *PostgresStore) StopCleanup() {
+	if p.stopCleanup != nil {
+		p.stopCleanup <- true
+	}
+}
+
+func (p *PostgresStore) deleteExpired() error {
+	_, err := p.DB.Exec(`DELETE FROM sessions WHERE expiry < current_timestamp`)
+	return err
+}
@@ -0,0 +1,124 @@
+// Package redisstore is a session store for scs which keeps each session in a Redis key, accessed through a redigo
+// connection pool. It is the counterpart of github.com/alexedwards/scs/redisstore, and satisfies
+// scs.IterableStore. Redis removes sessions itself when they expire, so there is no cleanup goroutine.
+package redisstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStore represents the session store.
+type RedisStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// New returns a new RedisStore instance, which stores sessions under keys with the prefix "scs:session:".
+func New(pool *redis.Pool) *RedisStore {
+	return NewWithPrefix(pool, "scs:session:")
+}
+
+// NewWithPrefix returns a new RedisStore instance, which stores sessions under keys with the given prefix. It lets
+// several applications share a Redis database without their sessions clashing.
+func NewWithPrefix(pool *redis.Pool, prefix string) *RedisStore {
+	return &RedisStore{pool: pool, prefix: prefix}
+}
+
+// Find returns the data for a given session token. If the session token is not found or is expired, the returned
+// exists flag will be set to false.
+func (r *RedisStore) Find(token string) ([]byte, bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	b, err := redis.Bytes(conn.Do("GET", r.prefix+token))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds a session token and data to the store with the given expiry time. If the session token already
+// exists, then the data and expiry time are updated.
+func (r *RedisStore) Commit(token string, b []byte, expiry time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	// Set the data and the expiry time in a single transaction, so that a session is never stored without one.
+	err := conn.Send("MULTI")
+	if err != nil {
+		return err
+	}
+
+	err = conn.Send("SET", r.prefix+token, b)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Send("PEXPIREAT", r.prefix+token, expiry.UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("EXEC")
+	return err
+}
+
+// Delete removes a session token and corresponding data from the store.
+func (r *RedisStore) Delete(token string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", r.prefix+token)
+	return err
+}
+
+// All returns a map containing the token and data for all active (i.e. not expired) sessions in the store. The
+// keys are found with SCAN rather than KEYS, so that Redis isn't blocked while a large database is searched.
+func (r *RedisStore) All() (map[string][]byte, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	sessions := make(map[string][]byte)
+
+	cursor := 0
+
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", r.prefix+"*", "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []string
+
+		_, err = redis.Scan(reply, &cursor, &keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			// The session may have expired or been deleted since SCAN returned its key.
+			b, err := redis.Bytes(conn.Do("GET", key))
+			if errors.Is(err, redis.ErrNil) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			sessions[key[len(r.prefix):]] = b
+		}
+
+		if cursor == 0 {
+			return sessions, nil
+		}
+	}
+}
+
+// StopCleanup does nothing, since Redis removes expired sessions itself. It lets a RedisStore be used in place of
+// the stores which have a cleanup goroutine.
+func (r *RedisStore) StopCleanup() {}
@@ -0,0 +1,130 @@
+// Package sqlite3store is a session store for scs which keeps sessions in the sessions table of an SQLite database,
+// accessed through a database/sql connection pool opened with the github.com/mattn/go-sqlite3 driver. It is the
+// SQLite counterpart of github.com/alexedwards/scs/mysqlstore, and satisfies scs.IterableStore. Expiry times are
+// stored as Julian day numbers, which can be compared with julianday('now') directly.
+package sqlite3store
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// SQLiteStore represents the session store.
+type SQLiteStore struct {
+	DB          *sql.DB
+	stopCleanup chan bool
+}
+
+// New returns a new SQLiteStore instance, with a background cleanup goroutine that runs every 5 minutes to remove
+// expired session data.
+func New(db *sql.DB) *SQLiteStore {
+	return NewWithCleanupInterval(db, 5*time.Minute)
+}
+
+// NewWithCleanupInterval returns a new SQLiteStore instance. The cleanupInterval parameter controls how
+// frequently expired session data is removed by the background cleanup goroutine. Setting it to 0 prevents the
+// cleanup goroutine from running (i.e. expired sessions will not be removed).
+func NewWithCleanupInterval(db *sql.DB, cleanupInterval time.Duration) *SQLiteStore {
+	s := &SQLiteStore{DB: db}
+
+	if cleanupInterval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Find returns the data for a given session token. If the session token is not found or is expired, the returned
+// exists flag will be set to false.
+func (s *SQLiteStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+
+	err := s.DB.QueryRow(`SELECT data FROM sessions WHERE token = ? AND julianday('now') < expiry`, token).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit adds a session token and data to the store with the given expiry time. If the session token already
+// exists, then the data and expiry time are updated.
+func (s *SQLiteStore) Commit(token string, b []byte, expiry time.Time) error {
+	stmt := `INSERT INTO sessions (token, data, expiry) VALUES (?, ?, julianday(?))
+	ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry`
+
+	_, err := s.DB.Exec(stmt, token, b, expiry.UTC().Format("2006-01-02T15:04:05.999"))
+	return err
+}
+
+// Delete removes a session token and corresponding data from the store.
+func (s *SQLiteStore) Delete(token string) error {
+	_, err := s.DB.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+// All returns a map containing the token and data for all active (i.e. not expired) sessions in the store.
+func (s *SQLiteStore) All() (map[string][]byte, error) {
+	rows, err := s.DB.Query(`SELECT token, data FROM sessions WHERE julianday('now') < expiry`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := make(map[string][]byte)
+
+	for rows.Next() {
+		var (
+			token string
+			data  []byte
+		)
+
+		err = rows.Scan(&token, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions[token] = data
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *SQLiteStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := s.deleteExpired()
+			if err != nil {
+				log.Println(err)
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup terminates the background cleanup goroutine, e.g. when the application shuts down.
+func (s *SQLiteStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+func (s *SQLiteStore) deleteExpired() error {
+	_, err := s.DB.Exec(`DELETE FROM sessions WHERE expiry < julianday('now')`)
+	return err
+}
@@ -1,8 +1,12 @@
 package validator
 
 import (
+	"cmp"
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -69,6 +73,36 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return false
 }
 
+// Between() returns true if a value is between min and max (inclusive).
+func Between[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
+// NoBlockedWords() returns true if none of the words in a value appear in a list of blocked words. The comparison
+// is case-insensitive and only matches whole words, so blocking "ass" does not reject "class".
+func NoBlockedWords(value string, blockedWords []string) bool {
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, word := range words {
+		if slices.Contains(blockedWords, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebURL() returns true if a value is an absolute http or https URL with a host.
+func WebURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 // Regex expression to validate the format of an email string.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
@@ -2,5 +2,5 @@ package ui
 
 import "embed"
 
-//go:embed "html" "static"
+//go:embed "api" "html" "locales" "static"
 var Files embed.FS
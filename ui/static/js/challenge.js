@@ -0,0 +1,73 @@
+// Solve the proof-of-work challenge on the page's form: find a nonce such that the SHA-256 hash of the token and
+// the nonce, separated by a colon, starts with the number of zero bits given by the token's difficulty. The search
+// starts as soon as the page loads, and if the form is submitted before it has finished, it is submitted when the
+// nonce has been found.
+(function () {
+	var token = document.querySelector("input[name='pow-token']");
+	if (!token || !window.crypto || !window.crypto.subtle || !window.TextEncoder) {
+		return;
+	}
+
+	var nonce = token.form.querySelector("input[name='pow-nonce']");
+	var status = token.form.querySelector(".challenge-status");
+	var difficulty = parseInt(token.getAttribute("data-difficulty"), 10);
+	var encoder = new TextEncoder();
+	var solved = false;
+	var submitting = false;
+
+	function leadingZeroBits(hash) {
+		var bytes = new Uint8Array(hash);
+		var n = 0;
+
+		for (var i = 0; i < bytes.length; i++) {
+			if (bytes[i] === 0) {
+				n += 8;
+				continue;
+			}
+
+			return n + Math.clz32(bytes[i]) - 24;
+		}
+
+		return n;
+	}
+
+	// Hash the candidates in batches, which is much faster than waiting for each hash in turn.
+	var batchSize = 256;
+
+	function search(start) {
+		var hashes = [];
+		for (var i = 0; i < batchSize; i++) {
+			hashes.push(window.crypto.subtle.digest("SHA-256", encoder.encode(token.value + ":" + (start + i))));
+		}
+
+		return Promise.all(hashes).then(function (results) {
+			for (var i = 0; i < results.length; i++) {
+				if (leadingZeroBits(results[i]) >= difficulty) {
+					return start + i;
+				}
+			}
+
+			return search(start + batchSize);
+		});
+	}
+
+	token.form.addEventListener("submit", function (event) {
+		if (!solved) {
+			event.preventDefault();
+			submitting = true;
+		}
+	});
+
+	search(0).then(function (found) {
+		nonce.value = String(found);
+		solved = true;
+
+		if (status) {
+			status.textContent = status.getAttribute("data-done");
+		}
+
+		if (submitting) {
+			token.form.submit();
+		}
+	});
+})();
@@ -0,0 +1,78 @@
+// Refresh the list of the latest snippets on the home page as new public snippets are created, using the
+// /ws/updates WebSocket. If the connection drops, reconnect with exponential backoff.
+(function () {
+	var list = document.getElementById("latest-snippets");
+	if (!list || !window.WebSocket) {
+		return;
+	}
+
+	var maxRows = parseInt(list.getAttribute("data-limit"), 10) || 10;
+	var delay = 1000;
+	var scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+
+	function addSnippet(snippet) {
+		// The page is showing the empty state, so reload it to get the table.
+		if (list.tagName !== "TABLE") {
+			window.location.reload();
+			return;
+		}
+
+		var row = document.createElement("tr");
+
+		var title = document.createDocumentFragment();
+
+		var link = document.createElement("a");
+		link.href = "/snippet/view/" + snippet.id;
+		link.textContent = snippet.title;
+		title.appendChild(link);
+
+		if (snippet.excerpt) {
+			var excerpt = document.createElement("p");
+			excerpt.className = "excerpt";
+			excerpt.textContent = snippet.excerpt;
+			title.appendChild(excerpt);
+		}
+
+		var values = [title, snippet.created, String(snippet.id)];
+		for (var i = 0; i < values.length; i++) {
+			var cell = document.createElement("td");
+			if (typeof values[i] === "string") {
+				cell.textContent = values[i];
+			} else {
+				cell.appendChild(values[i]);
+			}
+			row.appendChild(cell);
+		}
+
+		// Insert the new row below the header row, and drop the oldest row if the list is full.
+		var header = list.rows[0];
+		header.parentNode.insertBefore(row, header.nextSibling);
+
+		if (list.rows.length > maxRows + 1) {
+			var last = list.rows[list.rows.length - 1];
+			last.parentNode.removeChild(last);
+		}
+	}
+
+	function connect() {
+		var ws = new WebSocket(scheme + window.location.host + "/ws/updates");
+
+		ws.onopen = function () {
+			delay = 1000;
+		};
+
+		ws.onmessage = function (event) {
+			var msg = JSON.parse(event.data);
+			if (msg.type === "snippet") {
+				addSnippet(msg);
+			}
+		};
+
+		ws.onclose = function () {
+			setTimeout(connect, delay);
+			delay = Math.min(delay * 2, 60000);
+		};
+	}
+
+	connect();
+})();
@@ -1,8 +1,8 @@
-var navLinks = document.querySelectorAll("nav a");
-for (var i = 0; i < navLinks.length; i++) {
-	var link = navLinks[i]
-	if (link.getAttribute('href') == window.location.pathname) {
-		link.classList.add("live");
-		break;
+// Record the browser's time zone in a cookie, so that the server can show dates in it. IANA time zone names are
+// valid cookie values as they are.
+try {
+	var timezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+	if (timezone && document.cookie.indexOf("tz=" + timezone) == -1) {
+		document.cookie = "tz=" + timezone + "; path=/; max-age=31536000; samesite=lax";
 	}
-}
\ No newline at end of file
+} catch (e) {}